@@ -17,11 +17,15 @@ limitations under the License.
 package azureclients
 
 import (
+	"context"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/Azure/go-autorest/autorest"
 	"k8s.io/client-go/util/flowcontrol"
 
+	"sigs.k8s.io/cloud-provider-azure/pkg/metrics"
 	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
 )
 
@@ -97,3 +101,85 @@ func NewRateLimiter(config *RateLimitConfig) (flowcontrol.RateLimiter, flowcontr
 
 	return readLimiter, writeLimiter
 }
+
+// NewRateLimiterWithBudgetMetrics creates new read and write flowcontrol.RateLimiter from RateLimitConfig,
+// which report the remaining token budget of the "<clientName>_read" and "<clientName>_write" buckets of the
+// client of the subscription.
+func NewRateLimiterWithBudgetMetrics(config *RateLimitConfig, clientName, subscriptionID string) (flowcontrol.RateLimiter, flowcontrol.RateLimiter) {
+	readLimiter, writeLimiter := NewRateLimiter(config)
+	if !RateLimitEnabled(config) {
+		return readLimiter, writeLimiter
+	}
+
+	return newBudgetRateLimiter(readLimiter, clientName+"_read", subscriptionID, config.CloudProviderRateLimitQPS, config.CloudProviderRateLimitBucket),
+		newBudgetRateLimiter(writeLimiter, clientName+"_write", subscriptionID, config.CloudProviderRateLimitQPSWrite, config.CloudProviderRateLimitBucketWrite)
+}
+
+// budgetRateLimiter wraps a token bucket flowcontrol.RateLimiter and reports the remaining tokens of the
+// bucket after each request. The tokens are tracked by a shadow bucket refilled with the same QPS and burst,
+// since the underlying rate limiter does not expose them.
+type budgetRateLimiter struct {
+	flowcontrol.RateLimiter
+
+	bucket         string
+	subscriptionID string
+	qps            float64
+	burst          float64
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newBudgetRateLimiter(limiter flowcontrol.RateLimiter, bucket, subscriptionID string, qps float32, burst int) *budgetRateLimiter {
+	rl := &budgetRateLimiter{
+		RateLimiter:    limiter,
+		bucket:         bucket,
+		subscriptionID: subscriptionID,
+		qps:            float64(qps),
+		burst:          float64(burst),
+		tokens:         float64(burst),
+		last:           time.Now(),
+	}
+	metrics.SetRateLimiterRemainingTokens(bucket, subscriptionID, rl.tokens)
+	return rl
+}
+
+// TryAccept returns true if a token is taken immediately.
+func (rl *budgetRateLimiter) TryAccept() bool {
+	if !rl.RateLimiter.TryAccept() {
+		rl.observe(false)
+		return false
+	}
+	rl.observe(true)
+	return true
+}
+
+// Accept returns once a token becomes available.
+func (rl *budgetRateLimiter) Accept() {
+	rl.RateLimiter.Accept()
+	rl.observe(true)
+}
+
+// Wait returns nil if a token is taken before the Context is done.
+func (rl *budgetRateLimiter) Wait(ctx context.Context) error {
+	if err := rl.RateLimiter.Wait(ctx); err != nil {
+		return err
+	}
+	rl.observe(true)
+	return nil
+}
+
+func (rl *budgetRateLimiter) observe(accepted bool) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	now := time.Now()
+	rl.tokens = math.Min(rl.burst, rl.tokens+now.Sub(rl.last).Seconds()*rl.qps)
+	rl.last = now
+	if accepted {
+		// the shadow bucket may run slightly ahead of the real one, never report a negative budget
+		rl.tokens = math.Max(0, rl.tokens-1)
+	}
+	metrics.SetRateLimiterRemainingTokens(rl.bucket, rl.subscriptionID, rl.tokens)
+}
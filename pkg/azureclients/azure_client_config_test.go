@@ -21,6 +21,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/component-base/metrics/legacyregistry"
 )
 
 func TestWithRateLimiter(t *testing.T) {
@@ -64,3 +65,59 @@ func TestNewRateLimiter(t *testing.T) {
 	assert.Equal(t, flowcontrol.NewTokenBucketRateLimiter(3, 10), readLimiter)
 	assert.Equal(t, flowcontrol.NewTokenBucketRateLimiter(1, 3), writeLimiter)
 }
+
+func TestNewRateLimiterWithBudgetMetrics(t *testing.T) {
+	fakeRateLimiter := flowcontrol.NewFakeAlwaysRateLimiter()
+	readLimiter, writeLimiter := NewRateLimiterWithBudgetMetrics(nil, "test", "sub1")
+	assert.Equal(t, readLimiter, fakeRateLimiter)
+	assert.Equal(t, writeLimiter, fakeRateLimiter)
+
+	rateLimitConfig := &RateLimitConfig{
+		CloudProviderRateLimit:            true,
+		CloudProviderRateLimitQPS:         0.001,
+		CloudProviderRateLimitBucket:      5,
+		CloudProviderRateLimitQPSWrite:    0.001,
+		CloudProviderRateLimitBucketWrite: 2,
+	}
+	readLimiter, writeLimiter = NewRateLimiterWithBudgetMetrics(rateLimitConfig, "test", "sub1")
+	assert.InDelta(t, 5, getRateLimiterRemainingTokens(t, "test_read", "sub1"), 0.1)
+	assert.InDelta(t, 2, getRateLimiterRemainingTokens(t, "test_write", "sub1"), 0.1)
+
+	for i := 1; i <= 3; i++ {
+		assert.True(t, readLimiter.TryAccept())
+		assert.InDelta(t, 5-i, getRateLimiterRemainingTokens(t, "test_read", "sub1"), 0.1)
+	}
+
+	assert.True(t, writeLimiter.TryAccept())
+	assert.True(t, writeLimiter.TryAccept())
+	assert.False(t, writeLimiter.TryAccept())
+	assert.InDelta(t, 0, getRateLimiterRemainingTokens(t, "test_write", "sub1"), 0.1)
+	assert.InDelta(t, 2, getRateLimiterRemainingTokens(t, "test_read", "sub1"), 0.1)
+
+	// the budget of the client of another subscription is reported separately
+	otherReadLimiter, _ := NewRateLimiterWithBudgetMetrics(rateLimitConfig, "test", "sub2")
+	assert.True(t, otherReadLimiter.TryAccept())
+	assert.InDelta(t, 4, getRateLimiterRemainingTokens(t, "test_read", "sub2"), 0.1)
+	assert.InDelta(t, 2, getRateLimiterRemainingTokens(t, "test_read", "sub1"), 0.1)
+}
+
+func getRateLimiterRemainingTokens(t *testing.T, bucket, subscriptionID string) float64 {
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "cloudprovider_azure_rate_limiter_remaining_tokens" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["bucket"] == bucket && labels["subscription_id"] == subscriptionID {
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	t.Fatalf("rate limiter metric of bucket %s of subscription %s is not found", bucket, subscriptionID)
+	return 0
+}
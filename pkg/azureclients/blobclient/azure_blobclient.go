@@ -72,7 +72,7 @@ func New(config *azclients.ClientConfig) *Client {
 
 	klog.V(2).Infof("Azure BlobClient using API version: %s", apiVersion)
 	armClient := armclient.New(authorizer, *config, baseURI, apiVersion)
-	rateLimiterReader, rateLimiterWriter := azclients.NewRateLimiter(config.RateLimitConfig)
+	rateLimiterReader, rateLimiterWriter := azclients.NewRateLimiterWithBudgetMetrics(config.RateLimitConfig, "blob_container", config.SubscriptionID)
 
 	if azclients.RateLimitEnabled(config.RateLimitConfig) {
 		klog.V(2).Infof("Azure BlobClient (read ops) using rate limit config: QPS=%g, bucket=%d",
@@ -18,10 +18,12 @@ package cache
 
 import (
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
 
 	"sigs.k8s.io/cloud-provider-azure/pkg/util/deepcopy"
 )
@@ -55,6 +57,9 @@ type AzureCacheEntry struct {
 	Lock sync.Mutex
 	// time when entry was fetched and created
 	CreatedOn time.Time
+	// TTL of the entry, jittered from the TTL of the cache when the entry is fetched.
+	// The TTL of the cache is used if not set.
+	TTL time.Duration
 }
 
 // cacheKeyFunc defines the key function required in TTLStore.
@@ -66,6 +71,7 @@ func cacheKeyFunc(obj interface{}) (string, error) {
 type Resource interface {
 	Get(key string, crt AzureCacheReadType) (interface{}, error)
 	GetWithDeepCopy(key string, crt AzureCacheReadType) (interface{}, error)
+	GetWithMaxAge(key string, maxAge time.Duration) (interface{}, error)
 	Delete(key string) error
 	Set(key string, data interface{})
 	Update(key string, data interface{})
@@ -80,6 +86,15 @@ type TimedCache struct {
 	Store     cache.Store
 	MutexLock sync.RWMutex
 	TTL       time.Duration
+	// TTLJitter is the fraction of TTL by which the TTL of each entry is randomly shortened or extended,
+	// so that the entries fetched together do not expire at the same instant.
+	TTLJitter float64
+	// Clock timestamps and expires the entries. It defaults to the real clock, and could be replaced by
+	// a fake clock to test the expiry without sleeping.
+	Clock clock.PassiveClock
+	// TTLFunc returns the TTL of the entry of the key instead of TTL if set, e.g. to cache the entries of
+	// different keys for different durations. The jitter applies to the returned TTL too.
+	TTLFunc func(key string) time.Duration
 
 	resourceProvider Resource
 }
@@ -90,9 +105,18 @@ type ResourceProvider struct {
 
 // NewTimedCache creates a new azcache.Resource.
 func NewTimedCache(ttl time.Duration, getter GetFunc, disabled bool) (Resource, error) {
+	return NewTimedCacheWithTTLJitter(ttl, 0, getter, disabled)
+}
+
+// NewTimedCacheWithTTLJitter creates a new azcache.Resource whose entries expire after the TTL randomly
+// shortened or extended by up to the jitter fraction of it, e.g. ±10% for 0.1.
+func NewTimedCacheWithTTLJitter(ttl time.Duration, jitter float64, getter GetFunc, disabled bool) (Resource, error) {
 	if getter == nil {
 		return nil, fmt.Errorf("getter is not provided")
 	}
+	if jitter < 0 || jitter >= 1 {
+		return nil, fmt.Errorf("TTL jitter %v must be in [0, 1)", jitter)
+	}
 
 	provider := &ResourceProvider{
 		Getter: getter,
@@ -109,6 +133,8 @@ func NewTimedCache(ttl time.Duration, getter GetFunc, disabled bool) (Resource,
 		Store:            cache.NewStore(cacheKeyFunc),
 		MutexLock:        sync.RWMutex{},
 		TTL:              ttl,
+		TTLJitter:        jitter,
+		Clock:            clock.RealClock{},
 		resourceProvider: provider,
 	}
 	return timedCache, nil
@@ -170,6 +196,28 @@ func (c *ResourceProvider) GetWithDeepCopy(key string, _ AzureCacheReadType) (in
 	return c.Getter(key)
 }
 
+// GetWithMaxAge returns the requested item by key if it was fetched within maxAge, otherwise it refetches
+// the data using getter regardless of the TTL. It allows the callers to ask for a different freshness than
+// the TTL of the cache.
+func (t *TimedCache) GetWithMaxAge(key string, maxAge time.Duration) (interface{}, error) {
+	entry, err := t.getInternal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Lock.Lock()
+	defer entry.Lock.Unlock()
+
+	if entry.Data != nil && t.now().Sub(entry.CreatedOn) < maxAge {
+		return entry.Data, nil
+	}
+	return t.refreshEntry(entry)
+}
+
+func (c *ResourceProvider) GetWithMaxAge(key string, _ time.Duration) (interface{}, error) {
+	return c.Getter(key)
+}
+
 func (t *TimedCache) get(key string, crt AzureCacheReadType) (interface{}, error) {
 	entry, err := t.getInternal(key)
 	if err != nil {
@@ -186,14 +234,19 @@ func (t *TimedCache) get(key string, crt AzureCacheReadType) (interface{}, error
 			return entry.Data, nil
 		}
 		// if cached data is not expired, return cached data
-		if crt == CacheReadTypeDefault && time.Since(entry.CreatedOn) < t.TTL {
+		if crt == CacheReadTypeDefault && !t.isExpired(entry) {
 			return entry.Data, nil
 		}
 	}
 	// Data is not cached yet, cache data is expired or requested force refresh
 	// cache it by getter. entry is locked before getting to ensure concurrent
 	// gets don't result in multiple ARM calls.
-	data, err := t.resourceProvider.Get(key, CacheReadTypeDefault /* not matter */)
+	return t.refreshEntry(entry)
+}
+
+// refreshEntry fetches the data of the entry by getter. The entry must be locked by the caller.
+func (t *TimedCache) refreshEntry(entry *AzureCacheEntry) (interface{}, error) {
+	data, err := t.resourceProvider.Get(entry.Key, CacheReadTypeDefault /* not matter */)
 	if err != nil {
 		return nil, err
 	}
@@ -201,11 +254,46 @@ func (t *TimedCache) get(key string, crt AzureCacheReadType) (interface{}, error
 	// set the data in cache and also set the last update time
 	// to now as the data was recently fetched
 	entry.Data = data
-	entry.CreatedOn = time.Now().UTC()
+	entry.CreatedOn = t.now().UTC()
+	entry.TTL = t.newEntryTTL(entry.Key)
 
 	return entry.Data, nil
 }
 
+// newEntryTTL returns the TTL of a newly fetched entry of the key, which is the TTL of the key with the jitter applied.
+func (t *TimedCache) newEntryTTL(key string) time.Duration {
+	ttl := t.ttlOf(key)
+	if t.TTLJitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*t.TTLJitter*float64(ttl)) // #nosec G404
+}
+
+// ttlOf returns the TTL of the key from TTLFunc if set, or the TTL of the cache.
+func (t *TimedCache) ttlOf(key string) time.Duration {
+	if t.TTLFunc != nil {
+		return t.TTLFunc(key)
+	}
+	return t.TTL
+}
+
+// isExpired returns true if the entry is older than its TTL. The entry must be locked by the caller.
+func (t *TimedCache) isExpired(entry *AzureCacheEntry) bool {
+	ttl := entry.TTL
+	if ttl == 0 {
+		ttl = t.ttlOf(entry.Key)
+	}
+	return t.now().Sub(entry.CreatedOn) >= ttl
+}
+
+// now returns the time of the Clock, or the wall-clock time if it is not set.
+func (t *TimedCache) now() time.Time {
+	if t.Clock == nil {
+		return time.Now()
+	}
+	return t.Clock.Now()
+}
+
 // Delete removes an item from the cache.
 func (t *TimedCache) Delete(key string) error {
 	return t.Store.Delete(&AzureCacheEntry{
@@ -223,7 +311,8 @@ func (t *TimedCache) Set(key string, data interface{}) {
 	_ = t.Store.Add(&AzureCacheEntry{
 		Key:       key,
 		Data:      data,
-		CreatedOn: time.Now().UTC(),
+		CreatedOn: t.now().UTC(),
+		TTL:       t.newEntryTTL(key),
 	})
 }
 
@@ -235,12 +324,14 @@ func (t *TimedCache) Update(key string, data interface{}) {
 		entry.Lock.Lock()
 		defer entry.Lock.Unlock()
 		entry.Data = data
-		entry.CreatedOn = time.Now().UTC()
+		entry.CreatedOn = t.now().UTC()
+		entry.TTL = t.newEntryTTL(key)
 	} else {
 		_ = t.Store.Update(&AzureCacheEntry{
 			Key:       key,
 			Data:      data,
-			CreatedOn: time.Now().UTC(),
+			CreatedOn: t.now().UTC(),
+			TTL:       t.newEntryTTL(key),
 		})
 	}
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"golang.org/x/sync/semaphore"
+	testingclock "k8s.io/utils/clock/testing"
 )
 
 const (
@@ -210,6 +211,71 @@ func TestCacheExpired(t *testing.T) {
 	assert.Equal(t, val, v, "cache should get correct data even after expired")
 }
 
+func TestCacheExpiredWithFakeClock(t *testing.T) {
+	val := &fakeDataObj{}
+	data := map[string]*fakeDataObj{
+		testKey: val,
+	}
+	dataSource, cache := newFakeCache(t)
+	dataSource.set(data)
+	fakeClock := testingclock.NewFakePassiveClock(time.Now())
+	cache.Clock = fakeClock
+
+	_, err := cache.Get(testKey, CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dataSource.called)
+
+	// not expired right before the TTL
+	fakeClock.SetTime(fakeClock.Now().Add(fakeCacheTTL - time.Nanosecond))
+	_, err = cache.Get(testKey, CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dataSource.called)
+
+	// expired at the TTL
+	fakeClock.SetTime(fakeClock.Now().Add(time.Nanosecond))
+	_, err = cache.Get(testKey, CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dataSource.called)
+
+	// the max age is measured by the clock as well
+	fakeClock.SetTime(fakeClock.Now().Add(time.Second))
+	_, err = cache.GetWithMaxAge(testKey, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, dataSource.called)
+}
+
+func TestCacheTTLFunc(t *testing.T) {
+	shortKey, longKey := "short", "long"
+	data := map[string]*fakeDataObj{
+		shortKey: {},
+		longKey:  {},
+	}
+	dataSource, cache := newFakeCache(t)
+	dataSource.set(data)
+	fakeClock := testingclock.NewFakePassiveClock(time.Now())
+	cache.Clock = fakeClock
+	cache.TTLFunc = func(key string) time.Duration {
+		if key == shortKey {
+			return fakeCacheTTL / 2
+		}
+		return fakeCacheTTL
+	}
+
+	for _, key := range []string{shortKey, longKey} {
+		_, err := cache.Get(key, CacheReadTypeDefault)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, dataSource.called)
+
+	// only the entry with the shorter TTL is expired
+	fakeClock.SetTime(fakeClock.Now().Add(fakeCacheTTL / 2))
+	for _, key := range []string{shortKey, longKey} {
+		_, err := cache.Get(key, CacheReadTypeDefault)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, dataSource.called)
+}
+
 func TestCacheAllowUnsafeRead(t *testing.T) {
 	val := &fakeDataObj{}
 	data := map[string]*fakeDataObj{
@@ -311,3 +377,73 @@ func TestCacheForceRefresh(t *testing.T) {
 	assert.Equal(t, 2, dataSource.called)
 	assert.Equal(t, val, v, "should refetch unexpired data as forced refresh")
 }
+
+func TestCacheGetWithMaxAge(t *testing.T) {
+	val := &fakeDataObj{}
+	data := map[string]*fakeDataObj{
+		testKey: val,
+	}
+	dataSource, cache := newFakeCache(t)
+	dataSource.set(data)
+
+	v, err := cache.GetWithMaxAge(testKey, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dataSource.called)
+	assert.Equal(t, val, v, "cache should get correct data")
+
+	// the entry older than the TTL is served if it is younger than the max age
+	entry, err := cache.getInternal(testKey)
+	assert.NoError(t, err)
+	entry.CreatedOn = time.Now().Add(-2 * fakeCacheTTL)
+	v, err = cache.GetWithMaxAge(testKey, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dataSource.called)
+	assert.Equal(t, val, v, "cache should return the data younger than the max age")
+
+	// the entry younger than the TTL is refetched if it is older than the max age
+	entry.CreatedOn = time.Now().Add(-fakeCacheTTL / 2)
+	v, err = cache.GetWithMaxAge(testKey, fakeCacheTTL/4)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dataSource.called)
+	assert.Equal(t, val, v, "cache should refetch the data older than the max age")
+}
+
+func TestCacheTTLJitter(t *testing.T) {
+	dataSource := &fakeDataSource{
+		sem: *semaphore.NewWeighted(1),
+	}
+	dataSource.set(map[string]*fakeDataObj{"key1": {}, "key2": {}})
+	resource, err := NewTimedCacheWithTTLJitter(time.Hour, 0.1, dataSource.get, false)
+	assert.NoError(t, err)
+	cache := resource.(*TimedCache)
+
+	_, err = cache.Get("key1", CacheReadTypeDefault)
+	assert.NoError(t, err)
+	_, err = cache.Get("key2", CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	entry1, err := cache.getInternal("key1")
+	assert.NoError(t, err)
+	entry2, err := cache.getInternal("key2")
+	assert.NoError(t, err)
+	for _, entry := range []*AzureCacheEntry{entry1, entry2} {
+		assert.GreaterOrEqual(t, entry.TTL, 54*time.Minute)
+		assert.LessOrEqual(t, entry.TTL, 66*time.Minute)
+	}
+	assert.NotEqual(t, entry1.CreatedOn.Add(entry1.TTL), entry2.CreatedOn.Add(entry2.TTL), "the entries created together should expire at different times")
+
+	// the entry is only expired after its own TTL
+	entry1.CreatedOn = time.Now().Add(-entry1.TTL + time.Minute)
+	_, err = cache.Get("key1", CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dataSource.called)
+	entry1.CreatedOn = time.Now().Add(-entry1.TTL)
+	_, err = cache.Get("key1", CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, dataSource.called)
+
+	_, err = NewTimedCacheWithTTLJitter(time.Hour, 1, dataSource.get, false)
+	assert.Error(t, err, "the jitter of the whole TTL should be rejected")
+	_, err = NewTimedCacheWithTTLJitter(time.Hour, -0.1, dataSource.get, false)
+	assert.Error(t, err, "the negative jitter should be rejected")
+}
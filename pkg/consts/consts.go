@@ -108,6 +108,8 @@ const (
 
 	// RetryAfterHeaderKey is the retry-after header key in ARM responses.
 	RetryAfterHeaderKey = "Retry-After"
+	// CorrelationRequestIDHeaderKey is the header key of the correlation ID in ARM responses, which is used by the support tickets.
+	CorrelationRequestIDHeaderKey = "x-ms-correlation-request-id"
 
 	// StrRawVersion is the raw version string
 	StrRawVersion string = "raw"
@@ -127,10 +129,16 @@ const (
 	TagKeyValueDelimiter = "="
 	// VMSetNamesSharingPrimarySLBDelimiter is the delimiter of vmSet names sharing the primary SLB
 	VMSetNamesSharingPrimarySLBDelimiter = ","
+	// ProvisioningStateCreating ...
+	ProvisioningStateCreating = "Creating"
 	// ProvisioningStateDeleting ...
 	ProvisioningStateDeleting = "Deleting"
 	// ProvisioningStateSucceeded ...
 	ProvisioningStateSucceeded = "Succeeded"
+	// ProvisioningStateFailed ...
+	ProvisioningStateFailed = "Failed"
+	// ProvisioningStateCanceled ...
+	ProvisioningStateCanceled = "Canceled"
 )
 
 // cache
@@ -139,6 +147,9 @@ const (
 	VMSSNameSeparator = "_"
 	// VMSSKey is the key when querying vmss cache
 	VMSSKey = "k8svmssKey"
+	// VmssFlexCacheKeyPrefix namespaces the keys of the vmss flex cache partitions, so that they would never
+	// collide with the keys of the other caches. The colon is not allowed in the resource group names.
+	VmssFlexCacheKeyPrefix = "vmssflex:"
 	// VMASKey is the key when querying vmss cache
 	VMASKey = "k8svmasKey"
 	// NonVmssUniformNodesKey is the key when querying nonVmssUniformNodes cache
@@ -146,9 +157,6 @@ const (
 	// AvailabilitySetNodesKey is the availability set nodes key
 	AvailabilitySetNodesKey = "k8sAvailabilitySetNodesKey"
 
-	// VmssFlexKey is the key when querying vmssFlexVM cache
-	VmssFlexKey = "k8sVmssFlexKey"
-
 	// GetNodeVmssFlexIDLockKey is the key for getting the lock for getNodeVmssFlexID function
 	GetNodeVmssFlexIDLockKey = "k8sGetNodeVmssFlexIDLockKey"
 	// VMManagementTypeLockKey is the key for getting the lock for getVMManagementType function
@@ -165,8 +173,37 @@ const (
 
 	// VmssFlexCacheTTLDefaultInSeconds is the TTL of the vmss flex cache
 	VmssFlexCacheTTLDefaultInSeconds = 600
+	// VmssFlexCacheTTLMaxInSeconds is the max TTL of the vmss flex cache
+	VmssFlexCacheTTLMaxInSeconds = 86400
+	// VmssFlexCacheTTLJitterDefault is the fraction of the TTL by which the expirations of the vmss flex cache entries are staggered
+	VmssFlexCacheTTLJitterDefault = 0.1
 	// VmssFlexVMCacheTTLDefaultInSeconds is the TTL of the vmss flex vm cache
 	VmssFlexVMCacheTTLDefaultInSeconds = 600
+	// VmssFlexForceRefreshDebounceDefaultInMilliseconds is the window to reuse the result of a just-completed
+	// force refresh of the vmss flex caches
+	VmssFlexForceRefreshDebounceDefaultInMilliseconds = 1000
+	// VmssFlexNewVMRetryWindowDefaultInSeconds is the default age of the new nodes retried when not found in the vmss flex caches
+	VmssFlexNewVMRetryWindowDefaultInSeconds = 300
+	// VmssFlexNewVMRetryMinIntervalInMilliseconds is the min interval between the retries of the new nodes not found in the vmss flex caches
+	VmssFlexNewVMRetryMinIntervalInMilliseconds = 500
+	// VmssFlexVMGetThrottledRetryAttemptsDefault is the default number of the retries of a throttled vmss flex vm GET
+	VmssFlexVMGetThrottledRetryAttemptsDefault = 3
+	// VmssFlexVMGetThrottledRetryMaxDelayDefaultInSeconds is the default max delay before each retry of a throttled vmss flex vm GET
+	VmssFlexVMGetThrottledRetryMaxDelayDefaultInSeconds = 10
+	// VmssFlexVMGetThrottledRetryMinIntervalInMilliseconds is the initial backoff of the retries of a throttled vmss flex
+	// vm GET whose response has no Retry-After
+	VmssFlexVMGetThrottledRetryMinIntervalInMilliseconds = 500
+	// VmssFlexARMRetryInitialDelayInMilliseconds is the initial backoff of the retries of the ARM calls of the vmss flex caches
+	VmssFlexARMRetryInitialDelayInMilliseconds = 500
+	// VmssFlexARMRetryMaxDelayInSeconds is the max backoff of the retries of the ARM calls of the vmss flex caches
+	VmssFlexARMRetryMaxDelayInSeconds = 10
+	// VmssFlexCacheCircuitBreakerCooldownDefaultInSeconds is the time the circuit breaker of the vmss flex cache
+	// refreshes stays open before a trial refresh is allowed
+	VmssFlexCacheCircuitBreakerCooldownDefaultInSeconds = 30
+	// VmssFlexVMInstanceViewCacheTTLDefaultInSeconds is the TTL of the vmss flex vm instance view cache
+	VmssFlexVMInstanceViewCacheTTLDefaultInSeconds = 30
+	// VmssFlexCacheMaxStalenessDefaultInSeconds is the max age of the stale vmss flex entries served on refresh errors
+	VmssFlexCacheMaxStalenessDefaultInSeconds = 3600
 
 	// ZoneFetchingInterval defines the interval of performing zoneClient.GetZones
 	ZoneFetchingInterval = 30 * time.Minute
@@ -279,6 +316,10 @@ const (
 	// ServiceAnnotationIPTagsForPublicIP specifies the iptags used when dynamically creating a public ip
 	ServiceAnnotationIPTagsForPublicIP = "service.beta.kubernetes.io/azure-pip-ip-tags"
 
+	// ServiceAnnotationPIPDdosProtectionPlanID specifies the resource ID of the DDoS protection plan
+	// used when dynamically creating a standard public ip. It overrides publicIPDdosProtectionPlanID in the cloud config.
+	ServiceAnnotationPIPDdosProtectionPlanID = "service.beta.kubernetes.io/azure-pip-ddos-protection-plan-id"
+
 	// ServiceAnnotationAllowedServiceTag is the annotation used on the service
 	// to specify a list of allowed service tags separated by comma
 	// Refer https://docs.microsoft.com/en-us/azure/virtual-network/security-overview#service-tags for all supported service tags.
@@ -322,6 +363,18 @@ const (
 	// If omitted, the default value is false
 	ServiceAnnotationDisableLoadBalancerFloatingIP = "service.beta.kubernetes.io/azure-disable-load-balancer-floating-ip"
 
+	// ServiceAnnotationLoadBalancerOutboundOnly is the annotation used on the service to only provide the outbound
+	// connectivity of the backend nodes by an outbound rule, without any load balancing rule, health probe or inbound
+	// security rule. It is only supported by the external standard load balancer, and the load balancing rules of the
+	// other services need the outbound SNAT to be disabled by disableOutboundSNAT. If omitted, the default value is false.
+	ServiceAnnotationLoadBalancerOutboundOnly = "service.beta.kubernetes.io/azure-load-balancer-outbound-only"
+
+	// ServiceAnnotationLoadBalancerFreeze is the annotation used on the service to freeze the reconciliation of its
+	// load balancer, e.g. during a maintenance window. EnsureLoadBalancer and UpdateLoadBalancer leave the Azure
+	// resources untouched while it is "true", and the deletion of the service is not affected. If omitted, the default
+	// value is false.
+	ServiceAnnotationLoadBalancerFreeze = "service.beta.kubernetes.io/azure-lb-freeze"
+
 	// ServiceAnnotationAdditionalPublicIPs sets the additional Public IPs (split by comma) besides the service's Public IP configured on LoadBalancer.
 	// These additional Public IPs would be consumed by kube-proxy to configure the iptables rules on each node. Note they would not be configured
 	// automatically on Azure LoadBalancer. Instead, they need to be configured manually (e.g. on Azure cross-region LoadBalancer by another operator).
@@ -334,6 +387,10 @@ const (
 	// ServiceAnnotationDisableTCPReset is the annotation used on the service to disable TCP reset on the load balancer.
 	ServiceAnnotationDisableTCPReset = "service.beta.kubernetes.io/azure-load-balancer-disable-tcp-reset"
 
+	// ServiceAnnotationEnableTCPReset is the annotation used on the service to set EnableTcpReset on the TCP load balancing rules.
+	// It takes precedence over ServiceAnnotationDisableTCPReset. TCP reset is enabled by default for standard load balancers.
+	ServiceAnnotationEnableTCPReset = "service.beta.kubernetes.io/azure-load-balancer-enable-tcp-reset"
+
 	// ServiceTagKey is the service key applied for public IP tags.
 	ServiceTagKey       = "k8s-azure-service"
 	LegacyServiceTagKey = "service"
@@ -371,6 +428,8 @@ const (
 	LoadBalancerRuleNameMaxLength = 80
 	// IPFamilySuffixLength is the length of suffix length of IP family ("-IPv4", "-IPv6")
 	IPFamilySuffixLength = 5
+	// LoadBalancerClusterNameMaxLength is the max length of the cluster name segment in the load balancer resource names
+	LoadBalancerClusterNameMaxLength = 20
 
 	// LoadBalancerBackendPoolConfigurationTypeNodeIPConfiguration is the lb backend pool config type node IP configuration
 	LoadBalancerBackendPoolConfigurationTypeNodeIPConfiguration = "nodeIPConfiguration"
@@ -401,6 +460,8 @@ const (
 	CannotUpdateVMBeingDeletedMessageSuffix = "since it is marked for deletion"
 	// OperationPreemptedErrorCode is the error code returned for vm operation preempted errors
 	OperationPreemptedErrorCode = "OperationPreempted"
+	// PublicIPCountLimitReachedErrorCode is the error code that the public IP address quota of the subscription is exceeded.
+	PublicIPCountLimitReachedErrorCode = "PublicIPCountLimitReached"
 )
 
 // node ipam controller
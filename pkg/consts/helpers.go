@@ -40,6 +40,16 @@ func IsK8sServiceDisableLoadBalancerFloatingIP(service *v1.Service) bool {
 	return expectAttributeInSvcAnnotationBeEqualTo(service.Annotations, ServiceAnnotationDisableLoadBalancerFloatingIP, TrueAnnotationValue)
 }
 
+// IsK8sServiceOutboundOnly return if the service only provides the outbound connectivity by an outbound rule
+func IsK8sServiceOutboundOnly(service *v1.Service) bool {
+	return expectAttributeInSvcAnnotationBeEqualTo(service.Annotations, ServiceAnnotationLoadBalancerOutboundOnly, TrueAnnotationValue)
+}
+
+// IsK8sServiceLoadBalancerFrozen return if the reconciliation of the load balancer of the service is frozen
+func IsK8sServiceLoadBalancerFrozen(service *v1.Service) bool {
+	return expectAttributeInSvcAnnotationBeEqualTo(service.Annotations, ServiceAnnotationLoadBalancerFreeze, TrueAnnotationValue)
+}
+
 // GetHealthProbeConfigOfPortFromK8sSvcAnnotation get health probe configuration for port
 func GetHealthProbeConfigOfPortFromK8sSvcAnnotation(annotations map[string]string, port int32, key HealthProbeParams, validators ...BusinessValidator) (*string, error) {
 	return GetAttributeValueInSvcAnnotation(annotations, BuildHealthProbeAnnotationKeyForPort(port, key), validators...)
@@ -70,6 +80,15 @@ func IsTCPResetDisabled(annotations map[string]string) bool {
 	return expectAttributeInSvcAnnotationBeEqualTo(annotations, ServiceAnnotationDisableTCPReset, TrueAnnotationValue)
 }
 
+// IsTCPResetEnabled return true if TCP reset should be enabled on the load balancing rules.
+// ServiceAnnotationEnableTCPReset takes precedence over ServiceAnnotationDisableTCPReset.
+func IsTCPResetEnabled(annotations map[string]string) bool {
+	if _, found := annotations[ServiceAnnotationEnableTCPReset]; found {
+		return expectAttributeInSvcAnnotationBeEqualTo(annotations, ServiceAnnotationEnableTCPReset, TrueAnnotationValue)
+	}
+	return !IsTCPResetDisabled(annotations)
+}
+
 // Getint32ValueFromK8sSvcAnnotation get health probe configuration for port
 func Getint32ValueFromK8sSvcAnnotation(annotations map[string]string, key string, validators ...Int32BusinessValidator) (*int32, error) {
 	val, err := GetAttributeValueInSvcAnnotation(annotations, key)
@@ -145,6 +145,49 @@ func TestIsK8sServiceUsingInternalLoadBalancer(t *testing.T) {
 	}
 }
 
+func TestIsTCPResetEnabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name: "tcp reset is enabled by default",
+			want: true,
+		},
+		{
+			name:        "tcp reset is enabled by the annotation",
+			annotations: map[string]string{ServiceAnnotationEnableTCPReset: "True"},
+			want:        true,
+		},
+		{
+			name:        "tcp reset is disabled by the annotation",
+			annotations: map[string]string{ServiceAnnotationEnableTCPReset: "false"},
+			want:        false,
+		},
+		{
+			name:        "tcp reset is disabled by the deprecated annotation",
+			annotations: map[string]string{ServiceAnnotationDisableTCPReset: TrueAnnotationValue},
+			want:        false,
+		},
+		{
+			name: "enable annotation takes precedence over the disable annotation",
+			annotations: map[string]string{
+				ServiceAnnotationEnableTCPReset:  TrueAnnotationValue,
+				ServiceAnnotationDisableTCPReset: TrueAnnotationValue,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTCPResetEnabled(tt.annotations); got != tt.want {
+				t.Errorf("IsTCPResetEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetHealthProbeConfigOfPortFromK8sSvcAnnotation(t *testing.T) {
 	type args struct {
 		annotations map[string]string
@@ -38,6 +38,9 @@ var (
 
 	apiMetrics       = registerAPIMetrics(metricLabels...)
 	operationMetrics = registerOperationMetrics(metricLabels...)
+	cacheMetrics     = registerCacheMetrics("cache")
+
+	rateLimiterRemainingTokens = registerRateLimiterMetrics("bucket", "subscription_id")
 )
 
 // apiCallMetrics is the metrics measuring the performance of a single API call
@@ -56,6 +59,17 @@ type operationCallMetrics struct {
 	operationFailureCount *metrics.CounterVec
 }
 
+// resourceCacheMetrics is the metrics measuring the behavior of the caches of Azure resources.
+type resourceCacheMetrics struct {
+	staleServedCount           *metrics.CounterVec
+	duplicateComputerNameCount *metrics.CounterVec
+	getterPanicCount           *metrics.CounterVec
+	vmSkippedCount             *metrics.CounterVec
+	circuitBreakerState        *metrics.GaugeVec
+	refreshStaleness           *metrics.GaugeVec
+	discrepancyCount           *metrics.CounterVec
+}
+
 // MetricContext indicates the context for Azure client metrics.
 type MetricContext struct {
 	start      time.Time
@@ -120,6 +134,51 @@ func (mc *MetricContext) CountFailedOperation() {
 	operationMetrics.operationFailureCount.WithLabelValues(mc.attributes...).Inc()
 }
 
+// CountStaleCacheServed increases the number of stale cache entries served because the refresh failed.
+func CountStaleCacheServed(cacheName string) {
+	cacheMetrics.staleServedCount.WithLabelValues(cacheName).Inc()
+}
+
+// CountDuplicateComputerName increases the number of cached VMs whose computer name is claimed by another VM.
+func CountDuplicateComputerName(cacheName string) {
+	cacheMetrics.duplicateComputerNameCount.WithLabelValues(cacheName).Inc()
+}
+
+// CountCacheGetterPanic increases the number of panics recovered from the cache getters.
+func CountCacheGetterPanic(cacheName string) {
+	cacheMetrics.getterPanicCount.WithLabelValues(cacheName).Inc()
+}
+
+// CountVMSkippedFromCache increases the number of VMs skipped from the cache for the reason, e.g. the VMs
+// without computer names which are still booting.
+func CountVMSkippedFromCache(cacheName, reason string) {
+	cacheMetrics.vmSkippedCount.WithLabelValues(cacheName, reason).Inc()
+}
+
+// SetCacheCircuitBreakerState records the state of the circuit breaker of the cache refreshes,
+// which is 0 if closed, 1 if open and 2 if half-open.
+func SetCacheCircuitBreakerState(cacheName string, state int) {
+	cacheMetrics.circuitBreakerState.WithLabelValues(cacheName).Set(float64(state))
+}
+
+// CountCacheDiscrepancy increases the number of the discrepancies of the kind found between the cached and the
+// live data by verifying the cached lookups.
+func CountCacheDiscrepancy(cacheName, kind string) {
+	cacheMetrics.discrepancyCount.WithLabelValues(cacheName, kind).Inc()
+}
+
+// SetCacheRefreshStaleness records the seconds since the last successful refresh of the cache partition of the
+// resource group, which is 0 once a refresh succeeds and -1 if no refresh has succeeded yet.
+func SetCacheRefreshStaleness(cacheName, resourceGroup string, seconds float64) {
+	cacheMetrics.refreshStaleness.WithLabelValues(cacheName, resourceGroup).Set(seconds)
+}
+
+// SetRateLimiterRemainingTokens records the remaining token budget of the rate limiter bucket of the client
+// of the subscription, so that the clients of different subscriptions would not overwrite each other's budget.
+func SetRateLimiterRemainingTokens(bucket, subscriptionID string, tokens float64) {
+	rateLimiterRemainingTokens.WithLabelValues(bucket, subscriptionID).Set(tokens)
+}
+
 // registerAPIMetrics registers the API metrics.
 func registerAPIMetrics(attributes ...string) *apiCallMetrics {
 	metrics := &apiCallMetrics{
@@ -199,3 +258,99 @@ func registerOperationMetrics(attributes ...string) *operationCallMetrics {
 
 	return metrics
 }
+
+// registerCacheMetrics registers the resource cache metrics.
+func registerCacheMetrics(attributes ...string) *resourceCacheMetrics {
+	metrics := &resourceCacheMetrics{
+		staleServedCount: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Namespace:      consts.AzureMetricsNamespace,
+				Name:           "cache_stale_served_count",
+				Help:           "Number of stale cache entries served because the cache refresh failed",
+				StabilityLevel: metrics.ALPHA,
+			},
+			attributes,
+		),
+		duplicateComputerNameCount: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Namespace:      consts.AzureMetricsNamespace,
+				Name:           "cache_duplicate_computer_name_count",
+				Help:           "Number of cached VMs whose computer name is already claimed by another VM",
+				StabilityLevel: metrics.ALPHA,
+			},
+			attributes,
+		),
+		getterPanicCount: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Namespace:      consts.AzureMetricsNamespace,
+				Name:           "cache_getter_panic_count",
+				Help:           "Number of panics recovered from the cache getters",
+				StabilityLevel: metrics.ALPHA,
+			},
+			attributes,
+		),
+		vmSkippedCount: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Namespace:      consts.AzureMetricsNamespace,
+				Name:           "cache_vm_skipped_count",
+				Help:           "Number of VMs skipped from the cache, e.g. because they have no computer name yet",
+				StabilityLevel: metrics.ALPHA,
+			},
+			append(attributes[:len(attributes):len(attributes)], "reason"),
+		),
+		circuitBreakerState: metrics.NewGaugeVec(
+			&metrics.GaugeOpts{
+				Namespace:      consts.AzureMetricsNamespace,
+				Name:           "cache_circuit_breaker_state",
+				Help:           "State of the circuit breaker of the cache refreshes, 0 if closed, 1 if open and 2 if half-open",
+				StabilityLevel: metrics.ALPHA,
+			},
+			attributes,
+		),
+		refreshStaleness: metrics.NewGaugeVec(
+			&metrics.GaugeOpts{
+				Namespace:      consts.AzureMetricsNamespace,
+				Name:           "cache_refresh_staleness_seconds",
+				Help:           "Seconds since the last successful refresh of the cache partition of the resource group as of the last refresh, -1 if none has succeeded",
+				StabilityLevel: metrics.ALPHA,
+			},
+			append(attributes[:len(attributes):len(attributes)], "resource_group"),
+		),
+		discrepancyCount: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Namespace:      consts.AzureMetricsNamespace,
+				Name:           "cache_discrepancy_count",
+				Help:           "Number of discrepancies between the cached and the live data found by verifying the cached lookups",
+				StabilityLevel: metrics.ALPHA,
+			},
+			append(attributes[:len(attributes):len(attributes)], "kind"),
+		),
+	}
+
+	legacyregistry.MustRegister(metrics.staleServedCount)
+	legacyregistry.MustRegister(metrics.duplicateComputerNameCount)
+	legacyregistry.MustRegister(metrics.getterPanicCount)
+	legacyregistry.MustRegister(metrics.vmSkippedCount)
+	legacyregistry.MustRegister(metrics.circuitBreakerState)
+	legacyregistry.MustRegister(metrics.refreshStaleness)
+	legacyregistry.MustRegister(metrics.discrepancyCount)
+
+	return metrics
+}
+
+// registerRateLimiterMetrics registers the rate limiter metrics.
+func registerRateLimiterMetrics(attributes ...string) *metrics.GaugeVec {
+	remainingTokens := metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      consts.AzureMetricsNamespace,
+			Name:           "rate_limiter_remaining_tokens",
+			Help:           "Remaining tokens of the client side rate limiter bucket",
+			StabilityLevel: metrics.ALPHA,
+		},
+		attributes,
+	)
+
+	legacyregistry.MustRegister(remainingTokens)
+
+	return remainingTokens
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 
+	"k8s.io/component-base/metrics/testutil"
 	"k8s.io/klog/v2"
 )
 
@@ -112,3 +113,74 @@ func TestAzureMetricResultCode(t *testing.T) {
 		assert.Equal(t, tc.expectedResutCode, fakeLogger.infoBuffer.String())
 	}
 }
+
+func TestCountStaleCacheServed(t *testing.T) {
+	before, err := testutil.GetCounterMetricValue(cacheMetrics.staleServedCount.WithLabelValues("test_cache"))
+	assert.NoError(t, err)
+
+	CountStaleCacheServed("test_cache")
+
+	after, err := testutil.GetCounterMetricValue(cacheMetrics.staleServedCount.WithLabelValues("test_cache"))
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, after)
+}
+
+func TestCountDuplicateComputerName(t *testing.T) {
+	before, err := testutil.GetCounterMetricValue(cacheMetrics.duplicateComputerNameCount.WithLabelValues("test_cache"))
+	assert.NoError(t, err)
+
+	CountDuplicateComputerName("test_cache")
+
+	after, err := testutil.GetCounterMetricValue(cacheMetrics.duplicateComputerNameCount.WithLabelValues("test_cache"))
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, after)
+}
+
+func TestCountCacheGetterPanic(t *testing.T) {
+	before, err := testutil.GetCounterMetricValue(cacheMetrics.getterPanicCount.WithLabelValues("test_cache"))
+	assert.NoError(t, err)
+
+	CountCacheGetterPanic("test_cache")
+
+	after, err := testutil.GetCounterMetricValue(cacheMetrics.getterPanicCount.WithLabelValues("test_cache"))
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, after)
+}
+
+func TestCountVMSkippedFromCache(t *testing.T) {
+	before, err := testutil.GetCounterMetricValue(cacheMetrics.vmSkippedCount.WithLabelValues("test_cache", "test_reason"))
+	assert.NoError(t, err)
+
+	CountVMSkippedFromCache("test_cache", "test_reason")
+
+	after, err := testutil.GetCounterMetricValue(cacheMetrics.vmSkippedCount.WithLabelValues("test_cache", "test_reason"))
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, after)
+}
+
+func TestSetCacheCircuitBreakerState(t *testing.T) {
+	SetCacheCircuitBreakerState("test_cache", 1)
+
+	state, err := testutil.GetGaugeMetricValue(cacheMetrics.circuitBreakerState.WithLabelValues("test_cache"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), state)
+}
+
+func TestSetCacheRefreshStaleness(t *testing.T) {
+	SetCacheRefreshStaleness("test_cache", "rg", 30)
+
+	staleness, err := testutil.GetGaugeMetricValue(cacheMetrics.refreshStaleness.WithLabelValues("test_cache", "rg"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(30), staleness)
+}
+
+func TestCountCacheDiscrepancy(t *testing.T) {
+	before, err := testutil.GetCounterMetricValue(cacheMetrics.discrepancyCount.WithLabelValues("test_cache", "test_kind"))
+	assert.NoError(t, err)
+
+	CountCacheDiscrepancy("test_cache", "test_kind")
+
+	after, err := testutil.GetCounterMetricValue(cacheMetrics.discrepancyCount.WithLabelValues("test_cache", "test_kind"))
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, after)
+}
@@ -50,6 +50,7 @@ import (
 	cloudnodeutil "k8s.io/cloud-provider/node/helpers"
 	nodeutil "k8s.io/component-helpers/node/util"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 
 	azclients "sigs.k8s.io/cloud-provider-azure/pkg/azureclients"
 	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/blobclient"
@@ -173,9 +174,18 @@ type Config struct {
 	// LoadBalancerName determines the specific name of the load balancer user want to use, working with
 	// LoadBalancerResourceGroup
 	LoadBalancerName string `json:"loadBalancerName,omitempty" yaml:"loadBalancerName,omitempty"`
+	// LoadBalancerClusterName is incorporated into the names of the load balancing rules, probes, frontend IP
+	// configurations and security rules managed for the services, so that multiple clusters sharing the same load
+	// balancer would not collide. Only the first 20 characters are used. Changing it on an existing cluster would
+	// make the resources created with the previous names no longer owned by the services.
+	LoadBalancerClusterName string `json:"loadBalancerClusterName,omitempty" yaml:"loadBalancerClusterName,omitempty"`
 	// LoadBalancerResourceGroup determines the specific resource group of the load balancer user want to use, working
 	// with LoadBalancerName
 	LoadBalancerResourceGroup string `json:"loadBalancerResourceGroup,omitempty" yaml:"loadBalancerResourceGroup,omitempty"`
+	// PublicIPDdosProtectionPlanID is the resource ID of the DDoS protection plan which the standard public IPs
+	// created for the services are associated with. It can be overridden by the Service annotation
+	// service.beta.kubernetes.io/azure-pip-ddos-protection-plan-id. The existing public IPs are not changed.
+	PublicIPDdosProtectionPlanID string `json:"publicIPDdosProtectionPlanID,omitempty" yaml:"publicIPDdosProtectionPlanID,omitempty"`
 	// PreConfiguredBackendPoolLoadBalancerTypes determines whether the LoadBalancer BackendPool has been preconfigured.
 	// Candidate values are:
 	//   "": exactly with today (not pre-configured for any LBs)
@@ -183,6 +193,10 @@ type Config struct {
 	//   "external": for external LoadBalancer
 	//   "all": for both internal and external LoadBalancer
 	PreConfiguredBackendPoolLoadBalancerTypes string `json:"preConfiguredBackendPoolLoadBalancerTypes,omitempty" yaml:"preConfiguredBackendPoolLoadBalancerTypes,omitempty"`
+	// DisableBackendPoolManagement disables the creation of the LoadBalancer backend pools and the reconciliation of
+	// their membership for all the LoadBalancers. The backend pools are assumed to exist, e.g. pre-created via IaC,
+	// and only the frontend IP configurations, rules and probes are managed. Default to false.
+	DisableBackendPoolManagement bool `json:"disableBackendPoolManagement,omitempty" yaml:"disableBackendPoolManagement,omitempty"`
 
 	// DisableAvailabilitySetNodes disables VMAS nodes support when "VMType" is set to "vmss".
 	DisableAvailabilitySetNodes bool `json:"disableAvailabilitySetNodes,omitempty" yaml:"disableAvailabilitySetNodes,omitempty"`
@@ -227,6 +241,93 @@ type Config struct {
 	VmssFlexCacheTTLInSeconds int `json:"vmssFlexCacheTTLInSeconds,omitempty" yaml:"vmssFlexCacheTTLInSeconds,omitempty"`
 	// VmssFlexVMCacheTTLInSeconds sets the cache TTL for vmss flex vms
 	VmssFlexVMCacheTTLInSeconds int `json:"vmssFlexVMCacheTTLInSeconds,omitempty" yaml:"vmssFlexVMCacheTTLInSeconds,omitempty"`
+	// VmssFlexVMCacheTTLOverrides overrides VmssFlexVMCacheTTLInSeconds for the VMs of the VMSS Flex whose names match
+	// the patterns, e.g. a shorter TTL for the frequently changing spot pools and a longer one for the stable system
+	// pools. The first matching override applies, and the VMSS Flex matching none use VmssFlexVMCacheTTLInSeconds.
+	// VmssFlexCacheTTLJitter applies to the overridden TTLs too, while the VMSS Flex themselves are still cached for
+	// VmssFlexCacheTTLInSeconds.
+	VmssFlexVMCacheTTLOverrides []VmssFlexVMCacheTTLOverride `json:"vmssFlexVMCacheTTLOverrides,omitempty" yaml:"vmssFlexVMCacheTTLOverrides,omitempty"`
+	// VmssFlexVMInstanceViewCacheTTLInSeconds sets the cache TTL for the instance views of vmss flex vms
+	VmssFlexVMInstanceViewCacheTTLInSeconds int `json:"vmssFlexVMInstanceViewCacheTTLInSeconds,omitempty" yaml:"vmssFlexVMInstanceViewCacheTTLInSeconds,omitempty"`
+	// VmssFlexCacheTTLJitter sets the fraction of the TTL by which the TTL of each entry of the VMSS Flex caches
+	// is randomly shortened or extended, so that the partitions refreshed together do not expire at the same
+	// instant. It must be less than 1. If not set, it will be default to 0.1. Set it to a negative value to
+	// disable the jitter.
+	VmssFlexCacheTTLJitter float64 `json:"vmssFlexCacheTTLJitter,omitempty" yaml:"vmssFlexCacheTTLJitter,omitempty"`
+	// VmssFlexSubscriptionIDs sets the additional subscriptions to list the VMSS Flex from, besides SubscriptionID.
+	VmssFlexSubscriptionIDs []string `json:"vmssFlexSubscriptionIDs,omitempty" yaml:"vmssFlexSubscriptionIDs,omitempty"`
+	// VmssFlexForceRefreshDebounceInMilliseconds sets the window in which the force refreshes of the VMSS Flex caches
+	// reuse the result of the just-completed one. If not set, it will be default to 1000. Set it to a negative value
+	// to disable the debounce.
+	VmssFlexForceRefreshDebounceInMilliseconds int `json:"vmssFlexForceRefreshDebounceInMilliseconds,omitempty" yaml:"vmssFlexForceRefreshDebounceInMilliseconds,omitempty"`
+	// VmssFlexDisableForceRefreshOnNotFound disables force refreshing the VMSS Flex caches when a node or a VM is not
+	// found in them, so that a flood of the lookups of the missing nodes would not repeat the listings in the throttle
+	// sensitive environments. The missing nodes are then reported as not found until the caches expire.
+	VmssFlexDisableForceRefreshOnNotFound bool `json:"vmssFlexDisableForceRefreshOnNotFound,omitempty" yaml:"vmssFlexDisableForceRefreshOnNotFound,omitempty"`
+	// VmssFlexCacheMaxEntries sets the max number of VMSS Flex cached across the resource groups. The VMSS Flex
+	// listed beyond the limit are not cached, and thus not found by any lookup. If not set or non-positive, the
+	// number is not limited.
+	VmssFlexCacheMaxEntries int `json:"vmssFlexCacheMaxEntries,omitempty" yaml:"vmssFlexCacheMaxEntries,omitempty"`
+	// VmssFlexCacheMaxResourceGroupsPerRefresh sets the max number of resource groups whose VMSS Flex are read, and
+	// listed if expired, by a lookup across the resource groups. The resource groups are rotated across the lookups
+	// in a round-robin manner, and the cached VMSS Flex of the others are served as is. A node which is not found
+	// is then looked up in its own resource group. If not set or non-positive, all the resource groups are read.
+	VmssFlexCacheMaxResourceGroupsPerRefresh int `json:"vmssFlexCacheMaxResourceGroupsPerRefresh,omitempty" yaml:"vmssFlexCacheMaxResourceGroupsPerRefresh,omitempty"`
+	// VmssFlexCacheRefreshTimeoutSeconds sets the timeout of refreshing the VMSS Flex cache. If not set or
+	// non-positive, the refresh waits until the listing of the scale sets in all resource groups returns.
+	VmssFlexCacheRefreshTimeoutSeconds int `json:"vmssFlexCacheRefreshTimeoutSeconds,omitempty" yaml:"vmssFlexCacheRefreshTimeoutSeconds,omitempty"`
+	// VmssFlexNewVMRetryAttempts sets how many times the VMSS Flex caches are force refreshed again with backoff
+	// before a node created within VmssFlexNewVMRetryWindowInSeconds is reported as not found, since a new VM may
+	// not be listed until the ARM propagation completes. The other nodes are not retried. If not set or
+	// non-positive, the new nodes are not retried either.
+	VmssFlexNewVMRetryAttempts int `json:"vmssFlexNewVMRetryAttempts,omitempty" yaml:"vmssFlexNewVMRetryAttempts,omitempty"`
+	// VmssFlexNewVMRetryWindowInSeconds sets the age of the nodes retried by VmssFlexNewVMRetryAttempts.
+	// If not set or non-positive, it will be default to 300.
+	VmssFlexNewVMRetryWindowInSeconds int `json:"vmssFlexNewVMRetryWindowInSeconds,omitempty" yaml:"vmssFlexNewVMRetryWindowInSeconds,omitempty"`
+	// VmssFlexCacheServeStaleOnError serves the stale VMSS Flex entries when refreshing the cache fails,
+	// as long as they are not older than VmssFlexCacheMaxStalenessInSeconds.
+	VmssFlexCacheServeStaleOnError bool `json:"vmssFlexCacheServeStaleOnError,omitempty" yaml:"vmssFlexCacheServeStaleOnError,omitempty"`
+	// VmssFlexCacheMaxStalenessInSeconds sets the max age of the stale VMSS Flex entries served on refresh errors.
+	// If not set, it will be default to 3600.
+	VmssFlexCacheMaxStalenessInSeconds int `json:"vmssFlexCacheMaxStalenessInSeconds,omitempty" yaml:"vmssFlexCacheMaxStalenessInSeconds,omitempty"`
+	// VmssFlexNodeCacheSweepIntervalInSeconds sets the interval of sweeping the expired entries of the VMSS Flex
+	// node name maps in the background, so that the nodes which are no longer looked up do not occupy memory.
+	// If not set or non-positive, the entries are only removed when the nodes are deleted.
+	VmssFlexNodeCacheSweepIntervalInSeconds int `json:"vmssFlexNodeCacheSweepIntervalInSeconds,omitempty" yaml:"vmssFlexNodeCacheSweepIntervalInSeconds,omitempty"`
+	// VmssFlexNodeCacheMaxEntries sets the max number of the entries of each VMSS Flex node name map, beyond which
+	// the least recently resolved nodes are evicted and resolved again on the next lookups. It should be larger
+	// than the number of the VMSS Flex nodes, otherwise the nodes would be evicted before being resolved. It does
+	// not apply to the stores plugged in by NewFlexScaleSetWithCacheStore. If not set or non-positive, the maps
+	// are not bounded.
+	VmssFlexNodeCacheMaxEntries int `json:"vmssFlexNodeCacheMaxEntries,omitempty" yaml:"vmssFlexNodeCacheMaxEntries,omitempty"`
+	// VmssFlexCacheCircuitBreakerThreshold sets the number of the consecutive failed refreshes of the VMSS Flex caches
+	// after which the refreshes fail fast without calling ARM, e.g. to avoid amplifying the throttling. The stale
+	// entries are still served if VmssFlexCacheServeStaleOnError is set. A throttled refresh with a Retry-After opens
+	// the circuit for at least the Retry-After. If not set or non-positive, the circuit breaker is disabled.
+	VmssFlexCacheCircuitBreakerThreshold int `json:"vmssFlexCacheCircuitBreakerThreshold,omitempty" yaml:"vmssFlexCacheCircuitBreakerThreshold,omitempty"`
+	// VmssFlexCacheCircuitBreakerCooldownInSeconds sets the time the circuit breaker stays open before a single trial
+	// refresh is allowed to test the recovery. If not set or non-positive, it defaults to 30 seconds.
+	VmssFlexCacheCircuitBreakerCooldownInSeconds int `json:"vmssFlexCacheCircuitBreakerCooldownInSeconds,omitempty" yaml:"vmssFlexCacheCircuitBreakerCooldownInSeconds,omitempty"`
+	// VmssFlexVMGetThrottledRetryAttempts sets how many times a throttled GET of a VMSS Flex VM is retried after the
+	// Retry-After of the response, so that a transient throttling does not flap the node resolution. If not set, it
+	// will be default to 3. Set it to a negative value to disable the retries.
+	VmssFlexVMGetThrottledRetryAttempts int `json:"vmssFlexVMGetThrottledRetryAttempts,omitempty" yaml:"vmssFlexVMGetThrottledRetryAttempts,omitempty"`
+	// VmssFlexVMGetThrottledRetryMaxDelayInSeconds sets the max delay before each retry of a throttled GET of a VMSS
+	// Flex VM, even if the Retry-After is longer. If not set or non-positive, it will be default to 10.
+	VmssFlexVMGetThrottledRetryMaxDelayInSeconds int `json:"vmssFlexVMGetThrottledRetryMaxDelayInSeconds,omitempty" yaml:"vmssFlexVMGetThrottledRetryMaxDelayInSeconds,omitempty"`
+	// VmssFlexARMRetryAttempts sets the max number of attempts of the ARM calls listing the VMSS Flex and their VMs and
+	// getting the VMs, with exponential backoff from 500 milliseconds up to 10 seconds between them. The calls whose
+	// resource is not found are not retried. If not set or not greater than 1, the calls are not retried.
+	VmssFlexARMRetryAttempts int `json:"vmssFlexARMRetryAttempts,omitempty" yaml:"vmssFlexARMRetryAttempts,omitempty"`
+	// VmssFlexCacheVerificationSampleRate sets the fraction, between 0 and 1, of the lookups of the cached VMSS Flex VMs
+	// which are verified against ARM in the background. The discrepancies are logged and counted by the
+	// cache_discrepancy_count metric, and never affect the lookups. It is meant for the tests and the staging
+	// environments. If not set or non-positive, no lookup is verified.
+	VmssFlexCacheVerificationSampleRate float64 `json:"vmssFlexCacheVerificationSampleRate,omitempty" yaml:"vmssFlexCacheVerificationSampleRate,omitempty"`
+	// VmssFlexComputerNameStripPattern sets the regular expression removed from the lower-case computer names of the
+	// VMSS Flex VMs to derive the node names, e.g. `\.internal\.cloudapp\.net$` if the computer names carry the domain
+	// suffix while the node names do not. If not set, the lower-case computer names are used as the node names.
+	VmssFlexComputerNameStripPattern string `json:"vmssFlexComputerNameStripPattern,omitempty" yaml:"vmssFlexComputerNameStripPattern,omitempty"`
 
 	// VmCacheTTLInSeconds sets the cache TTL for vm
 	VMCacheTTLInSeconds int `json:"vmCacheTTLInSeconds,omitempty" yaml:"vmCacheTTLInSeconds,omitempty"`
@@ -256,6 +357,10 @@ type Config struct {
 	// PutVMSSVMBatchSize defines how many requests the client send concurrently when putting the VMSS VMs.
 	// If it is smaller than or equal to zero, the request will be sent one by one in sequence (default).
 	PutVMSSVMBatchSize int `json:"putVMSSVMBatchSize" yaml:"putVMSSVMBatchSize"`
+	// MaxConcurrentARMRequests is the ceiling of the in-flight ARM requests shared by the call sites respecting it,
+	// e.g. the VMSS Flex cache refreshes, so that they would not exceed the ARM rate limit of the subscription together.
+	// If it is smaller than or equal to zero, the requests are not limited (default).
+	MaxConcurrentARMRequests int `json:"maxConcurrentARMRequests,omitempty" yaml:"maxConcurrentARMRequests,omitempty"`
 	// PrivateLinkServiceResourceGroup determines the specific resource group of the private link services user want to use
 	PrivateLinkServiceResourceGroup string `json:"privateLinkServiceResourceGroup,omitempty" yaml:"privateLinkServiceResourceGroup,omitempty"`
 
@@ -280,6 +385,14 @@ type Config struct {
 	LoadBalancerBackendPoolUpdateIntervalInSeconds int `json:"loadBalancerBackendPoolUpdateIntervalInSeconds,omitempty" yaml:"loadBalancerBackendPoolUpdateIntervalInSeconds,omitempty"`
 }
 
+// VmssFlexVMCacheTTLOverride overrides the cache TTL of the VMs of the VMSS Flex whose names match the pattern.
+type VmssFlexVMCacheTTLOverride struct {
+	// ScaleSetNamePattern is the regular expression matched against the lower-case names of the VMSS Flex.
+	ScaleSetNamePattern string `json:"scaleSetNamePattern" yaml:"scaleSetNamePattern"`
+	// TTLInSeconds is the cache TTL of the VMs of the matching VMSS Flex, which must be positive.
+	TTLInSeconds int `json:"ttlInSeconds" yaml:"ttlInSeconds"`
+}
+
 // MultipleStandardLoadBalancerConfiguration stores the properties regarding multiple standard load balancers.
 type MultipleStandardLoadBalancerConfiguration struct {
 	// Name of the public load balancer. There will be an internal load balancer
@@ -381,6 +494,8 @@ type Cloud struct {
 	PrivateLinkServiceClient        privatelinkserviceclient.Interface
 	containerServiceClient          containerserviceclient.Interface
 	deploymentClient                deploymentclient.Interface
+	// vmssFlexSubscriptionClients are the clients of VmssFlexSubscriptionIDs, keyed by the lower-case subscription ID.
+	vmssFlexSubscriptionClients map[string]vmssFlexSubscriptionClients
 
 	ResourceRequestBackoff  wait.Backoff
 	Metadata                *InstanceMetadataService
@@ -404,6 +519,8 @@ type Cloud struct {
 	excludeLoadBalancerNodes   sets.Set[string]
 	nodePrivateIPs             map[string]sets.Set[string]
 	nodePrivateIPToNodeNameMap map[string]string
+	// nodeCreationTimestamps holds the creation timestamps of the current nodes keyed by the lower-case node names.
+	nodeCreationTimestamps map[string]time.Time
 	// nodeInformerSynced is for determining if the informer has synced.
 	nodeInformerSynced cache.InformerSynced
 
@@ -452,6 +569,14 @@ type Cloud struct {
 	multipleStandardLoadBalancersActiveNodesLock    sync.Mutex
 	localServiceNameToServiceInfoMap                sync.Map
 	endpointSlicesCache                             sync.Map
+	// backendPoolAttributions records the cluster and the Service attribution of the backend pools
+	// by the lower-case backend pool IDs, since backend pools do not support tags.
+	backendPoolAttributions sync.Map
+
+	// armRequestSemaphore bounds the in-flight ARM requests by MaxConcurrentARMRequests.
+	// It is initialized on the first use so that it is sized from the final config.
+	armRequestSemaphore     chan struct{}
+	armRequestSemaphoreOnce sync.Once
 }
 
 // NewCloud returns a Cloud with initialized clients
@@ -525,6 +650,7 @@ func NewCloudFromSecret(ctx context.Context, clientBuilder cloudprovider.Control
 		excludeLoadBalancerNodes:   sets.New[string](),
 		nodePrivateIPs:             map[string]sets.Set[string]{},
 		nodePrivateIPToNodeNameMap: map[string]string{},
+		nodeCreationTimestamps:     map[string]time.Time{},
 	}
 
 	az.configSecretMetadata(secretName, secretNamespace, cloudConfigKey)
@@ -558,6 +684,7 @@ func NewCloudWithoutFeatureGates(ctx context.Context, configReader io.Reader, ca
 		excludeLoadBalancerNodes:   sets.New[string](),
 		nodePrivateIPs:             map[string]sets.Set[string]{},
 		nodePrivateIPToNodeNameMap: map[string]string{},
+		nodeCreationTimestamps:     map[string]time.Time{},
 	}
 
 	err = az.InitializeCloudFromConfig(ctx, config, false, callFromCCM)
@@ -807,6 +934,26 @@ func (az *Cloud) getPutVMSSVMBatchSize() int {
 	return az.PutVMSSVMBatchSize
 }
 
+// acquireARMRequestSlot blocks until an ARM request is allowed by MaxConcurrentARMRequests or the
+// context is done. The returned release function must be called once the request completes.
+func (az *Cloud) acquireARMRequestSlot(ctx context.Context) (release func(), err error) {
+	az.armRequestSemaphoreOnce.Do(func() {
+		if az.MaxConcurrentARMRequests > 0 {
+			az.armRequestSemaphore = make(chan struct{}, az.MaxConcurrentARMRequests)
+		}
+	})
+	if az.armRequestSemaphore == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case az.armRequestSemaphore <- struct{}{}:
+		return func() { <-az.armRequestSemaphore }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for the ARM request slot: %w", ctx.Err())
+	}
+}
+
 func (az *Cloud) initCaches() (err error) {
 	if az.Config.DisableAPICallCache {
 		klog.Infof("API call cache is disabled, ignore logs about cache operations")
@@ -1022,6 +1169,21 @@ func (az *Cloud) configAzureClients(
 	az.containerServiceClient = containerserviceclient.New(containerServiceConfig)
 	az.deploymentClient = deploymentclient.New(deploymentConfig)
 
+	az.vmssFlexSubscriptionClients = make(map[string]vmssFlexSubscriptionClients)
+	for _, subscriptionID := range az.Config.VmssFlexSubscriptionIDs {
+		if subscriptionID == "" || strings.EqualFold(subscriptionID, az.Config.SubscriptionID) {
+			continue
+		}
+		subscriptionVMSSClientConfig := *vmssClientConfig
+		subscriptionVMSSClientConfig.SubscriptionID = subscriptionID
+		subscriptionVMClientConfig := *vmClientConfig
+		subscriptionVMClientConfig.SubscriptionID = subscriptionID
+		az.vmssFlexSubscriptionClients[strings.ToLower(subscriptionID)] = vmssFlexSubscriptionClients{
+			vmssClient: vmssclient.New(&subscriptionVMSSClientConfig),
+			vmClient:   vmclient.New(&subscriptionVMClientConfig),
+		}
+	}
+
 	if az.ZoneClient == nil {
 		az.ZoneClient = zoneclient.New(zoneClientConfig)
 	}
@@ -1075,6 +1237,10 @@ func ParseConfig(configReader io.Reader) (*Config, error) {
 		return nil, err
 	}
 
+	if config.VmssFlexCacheTTLInSeconds < 0 {
+		return nil, fmt.Errorf("vmssFlexCacheTTLInSeconds %d must not be negative", config.VmssFlexCacheTTLInSeconds)
+	}
+
 	// The resource group name may be in different cases from different Azure APIs, hence it is converted to lower here.
 	// See more context at https://github.com/kubernetes/kubernetes/issues/71994.
 	config.ResourceGroup = strings.ToLower(config.ResourceGroup)
@@ -1228,6 +1394,7 @@ func (az *Cloud) updateNodeCaches(prevNode, newNode *v1.Node) {
 	if prevNode != nil {
 		// Remove from nodeNames cache.
 		az.nodeNames.Delete(prevNode.ObjectMeta.Name)
+		delete(az.nodeCreationTimestamps, strings.ToLower(prevNode.ObjectMeta.Name))
 
 		// Remove from nodeZones cache.
 		prevZone, ok := prevNode.ObjectMeta.Labels[v1.LabelTopologyZone]
@@ -1272,6 +1439,7 @@ func (az *Cloud) updateNodeCaches(prevNode, newNode *v1.Node) {
 	if newNode != nil {
 		// Add to nodeNames cache.
 		az.nodeNames.Insert(newNode.ObjectMeta.Name)
+		az.nodeCreationTimestamps[strings.ToLower(newNode.ObjectMeta.Name)] = newNode.ObjectMeta.CreationTimestamp.Time
 
 		// Add to nodeZones cache.
 		newZone, ok := newNode.ObjectMeta.Labels[v1.LabelTopologyZone]
@@ -1406,6 +1574,32 @@ func (az *Cloud) GetNodeResourceGroup(nodeName string) (string, error) {
 	return az.ResourceGroup, nil
 }
 
+// getNodeResourceGroupFromLabel returns the resource group named by the ExternalResourceGroupLabel of the node.
+// It returns false if the node has no such label, or the node informer is not synced.
+func (az *Cloud) getNodeResourceGroupFromLabel(nodeName string) (string, bool) {
+	if az.nodeInformerSynced == nil {
+		return "", false
+	}
+
+	az.nodeCachesLock.RLock()
+	defer az.nodeCachesLock.RUnlock()
+	if !az.nodeInformerSynced() {
+		return "", false
+	}
+	resourceGroup, ok := az.nodeResourceGroups[nodeName]
+	return resourceGroup, ok
+}
+
+// isNodeRecentlyCreated returns true if the node was created within the window by the clock according to the
+// node informer.
+func (az *Cloud) isNodeRecentlyCreated(nodeName string, window time.Duration, clk clock.PassiveClock) bool {
+	az.nodeCachesLock.RLock()
+	defer az.nodeCachesLock.RUnlock()
+
+	creationTimestamp, ok := az.nodeCreationTimestamps[strings.ToLower(nodeName)]
+	return ok && clk.Since(creationTimestamp) <= window
+}
+
 // GetNodeNames returns a set of all node names in the k8s cluster.
 func (az *Cloud) GetNodeNames() (sets.Set[string], error) {
 	// Kubelet won't set az.nodeInformerSynced, return nil.
@@ -294,8 +294,8 @@ func (fs *FlexScaleSet) updateCache(nodeName string, vm *compute.VirtualMachine)
 	vmMap := cached.(*sync.Map)
 	vmMap.Store(nodeName, vm)
 
-	fs.vmssFlexVMNameToVmssID.Store(strings.ToLower(*vm.OsProfile.ComputerName), vmssFlexID)
-	fs.vmssFlexVMNameToNodeName.Store(*vm.Name, strings.ToLower(*vm.OsProfile.ComputerName))
+	fs.vmssFlexVMNameToVmssID.Set(strings.ToLower(*vm.OsProfile.ComputerName), vmssFlexID)
+	fs.vmssFlexVMNameToNodeName.Set(strings.ToLower(*vm.Name), strings.ToLower(*vm.OsProfile.ComputerName))
 	klog.V(2).Infof("updateCache(%s) for vmssFlexID(%s) successfully", nodeName, vmssFlexID)
 	return nil
 }
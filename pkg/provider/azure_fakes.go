@@ -19,6 +19,7 @@ package provider
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"sigs.k8s.io/cloud-provider-azure/pkg/provider/config"
 
@@ -102,6 +103,7 @@ func GetTestCloud(ctrl *gomock.Controller) (az *Cloud) {
 		unmanagedNodes:           sets.New[string](),
 		excludeLoadBalancerNodes: sets.New[string](),
 		nodePrivateIPs:           map[string]sets.Set[string]{},
+		nodeCreationTimestamps:   map[string]time.Time{},
 		routeCIDRs:               map[string]string{},
 		eventRecorder:            &record.FakeRecorder{},
 	}
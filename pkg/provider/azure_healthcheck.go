@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
+)
+
+// ClientHealth is the status of an ARM client reported by HealthCheck.
+type ClientHealth struct {
+	// Client is the name of the client, e.g. VirtualMachineScaleSetsClient.
+	Client string
+	// Err is the error of the read performed by the client, or nil if the client could reach Azure.
+	Err error
+}
+
+// HealthCheck checks each ARM client could reach Azure by listing the resources in the resource group of
+// the cluster, which is the cheapest read supported by all of them. A resource group which is not found
+// still proves the client could reach Azure. The clients which are not initialized are skipped. It returns
+// the status of each checked client, and the aggregated error of the failed ones.
+func (az *Cloud) HealthCheck(ctx context.Context) ([]ClientHealth, error) {
+	type clientCheck struct {
+		client string
+		list   func() *retry.Error
+	}
+	var checks []clientCheck
+	if az.VirtualMachineScaleSetsClient != nil {
+		checks = append(checks, clientCheck{"VirtualMachineScaleSetsClient", func() *retry.Error {
+			_, rerr := az.VirtualMachineScaleSetsClient.List(ctx, az.ResourceGroup)
+			return rerr
+		}})
+	}
+	if az.VirtualMachinesClient != nil {
+		checks = append(checks, clientCheck{"VirtualMachinesClient", func() *retry.Error {
+			_, rerr := az.VirtualMachinesClient.List(ctx, az.ResourceGroup)
+			return rerr
+		}})
+	}
+	if az.LoadBalancerClient != nil {
+		checks = append(checks, clientCheck{"LoadBalancerClient", func() *retry.Error {
+			_, rerr := az.LoadBalancerClient.List(ctx, az.ResourceGroup)
+			return rerr
+		}})
+	}
+	if az.PublicIPAddressesClient != nil {
+		checks = append(checks, clientCheck{"PublicIPAddressesClient", func() *retry.Error {
+			_, rerr := az.PublicIPAddressesClient.List(ctx, az.ResourceGroup)
+			return rerr
+		}})
+	}
+	if az.SecurityGroupsClient != nil {
+		checks = append(checks, clientCheck{"SecurityGroupsClient", func() *retry.Error {
+			_, rerr := az.SecurityGroupsClient.List(ctx, az.ResourceGroup)
+			return rerr
+		}})
+	}
+
+	healths := make([]ClientHealth, 0, len(checks))
+	var errs []error
+	for _, check := range checks {
+		health := ClientHealth{Client: check.client}
+		if rerr := check.list(); rerr != nil && !rerr.IsNotFound() {
+			health.Err = fmt.Errorf("%s: %w", check.client, rerr.Error())
+			klog.Errorf("HealthCheck: %s failed to reach Azure: %v", check.client, rerr.Error())
+			errs = append(errs, health.Err)
+		}
+		healths = append(healths, health)
+	}
+	return healths, utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2022-07-01/network"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/loadbalancerclient/mockloadbalancerclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/publicipclient/mockpublicipclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/securitygroupclient/mocksecuritygroupclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmclient/mockvmclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmssclient/mockvmssclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
+)
+
+func TestHealthCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]compute.VirtualMachineScaleSet{}, nil)
+	az.VirtualMachinesClient.(*mockvmclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return(nil, &retry.Error{HTTPStatusCode: http.StatusForbidden, RawError: errors.New("forbidden")})
+	az.LoadBalancerClient.(*mockloadbalancerclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.LoadBalancer{}, nil)
+	// the resource group which is not found should not fail the check
+	az.PublicIPAddressesClient.(*mockpublicipclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return(nil, &retry.Error{HTTPStatusCode: http.StatusNotFound, RawError: errors.New("not found")})
+	// the clients which are not initialized should be skipped
+	az.SecurityGroupsClient = nil
+
+	healths, err := az.HealthCheck(context.Background())
+	assert.ErrorContains(t, err, "VirtualMachinesClient")
+	assert.Len(t, healths, 4)
+	for _, health := range healths {
+		if health.Client == "VirtualMachinesClient" {
+			assert.ErrorContains(t, health.Err, "forbidden")
+			continue
+		}
+		assert.NoError(t, health.Err, health.Client)
+	}
+
+	az.VirtualMachinesClient.(*mockvmclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]compute.VirtualMachine{}, nil)
+	az.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]compute.VirtualMachineScaleSet{}, nil)
+	az.LoadBalancerClient.(*mockloadbalancerclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.LoadBalancer{}, nil)
+	az.PublicIPAddressesClient.(*mockpublicipclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.PublicIPAddress{}, nil)
+	az.SecurityGroupsClient = mocksecuritygroupclient.NewMockInterface(ctrl)
+	az.SecurityGroupsClient.(*mocksecuritygroupclient.MockInterface).EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.SecurityGroup{}, nil)
+
+	healths, err = az.HealthCheck(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, healths, 5)
+}
@@ -147,6 +147,19 @@ func fillNetInterfacePublicIPs(publicIPs []PublicIPMetadata, netInterface *Netwo
 	}
 }
 
+// getPrimaryNetworkInterfaceIndex returns the index of the first interface having private IP addresses.
+// With accelerated networking, the instance may report an interface without any IP address, which
+// should not be used for the node addresses.
+func getPrimaryNetworkInterfaceIndex(netInterfaces []NetworkInterface) int {
+	for i, netInterface := range netInterfaces {
+		if (len(netInterface.IPV4.IPAddress) > 0 && len(netInterface.IPV4.IPAddress[0].PrivateIP) > 0) ||
+			(len(netInterface.IPV6.IPAddress) > 0 && len(netInterface.IPV6.IPAddress[0].PrivateIP) > 0) {
+			return i
+		}
+	}
+	return 0
+}
+
 func (ims *InstanceMetadataService) getMetadata(key string) (interface{}, error) {
 	instanceMetadata, err := ims.getInstanceMetadata(key)
 	if err != nil {
@@ -154,7 +167,7 @@ func (ims *InstanceMetadataService) getMetadata(key string) (interface{}, error)
 	}
 
 	if instanceMetadata.Network != nil && len(instanceMetadata.Network.Interface) > 0 {
-		netInterface := instanceMetadata.Network.Interface[0]
+		netInterface := instanceMetadata.Network.Interface[getPrimaryNetworkInterfaceIndex(instanceMetadata.Network.Interface)]
 		if (len(netInterface.IPV4.IPAddress) > 0 && len(netInterface.IPV4.IPAddress[0].PublicIP) > 0) ||
 			(len(netInterface.IPV6.IPAddress) > 0 && len(netInterface.IPV6.IPAddress[0].PublicIP) > 0) {
 			// Return if public IP address has already part of instance metadata.
@@ -37,6 +37,10 @@ const (
 	vmPowerStateStopped      = "stopped"
 	vmPowerStateDeallocated  = "deallocated"
 	vmPowerStateDeallocating = "deallocating"
+	vmPowerStateRunning      = "running"
+
+	vmProvisioningStatePrefix = "ProvisioningState/"
+	vmProvisioningStateFailed = "failed"
 
 	// nodeNameEnvironmentName is the environment variable name for getting node name.
 	// It is only used for out-of-tree cloud provider.
@@ -116,7 +120,7 @@ func (az *Cloud) getLocalInstanceNodeAddresses(netInterfaces []NetworkInterface,
 	}
 
 	// Use ip address got from instance metadata.
-	netInterface := netInterfaces[0]
+	netInterface := netInterfaces[getPrimaryNetworkInterfaceIndex(netInterfaces)]
 	addresses := []v1.NodeAddress{
 		{Type: v1.NodeHostName, Address: nodeName},
 	}
@@ -564,6 +564,28 @@ func TestNodeAddresses(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:                "NodeAddresses should skip the interface without IP addresses of an accelerated networking instance",
+			nodeName:            "vm1",
+			metadataName:        "vm1",
+			vmType:              consts.VMTypeStandard,
+			metadataTemplate:    `{"compute":{"name":"vm1"},"network":{"interface":[{"ipv4":{"ipAddress":[]},"ipv6":{"ipAddress":[]}},{"ipv4":{"ipAddress":[{"privateIpAddress":"10.240.0.1","publicIpAddress":"192.168.1.12"}]},"ipv6":{"ipAddress":[]}}]}}`,
+			useInstanceMetadata: true,
+			expectedAddress: []v1.NodeAddress{
+				{
+					Type:    v1.NodeHostName,
+					Address: "vm1",
+				},
+				{
+					Type:    v1.NodeInternalIP,
+					Address: "10.240.0.1",
+				},
+				{
+					Type:    v1.NodeExternalIP,
+					Address: "192.168.1.12",
+				},
+			},
+		},
 		{
 			name:                "NodeAddresses should get IP addresses from local IMDS for standard LoadBalancer if node's name is equal to metadataName",
 			nodeName:            "vm1",
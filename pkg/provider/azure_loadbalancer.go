@@ -115,23 +115,29 @@ func getPublicIPDomainNameLabel(service *v1.Service) (string, bool) {
 	return "", false
 }
 
-// reconcileService reconcile the LoadBalancer service. It returns LoadBalancerStatus on success.
-func (az *Cloud) reconcileService(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
+// reconcileService reconcile the LoadBalancer service. It returns LoadBalancerStatus and the summary of the changes
+// of the load balancer on success.
+func (az *Cloud) reconcileService(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, lbReconcileSummary, error) {
 	serviceName := getServiceName(service)
 	resourceBaseName := az.GetLoadBalancerName(context.TODO(), "", service)
 	klog.V(2).Infof("reconcileService: Start reconciling Service %q with its resource basename %q", serviceName, resourceBaseName)
 
-	lb, err := az.reconcileLoadBalancer(clusterName, service, nodes, true /* wantLb */)
+	if err := az.validateServiceAnnotations(service); err != nil {
+		return nil, lbReconcileSummary{}, err
+	}
+
+	var summary lbReconcileSummary
+	lb, err := az.reconcileLoadBalancerWithSummary(clusterName, service, nodes, true /* wantLb */, &summary)
 	if err != nil {
 		klog.Errorf("reconcileLoadBalancer(%s) failed: %v", serviceName, err)
-		return nil, err
+		return nil, summary, err
 	}
 
 	lbStatus, lbIPsPrimaryPIPs, fipConfigs, err := az.getServiceLoadBalancerStatus(service, lb)
 	if err != nil {
 		klog.Errorf("getServiceLoadBalancerStatus(%s) failed: %v", serviceName, err)
 		if !errors.Is(err, ErrorNotVmssInstance) {
-			return nil, err
+			return nil, summary, err
 		}
 	}
 
@@ -139,28 +145,32 @@ func (az *Cloud) reconcileService(ctx context.Context, clusterName string, servi
 	klog.V(2).Infof("reconcileService: reconciling security group for service %q with IPs %q, wantLb = true", serviceName, serviceIPs)
 	if _, err := az.reconcileSecurityGroup(clusterName, service, &serviceIPs, lb.Name, true /* wantLb */); err != nil {
 		klog.Errorf("reconcileSecurityGroup(%s) failed: %#v", serviceName, err)
-		return nil, err
-	}
-
-	for _, fipConfig := range fipConfigs {
-		if err := az.reconcilePrivateLinkService(clusterName, service, fipConfig, true /* wantPLS */); err != nil {
-			klog.Errorf("reconcilePrivateLinkService(%s) failed: %#v", serviceName, err)
-			return nil, err
-		}
+		return nil, summary, err
 	}
 
 	updateService := updateServiceLoadBalancerIPs(service, lbIPsPrimaryPIPs)
 	flippedService := flipServiceInternalAnnotation(updateService)
 	if _, err := az.reconcileLoadBalancer(clusterName, flippedService, nil, false /* wantLb */); err != nil {
 		klog.Errorf("reconcileLoadBalancer(%s) failed: %#v", serviceName, err)
-		return nil, err
+		return nil, summary, err
 	}
 
 	// lb is not reused here because the ETAG may be changed in above operations, hence reconcilePublicIP() would get lb again from cache.
 	klog.V(2).Infof("reconcileService: reconciling pip")
 	if _, err := az.reconcilePublicIPs(clusterName, updateService, pointer.StringDeref(lb.Name, ""), true /* wantLb */); err != nil {
 		klog.Errorf("reconcilePublicIP(%s) failed: %#v", serviceName, err)
-		return nil, err
+		return nil, summary, err
+	}
+
+	// The private link services are reconciled after the public resources of the flipped service
+	// are cleaned up, so that a service switching from public IP to private link service exposure
+	// would not leave the orphaned frontend IP configurations and public IPs behind if the creation
+	// of the private link service fails.
+	for _, fipConfig := range fipConfigs {
+		if err := az.reconcilePrivateLinkService(clusterName, service, fipConfig, true /* wantPLS */); err != nil {
+			klog.Errorf("reconcilePrivateLinkService(%s) failed: %#v", serviceName, err)
+			return nil, summary, err
+		}
 	}
 
 	lbName := strings.ToLower(pointer.StringDeref(lb.Name, ""))
@@ -171,7 +181,7 @@ func (az *Cloud) reconcileService(ctx context.Context, clusterName string, servi
 		az.localServiceNameToServiceInfoMap.Delete(key)
 	}
 
-	return lbStatus, nil
+	return lbStatus, summary, nil
 }
 
 // EnsureLoadBalancer creates a new load balancer 'name', or updates the existing one. Returns the status of the balancer
@@ -195,15 +205,46 @@ func (az *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, ser
 		klog.V(5).InfoS("EnsureLoadBalancer Finish", "service", serviceName, "cluster", clusterName, "service_spec", service, "error", err)
 	}()
 
-	lbStatus, err := az.reconcileService(ctx, clusterName, service, nodes)
+	if consts.IsK8sServiceLoadBalancerFrozen(service) {
+		isOperationSucceeded = true
+		klog.V(2).Infof("EnsureLoadBalancer: skipping service %s because its load balancer is frozen by annotation %s", serviceName, consts.ServiceAnnotationLoadBalancerFreeze)
+		return service.Status.LoadBalancer.DeepCopy(), nil
+	}
+
+	if err = az.checkLoadBalancerServiceNotHeadless(service); err != nil {
+		return nil, err
+	}
+
+	lbStatus, summary, err := az.reconcileService(ctx, clusterName, service, nodes)
 	if err != nil {
 		return nil, err
 	}
+	az.recordLoadBalancerReconciledEvent(service, summary)
 
 	isOperationSucceeded = true
 	return lbStatus, nil
 }
 
+// recordLoadBalancerReconciledEvent records a normal event summarizing the changes of the load balancer of the
+// service, if anything is changed.
+func (az *Cloud) recordLoadBalancerReconciledEvent(service *v1.Service, summary lbReconcileSummary) {
+	if !summary.isEmpty() {
+		az.Event(service, v1.EventTypeNormal, "LoadBalancerReconciled", fmt.Sprintf("Load balancer %s reconciled: %s", summary.lbName, summary))
+	}
+}
+
+// checkLoadBalancerServiceNotHeadless returns an error and records a warning event if the LoadBalancer service
+// is headless, i.e. its clusterIP is None, which is a misconfiguration that can not be reconciled.
+func (az *Cloud) checkLoadBalancerServiceNotHeadless(service *v1.Service) error {
+	if service.Spec.ClusterIP != v1.ClusterIPNone {
+		return nil
+	}
+	msg := fmt.Sprintf("service %s of type LoadBalancer must not be headless (clusterIP: None), skipping the load balancer reconciliation", getServiceName(service))
+	klog.Warning(msg)
+	az.Event(service, v1.EventTypeWarning, "HeadlessLoadBalancerService", msg)
+	return errors.New(msg)
+}
+
 func (az *Cloud) getLatestService(serviceName string, deepcopy bool) (*v1.Service, bool, error) {
 	parts := strings.Split(serviceName, "/")
 	ns, n := parts[0], parts[1]
@@ -249,6 +290,16 @@ func (az *Cloud) UpdateLoadBalancer(ctx context.Context, clusterName string, ser
 		return nil
 	}
 
+	if consts.IsK8sServiceLoadBalancerFrozen(service) {
+		isOperationSucceeded = true
+		klog.V(2).Infof("UpdateLoadBalancer: skipping service %s because its load balancer is frozen by annotation %s", serviceName, consts.ServiceAnnotationLoadBalancerFreeze)
+		return nil
+	}
+
+	if err = az.checkLoadBalancerServiceNotHeadless(service); err != nil {
+		return err
+	}
+
 	shouldUpdateLB, err := az.shouldUpdateLoadBalancer(clusterName, service, nodes)
 	if err != nil {
 		return err
@@ -260,10 +311,11 @@ func (az *Cloud) UpdateLoadBalancer(ctx context.Context, clusterName string, ser
 		return nil
 	}
 
-	_, err = az.reconcileService(ctx, clusterName, service, nodes)
+	_, summary, err := az.reconcileService(ctx, clusterName, service, nodes)
 	if err != nil {
 		return err
 	}
+	az.recordLoadBalancerReconciledEvent(service, summary)
 
 	isOperationSucceeded = true
 	return nil
@@ -328,8 +380,48 @@ func (az *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName stri
 }
 
 // GetLoadBalancerName returns the LoadBalancer name.
+// It is prefixed with the cluster name segment if LoadBalancerClusterName is configured.
 func (az *Cloud) GetLoadBalancerName(ctx context.Context, clusterName string, service *v1.Service) string {
-	return cloudprovider.DefaultLoadBalancerName(service)
+	name := cloudprovider.DefaultLoadBalancerName(service)
+	if segment := az.getLoadBalancerClusterNameSegment(); segment != "" {
+		return fmt.Sprintf("%s-%s", segment, name)
+	}
+	return name
+}
+
+// ErrServiceNotPlacedOnLoadBalancer indicates no load balancer has the frontend IP configurations of the service.
+var ErrServiceNotPlacedOnLoadBalancer = errors.New("service is not placed on any load balancer")
+
+// GetLoadBalancerNameForService returns the name of the load balancer the service is currently placed on, which
+// is the one owning its frontend IP configurations. Different from GetLoadBalancerName, it could be any of the
+// load balancers in the multiple standard load balancers mode. It returns ErrServiceNotPlacedOnLoadBalancer if
+// the service is not placed yet.
+func (az *Cloud) GetLoadBalancerNameForService(service *v1.Service) (string, error) {
+	existingLBs, err := az.ListLB(service)
+	if err != nil {
+		return "", err
+	}
+
+	for _, lb := range existingLBs {
+		if lb.LoadBalancerPropertiesFormat == nil || lb.FrontendIPConfigurations == nil {
+			continue
+		}
+		for _, fip := range *lb.FrontendIPConfigurations {
+			if owns, _, _ := az.serviceOwnsFrontendIP(fip, service); owns {
+				return pointer.StringDeref(lb.Name, ""), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrServiceNotPlacedOnLoadBalancer, getServiceName(service))
+}
+
+// getLoadBalancerClusterNameSegment returns the cluster name segment of the load balancer resource names.
+func (az *Cloud) getLoadBalancerClusterNameSegment() string {
+	segment := az.LoadBalancerClusterName
+	if len(segment) > consts.LoadBalancerClusterNameMaxLength {
+		segment = segment[:consts.LoadBalancerClusterNameMaxLength]
+	}
+	return segment
 }
 
 func (az *Cloud) getLoadBalancerResourceGroup() string {
@@ -456,6 +548,7 @@ func (az *Cloud) removeFrontendIPConfigurationFromLoadBalancer(lb *network.LoadB
 			return "", err
 		}
 		_ = az.lbCache.Delete(pointer.StringDeref(lb.Name, ""))
+		az.recordBackendPoolAttributions(service, clusterName, lb)
 	}
 	return deletedLBName, nil
 }
@@ -1033,6 +1126,13 @@ func (az *Cloud) ensurePublicIPExists(service *v1.Service, pipName string, domai
 				pip.PublicIPPrefix = &network.SubResource{ID: pointer.String(id)}
 			}
 
+			if id := az.getPublicIPDdosProtectionPlanID(service); id != "" {
+				pip.DdosSettings = &network.DdosSettings{
+					ProtectionMode:     network.DdosSettingsProtectionModeEnabled,
+					DdosProtectionPlan: &network.SubResource{ID: pointer.String(id)},
+				}
+			}
+
 			// skip adding zone info since edge zones doesn't support multiple availability zones.
 			if !az.HasExtendedLocation() {
 				// only add zone information for the new standard pips
@@ -1343,6 +1443,22 @@ func (az *Cloud) isFrontendIPChanged(
 				return true, nil
 			}
 		}
+		// The frontend would fail the reconciliation if it references a deleted subnet,
+		// so it is recreated on the configured subnet.
+		if subnet != nil && config.Subnet != nil && config.Subnet.ID != nil &&
+			!strings.EqualFold(*config.Subnet.ID, pointer.StringDeref(subnet.ID, "")) {
+			_, existsSubnet, err := az.getSubnetByID(*config.Subnet.ID)
+			if err != nil {
+				return false, err
+			}
+			if !existsSubnet {
+				msg := fmt.Sprintf("the subnet %s of the frontend IP configuration %s is not found, recreating the frontend IP configuration on the subnet %s",
+					*config.Subnet.ID, lbFrontendIPConfigName, pointer.StringDeref(subnet.ID, ""))
+				klog.Warningf("isFrontendIPChanged(%s): %s", getServiceName(service), msg)
+				az.Event(service, v1.EventTypeWarning, "FrontendSubnetNotFound", msg)
+				return true, nil
+			}
+		}
 		return loadBalancerIP != "" && !strings.EqualFold(loadBalancerIP, pointer.StringDeref(config.PrivateIPAddress, "")), nil
 	}
 	pipName, _, err := az.determinePublicIPName(clusterName, service, isIPv6)
@@ -1412,10 +1528,14 @@ func (az *Cloud) isFrontendIPConfigUnsafeToDelete(
 		}
 	}
 
-	// check if there are outbound rules
+	// check if there are outbound rules not owned by the service
 	// referencing this frontend IP configuration
 	for _, outboundRule := range outboundRules {
 		if outboundRule.OutboundRulePropertiesFormat != nil && outboundRule.FrontendIPConfigurations != nil {
+			// the outbound rule of the outbound-only service is removed together with its frontend IP configuration
+			if az.serviceOwnsOutboundRule(service, pointer.StringDeref(outboundRule.Name, "")) {
+				continue
+			}
 			outboundRuleFIPConfigs := *outboundRule.FrontendIPConfigurations
 			if found := findMatchedOutboundRuleFIPConfig(fipConfigID, outboundRuleFIPConfigs); found {
 				warningMsg := fmt.Sprintf("isFrontendIPConfigUnsafeToDelete: frontend IP configuration with ID %s on LB %s cannot be deleted because it is being referenced by the outbound rule %s", *fipConfigID, *lb.Name, *outboundRule.Name)
@@ -1542,7 +1662,7 @@ func (az *Cloud) reconcileMultipleStandardLoadBalancerConfigurations(
 	for _, svc := range svcs.Items {
 		svc := svc
 		if strings.EqualFold(string(svc.Spec.Type), string(v1.ServiceTypeLoadBalancer)) {
-			prefix := az.GetLoadBalancerName(context.Background(), "", &svc)
+			prefix := cloudprovider.DefaultLoadBalancerName(&svc)
 			svcName := getServiceName(&svc)
 			rulePrefixToSVCNameMap[strings.ToLower(prefix)] = svcName
 			klog.V(2).Infof("reconcileMultipleStandardLoadBalancerConfigurations: found service %q with prefix %q", svcName, prefix)
@@ -1555,7 +1675,15 @@ func (az *Cloud) reconcileMultipleStandardLoadBalancerConfigurations(
 			existingLB.LoadBalancingRules != nil {
 			for _, rule := range *existingLB.LoadBalancingRules {
 				ruleName := pointer.StringDeref(rule.Name, "")
-				rulePrefix := strings.Split(ruleName, "-")[0]
+				ruleNameWithoutCluster := ruleName
+				if segment := az.getLoadBalancerClusterNameSegment(); segment != "" {
+					// the rule is managed by another cluster sharing the load balancer
+					if !strings.HasPrefix(strings.ToLower(ruleName), strings.ToLower(segment)+"-") {
+						continue
+					}
+					ruleNameWithoutCluster = ruleName[len(segment)+1:]
+				}
+				rulePrefix := strings.Split(ruleNameWithoutCluster, "-")[0]
 				if rulePrefix == "" {
 					klog.Warningf("reconcileMultipleStandardLoadBalancerConfigurations: the load balancing rule name %s is not in the correct format", ruleName)
 				}
@@ -1589,10 +1717,22 @@ func (az *Cloud) reconcileMultipleStandardLoadBalancerConfigurations(
 // This entails adding rules/probes for expected Ports and removing stale rules/ports.
 // nodes only used if wantLb is true
 func (az *Cloud) reconcileLoadBalancer(clusterName string, service *v1.Service, nodes []*v1.Node, wantLb bool) (*network.LoadBalancer, error) {
+	return az.reconcileLoadBalancerWithSummary(clusterName, service, nodes, wantLb, nil)
+}
+
+// reconcileLoadBalancerWithSummary is reconcileLoadBalancer that also summarizes the changes into summary if it is
+// not nil. When wantLb is true, the resources it changed and the backend pool members reported by EnsureHostsInPool
+// are counted.
+func (az *Cloud) reconcileLoadBalancerWithSummary(clusterName string, service *v1.Service, nodes []*v1.Node, wantLb bool, summary *lbReconcileSummary) (*network.LoadBalancer, error) {
 	isBackendPoolPreConfigured := az.isBackendPoolPreConfigured(service)
 	serviceName := getServiceName(service)
 	klog.V(2).Infof("reconcileLoadBalancer for service(%s) - wantLb(%t): started", serviceName, wantLb)
 
+	isOutboundOnly := consts.IsK8sServiceOutboundOnly(service)
+	if wantLb && isOutboundOnly && (!az.useStandardLoadBalancer() || requiresInternalLoadBalancer(service)) {
+		return nil, fmt.Errorf("reconcileLoadBalancer for service(%s): outbound-only service is only supported by the external standard load balancer", serviceName)
+	}
+
 	existingLBs, err := az.ListManagedLBs(service, nodes, clusterName)
 	if err != nil {
 		return nil, fmt.Errorf("reconcileLoadBalancer: failed to list managed LB: %w", err)
@@ -1634,7 +1774,8 @@ func (az *Cloud) reconcileLoadBalancer(clusterName string, service *v1.Service,
 	dirtyLb := false
 
 	// reconcile the load balancer's backend pool configuration.
-	if wantLb {
+	// The backend pools are assumed to exist if the backend pool management is disabled.
+	if wantLb && !az.DisableBackendPoolManagement {
 		preConfig, changed, shouldRefreshLB, err := az.LoadBalancerBackendPool.ReconcileBackendPools(clusterName, service, lb)
 		if err != nil {
 			return lb, err
@@ -1656,6 +1797,9 @@ func (az *Cloud) reconcileLoadBalancer(clusterName string, service *v1.Service,
 		}
 	}
 
+	// the resources of the load balancer before the reconciliation, to summarize the changes
+	originalLBResources := getLBResourceFingerprints(lb)
+
 	// reconcile the load balancer's frontend IP configurations.
 	ownedFIPConfigs, toDeleteConfigs, fipChanged, err := az.reconcileFrontendIPConfigs(clusterName, service, lb, lbStatus, wantLb, lbFrontendIPConfigNames)
 	if err != nil {
@@ -1690,7 +1834,18 @@ func (az *Cloud) reconcileLoadBalancer(clusterName string, service *v1.Service,
 
 	var expectedProbes []network.Probe
 	var expectedRules []network.LoadBalancingRule
+	var expectedOutboundRules []network.OutboundRule
 	getExpectedLBRule := func(isIPv6 bool) error {
+		// The outbound-only service has neither load balancing rules nor health probes, its frontend IP
+		// configuration is only referenced by the outbound rule.
+		if isOutboundOnly {
+			expectedOutboundRule, err := az.getExpectedOutboundRule(service, lbFrontendIPConfigIDs[isIPv6], lbBackendPoolIDs[isIPv6], isIPv6)
+			if err != nil {
+				return err
+			}
+			expectedOutboundRules = append(expectedOutboundRules, expectedOutboundRule)
+			return nil
+		}
 		expectedProbesSingleStack, expectedRulesSingleStack, err := az.getExpectedLBRules(service, lbFrontendIPConfigIDs[isIPv6], lbBackendPoolIDs[isIPv6], lbName, isIPv6)
 		if err != nil {
 			return err
@@ -1724,9 +1879,15 @@ func (az *Cloud) reconcileLoadBalancer(clusterName string, service *v1.Service,
 	if changed := az.reconcileLBRules(lb, service, serviceName, wantLb, expectedRules); changed {
 		dirtyLb = true
 	}
+
+	if changed := az.reconcileLBOutboundRules(lb, service, serviceName, wantLb, expectedOutboundRules); changed {
+		dirtyLb = true
+	}
 	if changed := az.ensureLoadBalancerTagged(lb); changed {
 		dirtyLb = true
 	}
+	changedLBResources := countChangedLBResources(originalLBResources, getLBResourceFingerprints(lb))
+	changedLBResources.lbName = lbName
 
 	// We don't care if the LB exists or not
 	// We only care about if there is any change in the LB, which means dirtyLB
@@ -1772,6 +1933,7 @@ func (az *Cloud) reconcileLoadBalancer(clusterName string, service *v1.Service,
 				return nil, fmt.Errorf("load balancer %q not found", lbName)
 			}
 			lb = newLB
+			az.recordBackendPoolAttributions(service, clusterName, lb)
 
 			addOrUpdateLBInList(existingLBs, newLB)
 		}
@@ -1807,7 +1969,9 @@ func (az *Cloud) reconcileLoadBalancer(clusterName string, service *v1.Service,
 				for _, backendPool := range *lb.LoadBalancerPropertiesFormat.BackendAddressPools {
 					isIPv6 := isBackendPoolIPv6(pointer.StringDeref(backendPool.Name, ""))
 					if strings.EqualFold(pointer.StringDeref(backendPool.Name, ""), az.getBackendPoolNameForService(service, clusterName, isIPv6)) {
-						if err := az.LoadBalancerBackendPool.EnsureHostsInPool(service, nodes, lbBackendPoolIDs[isIPv6], vmSetName, clusterName, lbName, backendPool); err != nil {
+						changedMembers, err := az.LoadBalancerBackendPool.EnsureHostsInPool(service, nodes, lbBackendPoolIDs[isIPv6], vmSetName, clusterName, lbName, backendPool)
+						changedLBResources.members += changedMembers
+						if err != nil {
 							return nil, err
 						}
 					}
@@ -1820,10 +1984,94 @@ func (az *Cloud) reconcileLoadBalancer(clusterName string, service *v1.Service,
 		az.reconcileMultipleStandardLoadBalancerConfigurationStatus(wantLb, serviceName, lbName)
 	}
 
+	if wantLb && summary != nil {
+		*summary = changedLBResources
+	}
+
 	klog.V(2).Infof("reconcileLoadBalancer for service(%s): lb(%s) finished", serviceName, lbName)
 	return lb, nil
 }
 
+// lbReconcileSummary counts the resources of the load balancer changed by reconcileLoadBalancer, including the
+// members added to or removed from the backend pools of the service.
+type lbReconcileSummary struct {
+	lbName            string
+	frontendIPConfigs int
+	rules             int
+	probes            int
+	members           int
+}
+
+// isEmpty returns true if nothing of the load balancer is changed.
+func (s lbReconcileSummary) isEmpty() bool {
+	return s.frontendIPConfigs == 0 && s.rules == 0 && s.probes == 0 && s.members == 0
+}
+
+func (s lbReconcileSummary) String() string {
+	return fmt.Sprintf("%d frontend IP configurations, %d rules and %d probes changed, %d backend pool members added or removed",
+		s.frontendIPConfigs, s.rules, s.probes, s.members)
+}
+
+// getLBResourceFingerprints returns the serialized frontend IP configurations, rules and probes of the load balancer,
+// keyed by their kinds and names.
+func getLBResourceFingerprints(lb *network.LoadBalancer) map[string]string {
+	fingerprints := make(map[string]string)
+	if lb == nil || lb.LoadBalancerPropertiesFormat == nil {
+		return fingerprints
+	}
+	add := func(kind string, name *string, resource interface{}) {
+		data, _ := json.Marshal(resource)
+		fingerprints[kind+"/"+strings.ToLower(pointer.StringDeref(name, ""))] = string(data)
+	}
+	if lb.FrontendIPConfigurations != nil {
+		for _, fip := range *lb.FrontendIPConfigurations {
+			add("frontend", fip.Name, fip)
+		}
+	}
+	if lb.LoadBalancingRules != nil {
+		for _, rule := range *lb.LoadBalancingRules {
+			add("rule", rule.Name, rule)
+		}
+	}
+	if lb.OutboundRules != nil {
+		for _, rule := range *lb.OutboundRules {
+			add("rule", rule.Name, rule)
+		}
+	}
+	if lb.Probes != nil {
+		for _, probe := range *lb.Probes {
+			add("probe", probe.Name, probe)
+		}
+	}
+	return fingerprints
+}
+
+// countChangedLBResources counts the resources added, updated or removed between the fingerprints.
+func countChangedLBResources(before, after map[string]string) lbReconcileSummary {
+	var summary lbReconcileSummary
+	count := func(key string) {
+		switch kind, _, _ := strings.Cut(key, "/"); kind {
+		case "frontend":
+			summary.frontendIPConfigs++
+		case "rule":
+			summary.rules++
+		case "probe":
+			summary.probes++
+		}
+	}
+	for key, fingerprint := range after {
+		if previous, ok := before[key]; !ok || previous != fingerprint {
+			count(key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			count(key)
+		}
+	}
+	return summary
+}
+
 // addOrUpdateLBInList adds or updates the given lb in the list
 func addOrUpdateLBInList(lbs *[]network.LoadBalancer, targetLB *network.LoadBalancer) {
 	for i, lb := range *lbs {
@@ -2189,6 +2437,46 @@ func (az *Cloud) reconcileLBRules(lb *network.LoadBalancer, service *v1.Service,
 	return dirtyRules
 }
 
+// reconcileLBOutboundRules reconciles the outbound rules owned by the service, which are only expected
+// for the outbound-only service.
+func (az *Cloud) reconcileLBOutboundRules(lb *network.LoadBalancer, service *v1.Service, serviceName string, wantLb bool, expectedRules []network.OutboundRule) bool {
+	dirtyRules := false
+	var updatedRules []network.OutboundRule
+	if lb.LoadBalancerPropertiesFormat != nil && lb.OutboundRules != nil {
+		updatedRules = *lb.OutboundRules
+	}
+
+	// remove unwanted
+	for i := len(updatedRules) - 1; i >= 0; i-- {
+		existingRule := updatedRules[i]
+		if !az.serviceOwnsOutboundRule(service, pointer.StringDeref(existingRule.Name, "")) {
+			continue
+		}
+		if !findOutboundRule(expectedRules, existingRule) {
+			klog.V(2).Infof("reconcileLoadBalancer for service (%s)(%t): lb outbound rule(%s) - dropping", serviceName, wantLb, *existingRule.Name)
+			updatedRules = append(updatedRules[:i], updatedRules[i+1:]...)
+			dirtyRules = true
+		}
+	}
+	// add needed
+	for _, expectedRule := range expectedRules {
+		if !findOutboundRule(updatedRules, expectedRule) {
+			klog.V(10).Infof("reconcileLoadBalancer for service (%s)(%t): lb outbound rule(%s) adding", serviceName, wantLb, *expectedRule.Name)
+			updatedRules = append(updatedRules, expectedRule)
+			dirtyRules = true
+		}
+	}
+	if dirtyRules {
+		ruleJSON, _ := json.Marshal(expectedRules)
+		klog.V(2).Infof("reconcileLoadBalancer for service (%s)(%t): lb outbound rules updated: %s", serviceName, wantLb, string(ruleJSON))
+		if lb.LoadBalancerPropertiesFormat == nil {
+			lb.LoadBalancerPropertiesFormat = &network.LoadBalancerPropertiesFormat{}
+		}
+		lb.OutboundRules = &updatedRules
+	}
+	return dirtyRules
+}
+
 func (az *Cloud) reconcileFrontendIPConfigs(clusterName string,
 	service *v1.Service,
 	lb *network.LoadBalancer,
@@ -2247,6 +2535,9 @@ func (az *Cloud) reconcileFrontendIPConfigs(clusterName string,
 		)
 
 		if isInternal {
+			if err := az.validateInternalSubnet(service); err != nil {
+				return nil, toDeleteConfigs, false, err
+			}
 			subnetName := getInternalSubnet(service)
 			if subnetName == nil {
 				subnetName = &az.SubnetName
@@ -2256,11 +2547,13 @@ func (az *Cloud) reconcileFrontendIPConfigs(clusterName string,
 				return nil, toDeleteConfigs, false, err
 			}
 			if !existsSubnet {
+				az.Event(service, v1.EventTypeWarning, "SubnetNotFound", fmt.Sprintf("the subnet %s/%s of the internal load balancer is not found", az.VnetName, *subnetName))
 				return nil, toDeleteConfigs, false, fmt.Errorf("ensure(%s): lb(%s) - failed to get subnet: %s/%s", serviceName, lbName, az.VnetName, *subnetName)
 			}
 		}
 
 		pipRG := az.getPublicIPAddressResourceGroup(service)
+		v4Enabled, v6Enabled := getIPFamiliesEnabled(service)
 
 		for i := len(newConfigs) - 1; i >= 0; i-- {
 			config := newConfigs[i]
@@ -2280,6 +2573,22 @@ func (az *Cloud) reconcileFrontendIPConfigs(clusterName string,
 				}
 			}
 
+			// The IP family is dropped from the Service, e.g. a dual-stack Service is changed to single-stack,
+			// so the frontend IP config of the family is not needed anymore.
+			if (isIPv6 && !v6Enabled) || (!isIPv6 && !v4Enabled) {
+				unsafe, err := az.isFrontendIPConfigUnsafeToDelete(lb, service, config.ID)
+				if err != nil {
+					return nil, toDeleteConfigs, false, err
+				}
+				if !unsafe {
+					klog.V(2).Infof("reconcileLoadBalancer for service (%s)(%t): lb frontendconfig(%s) of the dropped IP family (isIPv6=%t) - dropping", serviceName, wantLb, pointer.StringDeref(config.Name, ""), isIPv6)
+					toDeleteConfigs = append(toDeleteConfigs, newConfigs[i])
+					newConfigs = append(newConfigs[:i], newConfigs[i+1:]...)
+					dirtyConfigs = true
+				}
+				continue
+			}
+
 			isFipChanged, err = az.isFrontendIPChanged(clusterName, config, service, lbFrontendIPConfigNames[isIPv6], &subnet)
 			if err != nil {
 				return nil, toDeleteConfigs, false, err
@@ -2376,7 +2685,6 @@ func (az *Cloud) reconcileFrontendIPConfigs(clusterName string,
 			return nil
 		}
 
-		v4Enabled, v6Enabled := getIPFamiliesEnabled(service)
 		if v4Enabled && ownedFIPConfigMap[false] == nil {
 			if err := addNewFIPOfService(false); err != nil {
 				return nil, toDeleteConfigs, false, err
@@ -2564,9 +2872,11 @@ func (az *Cloud) getExpectedLBRules(
 
 	// In HA mode, lb forward traffic of all port to backend
 	// HA mode is only supported on standard loadbalancer SKU in internal mode
+	// the HA mode rule is named after the first port, so there is no rule without ports
 	if consts.IsK8sServiceUsingInternalLoadBalancer(service) &&
 		az.useStandardLoadBalancer() &&
-		consts.IsK8sServiceHasHAModeEnabled(service) {
+		consts.IsK8sServiceHasHAModeEnabled(service) &&
+		len(service.Spec.Ports) > 0 {
 
 		lbRuleName := az.getloadbalancerHAmodeRuleName(service, isIPv6)
 		klog.V(2).Infof("getExpectedLBRules lb name (%s) rule name (%s)", lbName, lbRuleName)
@@ -2685,20 +2995,9 @@ func (az *Cloud) getExpectedLoadBalancingRulePropertiesForPort(
 		loadDistribution = network.LoadDistributionSourceIP
 	}
 
-	var lbIdleTimeout *int32
-	if lbIdleTimeout, err = consts.Getint32ValueFromK8sSvcAnnotation(service.Annotations, consts.ServiceAnnotationLoadBalancerIdleTimeout, func(val *int32) error {
-		const (
-			min = 4
-			max = 100
-		)
-		if *val < min || *val > max {
-			return fmt.Errorf("idle timeout value must be a whole number representing minutes between %d and %d, actual value: %d", min, max, *val)
-		}
-		return nil
-	}); err != nil {
-		return nil, fmt.Errorf("error parsing idle timeout key: %s, err: %w", consts.ServiceAnnotationLoadBalancerIdleTimeout, err)
-	} else if lbIdleTimeout == nil {
-		lbIdleTimeout = pointer.Int32(4)
+	lbIdleTimeout, err := getLoadBalancerIdleTimeout(service)
+	if err != nil {
+		return nil, err
 	}
 
 	props := &network.LoadBalancingRulePropertiesFormat{
@@ -2717,7 +3016,7 @@ func (az *Cloud) getExpectedLoadBalancingRulePropertiesForPort(
 		IdleTimeoutInMinutes: lbIdleTimeout,
 	}
 	if strings.EqualFold(string(transportProto), string(network.TransportProtocolTCP)) && az.useStandardLoadBalancer() {
-		props.EnableTCPReset = pointer.Bool(!consts.IsTCPResetDisabled(service.Annotations))
+		props.EnableTCPReset = pointer.Bool(consts.IsTCPResetEnabled(service.Annotations))
 	}
 
 	// Azure ILB does not support secondary IPs as floating IPs on the LB. Therefore, floating IP needs to be turned
@@ -2729,6 +3028,50 @@ func (az *Cloud) getExpectedLoadBalancingRulePropertiesForPort(
 	return props, nil
 }
 
+// getLoadBalancerIdleTimeout returns the idle timeout in minutes of the rules of the service, which defaults to 4.
+func getLoadBalancerIdleTimeout(service *v1.Service) (*int32, error) {
+	lbIdleTimeout, err := consts.Getint32ValueFromK8sSvcAnnotation(service.Annotations, consts.ServiceAnnotationLoadBalancerIdleTimeout, func(val *int32) error {
+		const (
+			min = 4
+			max = 100
+		)
+		if *val < min || *val > max {
+			return fmt.Errorf("idle timeout value must be a whole number representing minutes between %d and %d, actual value: %d", min, max, *val)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing idle timeout key: %s, err: %w", consts.ServiceAnnotationLoadBalancerIdleTimeout, err)
+	}
+	if lbIdleTimeout == nil {
+		lbIdleTimeout = pointer.Int32(4)
+	}
+	return lbIdleTimeout, nil
+}
+
+// getExpectedOutboundRule builds the outbound rule of the outbound-only service, which SNATs the
+// outbound traffic of the backend pool to the frontend IP configuration of the service.
+func (az *Cloud) getExpectedOutboundRule(service *v1.Service, lbFrontendIPConfigID, lbBackendPoolID string, isIPv6 bool) (network.OutboundRule, error) {
+	idleTimeout, err := getLoadBalancerIdleTimeout(service)
+	if err != nil {
+		return network.OutboundRule{}, err
+	}
+	return network.OutboundRule{
+		Name: pointer.String(az.getOutboundRuleName(service, isIPv6)),
+		OutboundRulePropertiesFormat: &network.OutboundRulePropertiesFormat{
+			Protocol: network.LoadBalancerOutboundRuleProtocolAll,
+			FrontendIPConfigurations: &[]network.SubResource{
+				{ID: pointer.String(lbFrontendIPConfigID)},
+			},
+			BackendAddressPool: &network.SubResource{
+				ID: pointer.String(lbBackendPoolID),
+			},
+			EnableTCPReset:       pointer.Bool(consts.IsTCPResetEnabled(service.Annotations)),
+			IdleTimeoutInMinutes: idleTimeout,
+		},
+	}, nil
+}
+
 // getExpectedHAModeLoadBalancingRuleProperties build load balancing rule for lb in HA mode
 func (az *Cloud) getExpectedHAModeLoadBalancingRuleProperties(
 	service *v1.Service,
@@ -2738,7 +3081,7 @@ func (az *Cloud) getExpectedHAModeLoadBalancingRuleProperties(
 	if err != nil {
 		return nil, fmt.Errorf("error generate lb rule for ha mod loadbalancer. err: %w", err)
 	}
-	props.EnableTCPReset = pointer.Bool(!consts.IsTCPResetDisabled(service.Annotations))
+	props.EnableTCPReset = pointer.Bool(consts.IsTCPResetEnabled(service.Annotations))
 
 	return props, nil
 }
@@ -2750,11 +3093,22 @@ func (az *Cloud) reconcileSecurityGroup(clusterName string, service *v1.Service,
 	klog.V(5).Infof("reconcileSecurityGroup(%s): START clusterName=%q", serviceName, clusterName)
 
 	ports := service.Spec.Ports
-	if ports == nil {
-		if useSharedSecurityRule(service) {
-			klog.V(2).Infof("Attempting to reconcile security group for service %s, but service uses shared rule and we don't know which port it's for", service.Name)
+	// the outbound-only service has no inbound traffic to allow
+	if consts.IsK8sServiceOutboundOnly(service) {
+		ports = []v1.ServicePort{}
+	}
+	// the service may momentarily have no ports while being edited, in which case its rules are removed
+	// and added back once the ports return. The shared rules are kept, since the ones referring to the
+	// service could not be told from the ones of the other services sharing its IP without the ports.
+	// They could not be kept once the service is deleted though, otherwise they would be leaked.
+	if len(ports) == 0 {
+		if useSharedSecurityRule(service) && !wantLb && !consts.IsK8sServiceOutboundOnly(service) {
+			klog.V(2).Infof("Attempting to remove service %s from the shared rules, but service has no ports and we don't know which rules it is in", service.Name)
 			return nil, fmt.Errorf("no port info for reconciling shared rule for service %s", service.Name)
 		}
+		if useSharedSecurityRule(service) {
+			klog.V(2).Infof("reconcileSecurityGroup(%s): service uses shared rule and has no ports, keeping the shared rules", serviceName)
+		}
 		ports = []v1.ServicePort{}
 	}
 
@@ -3056,9 +3410,11 @@ func (az *Cloud) getExpectedSecurityRules(wantLb bool, ports []v1.ServicePort, s
 					},
 				}
 
-				if len(destinationIPAddresses) == 1 && disableFloatingIP {
+				if disableFloatingIP && len(backendIPAddresses) > 0 {
+					// without floating IP, the traffic is destined to the node ports of the backend nodes, so that
+					// the rule only allows it to the nodes backing the service, even with additional public IPs.
 					nsgRule.DestinationAddressPrefixes = &(backendIPAddresses)
-				} else if len(destinationIPAddresses) == 1 && !disableFloatingIP {
+				} else if len(destinationIPAddresses) == 1 {
 					// continue to use DestinationAddressPrefix to avoid NSG updates for existing rules.
 					nsgRule.DestinationAddressPrefix = pointer.String(destinationIPAddresses[0])
 				} else {
@@ -3374,6 +3730,11 @@ func (az *Cloud) reconcilePublicIPs(clusterName string, service *v1.Service, lbN
 		if reconciledPIP != nil {
 			reconciledPIPs = append(reconciledPIPs, reconciledPIP)
 		}
+	} else if serviceOwnsAnyPublicIP(service, pipsV4, clusterName) {
+		// the IPv4 family is dropped from the Service, release its public IPs
+		if _, err := az.reconcilePublicIP(pipsV4, clusterName, service, lbName, false /* wantLb */, false); err != nil {
+			return reconciledPIPs, err
+		}
 	}
 	if v6Enabled {
 		reconciledPIP, err := az.reconcilePublicIP(pipsV6, clusterName, service, lbName, wantLb, true)
@@ -3383,10 +3744,25 @@ func (az *Cloud) reconcilePublicIPs(clusterName string, service *v1.Service, lbN
 		if reconciledPIP != nil {
 			reconciledPIPs = append(reconciledPIPs, reconciledPIP)
 		}
+	} else if serviceOwnsAnyPublicIP(service, pipsV6, clusterName) {
+		// the IPv6 family is dropped from the Service, release its public IPs
+		if _, err := az.reconcilePublicIP(pipsV6, clusterName, service, lbName, false /* wantLb */, true); err != nil {
+			return reconciledPIPs, err
+		}
 	}
 	return reconciledPIPs, nil
 }
 
+// serviceOwnsAnyPublicIP returns true if any of the public IPs is owned by the service.
+func serviceOwnsAnyPublicIP(service *v1.Service, pips []network.PublicIPAddress, clusterName string) bool {
+	for i := range pips {
+		if owns, _ := serviceOwnsPublicIP(service, &pips[i], clusterName); owns {
+			return true
+		}
+	}
+	return false
+}
+
 // reconcilePublicIP reconciles the PublicIP resources similar to how the LB is reconciled with the specified IP family.
 func (az *Cloud) reconcilePublicIP(pips []network.PublicIPAddress, clusterName string, service *v1.Service, lbName string, wantLb, isIPv6 bool) (*network.PublicIPAddress, error) {
 	isInternal := requiresInternalLoadBalancer(service)
@@ -3675,6 +4051,40 @@ func equalLoadBalancingRulePropertiesFormat(s *network.LoadBalancingRuleProperti
 	return properties
 }
 
+// findOutboundRule returns true if there is an outbound rule of the same name, protocol, frontend IP
+// configurations, backend pool, TCP reset and idle timeout in the rules.
+func findOutboundRule(rules []network.OutboundRule, rule network.OutboundRule) bool {
+	for _, existingRule := range rules {
+		if !strings.EqualFold(pointer.StringDeref(existingRule.Name, ""), pointer.StringDeref(rule.Name, "")) {
+			continue
+		}
+		if existingRule.OutboundRulePropertiesFormat == nil || rule.OutboundRulePropertiesFormat == nil {
+			return existingRule.OutboundRulePropertiesFormat == rule.OutboundRulePropertiesFormat
+		}
+		if existingRule.Protocol != rule.Protocol ||
+			!equalSubResource(existingRule.BackendAddressPool, rule.BackendAddressPool) ||
+			!reflect.DeepEqual(getSubResourceIDs(existingRule.FrontendIPConfigurations), getSubResourceIDs(rule.FrontendIPConfigurations)) ||
+			pointer.BoolDeref(existingRule.EnableTCPReset, false) != pointer.BoolDeref(rule.EnableTCPReset, false) {
+			return false
+		}
+		// the idle timeout defaults to 4 minutes if not set
+		return pointer.Int32Deref(existingRule.IdleTimeoutInMinutes, 4) == pointer.Int32Deref(rule.IdleTimeoutInMinutes, 4)
+	}
+	return false
+}
+
+// getSubResourceIDs returns the sorted lower-case IDs of the sub resources.
+func getSubResourceIDs(subResources *[]network.SubResource) []string {
+	var ids []string
+	if subResources != nil {
+		for _, subResource := range *subResources {
+			ids = append(ids, strings.ToLower(pointer.StringDeref(subResource.ID, "")))
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 func equalSubResource(s *network.SubResource, t *network.SubResource) bool {
 	if s == nil && t == nil {
 		return true
@@ -3737,6 +4147,10 @@ func (az *Cloud) getPublicIPAddressResourceGroup(service *v1.Service) string {
 }
 
 func (az *Cloud) isBackendPoolPreConfigured(service *v1.Service) bool {
+	if az.DisableBackendPoolManagement {
+		return true
+	}
+
 	preConfigured := false
 	isInternal := requiresInternalLoadBalancer(service)
 
@@ -3765,6 +4179,10 @@ func requiresInternalLoadBalancer(service *v1.Service) bool {
 func getInternalSubnet(service *v1.Service) *string {
 	if requiresInternalLoadBalancer(service) {
 		if l, found := service.Annotations[consts.ServiceAnnotationLoadBalancerInternalSubnet]; found && strings.TrimSpace(l) != "" {
+			// the subnet may be specified by its resource ID, whose virtual network is checked by validateInternalSubnet
+			if matches := subnetIDRE.FindStringSubmatch(strings.TrimSpace(l)); len(matches) == 5 {
+				return &matches[4]
+			}
 			return &l
 		}
 	}
@@ -3772,6 +4190,30 @@ func getInternalSubnet(service *v1.Service) *string {
 	return nil
 }
 
+// validateInternalSubnet checks the subnet specified by its resource ID in the internal subnet annotation is
+// in the configured virtual network, since the subnets of the internal load balancers are looked up there.
+func (az *Cloud) validateInternalSubnet(service *v1.Service) error {
+	if !requiresInternalLoadBalancer(service) {
+		return nil
+	}
+	subnetID := strings.TrimSpace(service.Annotations[consts.ServiceAnnotationLoadBalancerInternalSubnet])
+	matches := subnetIDRE.FindStringSubmatch(subnetID)
+	if len(matches) != 5 {
+		return nil
+	}
+
+	vnetResourceGroup := az.VnetResourceGroup
+	if vnetResourceGroup == "" {
+		vnetResourceGroup = az.ResourceGroup
+	}
+	if strings.EqualFold(matches[2], vnetResourceGroup) && strings.EqualFold(matches[3], az.VnetName) {
+		return nil
+	}
+	msg := fmt.Sprintf("the internal subnet %s is not in the virtual network %s of the resource group %s", subnetID, az.VnetName, vnetResourceGroup)
+	az.Event(service, v1.EventTypeWarning, "InternalSubnetNotInVirtualNetwork", msg)
+	return fmt.Errorf("validateInternalSubnet(%s): %s", getServiceName(service), msg)
+}
+
 func ipInSubnet(ip string, subnet *network.Subnet) bool {
 	if subnet == nil || subnet.SubnetPropertiesFormat == nil {
 		return false
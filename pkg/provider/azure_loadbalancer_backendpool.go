@@ -38,8 +38,9 @@ import (
 )
 
 type BackendPool interface {
-	// EnsureHostsInPool ensures the nodes join the backend pool of the load balancer
-	EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID, vmSetName, clusterName, lbName string, backendPool network.BackendAddressPool) error
+	// EnsureHostsInPool ensures the nodes join the backend pool of the load balancer, and returns the number of the
+	// members added to or removed from it
+	EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID, vmSetName, clusterName, lbName string, backendPool network.BackendAddressPool) (int, error)
 
 	// CleanupVMSetFromBackendPoolByCondition removes nodes of the unwanted vmSet from the lb backend pool.
 	// This is needed in two scenarios:
@@ -66,7 +67,7 @@ func newBackendPoolTypeNodeIPConfig(c *Cloud) BackendPool {
 	return &backendPoolTypeNodeIPConfig{c}
 }
 
-func (bc *backendPoolTypeNodeIPConfig) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID, vmSetName, clusterName, lbName string, backendPool network.BackendAddressPool) error {
+func (bc *backendPoolTypeNodeIPConfig) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID, vmSetName, clusterName, lbName string, backendPool network.BackendAddressPool) (int, error) {
 	return bc.VMSet.EnsureHostsInPool(service, nodes, backendPoolID, vmSetName)
 }
 
@@ -383,7 +384,7 @@ func newBackendPoolTypeNodeIP(c *Cloud) BackendPool {
 	return &backendPoolTypeNodeIP{c}
 }
 
-func (bi *backendPoolTypeNodeIP) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID, vmSetName, clusterName, lbName string, backendPool network.BackendAddressPool) error {
+func (bi *backendPoolTypeNodeIP) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID, vmSetName, clusterName, lbName string, backendPool network.BackendAddressPool) (int, error) {
 	isIPv6 := isBackendPoolIPv6(pointer.StringDeref(backendPool.Name, ""))
 	vnetResourceGroup := bi.ResourceGroup
 	if len(bi.VnetResourceGroup) > 0 {
@@ -408,11 +409,11 @@ func (bi *backendPoolTypeNodeIP) EnsureHostsInPool(service *v1.Service, nodes []
 					"service", key,
 					"previous load balancer", lbName,
 					"current load balancer", si.lbName)
-				return nil
+				return 0, nil
 			}
 			activeNodes, err = bi.getLocalServiceEndpointsNodeNames(service)
 			if err != nil {
-				return err
+				return 0, err
 			}
 		}
 		if activeNodes == nil {
@@ -449,7 +450,13 @@ func (bi *backendPoolTypeNodeIP) EnsureHostsInPool(service *v1.Service, nodes []
 				continue
 			}
 
+			// only the private IP of the same family as the backend pool is added,
+			// the nodes without such IP are skipped in dual-stack clusters
 			privateIP := getNodePrivateIPAddress(node, isIPv6)
+			if privateIP == "" {
+				klog.V(4).Infof("bi.EnsureHostsInPool: skipping node %s without private IP of the backend pool %s", node.Name, lbBackendPoolName)
+				continue
+			}
 			nodePrivateIPsSet.Insert(privateIP)
 
 			if bi.useMultipleStandardLoadBalancers() {
@@ -495,11 +502,12 @@ func (bi *backendPoolTypeNodeIP) EnsureHostsInPool(service *v1.Service, nodes []
 	if changed {
 		klog.V(2).Infof("bi.EnsureHostsInPool: updating backend pool %s of load balancer %s to add %d nodes and remove %d nodes", lbBackendPoolName, lbName, numOfAdd, numOfDelete)
 		if err := bi.CreateOrUpdateLBBackendPool(lbName, backendPool); err != nil {
-			return fmt.Errorf("bi.EnsureHostsInPool: failed to update backend pool %s: %w", lbBackendPoolName, err)
+			return 0, fmt.Errorf("bi.EnsureHostsInPool: failed to update backend pool %s: %w", lbBackendPoolName, err)
 		}
+		return numOfAdd + numOfDelete, nil
 	}
 
-	return nil
+	return 0, nil
 }
 
 func (bi *backendPoolTypeNodeIP) CleanupVMSetFromBackendPoolByCondition(slb *network.LoadBalancer, service *v1.Service, nodes []*v1.Node, clusterName string, shouldRemoveVMSetFromSLB func(string) bool) (*network.LoadBalancer, error) {
@@ -523,6 +531,9 @@ func (bi *backendPoolTypeNodeIP) CleanupVMSetFromBackendPoolByCondition(slb *net
 
 				if shouldRemoveVMSetFromSLB(vmSetName) {
 					privateIP := getNodePrivateIPAddress(node, isIPv6)
+					if privateIP == "" {
+						continue
+					}
 					klog.V(4).Infof("bi.CleanupVMSetFromBackendPoolByCondition: removing ip %s from the backend pool %s", privateIP, lbBackendPoolNames[isIPv6])
 					vmIPsToBeDeleted.Insert(privateIP)
 				}
@@ -866,3 +877,85 @@ func removeNodeIPAddressesFromBackendPool(
 
 	return changed
 }
+
+// BackendPoolAttribution records the cluster and the Service a backend pool is created for. Backend pools
+// do not support tags, so the attribution is kept in memory for auditing.
+type BackendPoolAttribution struct {
+	LoadBalancerName string
+	BackendPoolName  string
+	ClusterName      string
+	// ServiceName is the namespaced name of the Service owning the backend pool. It is empty
+	// for the backend pools shared by the Services of the cluster.
+	ServiceName string
+}
+
+// recordBackendPoolAttributions records the attribution of the backend pools of the service in the load balancer
+// which has been created or updated, and forgets the backend pools no longer in the load balancer.
+func (az *Cloud) recordBackendPoolAttributions(service *v1.Service, clusterName string, lb *network.LoadBalancer) {
+	lbName := pointer.StringDeref(lb.Name, "")
+	bpNames := sets.New[string]()
+	if lb.BackendAddressPools != nil {
+		for _, bp := range *lb.BackendAddressPools {
+			bpNames.Insert(strings.ToLower(pointer.StringDeref(bp.Name, "")))
+		}
+	}
+
+	az.backendPoolAttributions.Range(func(key, value interface{}) bool {
+		attribution := value.(*BackendPoolAttribution)
+		if strings.EqualFold(attribution.LoadBalancerName, lbName) && !bpNames.Has(strings.ToLower(attribution.BackendPoolName)) {
+			az.backendPoolAttributions.Delete(key)
+		}
+		return true
+	})
+
+	var owner string
+	if isLocalService(service) && az.useMultipleStandardLoadBalancers() {
+		owner = getServiceName(service)
+	}
+	for _, bpName := range az.getBackendPoolNamesForService(service, clusterName) {
+		if !bpNames.Has(strings.ToLower(bpName)) {
+			continue
+		}
+		az.backendPoolAttributions.Store(strings.ToLower(az.getBackendPoolID(lbName, bpName)), &BackendPoolAttribution{
+			LoadBalancerName: lbName,
+			BackendPoolName:  bpName,
+			ClusterName:      clusterName,
+			ServiceName:      owner,
+		})
+	}
+}
+
+// deleteBackendPoolAttribution forgets the attribution of the deleted backend pool.
+func (az *Cloud) deleteBackendPoolAttribution(lbName, bpName string) {
+	az.backendPoolAttributions.Delete(strings.ToLower(az.getBackendPoolID(lbName, bpName)))
+}
+
+// deleteBackendPoolAttributionsOfLB forgets the attribution of the backend pools of the deleted load balancer.
+func (az *Cloud) deleteBackendPoolAttributionsOfLB(lbName string) {
+	az.backendPoolAttributions.Range(func(key, value interface{}) bool {
+		if strings.EqualFold(value.(*BackendPoolAttribution).LoadBalancerName, lbName) {
+			az.backendPoolAttributions.Delete(key)
+		}
+		return true
+	})
+}
+
+// GetBackendPoolAttribution returns the attribution of the backend pool by its ID.
+// It returns false if the backend pool is not created or updated by this instance.
+func (az *Cloud) GetBackendPoolAttribution(backendPoolID string) (BackendPoolAttribution, bool) {
+	value, ok := az.backendPoolAttributions.Load(strings.ToLower(backendPoolID))
+	if !ok {
+		return BackendPoolAttribution{}, false
+	}
+	return *value.(*BackendPoolAttribution), true
+}
+
+// ListBackendPoolAttributions returns the attributions of the recorded backend pools keyed by the lower-case backend pool IDs.
+func (az *Cloud) ListBackendPoolAttributions() map[string]BackendPoolAttribution {
+	attributions := make(map[string]BackendPoolAttribution)
+	az.backendPoolAttributions.Range(func(key, value interface{}) bool {
+		attributions[key.(string)] = *value.(*BackendPoolAttribution)
+		return true
+	})
+	return attributions
+}
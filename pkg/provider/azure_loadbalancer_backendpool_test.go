@@ -432,13 +432,103 @@ func TestEnsureHostsInPoolNodeIP(t *testing.T) {
 			if tc.namespace != "" {
 				service.Namespace = tc.namespace
 			}
-			err := bi.EnsureHostsInPool(&service, nodes, "", "", "kubernetes", "kubernetes", tc.backendPool)
+			_, err := bi.EnsureHostsInPool(&service, nodes, "", "", "kubernetes", "kubernetes", tc.backendPool)
 			assert.NoError(t, err)
 			assert.Equal(t, tc.expectedBackendPool, tc.backendPool)
 		})
 	}
 }
 
+func TestEnsureHostsInPoolNodeIPDualStack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "vmss-0",
+			},
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{
+					{
+						Type:    v1.NodeInternalIP,
+						Address: "2001::2",
+					},
+					{
+						Type:    v1.NodeInternalIP,
+						Address: "10.0.0.2",
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "vmss-1",
+			},
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{
+					{
+						Type:    v1.NodeInternalIP,
+						Address: "10.0.0.1",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		desc                string
+		backendPoolName     string
+		expectedIPAddresses map[string]string
+	}{
+		{
+			desc:            "IPv4 backend pool should only have the IPv4 addresses of the nodes",
+			backendPoolName: "kubernetes",
+			expectedIPAddresses: map[string]string{
+				"vmss-0": "10.0.0.2",
+				"vmss-1": "10.0.0.1",
+			},
+		},
+		{
+			desc:            "IPv6 backend pool should only have the IPv6 addresses of the nodes",
+			backendPoolName: "kubernetes-IPv6",
+			expectedIPAddresses: map[string]string{
+				"vmss-0": "2001::2",
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			az.LoadBalancerSku = consts.LoadBalancerSkuStandard
+			az.nodePrivateIPToNodeNameMap = map[string]string{
+				"10.0.0.2": "vmss-0",
+				"2001::2":  "vmss-0",
+				"10.0.0.1": "vmss-1",
+			}
+			bi := newBackendPoolTypeNodeIP(az)
+
+			lbClient := mockloadbalancerclient.NewMockInterface(ctrl)
+			lbClient.EXPECT().CreateOrUpdateBackendPools(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			az.LoadBalancerClient = lbClient
+
+			backendPool := network.BackendAddressPool{
+				Name:                               pointer.String(tc.backendPoolName),
+				BackendAddressPoolPropertiesFormat: &network.BackendAddressPoolPropertiesFormat{},
+			}
+			service := getTestServiceDualStack("svc-1", v1.ProtocolTCP, nil, 80)
+			changedMembers, err := bi.EnsureHostsInPool(&service, nodes, "", "", "kubernetes", "kubernetes", backendPool)
+			assert.NoError(t, err)
+			assert.Equal(t, len(*backendPool.LoadBalancerBackendAddresses), changedMembers, "all the addresses should be counted as added")
+
+			ipAddresses := map[string]string{}
+			for _, address := range *backendPool.LoadBalancerBackendAddresses {
+				ipAddresses[pointer.StringDeref(address.Name, "")] = pointer.StringDeref(address.IPAddress, "")
+			}
+			assert.Equal(t, tc.expectedIPAddresses, ipAddresses)
+		})
+	}
+}
+
 func TestIsLBBackendPoolsExisting(t *testing.T) {
 	testcases := []struct {
 		desc               string
@@ -1136,3 +1226,64 @@ func TestGetBackendIPConfigurationsToBeDeleted(t *testing.T) {
 		assert.Equal(t, tc.expected, actual)
 	}
 }
+
+func TestBackendPoolAttributions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cloud := GetTestCloud(ctrl)
+	cloud.LoadBalancerSku = consts.LoadBalancerSkuStandard
+	cloud.MultipleStandardLoadBalancerConfigurations = []MultipleStandardLoadBalancerConfiguration{{Name: "lb1"}}
+	lbClient := mockloadbalancerclient.NewMockInterface(ctrl)
+	cloud.LoadBalancerClient = lbClient
+
+	clusterPoolID := cloud.getBackendPoolID("lb1", "kubernetes")
+	localPoolID := cloud.getBackendPoolID("lb1", "default-test")
+	lb := network.LoadBalancer{
+		Name: pointer.String("lb1"),
+		LoadBalancerPropertiesFormat: &network.LoadBalancerPropertiesFormat{
+			BackendAddressPools: &[]network.BackendAddressPool{
+				{Name: pointer.String("kubernetes")},
+				{Name: pointer.String("default-test")},
+				{Name: pointer.String("unmanaged")},
+			},
+		},
+	}
+
+	// the shared backend pool is attributed to the cluster
+	clusterSvc := getTestService("cluster", v1.ProtocolTCP, nil, false, 80)
+	cloud.recordBackendPoolAttributions(&clusterSvc, "kubernetes", &lb)
+	attribution, ok := cloud.GetBackendPoolAttribution(strings.ToUpper(clusterPoolID))
+	assert.True(t, ok)
+	assert.Equal(t, BackendPoolAttribution{LoadBalancerName: "lb1", BackendPoolName: "kubernetes", ClusterName: "kubernetes"}, attribution)
+
+	// the backend pool of a local service is attributed to the service
+	localSvc := getTestService("test", v1.ProtocolTCP, nil, false, 80)
+	localSvc.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyLocal
+	cloud.recordBackendPoolAttributions(&localSvc, "kubernetes", &lb)
+	attribution, ok = cloud.GetBackendPoolAttribution(localPoolID)
+	assert.True(t, ok)
+	assert.Equal(t, BackendPoolAttribution{LoadBalancerName: "lb1", BackendPoolName: "default-test", ClusterName: "kubernetes", ServiceName: "default/test"}, attribution)
+	assert.Len(t, cloud.ListBackendPoolAttributions(), 2, "the unmanaged backend pool should not be attributed")
+
+	// the backend pool removed from the load balancer is forgotten
+	lb.BackendAddressPools = &[]network.BackendAddressPool{{Name: pointer.String("kubernetes")}}
+	cloud.recordBackendPoolAttributions(&clusterSvc, "kubernetes", &lb)
+	_, ok = cloud.GetBackendPoolAttribution(localPoolID)
+	assert.False(t, ok)
+
+	// the deleted backend pool is forgotten
+	lb.BackendAddressPools = &[]network.BackendAddressPool{{Name: pointer.String("kubernetes")}, {Name: pointer.String("default-test")}}
+	cloud.recordBackendPoolAttributions(&localSvc, "kubernetes", &lb)
+	lbClient.EXPECT().DeleteLBBackendPool(gomock.Any(), gomock.Any(), "lb1", "default-test").Return(nil)
+	assert.NoError(t, cloud.DeleteLBBackendPool("lb1", "default-test"))
+	_, ok = cloud.GetBackendPoolAttribution(localPoolID)
+	assert.False(t, ok)
+	_, ok = cloud.GetBackendPoolAttribution(clusterPoolID)
+	assert.True(t, ok)
+
+	// the backend pools of the deleted load balancer are forgotten
+	lbClient.EXPECT().Delete(gomock.Any(), gomock.Any(), "lb1").Return(nil)
+	assert.Nil(t, cloud.DeleteLB(&clusterSvc, "lb1"))
+	assert.Empty(t, cloud.ListBackendPoolAttributions())
+}
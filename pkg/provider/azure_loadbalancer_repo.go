@@ -47,11 +47,12 @@ func (az *Cloud) DeleteLB(service *v1.Service, lbName string) *retry.Error {
 	if rerr == nil {
 		// Invalidate the cache right after updating
 		_ = az.lbCache.Delete(lbName)
+		az.deleteBackendPoolAttributionsOfLB(lbName)
 		return nil
 	}
 
-	klog.Errorf("LoadBalancerClient.Delete(%s) failed: %s", lbName, rerr.Error().Error())
-	az.Event(service, v1.EventTypeWarning, "DeleteLoadBalancer", rerr.Error().Error())
+	klog.Errorf("LoadBalancerClient.Delete(%s) failed: %s", lbName, rerr.ErrorWithCorrelationID().Error())
+	az.Event(service, v1.EventTypeWarning, "DeleteLoadBalancer", rerr.ErrorWithCorrelationID().Error())
 	return rerr
 }
 
@@ -66,9 +67,9 @@ func (az *Cloud) ListLB(service *v1.Service) ([]network.LoadBalancer, error) {
 		if rerr.IsNotFound() {
 			return nil, nil
 		}
-		az.Event(service, v1.EventTypeWarning, "ListLoadBalancers", rerr.Error().Error())
-		klog.Errorf("LoadBalancerClient.List(%v) failure with err=%v", rgName, rerr)
-		return nil, rerr.Error()
+		az.Event(service, v1.EventTypeWarning, "ListLoadBalancers", rerr.ErrorWithCorrelationID().Error())
+		klog.Errorf("LoadBalancerClient.List(%v) failure with err=%v", rgName, rerr.ErrorWithCorrelationID())
+		return nil, rerr.ErrorWithCorrelationID()
 	}
 	klog.V(2).Infof("LoadBalancerClient.List(%v) success", rgName)
 	return allLBs, nil
@@ -147,7 +148,7 @@ func (az *Cloud) CreateOrUpdateLB(service *v1.Service, lb network.LoadBalancer)
 	}
 
 	lbJSON, _ := json.Marshal(lb)
-	klog.Warningf("LoadBalancerClient.CreateOrUpdate(%s) failed: %v, LoadBalancer request: %s", pointer.StringDeref(lb.Name, ""), rerr.Error(), string(lbJSON))
+	klog.Warningf("LoadBalancerClient.CreateOrUpdate(%s) failed: %v, LoadBalancer request: %s", pointer.StringDeref(lb.Name, ""), rerr.ErrorWithCorrelationID(), string(lbJSON))
 
 	// Invalidate the cache because ETAG precondition mismatch.
 	if rerr.HTTPStatusCode == http.StatusPreconditionFailed {
@@ -167,27 +168,27 @@ func (az *Cloud) CreateOrUpdateLB(service *v1.Service, lb network.LoadBalancer)
 		matches := pipErrorMessageRE.FindStringSubmatch(retryErrorMessage)
 		if len(matches) != 3 {
 			klog.Errorf("Failed to parse the retry error message %s", retryErrorMessage)
-			return rerr.Error()
+			return rerr.ErrorWithCorrelationID()
 		}
 		pipRG, pipName := matches[1], matches[2]
 		klog.V(3).Infof("The public IP %s referenced by load balancer %s is not in Succeeded provisioning state, will try to update it", pipName, pointer.StringDeref(lb.Name, ""))
 		pip, _, err := az.getPublicIPAddress(pipRG, pipName, azcache.CacheReadTypeDefault)
 		if err != nil {
 			klog.Errorf("Failed to get the public IP %s in resource group %s: %v", pipName, pipRG, err)
-			return rerr.Error()
+			return rerr.ErrorWithCorrelationID()
 		}
 		// Perform a dummy update to fix the provisioning state
 		err = az.CreateOrUpdatePIP(service, pipRG, pip)
 		if err != nil {
 			klog.Errorf("Failed to update the public IP %s in resource group %s: %v", pipName, pipRG, err)
-			return rerr.Error()
+			return rerr.ErrorWithCorrelationID()
 		}
 		// Invalidate the LB cache, return the error, and the controller manager
 		// would retry the LB update in the next reconcile loop
 		_ = az.lbCache.Delete(*lb.Name)
 	}
 
-	return rerr.Error()
+	return rerr.ErrorWithCorrelationID()
 }
 
 func (az *Cloud) CreateOrUpdateLBBackendPool(lbName string, backendPool network.BackendAddressPool) error {
@@ -215,7 +216,7 @@ func (az *Cloud) CreateOrUpdateLBBackendPool(lbName string, backendPool network.
 		_ = az.lbCache.Delete(lbName)
 	}
 
-	return rerr.Error()
+	return rerr.ErrorWithCorrelationID()
 }
 
 func (az *Cloud) DeleteLBBackendPool(lbName, backendPoolName string) error {
@@ -227,6 +228,7 @@ func (az *Cloud) DeleteLBBackendPool(lbName, backendPoolName string) error {
 	if rerr == nil {
 		// Invalidate the cache right after updating
 		_ = az.lbCache.Delete(lbName)
+		az.deleteBackendPoolAttribution(lbName, backendPoolName)
 		return nil
 	}
 
@@ -243,7 +245,7 @@ func (az *Cloud) DeleteLBBackendPool(lbName, backendPoolName string) error {
 		_ = az.lbCache.Delete(lbName)
 	}
 
-	return rerr.Error()
+	return rerr.ErrorWithCorrelationID()
 }
 
 func cleanupSubnetInFrontendIPConfigurations(lb *network.LoadBalancer) network.LoadBalancer {
@@ -26,6 +26,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 
 	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/loadbalancerclient/mockloadbalancerclient"
@@ -47,6 +48,32 @@ func TestDeleteLB(t *testing.T) {
 	assert.EqualError(t, fmt.Errorf("Retriable: false, RetryAfter: 0s, HTTPStatusCode: 500, RawError: %w", error(nil)), fmt.Sprintf("%s", err.Error()))
 }
 
+func TestLBErrorsWithCorrelationID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	recorder := record.NewFakeRecorder(10)
+	az.eventRecorder = recorder
+	clientErr := &retry.Error{HTTPStatusCode: http.StatusInternalServerError, RawError: fmt.Errorf("internal error"), CorrelationID: "correlation"}
+
+	mockLBClient := az.LoadBalancerClient.(*mockloadbalancerclient.MockInterface)
+	mockLBClient.EXPECT().Delete(gomock.Any(), az.ResourceGroup, "lb").Return(clientErr)
+	mockLBClient.EXPECT().List(gomock.Any(), az.ResourceGroup).Return(nil, clientErr)
+	mockLBClient.EXPECT().CreateOrUpdate(gomock.Any(), az.ResourceGroup, "lb", gomock.Any(), gomock.Any()).Return(clientErr)
+
+	rerr := az.DeleteLB(&v1.Service{}, "lb")
+	assert.Equal(t, "correlation", rerr.CorrelationID)
+	assert.Contains(t, <-recorder.Events, "CorrelationID: correlation")
+
+	_, err := az.ListLB(&v1.Service{})
+	assert.ErrorContains(t, err, "CorrelationID: correlation")
+	assert.Contains(t, <-recorder.Events, "CorrelationID: correlation")
+
+	err = az.CreateOrUpdateLB(&v1.Service{}, network.LoadBalancer{Name: pointer.String("lb")})
+	assert.ErrorContains(t, err, "CorrelationID: correlation")
+}
+
 func TestListManagedLBs(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -40,7 +40,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 
 	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/loadbalancerclient/mockloadbalancerclient"
@@ -668,12 +670,88 @@ func TestSubnet(t *testing.T) {
 			},
 			expected: pointer.String("subnet"),
 		},
+		{
+			desc: "annotation with subnet ID and ILB should return the subnet name",
+			service: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						consts.ServiceAnnotationLoadBalancerInternalSubnet: "/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+						consts.ServiceAnnotationLoadBalancerInternal:       "true",
+					},
+				},
+			},
+			expected: pointer.String("subnet"),
+		},
 	} {
 		real := getInternalSubnet(c.service)
 		assert.Equal(t, c.expected, real, fmt.Sprintf("TestCase[%d]: %s", i, c.desc))
 	}
 }
 
+func TestValidateInternalSubnet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		desc          string
+		subnet        string
+		expectedEvent bool
+	}{
+		{
+			desc:   "the subnet name should be valid",
+			subnet: "subnet",
+		},
+		{
+			desc:   "the subnet ID in the configured virtual network should be valid",
+			subnet: "/subscriptions/subscription/resourceGroups/RG/providers/Microsoft.Network/virtualNetworks/VNET/subnets/subnet",
+		},
+		{
+			desc:          "the subnet ID in another virtual network should be invalid",
+			subnet:        "/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/othervnet/subnets/subnet",
+			expectedEvent: true,
+		},
+		{
+			desc:          "the subnet ID in the virtual network of the same name in another resource group should be invalid",
+			subnet:        "/subscriptions/subscription/resourceGroups/otherrg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			expectedEvent: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			recorder := record.NewFakeRecorder(10)
+			az.eventRecorder = recorder
+			svc := getInternalTestService("test", 80)
+			svc.Annotations[consts.ServiceAnnotationLoadBalancerInternalSubnet] = tc.subnet
+
+			err := az.validateInternalSubnet(&svc)
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			if tc.expectedEvent {
+				assert.ErrorContains(t, err, "is not in the virtual network vnet of the resource group rg")
+				assert.Len(t, events, 1)
+				assert.Contains(t, events[0], "InternalSubnetNotInVirtualNetwork")
+			} else {
+				assert.NoError(t, err)
+				assert.Empty(t, events)
+			}
+		})
+	}
+
+	// the cross-vnet subnet fails the reconciliation before looking it up in the configured virtual network
+	az := GetTestCloud(ctrl)
+	az.LoadBalancerSku = consts.LoadBalancerSkuStandard
+	svc := getInternalTestService("test", 80)
+	svc.Annotations[consts.ServiceAnnotationLoadBalancerInternalSubnet] = "/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/othervnet/subnets/subnet"
+	lb := getTestLoadBalancer(pointer.String("lb-internal"), pointer.String("rg"), pointer.String("testCluster"), pointer.String("testCluster"), svc, consts.LoadBalancerSkuStandard)
+	_, _, _, err := az.reconcileFrontendIPConfigs("testCluster", &svc, &lb, nil, true, map[bool]string{false: "fip"})
+	assert.ErrorContains(t, err, "validateInternalSubnet(default/test)")
+}
+
 func TestEnsureLoadBalancerDeleted(t *testing.T) {
 	const vmCount = 8
 	const availabilitySetCount = 4
@@ -728,7 +806,7 @@ func TestEnsureLoadBalancerDeleted(t *testing.T) {
 	az := GetTestCloud(ctrl)
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 	mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 	clusterResources, expectedInterfaces, expectedVirtualMachines := getClusterResources(az, vmCount, availabilitySetCount)
@@ -1656,6 +1734,76 @@ func TestGetServiceTags(t *testing.T) {
 	}
 }
 
+func TestGetLoadBalancerNameForService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	existingLBs := []network.LoadBalancer{
+		{
+			Name: pointer.String("lb1-internal"),
+			LoadBalancerPropertiesFormat: &network.LoadBalancerPropertiesFormat{
+				FrontendIPConfigurations: &[]network.FrontendIPConfiguration{
+					{
+						Name: pointer.String("atest2"),
+						FrontendIPConfigurationPropertiesFormat: &network.FrontendIPConfigurationPropertiesFormat{
+							PrivateIPAddress: pointer.String("1.2.3.5"),
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: pointer.String("lb2-internal"),
+			LoadBalancerPropertiesFormat: &network.LoadBalancerPropertiesFormat{
+				FrontendIPConfigurations: &[]network.FrontendIPConfiguration{
+					{
+						Name: pointer.String("atest1"),
+						FrontendIPConfigurationPropertiesFormat: &network.FrontendIPConfigurationPropertiesFormat{
+							PrivateIPAddress: pointer.String("1.2.3.4"),
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: pointer.String("lb3-internal"),
+		},
+	}
+
+	for _, tc := range []struct {
+		description    string
+		service        v1.Service
+		expectedLBName string
+		expectedError  error
+	}{
+		{
+			description:    "should return the load balancer the service is placed on",
+			service:        getInternalTestService("test1"),
+			expectedLBName: "lb2-internal",
+		},
+		{
+			description:   "should return an error if the service is not placed on any load balancer",
+			service:       getInternalTestService("test3"),
+			expectedError: ErrServiceNotPlacedOnLoadBalancer,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			az.MultipleStandardLoadBalancerConfigurations = []MultipleStandardLoadBalancerConfiguration{
+				{Name: "lb1"},
+				{Name: "lb2"},
+				{Name: "lb3"},
+			}
+			mockLBClient := az.LoadBalancerClient.(*mockloadbalancerclient.MockInterface)
+			mockLBClient.EXPECT().List(gomock.Any(), "rg").Return(existingLBs, nil)
+
+			lbName, err := az.GetLoadBalancerNameForService(&tc.service)
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedLBName, lbName)
+		})
+	}
+}
+
 func TestGetServiceLoadBalancerMultiSLB(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -2254,6 +2402,21 @@ func TestIsFrontendIPChanged(t *testing.T) {
 			expectedFlag:  true,
 			expectedError: false,
 		},
+		{
+			desc: "isFrontendIPChanged shall return true if the service is internal and the subnet of the config is deleted",
+			config: network.FrontendIPConfiguration{
+				Name: pointer.String("btest1-name"),
+				FrontendIPConfigurationPropertiesFormat: &network.FrontendIPConfigurationPropertiesFormat{
+					Subnet: &network.Subnet{
+						ID: pointer.String("/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/deletedSubnet"),
+					},
+				},
+			},
+			lbFrontendIPConfigName: "btest1-name",
+			service:                getInternalTestService("test1", 80),
+			expectedFlag:           true,
+			expectedError:          false,
+		},
 	}
 
 	for _, test := range testCases {
@@ -2261,6 +2424,7 @@ func TestIsFrontendIPChanged(t *testing.T) {
 			az := GetTestCloud(ctrl)
 			mockSubnetsClient := az.SubnetsClient.(*mocksubnetclient.MockInterface)
 			mockSubnetsClient.EXPECT().Get(gomock.Any(), "rg", "vnet", "testSubnet", "").Return(test.existingSubnet, nil).AnyTimes()
+			mockSubnetsClient.EXPECT().Get(gomock.Any(), "rg", "vnet", "deletedSubnet", "").Return(network.Subnet{}, &retry.Error{HTTPStatusCode: http.StatusNotFound}).AnyTimes()
 			mockSubnetsClient.EXPECT().CreateOrUpdate(gomock.Any(), "rg", "vnet", "testSubnet", test.existingSubnet).Return(nil)
 			err := az.SubnetsClient.CreateOrUpdate(context.TODO(), "rg", "vnet", "testSubnet", test.existingSubnet)
 			if err != nil {
@@ -2673,6 +2837,24 @@ func TestReconcileLoadBalancerRuleCommon(t *testing.T) {
 			expectedRules:   getTCPResetTestRules(false),
 			expectedProbes:  getDefaultTestProbes("Tcp", ""),
 		},
+		{
+			desc: "getExpectedLBRules should enable tcp reset when annotation is set to true",
+			service: getTestServiceDualStack("test1", v1.ProtocolTCP, map[string]string{
+				consts.ServiceAnnotationEnableTCPReset: "true",
+			}, 80),
+			loadBalancerSku: "standard",
+			expectedRules:   getTCPResetTestRules(true),
+			expectedProbes:  getDefaultTestProbes("Tcp", ""),
+		},
+		{
+			desc: "getExpectedLBRules should disable tcp reset when annotation is set to false",
+			service: getTestServiceDualStack("test1", v1.ProtocolTCP, map[string]string{
+				consts.ServiceAnnotationEnableTCPReset: "false",
+			}, 80),
+			loadBalancerSku: "standard",
+			expectedRules:   getTCPResetTestRules(false),
+			expectedProbes:  getDefaultTestProbes("Tcp", ""),
+		},
 		{
 			desc: "getExpectedLBRules should prioritize port specific probe protocol over appProtocol",
 			service: getTestServiceDualStack("test1", v1.ProtocolTCP, map[string]string{
@@ -2988,6 +3170,23 @@ func TestReconcileLoadBalancerRuleCommon(t *testing.T) {
 	}
 }
 
+func TestGetExpectedLBRulesHAModeWithoutPorts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.Config.LoadBalancerSku = consts.LoadBalancerSkuStandard
+	service := getTestServiceDualStack("test1", v1.ProtocolTCP, map[string]string{
+		consts.ServiceAnnotationLoadBalancerEnableHighAvailabilityPorts: "true",
+		consts.ServiceAnnotationLoadBalancerInternal:                    "true",
+	})
+
+	probes, rules, err := az.getExpectedLBRules(&service, "frontendIPConfigID", "backendPoolID", "lbname", consts.IPVersionIPv4)
+	assert.NoError(t, err)
+	assert.Empty(t, probes)
+	assert.Empty(t, rules)
+}
+
 // getDefaultTestRules returns dualstack rules.
 func getDefaultTestRules(enableTCPReset bool) map[bool][]network.LoadBalancingRule {
 	return map[bool][]network.LoadBalancingRule{
@@ -3886,7 +4085,7 @@ func TestReconcileLoadBalancerCommon(t *testing.T) {
 				mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, true, nil)
 			}
 			mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-			mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 			lb, rerr := az.reconcileLoadBalancer("testCluster", &service, clusterResources.nodes, test.wantLb)
 			assert.Equal(t, test.expectedError, rerr)
@@ -3898,6 +4097,147 @@ func TestReconcileLoadBalancerCommon(t *testing.T) {
 	}
 }
 
+func TestReconcileLoadBalancerDisableBackendPoolManagement(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.Config.LoadBalancerSku = "basic"
+	az.DisableBackendPoolManagement = true
+	az.LoadBalancerBackendPool = newBackendPoolTypeNodeIPConfig(az)
+
+	clusterResources, expectedInterfaces, expectedVirtualMachines := getClusterResources(az, 3, 3)
+	setMockEnvDualStack(az, ctrl, expectedInterfaces, expectedVirtualMachines, 1)
+
+	service := getTestServiceDualStack("service1", v1.ProtocolTCP, nil, 80)
+	existingLB := getTestLoadBalancerDualStack(pointer.String("testCluster"), pointer.String("rg"), pointer.String("testCluster"), pointer.String("aservice1"), service, "Basic")
+	existingLB.BackendAddressPools = nil
+
+	mockLBsClient := mockloadbalancerclient.NewMockInterface(ctrl)
+	mockLBsClient.EXPECT().List(gomock.Any(), az.getLoadBalancerResourceGroup()).Return([]network.LoadBalancer{existingLB}, nil)
+	mockLBsClient.EXPECT().Get(gomock.Any(), az.getLoadBalancerResourceGroup(), *existingLB.Name, gomock.Any()).Return(existingLB, nil).AnyTimes()
+	mockLBsClient.EXPECT().CreateOrUpdate(gomock.Any(), az.getLoadBalancerResourceGroup(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _, _ string, lb network.LoadBalancer, _ string) *retry.Error {
+			assert.Nil(t, lb.BackendAddressPools, "backend pools should not be created")
+			return nil
+		}).AnyTimes()
+	az.LoadBalancerClient = mockLBsClient
+
+	lb, err := az.reconcileLoadBalancer("testCluster", &service, clusterResources.nodes, true)
+	assert.NoError(t, err)
+	assert.Nil(t, lb.BackendAddressPools)
+	assert.NotEmpty(t, *lb.LoadBalancingRules)
+	assert.True(t, az.isBackendPoolPreConfigured(&service))
+}
+
+func TestReconcileLoadBalancerSummary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := getTestServiceDualStack("service1", v1.ProtocolTCP, nil, 80)
+	unchangedLB := getTestLoadBalancerDualStack(pointer.String("testCluster"), pointer.String("rg"), pointer.String("testCluster"), pointer.String("aservice1"), service, "Basic")
+	lbWithoutRules := getTestLoadBalancerDualStack(pointer.String("testCluster"), pointer.String("rg"), pointer.String("testCluster"), pointer.String("aservice1"), service, "Basic")
+	lbWithoutRules.LoadBalancingRules = &[]network.LoadBalancingRule{}
+
+	testCases := []struct {
+		desc            string
+		existingLB      network.LoadBalancer
+		changedMembers  int
+		expectedSummary string
+		expectedEmpty   bool
+	}{
+		{
+			desc:            "should summarize no changes if the load balancer is up to date",
+			existingLB:      unchangedLB,
+			expectedSummary: "0 frontend IP configurations, 0 rules and 0 probes changed, 0 backend pool members added or removed",
+			expectedEmpty:   true,
+		},
+		{
+			desc:            "should summarize the rules created",
+			existingLB:      lbWithoutRules,
+			expectedSummary: "0 frontend IP configurations, 2 rules and 0 probes changed, 0 backend pool members added or removed",
+		},
+		{
+			desc:            "should summarize the members changed by EnsureHostsInPool of both backend pools",
+			existingLB:      unchangedLB,
+			changedMembers:  2,
+			expectedSummary: "0 frontend IP configurations, 0 rules and 0 probes changed, 4 backend pool members added or removed",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			az.Config.LoadBalancerSku = "basic"
+
+			clusterResources, expectedInterfaces, expectedVirtualMachines := getClusterResources(az, 3, 3)
+			setMockEnvDualStack(az, ctrl, expectedInterfaces, expectedVirtualMachines, 1)
+
+			mockLBsClient := mockloadbalancerclient.NewMockInterface(ctrl)
+			mockLBsClient.EXPECT().List(gomock.Any(), az.getLoadBalancerResourceGroup()).Return([]network.LoadBalancer{test.existingLB}, nil)
+			mockLBsClient.EXPECT().Get(gomock.Any(), az.getLoadBalancerResourceGroup(), *test.existingLB.Name, gomock.Any()).Return(test.existingLB, nil).AnyTimes()
+			mockLBsClient.EXPECT().CreateOrUpdate(gomock.Any(), az.getLoadBalancerResourceGroup(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			az.LoadBalancerClient = mockLBsClient
+
+			mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
+			mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
+			mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(test.changedMembers, nil).Times(2)
+
+			var summary lbReconcileSummary
+			_, err := az.reconcileLoadBalancerWithSummary("testCluster", &service, clusterResources.nodes, true, &summary)
+			assert.NoError(t, err)
+			assert.Equal(t, "testCluster", summary.lbName)
+			assert.Equal(t, test.expectedSummary, summary.String())
+			assert.Equal(t, test.expectedEmpty, summary.isEmpty())
+		})
+	}
+}
+
+func TestEnsureLoadBalancerReconciledEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	recorder := record.NewFakeRecorder(10)
+	az.eventRecorder = recorder
+	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
+	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).Times(1)
+	mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	clusterResources, expectedInterfaces, expectedVirtualMachines := getClusterResources(az, 1, 1)
+	setMockEnv(az, ctrl, expectedInterfaces, expectedVirtualMachines, 1)
+
+	service := getTestService("service1", v1.ProtocolTCP, nil, false, 80)
+	expectedLBs := make([]network.LoadBalancer, 0)
+	setMockLBs(az, ctrl, &expectedLBs, "service", 1, 1, false)
+
+	mockPLSClient := mockprivatelinkserviceclient.NewMockInterface(ctrl)
+	mockPLSClient.EXPECT().List(gomock.Any(), az.Config.ResourceGroup).Return([]network.PrivateLinkService{}, nil).AnyTimes()
+	az.PrivateLinkServiceClient = mockPLSClient
+
+	_, err := az.EnsureLoadBalancer(context.TODO(), testClusterName, &service, clusterResources.nodes)
+	assert.NoError(t, err)
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	assert.Equal(t, []string{"Normal LoadBalancerReconciled Load balancer testCluster reconciled: 0 frontend IP configurations, 2 rules and 1 probes changed, 0 backend pool members added or removed"}, events)
+
+	// the members changed by the node updates are summarized by UpdateLoadBalancer as well
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil).Times(1)
+	mockLBsClient := az.LoadBalancerClient.(*mockloadbalancerclient.MockInterface)
+	mockLBsClient.EXPECT().List(gomock.Any(), az.ResourceGroup).Return(expectedLBs, nil).AnyTimes()
+	mockLBsClient.EXPECT().Get(gomock.Any(), az.ResourceGroup, gomock.Any(), gomock.Any()).Return(expectedLBs[0], nil).AnyTimes()
+	client := fake.NewSimpleClientset(&service)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	az.serviceLister = informerFactory.Core().V1().Services().Lister()
+	assert.NoError(t, informerFactory.Core().V1().Services().Informer().GetIndexer().Add(&service))
+	err = az.UpdateLoadBalancer(context.TODO(), testClusterName, &service, clusterResources.nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, "Normal LoadBalancerReconciled Load balancer testCluster reconciled: 0 frontend IP configurations, 0 rules and 0 probes changed, 1 backend pool members added or removed", <-recorder.Events)
+}
+
 func TestGetServiceLoadBalancerStatus(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -4517,6 +4857,56 @@ func TestReconcileSecurityGroupCommon(t *testing.T) {
 	}
 }
 
+func TestReconcileSecurityGroupScopedToBackendNodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		desc                 string
+		backendIPs           []string
+		expectedDestinations []string
+	}{
+		{
+			desc:                 "reconcileSecurityGroup shall scope the rules to the backend nodes with floating IP disabled",
+			backendIPs:           []string{"10.0.0.4"},
+			expectedDestinations: []string{"10.0.0.4"},
+		},
+		{
+			desc:                 "reconcileSecurityGroup shall fall back to the frontend IPs if there is no backend node",
+			expectedDestinations: []string{"1.2.3.4", "2.3.4.5"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			existingSg := network.SecurityGroup{
+				Name:                          pointer.String("nsg"),
+				SecurityGroupPropertiesFormat: &network.SecurityGroupPropertiesFormat{},
+			}
+			mockSGsClient := az.SecurityGroupsClient.(*mocksecuritygroupclient.MockInterface)
+			mockSGsClient.EXPECT().Get(gomock.Any(), "rg", "nsg", gomock.Any()).Return(existingSg, nil)
+			mockSGsClient.EXPECT().CreateOrUpdate(gomock.Any(), "rg", "nsg", gomock.Any(), gomock.Any()).Return(nil)
+			mockLBClient := az.LoadBalancerClient.(*mockloadbalancerclient.MockInterface)
+			mockLBClient.EXPECT().Get(gomock.Any(), "rg", "lb", gomock.Any()).Return(network.LoadBalancer{}, nil)
+			mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
+			mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(test.backendIPs, nil)
+
+			service := getTestService("test1", v1.ProtocolTCP, map[string]string{
+				consts.ServiceAnnotationDisableLoadBalancerFloatingIP: "true",
+				consts.ServiceAnnotationAdditionalPublicIPs:           "2.3.4.5",
+			}, false, 80)
+			sg, err := az.reconcileSecurityGroup("testCluster", &service, &[]string{"1.2.3.4"}, pointer.String("lb"), true)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(*sg.SecurityRules))
+			rule := (*sg.SecurityRules)[0]
+			assert.Nil(t, rule.DestinationAddressPrefix)
+			assert.Equal(t, test.expectedDestinations, *rule.DestinationAddressPrefixes)
+			assert.Equal(t, strconv.Itoa(int(getBackendPort(80))), *rule.DestinationPortRange)
+		})
+	}
+}
+
 func TestReconcileSecurityGroupLoadBalancerSourceRanges(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -5819,6 +6209,75 @@ func TestEnsurePublicIPExistsWithExtendedLocation(t *testing.T) {
 	}
 }
 
+func TestEnsurePublicIPExistsWithDdosProtectionPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configPlanID := "/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/ddosProtectionPlans/config-plan"
+	annotationPlanID := "/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/ddosProtectionPlans/annotation-plan"
+
+	testcases := []struct {
+		desc           string
+		configPlanID   string
+		annotations    map[string]string
+		expectedPlanID string
+	}{
+		{
+			desc: "should not set the DDoS settings if no plan is configured",
+		},
+		{
+			desc:           "should set the DDoS plan from the config",
+			configPlanID:   configPlanID,
+			expectedPlanID: configPlanID,
+		},
+		{
+			desc:           "should prefer the DDoS plan from the annotation",
+			configPlanID:   configPlanID,
+			annotations:    map[string]string{consts.ServiceAnnotationPIPDdosProtectionPlanID: annotationPlanID},
+			expectedPlanID: annotationPlanID,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			az.LoadBalancerSku = consts.LoadBalancerSkuStandard
+			az.PublicIPDdosProtectionPlanID = tc.configPlanID
+			service := getTestService("test1", v1.ProtocolTCP, tc.annotations, false, 80)
+
+			zoneClient := mockzoneclient.NewMockInterface(ctrl)
+			zoneClient.EXPECT().GetZones(gomock.Any(), gomock.Any()).Return(map[string][]string{}, nil).AnyTimes()
+			az.ZoneClient = zoneClient
+
+			createdPIP := network.PublicIPAddress{
+				Name:     pointer.String("pip1"),
+				Location: &az.Location,
+				PublicIPAddressPropertiesFormat: &network.PublicIPAddressPropertiesFormat{
+					PublicIPAllocationMethod: network.Static,
+					PublicIPAddressVersion:   network.IPv4,
+				},
+			}
+			mockPIPsClient := az.PublicIPAddressesClient.(*mockpublicipclient.MockInterface)
+			first := mockPIPsClient.EXPECT().List(gomock.Any(), "rg").Return([]network.PublicIPAddress{}, nil).Times(2)
+			mockPIPsClient.EXPECT().Get(gomock.Any(), "rg", "pip1", gomock.Any()).Return(createdPIP, nil).After(first)
+			mockPIPsClient.EXPECT().CreateOrUpdate(gomock.Any(), "rg", "pip1", gomock.Any()).
+				DoAndReturn(func(ctx context.Context, resourceGroupName string, publicIPAddressName string, publicIPAddressParameters network.PublicIPAddress) *retry.Error {
+					if tc.expectedPlanID == "" {
+						assert.Nil(t, publicIPAddressParameters.DdosSettings)
+						return nil
+					}
+					assert.NotNil(t, publicIPAddressParameters.DdosSettings)
+					assert.Equal(t, network.DdosSettingsProtectionModeEnabled, publicIPAddressParameters.DdosSettings.ProtectionMode)
+					assert.Equal(t, tc.expectedPlanID, pointer.StringDeref(publicIPAddressParameters.DdosSettings.DdosProtectionPlan.ID, ""))
+					return nil
+				}).Times(1)
+
+			_, err := az.ensurePublicIPExists(&service, "pip1", "", "", false, false, false)
+			assert.NoError(t, err)
+		})
+	}
+}
+
 func TestShouldUpdateLoadBalancer(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -6489,6 +6948,59 @@ func TestEnsurePIPTagged(t *testing.T) {
 	})
 }
 
+func TestEnsureLoadBalancerFrozen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the mock clients fail the test on any call, so no Azure resources should be touched
+	az := GetTestCloud(ctrl)
+	clusterResources, _, _ := getClusterResources(az, 1, 1)
+	svc := getTestService("service1", v1.ProtocolTCP, map[string]string{
+		consts.ServiceAnnotationLoadBalancerFreeze: consts.TrueAnnotationValue,
+	}, false, 80)
+	svc.Status.LoadBalancer = v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}}
+
+	client := fake.NewSimpleClientset(&svc)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	az.serviceLister = informerFactory.Core().V1().Services().Lister()
+	assert.NoError(t, informerFactory.Core().V1().Services().Informer().GetIndexer().Add(&svc))
+
+	lbStatus, err := az.EnsureLoadBalancer(context.TODO(), testClusterName, &svc, clusterResources.nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, &svc.Status.LoadBalancer, lbStatus, "the status of the frozen service should be kept")
+
+	err = az.UpdateLoadBalancer(context.TODO(), testClusterName, &svc, clusterResources.nodes)
+	assert.NoError(t, err)
+}
+
+func TestEnsureLoadBalancerHeadless(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the mock clients fail the test on any call, so the headless service should not be reconciled
+	az := GetTestCloud(ctrl)
+	recorder := record.NewFakeRecorder(10)
+	az.eventRecorder = recorder
+	clusterResources, _, _ := getClusterResources(az, 1, 1)
+	svc := getTestService("service1", v1.ProtocolTCP, nil, false, 80)
+	svc.Spec.ClusterIP = v1.ClusterIPNone
+
+	client := fake.NewSimpleClientset(&svc)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	az.serviceLister = informerFactory.Core().V1().Services().Lister()
+	assert.NoError(t, informerFactory.Core().V1().Services().Informer().GetIndexer().Add(&svc))
+
+	expectedMsg := "service default/service1 of type LoadBalancer must not be headless (clusterIP: None), skipping the load balancer reconciliation"
+	lbStatus, err := az.EnsureLoadBalancer(context.TODO(), testClusterName, &svc, clusterResources.nodes)
+	assert.EqualError(t, err, expectedMsg)
+	assert.Nil(t, lbStatus)
+	assert.Equal(t, "Warning HeadlessLoadBalancerService "+expectedMsg, <-recorder.Events)
+
+	err = az.UpdateLoadBalancer(context.TODO(), testClusterName, &svc, clusterResources.nodes)
+	assert.EqualError(t, err, expectedMsg)
+	assert.Equal(t, "Warning HeadlessLoadBalancerService "+expectedMsg, <-recorder.Events)
+}
+
 func TestEnsureLoadBalancerTagged(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -6999,6 +7511,64 @@ func TestReconcileFrontendIPConfigs(t *testing.T) {
 			wantLB:      false,
 			expectedErr: fmt.Errorf("isFrontendIPConfigUnsafeToDelete: incorrect parameters"),
 		},
+		{
+			desc:    "DualStack Service changed to IPv4 only should drop the IPv6 FIP",
+			service: getTestService("test", v1.ProtocolTCP, nil, false, 80),
+			existingFIPs: []network.FrontendIPConfiguration{
+				{
+					Name: pointer.String("atest"),
+					ID:   pointer.String("/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb/frontendIPConfigurations/atest"),
+					FrontendIPConfigurationPropertiesFormat: &network.FrontendIPConfigurationPropertiesFormat{
+						PublicIPAddress: &network.PublicIPAddress{
+							ID: pointer.String("testCluster-atest-id"),
+						},
+					},
+				},
+				{
+					Name: pointer.String("atest-IPv6"),
+					ID:   pointer.String("/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb/frontendIPConfigurations/atest-IPv6"),
+					FrontendIPConfigurationPropertiesFormat: &network.FrontendIPConfigurationPropertiesFormat{
+						PublicIPAddress: &network.PublicIPAddress{
+							ID: pointer.String("testCluster-atest-id-IPv6"),
+						},
+					},
+				},
+			},
+			existingPIPs: []network.PublicIPAddress{
+				{
+					Name: pointer.String("testCluster-atest"),
+					ID:   pointer.String("testCluster-atest-id"),
+					PublicIPAddressPropertiesFormat: &network.PublicIPAddressPropertiesFormat{
+						PublicIPAddressVersion:   network.IPv4,
+						PublicIPAllocationMethod: network.Static,
+						IPAddress:                pointer.String("1.2.3.5"),
+					},
+				},
+				{
+					Name: pointer.String("testCluster-atest-IPv6"),
+					ID:   pointer.String("testCluster-atest-id-IPv6"),
+					PublicIPAddressPropertiesFormat: &network.PublicIPAddressPropertiesFormat{
+						PublicIPAddressVersion:   network.IPv6,
+						PublicIPAllocationMethod: network.Static,
+						IPAddress:                pointer.String("fe::2"),
+					},
+				},
+			},
+			status:        nil,
+			wantLB:        true,
+			expectedDirty: true,
+			expectedFIPs: []network.FrontendIPConfiguration{
+				{
+					Name: pointer.String("atest"),
+					ID:   pointer.String("/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb/frontendIPConfigurations/atest"),
+					FrontendIPConfigurationPropertiesFormat: &network.FrontendIPConfigurationPropertiesFormat{
+						PublicIPAddress: &network.PublicIPAddress{
+							ID: pointer.String("testCluster-atest-id"),
+						},
+					},
+				},
+			},
+		},
 		{
 			desc:    "IPv6 Service with existing IPv4 FIP",
 			service: getTestService("test", v1.ProtocolTCP, nil, true, 80),
@@ -7420,6 +7990,60 @@ func TestEqualSubResource(t *testing.T) {
 	}
 }
 
+func TestFindOutboundRule(t *testing.T) {
+	outboundRule := func(mutate func(*network.OutboundRulePropertiesFormat)) network.OutboundRule {
+		rule := network.OutboundRule{
+			Name: pointer.String("outbound-rule"),
+			OutboundRulePropertiesFormat: &network.OutboundRulePropertiesFormat{
+				Protocol:                 network.LoadBalancerOutboundRuleProtocolAll,
+				FrontendIPConfigurations: &[]network.SubResource{{ID: pointer.String("fip")}},
+				BackendAddressPool:       &network.SubResource{ID: pointer.String("pool")},
+				EnableTCPReset:           pointer.Bool(true),
+				IdleTimeoutInMinutes:     pointer.Int32(4),
+			},
+		}
+		if mutate != nil {
+			mutate(rule.OutboundRulePropertiesFormat)
+		}
+		return rule
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		existing network.OutboundRule
+		expected bool
+	}{
+		{
+			desc:     "should find the same rule",
+			existing: outboundRule(nil),
+			expected: true,
+		},
+		{
+			desc:     "should treat the unset idle timeout as the default one",
+			existing: outboundRule(func(p *network.OutboundRulePropertiesFormat) { p.IdleTimeoutInMinutes = nil }),
+			expected: true,
+		},
+		{
+			desc: "should not find the rule of another backend pool",
+			existing: outboundRule(func(p *network.OutboundRulePropertiesFormat) {
+				p.BackendAddressPool = &network.SubResource{ID: pointer.String("other")}
+			}),
+		},
+		{
+			desc:     "should not find the rule of another TCP reset",
+			existing: outboundRule(func(p *network.OutboundRulePropertiesFormat) { p.EnableTCPReset = pointer.Bool(false) }),
+		},
+		{
+			desc:     "should not find the rule of another idle timeout",
+			existing: outboundRule(func(p *network.OutboundRulePropertiesFormat) { p.IdleTimeoutInMinutes = pointer.Int32(30) }),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, findOutboundRule([]network.OutboundRule{tc.existing}, outboundRule(nil)))
+		})
+	}
+}
+
 func TestGetEligibleLoadBalancers(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -32,6 +32,7 @@ import (
 
 	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
 	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
 	"sigs.k8s.io/cloud-provider-azure/pkg/util/deepcopy"
 )
 
@@ -50,6 +51,17 @@ func (az *Cloud) CreateOrUpdatePIP(service *v1.Service, pipResourceGroup string,
 
 	pipJSON, _ := json.Marshal(pip)
 	klog.Warningf("PublicIPAddressesClient.CreateOrUpdate(%s, %s) failed: %s, PublicIP request: %s", pipResourceGroup, pointer.StringDeref(pip.Name, ""), rerr.Error().Error(), string(pipJSON))
+
+	if isPublicIPQuotaExceeded(rerr) {
+		az.Event(service, v1.EventTypeWarning, "PublicIPQuotaExceeded", fmt.Sprintf(
+			"Failed to create public IP %s in resource group %s because the public IP address quota is exceeded, "+
+				"request a quota increase or delete the unused public IPs: %s", pointer.StringDeref(pip.Name, ""), pipResourceGroup, rerr.ServiceErrorMessage()))
+		// the quota would not be released by retrying the request, so the error is not retriable and
+		// the service would be requeued with the controller's backoff.
+		rerr.Retriable = false
+		rerr.RetryAfter = time.Time{}
+		return rerr.Error()
+	}
 	az.Event(service, v1.EventTypeWarning, "CreateOrUpdatePublicIPAddress", rerr.Error().Error())
 
 	// Invalidate the cache because ETAG precondition mismatch.
@@ -68,6 +80,15 @@ func (az *Cloud) CreateOrUpdatePIP(service *v1.Service, pipResourceGroup string,
 	return rerr.Error()
 }
 
+// isPublicIPQuotaExceeded returns true if the public IP cannot be created due to the quota.
+func isPublicIPQuotaExceeded(rerr *retry.Error) bool {
+	switch rerr.ServiceErrorCode() {
+	case consts.PublicIPCountLimitReachedErrorCode, retry.QuotaExceeded:
+		return true
+	}
+	return false
+}
+
 // DeletePublicIP invokes az.PublicIPAddressesClient.Delete with exponential backoff retry
 func (az *Cloud) DeletePublicIP(service *v1.Service, pipResourceGroup string, pipName string) error {
 	ctx, cancel := getContextWithCancel()
@@ -18,6 +18,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -35,6 +36,7 @@ import (
 	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
 	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
 	"sigs.k8s.io/cloud-provider-azure/pkg/metrics"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
 )
 
 // routeOperation defines the allowed operations for route updating.
@@ -469,10 +471,7 @@ func (az *Cloud) DeleteRoute(ctx context.Context, clusterName string, kubeRoute
 		Name:                  pointer.String(routeName),
 		RoutePropertiesFormat: &network.RoutePropertiesFormat{},
 	}
-	op := az.routeUpdater.addOperation(getDeleteRouteOperation(route))
-
-	// Wait for operation complete.
-	err = op.wait().err
+	err = az.deleteRouteWithRetry(route)
 	if err != nil {
 		klog.Errorf("DeleteRoute failed for node %q with error: %v", kubeRoute.TargetNode, err)
 		return err
@@ -486,10 +485,7 @@ func (az *Cloud) DeleteRoute(ctx context.Context, clusterName string, kubeRoute
 			Name:                  pointer.String(routeNameWithoutIPV6Suffix),
 			RoutePropertiesFormat: &network.RoutePropertiesFormat{},
 		}
-		op := az.routeUpdater.addOperation(getDeleteRouteOperation(route))
-
-		// Wait for operation complete.
-		err = op.wait().err
+		err = az.deleteRouteWithRetry(route)
 		if err != nil {
 			klog.Errorf("DeleteRoute failed for node %q with error: %v", kubeRoute.TargetNode, err)
 			return err
@@ -502,6 +498,33 @@ func (az *Cloud) DeleteRoute(ctx context.Context, clusterName string, kubeRoute
 	return nil
 }
 
+// deleteRouteWithRetry deletes the route by the route updater, and retries with exponential backoff
+// if the route table update fails with a retriable error. The route table cache is invalidated
+// before each retry, so that the retry would be based on the latest route table.
+func (az *Cloud) deleteRouteWithRetry(route network.Route) error {
+	var retryErr error
+	err := wait.ExponentialBackoff(az.RequestBackoff(), func() (bool, error) {
+		op := az.routeUpdater.addOperation(getDeleteRouteOperation(route))
+
+		// Wait for operation complete.
+		retryErr = op.wait().err
+		if retryErr == nil {
+			return true, nil
+		}
+		if !retry.IsErrorRetriable(retryErr) {
+			return true, retryErr
+		}
+		klog.Errorf("deleteRouteWithRetry(%s): backoff failure, will retry, err=%v", pointer.StringDeref(route.Name, ""), retryErr)
+		// the routes of the cached route table may have been changed by the failed update
+		_ = az.rtCache.Delete(az.RouteTableName)
+		return false, nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		err = retryErr
+	}
+	return err
+}
+
 // This must be kept in sync with MapRouteNameToNodeName.
 // These two functions enable stashing the instance name in the route
 // and then retrieving it later when listing. This is needed because
@@ -30,6 +30,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/utils/pointer"
 
@@ -109,6 +110,65 @@ func TestDeleteRoute(t *testing.T) {
 	}
 }
 
+func TestDeleteRouteWithRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	routeTableClient := mockroutetableclient.NewMockInterface(ctrl)
+
+	cloud := &Cloud{
+		RouteTablesClient: routeTableClient,
+		Config: Config{
+			RouteTableResourceGroup: "foo",
+			RouteTableName:          "bar",
+			Location:                "location",
+			CloudProviderBackoff:    true,
+		},
+		ResourceRequestBackoff: wait.Backoff{Steps: 3, Duration: time.Millisecond},
+		unmanagedNodes:         sets.New[string](),
+		nodeInformerSynced:     func() bool { return true },
+	}
+	cache, _ := cloud.newRouteTableCache()
+	cloud.rtCache = cache
+	cloud.routeUpdater = newDelayedRouteUpdater(cloud, 100*time.Millisecond)
+	go cloud.routeUpdater.run(context.Background())
+	route := cloudprovider.Route{
+		TargetNode:      "node",
+		DestinationCIDR: "1.2.3.4/24",
+	}
+	routeName := mapNodeNameToRouteName(false, route.TargetNode, route.DestinationCIDR)
+	routeTables := network.RouteTable{
+		Name:     &cloud.RouteTableName,
+		Location: &cloud.Location,
+		RouteTablePropertiesFormat: &network.RouteTablePropertiesFormat{
+			Routes: &[]network.Route{
+				{
+					Name: &routeName,
+				},
+			},
+		},
+	}
+	routeTablesAfterDeletion := network.RouteTable{
+		Name:     &cloud.RouteTableName,
+		Location: &cloud.Location,
+		RouteTablePropertiesFormat: &network.RouteTablePropertiesFormat{
+			Routes: &[]network.Route{},
+		},
+	}
+	routeTableClient.EXPECT().Get(gomock.Any(), cloud.RouteTableResourceGroup, cloud.RouteTableName, "").Return(routeTables, nil).Times(2)
+	gomock.InOrder(
+		routeTableClient.EXPECT().CreateOrUpdate(gomock.Any(), cloud.RouteTableResourceGroup, cloud.RouteTableName, routeTablesAfterDeletion, "").Return(&retry.Error{HTTPStatusCode: http.StatusInternalServerError, Retriable: true, RawError: fmt.Errorf("internal error")}),
+		routeTableClient.EXPECT().CreateOrUpdate(gomock.Any(), cloud.RouteTableResourceGroup, cloud.RouteTableName, routeTablesAfterDeletion, "").Return(nil),
+	)
+	err := cloud.DeleteRoute(context.TODO(), "cluster", &route)
+	assert.NoError(t, err)
+
+	// the non-retriable errors should not be retried
+	routeTableClient.EXPECT().Get(gomock.Any(), cloud.RouteTableResourceGroup, cloud.RouteTableName, "").Return(routeTables, nil).Times(1)
+	routeTableClient.EXPECT().CreateOrUpdate(gomock.Any(), cloud.RouteTableResourceGroup, cloud.RouteTableName, routeTablesAfterDeletion, "").Return(&retry.Error{HTTPStatusCode: http.StatusBadRequest, RawError: fmt.Errorf("bad request")}).Times(1)
+	err = cloud.DeleteRoute(context.TODO(), "cluster", &route)
+	assert.EqualError(t, err, "Retriable: false, RetryAfter: 0s, HTTPStatusCode: 400, RawError: bad request")
+}
+
 func TestDeleteRouteDualStack(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
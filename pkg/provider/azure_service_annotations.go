@@ -0,0 +1,188 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2022-07-01/network"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
+)
+
+// booleanServiceAnnotations are the recognized Service annotations which only accept "true" or "false".
+// Any other value is treated as "false", so it is reported as a warning rather than failing the reconciliation.
+// The unsupported health probe protocols are warned likewise, since they fall back to Tcp for backward compatibility.
+var booleanServiceAnnotations = []string{
+	consts.ServiceAnnotationLoadBalancerInternal,
+	consts.ServiceAnnotationSharedSecurityRule,
+	consts.ServiceAnnotationDenyAllExceptLoadBalancerSourceRanges,
+	consts.ServiceAnnotationLoadBalancerEnableHighAvailabilityPorts,
+	consts.ServiceAnnotationDisableLoadBalancerFloatingIP,
+	consts.ServiceAnnotationDisableTCPReset,
+	consts.ServiceAnnotationEnableTCPReset,
+	consts.ServiceAnnotationPLSCreation,
+	consts.ServiceAnnotationPLSProxyProtocol,
+	consts.ServiceAnnotationLoadBalancerOutboundOnly,
+}
+
+// invalidServiceAnnotation is an invalid value of a recognized Service annotation.
+type invalidServiceAnnotation struct {
+	key    string
+	value  string
+	reason string
+}
+
+func (a invalidServiceAnnotation) String() string {
+	return fmt.Sprintf("%s=%q: %s", a.key, a.value, a.reason)
+}
+
+// validateServiceAnnotations validates the values of the recognized Service annotations before
+// reconciling any Azure resources. The invalid values are reported by a warning event listing the
+// offending keys and values, and an error is returned if any of them would fail the reconciliation.
+func (az *Cloud) validateServiceAnnotations(service *v1.Service) error {
+	invalids, warnings := getInvalidServiceAnnotations(service)
+
+	if len(warnings) > 0 {
+		msg := fmt.Sprintf("Unrecognized values of the Service annotations are ignored: %s", joinInvalidServiceAnnotations(warnings))
+		klog.Warningf("validateServiceAnnotations(%s): %s", getServiceName(service), msg)
+		az.Event(service, v1.EventTypeWarning, "UnrecognizedServiceAnnotationValue", msg)
+	}
+	if len(invalids) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("Invalid values of the Service annotations: %s", joinInvalidServiceAnnotations(invalids))
+	klog.Errorf("validateServiceAnnotations(%s): %s", getServiceName(service), msg)
+	az.Event(service, v1.EventTypeWarning, "InvalidServiceAnnotation", msg)
+	return fmt.Errorf("%s", msg)
+}
+
+// getInvalidServiceAnnotations returns the invalid annotations that would fail the reconciliation
+// and the ones that would be silently ignored.
+func getInvalidServiceAnnotations(service *v1.Service) (invalids, warnings []invalidServiceAnnotation) {
+	annotations := service.Annotations
+	if len(annotations) == 0 {
+		return nil, nil
+	}
+
+	for _, key := range booleanServiceAnnotations {
+		if a := validateBooleanServiceAnnotation(annotations, key); a != nil {
+			warnings = append(warnings, *a)
+		}
+	}
+
+	int32Annotations := map[string]consts.Int32BusinessValidator{
+		consts.ServiceAnnotationLoadBalancerIdleTimeout:           rangeValidator(4, 100),
+		consts.ServiceAnnotationLoadBalancerHealthProbeInterval:   rangeValidator(5, 0),
+		consts.ServiceAnnotationLoadBalancerHealthProbeNumOfProbe: rangeValidator(2, 0),
+		consts.ServiceAnnotationPLSIpConfigurationIPAddressCount:  rangeValidator(1, 8),
+	}
+	// the global health probe protocol is only used by the ports without the per-port protocol or the AppProtocol
+	usesGlobalHealthProbeProtocol := false
+	for _, port := range service.Spec.Ports {
+		for _, key := range []string{
+			consts.BuildAnnotationKeyForPort(port.Port, consts.PortAnnotationNoLBRule),
+			consts.BuildAnnotationKeyForPort(port.Port, consts.PortAnnotationNoHealthProbeRule),
+		} {
+			if a := validateBooleanServiceAnnotation(annotations, key); a != nil {
+				warnings = append(warnings, *a)
+			}
+		}
+		portProtocolKey := consts.BuildHealthProbeAnnotationKeyForPort(port.Port, consts.HealthProbeParamsProtocol)
+		if _, found := annotations[portProtocolKey]; found {
+			if a := validateHealthProbeProtocolAnnotation(annotations, portProtocolKey); a != nil {
+				warnings = append(warnings, *a)
+			}
+		} else if port.AppProtocol == nil {
+			usesGlobalHealthProbeProtocol = true
+		}
+		int32Annotations[consts.BuildHealthProbeAnnotationKeyForPort(port.Port, consts.HealthProbeParamsProbeInterval)] = rangeValidator(5, 0)
+		int32Annotations[consts.BuildHealthProbeAnnotationKeyForPort(port.Port, consts.HealthProbeParamsNumOfProbe)] = rangeValidator(2, 0)
+	}
+
+	if usesGlobalHealthProbeProtocol {
+		if a := validateHealthProbeProtocolAnnotation(annotations, consts.ServiceAnnotationLoadBalancerHealthProbeProtocol); a != nil {
+			warnings = append(warnings, *a)
+		}
+	}
+
+	for key, validator := range int32Annotations {
+		if _, err := consts.Getint32ValueFromK8sSvcAnnotation(annotations, key, validator); err != nil {
+			invalids = append(invalids, invalidServiceAnnotation{key: key, value: annotations[key], reason: err.Error()})
+		}
+	}
+
+	// sort by key so the events are stable
+	sort.Slice(invalids, func(i, j int) bool { return invalids[i].key < invalids[j].key })
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].key < warnings[j].key })
+	return invalids, warnings
+}
+
+func validateBooleanServiceAnnotation(annotations map[string]string, key string) *invalidServiceAnnotation {
+	value, found := annotations[key]
+	if !found {
+		return nil
+	}
+	if value == consts.TrueAnnotationValue || value == "false" {
+		return nil
+	}
+	// some of the consumers compare the value case-sensitively, so the other cases may be treated as false
+	if strings.EqualFold(value, consts.TrueAnnotationValue) || strings.EqualFold(value, "false") {
+		return &invalidServiceAnnotation{key: key, value: value, reason: "the value must be exactly true or false, other cases may be treated as false"}
+	}
+	return &invalidServiceAnnotation{key: key, value: value, reason: "the value must be true or false, treated as false"}
+}
+
+func validateHealthProbeProtocolAnnotation(annotations map[string]string, key string) *invalidServiceAnnotation {
+	value, found := annotations[key]
+	if !found {
+		return nil
+	}
+	for _, protocol := range []network.ProbeProtocol{network.ProbeProtocolTCP, network.ProbeProtocolHTTP, network.ProbeProtocolHTTPS} {
+		if strings.EqualFold(strings.TrimSpace(value), string(protocol)) {
+			return nil
+		}
+	}
+	return &invalidServiceAnnotation{key: key, value: value, reason: "the value must be one of Tcp, Http or Https, falling back to Tcp"}
+}
+
+// rangeValidator returns an Int32BusinessValidator which checks the value is between min and max.
+// The max is not checked if it is 0.
+func rangeValidator(min, max int32) consts.Int32BusinessValidator {
+	return func(val *int32) error {
+		if *val < min {
+			return fmt.Errorf("the minimum value is %d, actual value: %d", min, *val)
+		}
+		if max > 0 && *val > max {
+			return fmt.Errorf("the maximum value is %d, actual value: %d", max, *val)
+		}
+		return nil
+	}
+}
+
+func joinInvalidServiceAnnotations(annotations []invalidServiceAnnotation) string {
+	msgs := make([]string, 0, len(annotations))
+	for _, a := range annotations {
+		msgs = append(msgs, a.String())
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
+)
+
+func TestValidateServiceAnnotations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tc := range []struct {
+		desc          string
+		annotations   map[string]string
+		appProtocol   *string
+		expectedErr   bool
+		expectedEvent []string
+	}{
+		{
+			desc: "valid annotations should not generate any event",
+			annotations: map[string]string{
+				consts.ServiceAnnotationLoadBalancerInternal:               "true",
+				consts.ServiceAnnotationLoadBalancerHealthProbeProtocol:    "http",
+				consts.ServiceAnnotationLoadBalancerIdleTimeout:            "10",
+				"service.beta.kubernetes.io/port_80_health-probe_interval": "5",
+			},
+		},
+		{
+			desc: "boolean annotations in other cases should be warned since they may be treated as false",
+			annotations: map[string]string{
+				consts.ServiceAnnotationLoadBalancerInternal: "True",
+			},
+			expectedEvent: []string{
+				`Warning UnrecognizedServiceAnnotationValue Unrecognized values of the Service annotations are ignored: ` +
+					`service.beta.kubernetes.io/azure-load-balancer-internal="True": the value must be exactly true or false, other cases may be treated as false`,
+			},
+		},
+		{
+			desc: "unsupported health probe protocols should only be warned since they fall back to Tcp",
+			annotations: map[string]string{
+				consts.ServiceAnnotationLoadBalancerHealthProbeProtocol:    "htp",
+				"service.beta.kubernetes.io/port_80_health-probe_protocol": "udp",
+			},
+			expectedEvent: []string{
+				`Warning UnrecognizedServiceAnnotationValue Unrecognized values of the Service annotations are ignored: ` +
+					`service.beta.kubernetes.io/port_80_health-probe_protocol="udp": the value must be one of Tcp, Http or Https, falling back to Tcp`,
+			},
+		},
+		{
+			desc: "global health probe protocol should be warned if any port uses it",
+			annotations: map[string]string{
+				consts.ServiceAnnotationLoadBalancerHealthProbeProtocol: "htp",
+			},
+			expectedEvent: []string{
+				`Warning UnrecognizedServiceAnnotationValue Unrecognized values of the Service annotations are ignored: ` +
+					`service.beta.kubernetes.io/azure-load-balancer-health-probe-protocol="htp": the value must be one of Tcp, Http or Https, falling back to Tcp`,
+			},
+		},
+		{
+			desc: "global health probe protocol should not be validated if the AppProtocol overrides it",
+			annotations: map[string]string{
+				consts.ServiceAnnotationLoadBalancerHealthProbeProtocol: "htp",
+			},
+			appProtocol: pointer.String("Http"),
+		},
+		{
+			desc: "invalid numeric annotations should be rejected and listed by key",
+			annotations: map[string]string{
+				consts.ServiceAnnotationLoadBalancerIdleTimeout:                "200",
+				consts.ServiceAnnotationPLSIpConfigurationIPAddressCount:       "not-a-number",
+				"service.beta.kubernetes.io/port_80_health-probe_num-of-probe": "1",
+			},
+			expectedErr: true,
+			expectedEvent: []string{
+				`Warning InvalidServiceAnnotation Invalid values of the Service annotations: ` +
+					`service.beta.kubernetes.io/azure-load-balancer-tcp-idle-timeout="200": error parsing value: the maximum value is 100, actual value: 200; ` +
+					`service.beta.kubernetes.io/azure-pls-ip-configuration-ip-address-count="not-a-number": error value: strconv.ParseInt: parsing "not-a-number": invalid syntax: not-a-number value must be a whole number; ` +
+					`service.beta.kubernetes.io/port_80_health-probe_num-of-probe="1": error parsing value: the minimum value is 2, actual value: 1`,
+			},
+		},
+		{
+			desc: "unrecognized boolean values should only be warned",
+			annotations: map[string]string{
+				consts.ServiceAnnotationLoadBalancerInternal:    "yes",
+				"service.beta.kubernetes.io/port_80_no_lb_rule": "1",
+			},
+			expectedEvent: []string{
+				`Warning UnrecognizedServiceAnnotationValue Unrecognized values of the Service annotations are ignored: ` +
+					`service.beta.kubernetes.io/azure-load-balancer-internal="yes": the value must be true or false, treated as false; ` +
+					`service.beta.kubernetes.io/port_80_no_lb_rule="1": the value must be true or false, treated as false`,
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			recorder := record.NewFakeRecorder(10)
+			az.eventRecorder = recorder
+			svc := getTestService("service1", v1.ProtocolTCP, tc.annotations, false, 80)
+			svc.Spec.Ports[0].AppProtocol = tc.appProtocol
+
+			err := az.validateServiceAnnotations(&svc)
+			assert.Equal(t, tc.expectedErr, err != nil)
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			assert.Equal(t, tc.expectedEvent, events)
+		})
+	}
+}
@@ -200,17 +200,23 @@ func getProtocolsFromKubernetesProtocol(protocol v1.Protocol) (*network.Transpor
 }
 
 // This returns the full identifier of the primary NIC for the given VM.
+// The NIC flagged as primary is always preferred, e.g. when an accelerated
+// networking NIC is listed before it.
 func getPrimaryInterfaceID(machine compute.VirtualMachine) (string, error) {
-	if len(*machine.NetworkProfile.NetworkInterfaces) == 1 {
-		return *(*machine.NetworkProfile.NetworkInterfaces)[0].ID, nil
+	if machine.VirtualMachineProperties == nil || machine.NetworkProfile == nil || machine.NetworkProfile.NetworkInterfaces == nil {
+		return "", fmt.Errorf("failed to find the network interfaces for vm %s", pointer.StringDeref(machine.Name, ""))
 	}
 
 	for _, ref := range *machine.NetworkProfile.NetworkInterfaces {
-		if pointer.BoolDeref(ref.Primary, false) {
+		if ref.NetworkInterfaceReferenceProperties != nil && pointer.BoolDeref(ref.Primary, false) {
 			return *ref.ID, nil
 		}
 	}
 
+	if len(*machine.NetworkProfile.NetworkInterfaces) == 1 {
+		return *(*machine.NetworkProfile.NetworkInterfaces)[0].ID, nil
+	}
+
 	return "", fmt.Errorf("failed to find a primary nic for the vm. vmname=%q", *machine.Name)
 }
 
@@ -225,7 +231,7 @@ func getPrimaryIPConfig(nic network.Interface) (*network.InterfaceIPConfiguratio
 
 	for _, ref := range *nic.IPConfigurations {
 		ref := ref
-		if *ref.Primary {
+		if ref.InterfaceIPConfigurationPropertiesFormat != nil && pointer.BoolDeref(ref.Primary, false) {
 			return &ref, nil
 		}
 	}
@@ -233,6 +239,35 @@ func getPrimaryIPConfig(nic network.Interface) (*network.InterfaceIPConfiguratio
 	return nil, fmt.Errorf("failed to determine the primary ipconfig. nicname=%q", *nic.Name)
 }
 
+// removeBackendPoolFromStaleIPConfigs removes the backend pool from the IP configurations of the nic other
+// than ipConfig. The membership is keyed on the IP configuration, so it is left on the stale IP configuration
+// if the IP configuration is renamed or replaced. It returns true if any IP configuration is changed.
+func removeBackendPoolFromStaleIPConfigs(nic network.Interface, ipConfig *network.InterfaceIPConfiguration, backendPoolID string) bool {
+	if nic.IPConfigurations == nil || ipConfig == nil {
+		return false
+	}
+
+	changed := false
+	for _, ref := range *nic.IPConfigurations {
+		if ref.InterfaceIPConfigurationPropertiesFormat == nil || ref.LoadBalancerBackendAddressPools == nil ||
+			strings.EqualFold(pointer.StringDeref(ref.Name, ""), pointer.StringDeref(ipConfig.Name, "")) {
+			continue
+		}
+		backendPools := make([]network.BackendAddressPool, 0, len(*ref.LoadBalancerBackendAddressPools))
+		for _, pool := range *ref.LoadBalancerBackendAddressPools {
+			if strings.EqualFold(pointer.StringDeref(pool.ID, ""), backendPoolID) {
+				klog.V(2).Infof("removeBackendPoolFromStaleIPConfigs: moving the backend pool %s of nic %s from ipconfig %s to %s",
+					backendPoolID, pointer.StringDeref(nic.Name, ""), pointer.StringDeref(ref.Name, ""), pointer.StringDeref(ipConfig.Name, ""))
+				changed = true
+				continue
+			}
+			backendPools = append(backendPools, pool)
+		}
+		ref.LoadBalancerBackendAddressPools = &backendPools
+	}
+	return changed
+}
+
 // returns first ip configuration on a nic by family
 func getIPConfigByIPFamily(nic network.Interface, IPv6 bool) (*network.InterfaceIPConfiguration, error) {
 	if nic.IPConfigurations == nil {
@@ -316,6 +351,18 @@ func (az *Cloud) getloadbalancerHAmodeRuleName(service *v1.Service, isIPv6 bool)
 	return az.getLoadBalancerRuleName(service, service.Spec.Ports[0].Protocol, service.Spec.Ports[0].Port, isIPv6)
 }
 
+// getOutboundRuleName returns the name of the outbound rule of the outbound-only service.
+func (az *Cloud) getOutboundRuleName(service *v1.Service, isIPv6 bool) string {
+	return getResourceByIPFamily(fmt.Sprintf("%s-outbound", az.getRulePrefix(service)), isServiceDualStack(service), isIPv6)
+}
+
+// serviceOwnsOutboundRule returns true if the outbound rule is the one of the outbound-only service. Unlike the
+// load balancing rules, the outbound rules are matched by the full names, so that the outbound rules created by
+// the users would not be removed.
+func (az *Cloud) serviceOwnsOutboundRule(service *v1.Service, rule string) bool {
+	return strings.EqualFold(rule, az.getOutboundRuleName(service, false)) || strings.EqualFold(rule, az.getOutboundRuleName(service, true))
+}
+
 func (az *Cloud) getSecurityRuleName(service *v1.Service, port v1.ServicePort, sourceAddrPrefix string, isIPv6 bool) string {
 	isDualStack := isServiceDualStack(service)
 	safePrefix := strings.Replace(sourceAddrPrefix, "/", "_", -1)
@@ -343,7 +390,8 @@ func (az *Cloud) getRulePrefix(service *v1.Service) string {
 
 func (az *Cloud) getPublicIPName(clusterName string, service *v1.Service, isIPv6 bool) (string, error) {
 	isDualStack := isServiceDualStack(service)
-	pipName := fmt.Sprintf("%s-%s", clusterName, az.GetLoadBalancerName(context.TODO(), clusterName, service))
+	// the public IP is already scoped by the cluster name, so the cluster name segment is not needed here.
+	pipName := fmt.Sprintf("%s-%s", clusterName, cloudprovider.DefaultLoadBalancerName(service))
 	if id := getServicePIPPrefixID(service, isIPv6); id != "" {
 		id, err := getLastSegment(id, "/")
 		if err == nil {
@@ -868,22 +916,28 @@ func (as *availabilitySet) getPrimaryInterfaceWithVMSet(nodeName, vmSetName stri
 // EnsureHostInPool ensures the given VM's Primary NIC's Primary IP Configuration is
 // participating in the specified LoadBalancer Backend Pool.
 func (as *availabilitySet) EnsureHostInPool(service *v1.Service, nodeName types.NodeName, backendPoolID string, vmSetName string) (string, string, string, *compute.VirtualMachineScaleSetVM, error) {
+	_, err := as.ensureHostInPool(service, nodeName, backendPoolID, vmSetName)
+	return "", "", "", nil, err
+}
+
+// ensureHostInPool is EnsureHostInPool which also returns whether the primary NIC of the VM is updated.
+func (as *availabilitySet) ensureHostInPool(service *v1.Service, nodeName types.NodeName, backendPoolID string, vmSetName string) (bool, error) {
 	vmName := mapNodeNameToVMName(nodeName)
 	serviceName := getServiceName(service)
 	nic, _, err := as.getPrimaryInterfaceWithVMSet(vmName, vmSetName)
 	if err != nil {
 		if errors.Is(err, errNotInVMSet) {
 			klog.V(3).Infof("EnsureHostInPool skips node %s because it is not in the vmSet %s", nodeName, vmSetName)
-			return "", "", "", nil, nil
+			return false, nil
 		}
 
 		klog.Errorf("error: az.EnsureHostInPool(%s), az.VMSet.GetPrimaryInterface.Get(%s, %s), err=%v", nodeName, vmName, vmSetName, err)
-		return "", "", "", nil, err
+		return false, err
 	}
 
 	if nic.ProvisioningState == consts.NicFailedState {
 		klog.Warningf("EnsureHostInPool skips node %s because its primary nic %s is in Failed state", nodeName, *nic.Name)
-		return "", "", "", nil, nil
+		return false, nil
 	}
 
 	var primaryIPConfig *network.InterfaceIPConfiguration
@@ -891,12 +945,12 @@ func (as *availabilitySet) EnsureHostInPool(service *v1.Service, nodeName types.
 	if !as.Cloud.ipv6DualStackEnabled && !ipv6 {
 		primaryIPConfig, err = getPrimaryIPConfig(nic)
 		if err != nil {
-			return "", "", "", nil, err
+			return false, err
 		}
 	} else {
 		primaryIPConfig, err = getIPConfigByIPFamily(nic, ipv6)
 		if err != nil {
-			return "", "", "", nil, err
+			return false, err
 		}
 	}
 
@@ -911,6 +965,7 @@ func (as *availabilitySet) EnsureHostInPool(service *v1.Service, nodeName types.
 			break
 		}
 	}
+	isMovedFromStaleIPConfig := removeBackendPoolFromStaleIPConfigs(nic, primaryIPConfig, backendPoolID)
 	if !foundPool {
 		if as.useStandardLoadBalancer() && len(newBackendPools) > 0 {
 			// Although standard load balancer supports backends from multiple availability
@@ -925,11 +980,11 @@ func (as *availabilitySet) EnsureHostInPool(service *v1.Service, nodeName types.
 			}
 			isSameLB, oldLBName, err := isBackendPoolOnSameLB(backendPoolID, newBackendPoolsIDs)
 			if err != nil {
-				return "", "", "", nil, err
+				return false, err
 			}
 			if !isSameLB {
 				klog.V(4).Infof("Node %q has already been added to LB %q, omit adding it to a new one", nodeName, oldLBName)
-				return "", "", "", nil, nil
+				return false, nil
 			}
 		}
 
@@ -939,26 +994,29 @@ func (as *availabilitySet) EnsureHostInPool(service *v1.Service, nodeName types.
 			})
 
 		primaryIPConfig.LoadBalancerBackendAddressPools = &newBackendPools
-
+	}
+	if !foundPool || isMovedFromStaleIPConfig {
 		nicName := *nic.Name
 		klog.V(3).Infof("nicupdate(%s): nic(%s) - updating", serviceName, nicName)
 		err := as.CreateOrUpdateInterface(service, nic)
 		if err != nil {
-			return "", "", "", nil, err
+			return false, err
 		}
+		return true, nil
 	}
-	return "", "", "", nil, nil
+	return false, nil
 }
 
 // EnsureHostsInPool ensures the given Node's primary IP configurations are
-// participating in the specified LoadBalancer Backend Pool.
-func (as *availabilitySet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID string, vmSetName string) error {
+// participating in the specified LoadBalancer Backend Pool. It returns the number of the VMs whose NICs are updated.
+func (as *availabilitySet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID string, vmSetName string) (int, error) {
 	mc := metrics.NewMetricContext("services", "vmas_ensure_hosts_in_pool", as.ResourceGroup, as.SubscriptionID, getServiceName(service))
 	isOperationSucceeded := false
 	defer func() {
 		mc.ObserveOperationWithResult(isOperationSucceeded)
 	}()
 
+	var updatedVMs atomic.Int32
 	hostUpdates := make([]func() error, 0, len(nodes))
 	for _, node := range nodes {
 		localNodeName := node.Name
@@ -970,7 +1028,7 @@ func (as *availabilitySet) EnsureHostsInPool(service *v1.Service, nodes []*v1.No
 		shouldExcludeLoadBalancer, err := as.ShouldNodeExcludedFromLoadBalancer(localNodeName)
 		if err != nil {
 			klog.Errorf("ShouldNodeExcludedFromLoadBalancer(%s) failed with error: %v", localNodeName, err)
-			return err
+			return 0, err
 		}
 		if shouldExcludeLoadBalancer {
 			klog.V(4).Infof("Excluding unmanaged/external-resource-group node %q", localNodeName)
@@ -978,10 +1036,13 @@ func (as *availabilitySet) EnsureHostsInPool(service *v1.Service, nodes []*v1.No
 		}
 
 		f := func() error {
-			_, _, _, _, err := as.EnsureHostInPool(service, types.NodeName(localNodeName), backendPoolID, vmSetName)
+			updated, err := as.ensureHostInPool(service, types.NodeName(localNodeName), backendPoolID, vmSetName)
 			if err != nil {
 				return fmt.Errorf("ensure(%s): backendPoolID(%s) - failed to ensure host in pool: %w", getServiceName(service), backendPoolID, err)
 			}
+			if updated {
+				updatedVMs.Add(1)
+			}
 			return nil
 		}
 		hostUpdates = append(hostUpdates, f)
@@ -989,11 +1050,11 @@ func (as *availabilitySet) EnsureHostsInPool(service *v1.Service, nodes []*v1.No
 
 	errs := utilerrors.AggregateGoroutines(hostUpdates...)
 	if errs != nil {
-		return utilerrors.Flatten(errs)
+		return int(updatedVMs.Load()), utilerrors.Flatten(errs)
 	}
 
 	isOperationSucceeded = true
-	return nil
+	return int(updatedVMs.Load()), nil
 }
 
 // EnsureBackendPoolDeleted ensures the loadBalancer backendAddressPools deleted from the specified nodes.
@@ -17,6 +17,7 @@ limitations under the License.
 package provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -415,6 +416,61 @@ func TestGetloadbalancerHAmodeRuleName(t *testing.T) {
 	}
 }
 
+func TestLoadBalancerResourceNamesWithClusterName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the same service is deployed in two clusters sharing one load balancer
+	svc := &v1.Service{
+		ObjectMeta: meta.ObjectMeta{
+			UID: "257b9655-5137-4ad2-b091-ef3f07043ad3",
+			Annotations: map[string]string{
+				consts.ServiceAnnotationLoadBalancerInternal:       "true",
+				consts.ServiceAnnotationLoadBalancerInternalSubnet: "shortsubnet",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Port:     9000,
+					Protocol: v1.ProtocolTCP,
+				},
+			},
+		},
+	}
+
+	az1 := GetTestCloud(ctrl)
+	az1.LoadBalancerClusterName = "cluster1"
+	az2 := GetTestCloud(ctrl)
+	az2.LoadBalancerClusterName = "cluster2-with-a-very-long-name"
+
+	ruleName1 := az1.getLoadBalancerRuleName(svc, v1.ProtocolTCP, 9000, false)
+	ruleName2 := az2.getLoadBalancerRuleName(svc, v1.ProtocolTCP, 9000, false)
+	assert.Equal(t, "cluster1-a257b965551374ad2b091ef3f07043ad-shortsubnet-TCP-9000", ruleName1)
+	assert.Equal(t, "cluster2-with-a-very-a257b965551374ad2b091ef3f07043ad-shortsubnet-TCP-9000", ruleName2)
+
+	fipName1 := az1.getDefaultFrontendIPConfigName(svc)
+	fipName2 := az2.getDefaultFrontendIPConfigName(svc)
+	assert.Equal(t, "cluster1-a257b965551374ad2b091ef3f07043ad-shortsubnet", fipName1)
+	assert.Equal(t, "cluster2-with-a-very-a257b965551374ad2b091ef3f07043ad-shortsubnet", fipName2)
+
+	assert.True(t, az1.serviceOwnsRule(svc, ruleName1))
+	assert.False(t, az1.serviceOwnsRule(svc, ruleName2))
+	assert.True(t, az2.serviceOwnsRule(svc, ruleName2))
+	assert.False(t, az2.serviceOwnsRule(svc, ruleName1))
+
+	owns, isPrimary, _ := az1.serviceOwnsFrontendIP(network.FrontendIPConfiguration{Name: pointer.String(fipName2)}, svc)
+	assert.False(t, owns)
+	assert.False(t, isPrimary)
+	owns, isPrimary, _ = az2.serviceOwnsFrontendIP(network.FrontendIPConfiguration{Name: pointer.String(fipName2)}, svc)
+	assert.True(t, owns)
+	assert.True(t, isPrimary)
+
+	// the names are not changed if the cluster name is not configured
+	az := GetTestCloud(ctrl)
+	assert.Equal(t, "a257b965551374ad2b091ef3f07043ad-shortsubnet-TCP-9000", az.getLoadBalancerRuleName(svc, v1.ProtocolTCP, 9000, false))
+}
+
 func TestGetFrontendIPConfigID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -664,6 +720,33 @@ func TestGetStandardVMPrimaryInterfaceID(t *testing.T) {
 			},
 			expectedNicID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic1",
 		},
+		{
+			name: "GetPrimaryInterfaceID should prefer the primary NIC of an accelerated networking VM",
+			vm: compute.VirtualMachine{
+				Name: pointer.String("vm-accelerated"),
+				VirtualMachineProperties: &compute.VirtualMachineProperties{
+					NetworkProfile: &compute.NetworkProfile{
+						NetworkInterfaces: &[]compute.NetworkInterfaceReference{
+							{
+								ID: pointer.String("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic-accelerated"),
+							},
+							{
+								NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{
+									Primary: pointer.Bool(true),
+								},
+								ID: pointer.String("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic-primary"),
+							},
+						},
+					},
+				},
+			},
+			expectedNicID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic-primary",
+		},
+		{
+			name:           "GetPrimaryInterfaceID should report error if the VM has no network profile",
+			vm:             compute.VirtualMachine{Name: pointer.String("vm4"), VirtualMachineProperties: &compute.VirtualMachineProperties{}},
+			expectedErrMsg: fmt.Errorf("failed to find the network interfaces for vm %s", "vm4"),
+		},
 		{
 			name: "GetPrimaryInterfaceID should report error if node don't have primary NIC",
 			vm: compute.VirtualMachine{
@@ -750,6 +833,32 @@ func TestGetPrimaryIPConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "GetPrimaryIPConfig should skip the IP configuration without properties",
+			nic: network.Interface{
+				Name: pointer.String("nic"),
+				InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+					EnableAcceleratedNetworking: pointer.Bool(true),
+					IPConfigurations: &[]network.InterfaceIPConfiguration{
+						{
+							Name: pointer.String("ipconfig1"),
+						},
+						{
+							Name: pointer.String("ipconfig2"),
+							InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+								Primary: pointer.Bool(true),
+							},
+						},
+					},
+				},
+			},
+			expectedIPConfig: &network.InterfaceIPConfiguration{
+				Name: pointer.String("ipconfig2"),
+				InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+					Primary: pointer.Bool(true),
+				},
+			},
+		},
 		{
 			name: "GetPrimaryIPConfig should report error if nic don't have IP configuration",
 			nic: network.Interface{
@@ -1477,6 +1586,56 @@ func TestStandardEnsureHostInPool(t *testing.T) {
 	}
 }
 
+func TestStandardEnsureHostInPoolWithRenamedIPConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cloud := GetTestCloud(ctrl)
+
+	backendAddressPoolID := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb1-internal/backendAddressPools/backendpool-1"
+	nicID := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic1"
+	testVM := buildDefaultTestVirtualMachine(asID, []string{nicID})
+	testVM.Name = pointer.String("vm1")
+
+	// the membership is left on the old IP config after the primary IP config is renamed
+	testNIC := network.Interface{
+		Name: pointer.String("nic1"),
+		ID:   pointer.String(nicID),
+		InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+			ProvisioningState: network.ProvisioningStateSucceeded,
+			IPConfigurations: &[]network.InterfaceIPConfiguration{
+				{
+					Name: pointer.String("ipconfig-old"),
+					InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+						Primary:                         pointer.Bool(false),
+						LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{{ID: pointer.String(backendAddressPoolID)}},
+					},
+				},
+				{
+					Name: pointer.String("ipconfig-new"),
+					InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+						Primary: pointer.Bool(true),
+					},
+				},
+			},
+		},
+	}
+
+	mockVMClient := cloud.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().Get(gomock.Any(), cloud.ResourceGroup, "vm1", gomock.Any()).Return(testVM, nil).AnyTimes()
+	mockInterfaceClient := cloud.InterfacesClient.(*mockinterfaceclient.MockInterface)
+	mockInterfaceClient.EXPECT().Get(gomock.Any(), cloud.ResourceGroup, "nic1", gomock.Any()).Return(testNIC, nil).AnyTimes()
+	mockInterfaceClient.EXPECT().CreateOrUpdate(gomock.Any(), cloud.ResourceGroup, "nic1", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _, _ string, nic network.Interface) *retry.Error {
+			ipConfigs := *nic.IPConfigurations
+			assert.Empty(t, *ipConfigs[0].LoadBalancerBackendAddressPools, "the backend pool should be removed from the old IP config")
+			assert.Equal(t, []network.BackendAddressPool{{ID: pointer.String(backendAddressPoolID)}}, *ipConfigs[1].LoadBalancerBackendAddressPools)
+			return nil
+		}).Times(1)
+
+	_, _, _, _, err := cloud.VMSet.EnsureHostInPool(&v1.Service{}, "vm1", backendAddressPoolID, "myAvailabilitySet")
+	assert.NoError(t, err)
+}
+
 func TestStandardEnsureHostsInPool(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1497,6 +1656,8 @@ func TestStandardEnsureHostsInPool(t *testing.T) {
 		vmSetName      string
 		expectedErr    bool
 		expectedErrMsg error
+
+		expectedUpdatedVMs int
 	}{
 		{
 			name:     "EnsureHostsInPool should return nil if there's no error when invoke EnsureHostInPool",
@@ -1566,6 +1727,23 @@ func TestStandardEnsureHostsInPool(t *testing.T) {
 			nicID:     "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic4",
 			vmSetName: "availabilityset-1",
 		},
+		{
+			name:     "EnsureHostsInPool should count the VMs whose NICs are updated",
+			service:  &v1.Service{},
+			nodeName: "vm5",
+			nodes: []*v1.Node{
+				{
+					ObjectMeta: meta.ObjectMeta{
+						Name: "vm5",
+					},
+				},
+			},
+			nicName:            "nic5",
+			nicID:              "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic5",
+			backendPoolID:      "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb1-internal/backendAddressPools/backendpool-2",
+			vmSetName:          "availabilityset-1",
+			expectedUpdatedVMs: 1,
+		},
 	}
 
 	for _, test := range testCases {
@@ -1586,12 +1764,13 @@ func TestStandardEnsureHostsInPool(t *testing.T) {
 			mockInterfaceClient.EXPECT().Get(gomock.Any(), cloud.ResourceGroup, test.nicName, gomock.Any()).Return(testNIC, nil).AnyTimes()
 			mockInterfaceClient.EXPECT().CreateOrUpdate(gomock.Any(), cloud.ResourceGroup, gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
-			err := cloud.VMSet.EnsureHostsInPool(test.service, test.nodes, test.backendPoolID, test.vmSetName)
+			updatedVMs, err := cloud.VMSet.EnsureHostsInPool(test.service, test.nodes, test.backendPoolID, test.vmSetName)
 			if test.expectedErr {
 				assert.EqualError(t, test.expectedErrMsg, err.Error(), test.name)
 			} else {
 				assert.Nil(t, err, test.name)
 			}
+			assert.Equal(t, test.expectedUpdatedVMs, updatedVMs, test.name)
 		})
 	}
 }
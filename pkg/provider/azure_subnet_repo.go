@@ -17,11 +17,17 @@ limitations under the License.
 package provider
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2022-07-01/network"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 )
 
+var subnetIDRE = regexp.MustCompile(`(?i)^/subscriptions/([^/]+)/resourceGroups/([^/]+)/providers/Microsoft.Network/virtualNetworks/([^/]+)/subnets/([^/]+)$`)
+
 // CreateOrUpdateSubnet invokes az.SubnetClient.CreateOrUpdate with exponential backoff retry
 func (az *Cloud) CreateOrUpdateSubnet(service *v1.Service, subnet network.Subnet) error {
 	ctx, cancel := getContextWithCancel()
@@ -66,3 +72,29 @@ func (az *Cloud) getSubnet(virtualNetworkName string, subnetName string) (networ
 	}
 	return subnet, exists, nil
 }
+
+// getSubnetByID gets the subnet by its resource ID, which may be in a different
+// resource group or virtual network from the configured ones. The subnet must be in the
+// subscription of the network resources, which is the only one SubnetsClient reads from.
+func (az *Cloud) getSubnetByID(subnetID string) (network.Subnet, bool, error) {
+	matches := subnetIDRE.FindStringSubmatch(subnetID)
+	if len(matches) != 5 {
+		return network.Subnet{}, false, fmt.Errorf("invalid subnet ID %q", subnetID)
+	}
+	if subscriptionID := az.getNetworkResourceSubscriptionID(); !strings.EqualFold(matches[1], subscriptionID) {
+		return network.Subnet{}, false, fmt.Errorf("subnet ID %q is not in the subscription %s of the network resources", subnetID, subscriptionID)
+	}
+
+	ctx, cancel := getContextWithCancel()
+	defer cancel()
+	subnet, err := az.SubnetsClient.Get(ctx, matches[2], matches[3], matches[4], "")
+	exists, rerr := checkResourceExistsFromError(err)
+	if rerr != nil {
+		return subnet, false, rerr.Error()
+	}
+
+	if !exists {
+		klog.V(2).Infof("Subnet %q not found", subnetID)
+	}
+	return subnet, exists, nil
+}
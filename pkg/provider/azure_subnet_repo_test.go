@@ -15,3 +15,86 @@ limitations under the License.
 */
 
 package provider
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2022-07-01/network"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/subnetclient/mocksubnetclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
+)
+
+func TestGetSubnetByID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		desc                string
+		subnetID            string
+		networkSubscription string
+		getErr              *retry.Error
+		expectGet           bool
+		expectedExists      bool
+		expectedErrMsg      string
+	}{
+		{
+			desc:           "getSubnetByID should get the subnet in the subscription",
+			subnetID:       "/subscriptions/subscription/resourceGroups/vnetrg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			expectGet:      true,
+			expectedExists: true,
+		},
+		{
+			desc:      "getSubnetByID should report the subnet not found",
+			subnetID:  "/subscriptions/subscription/resourceGroups/vnetrg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			getErr:    &retry.Error{HTTPStatusCode: http.StatusNotFound},
+			expectGet: true,
+		},
+		{
+			desc:                "getSubnetByID should get the subnet in the subscription of the network resources",
+			subnetID:            "/subscriptions/networksubscription/resourceGroups/vnetrg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			networkSubscription: "networksubscription",
+			expectGet:           true,
+			expectedExists:      true,
+		},
+		{
+			desc:           "getSubnetByID should reject the subnet in another subscription",
+			subnetID:       "/subscriptions/other/resourceGroups/vnetrg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			expectedErrMsg: "is not in the subscription subscription of the network resources",
+		},
+		{
+			desc:           "getSubnetByID should reject the malformed subnet ID",
+			subnetID:       "subnet",
+			expectedErrMsg: "invalid subnet ID",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			if tc.networkSubscription != "" {
+				az.NetworkResourceSubscriptionID = tc.networkSubscription
+			}
+			mockSubnetsClient := az.SubnetsClient.(*mocksubnetclient.MockInterface)
+			if tc.expectGet {
+				mockSubnetsClient.EXPECT().Get(gomock.Any(), "vnetrg", "vnet", "subnet", "").Return(network.Subnet{ID: pointer.String(tc.subnetID)}, tc.getErr).Times(1)
+			}
+
+			subnet, exists, err := az.getSubnetByID(tc.subnetID)
+			if tc.expectedErrMsg != "" {
+				assert.ErrorContains(t, err, tc.expectedErrMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedExists, exists)
+			if exists {
+				assert.Equal(t, tc.subnetID, pointer.StringDeref(subnet.ID, ""))
+			}
+		})
+	}
+}
@@ -39,6 +39,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	cloudproviderapi "k8s.io/cloud-provider/api"
 	servicehelpers "k8s.io/cloud-provider/service/helpers"
@@ -124,7 +125,7 @@ func TestAddPort(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
 	assert.Nil(t, err)
@@ -515,7 +516,7 @@ func testLoadBalancerServiceDefaultModeSelection(t *testing.T, isInternal bool)
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 	mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 	expectedLBs := make([]network.LoadBalancer, 0)
@@ -584,7 +585,7 @@ func testLoadBalancerServiceAutoModeSelection(t *testing.T, isInternal bool) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 	mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 	for index := 1; index <= serviceCount; index++ {
@@ -659,7 +660,7 @@ func testLoadBalancerServicesSpecifiedSelection(t *testing.T, isInternal bool) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 	mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 	for index := 1; index <= serviceCount; index++ {
@@ -718,7 +719,7 @@ func testLoadBalancerMaxRulesServices(t *testing.T, isInternal bool) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 	mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 	for index := 1; index <= az.Config.MaximumLoadBalancerRuleCount; index++ {
@@ -803,7 +804,7 @@ func testLoadBalancerServiceAutoModeDeleteSelection(t *testing.T, isInternal boo
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 	mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 	for index := 1; index <= serviceCount; index++ {
@@ -897,7 +898,7 @@ func TestReconcileLoadBalancerAddServiceOnInternalSubnet(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
 	assert.Nil(t, err)
@@ -962,7 +963,7 @@ func TestReconcileLoadBalancerAddServicesOnMultipleSubnets(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	// svc1 is using LB without "-internal" suffix
 	lb, err := az.reconcileLoadBalancer(testClusterName, &svc1, clusterResources.nodes, true /* wantLb */)
@@ -1010,7 +1011,7 @@ func TestReconcileLoadBalancerEditServiceSubnet(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	expectedPLS := make([]network.PrivateLinkService, 0)
 	mockPLSClient := az.PrivateLinkServiceClient.(*mockprivatelinkserviceclient.MockInterface)
@@ -1057,7 +1058,7 @@ func TestReconcileLoadBalancerNodeHealth(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
 	assert.Nil(t, err)
@@ -1068,6 +1069,65 @@ func TestReconcileLoadBalancerNodeHealth(t *testing.T) {
 	validateLoadBalancer(t, lb, svc)
 }
 
+func TestReconcileLoadBalancerOutboundOnlyService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.LoadBalancerSku = consts.LoadBalancerSkuStandard
+	clusterResources, expectedInterfaces, expectedVirtualMachines := getClusterResources(az, 1, 1)
+	setMockEnvDualStack(az, ctrl, expectedInterfaces, expectedVirtualMachines, 1)
+
+	svc := getTestServiceDualStack("service1", v1.ProtocolTCP, map[string]string{consts.ServiceAnnotationLoadBalancerOutboundOnly: consts.TrueAnnotationValue}, 80)
+
+	expectedLBs := make([]network.LoadBalancer, 0)
+	setMockLBsDualStack(az, ctrl, &expectedLBs, "service", 1, 1, false)
+
+	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
+	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
+
+	lb, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
+	assert.NoError(t, err)
+
+	// ensure the frontend ip configurations are only referenced by the outbound rules
+	assert.Equal(t, 2, len(*lb.FrontendIPConfigurations))
+	assert.Empty(t, *lb.LoadBalancingRules)
+	assert.Nil(t, lb.Probes)
+	assert.Equal(t, 2, len(*lb.OutboundRules))
+	backendPoolIDs := az.getBackendPoolIDsForService(&svc, testClusterName, *lb.Name)
+	for i, isIPv6 := range []bool{false, true} {
+		expectedRule, err := az.getExpectedOutboundRule(&svc, *(*lb.FrontendIPConfigurations)[i].ID, backendPoolIDs[isIPv6], isIPv6)
+		assert.NoError(t, err)
+		assert.True(t, findOutboundRule(*lb.OutboundRules, expectedRule), "outbound rule %s is missing", *expectedRule.Name)
+	}
+
+	// ensure no inbound security rule is created for the service
+	sg := getTestSecurityGroupDualStack(az, getTestServiceDualStack("service1", v1.ProtocolTCP, nil, 80))
+	setMockSecurityGroup(az, ctrl, sg)
+	lbIPs := []string{"1.2.3.4", "fd00::eef0"}
+	sg, err = az.reconcileSecurityGroup(testClusterName, &svc, &lbIPs, lb.Name, true /* wantLb */)
+	assert.NoError(t, err)
+	for _, rule := range *sg.SecurityRules {
+		assert.False(t, az.serviceOwnsRule(&svc, pointer.StringDeref(rule.Name, "")), "unexpected security rule %s", pointer.StringDeref(rule.Name, ""))
+	}
+
+	// ensure the outbound rules are cleaned up together with the frontend ip configurations
+	mockLBsClient := mockloadbalancerclient.NewMockInterface(ctrl)
+	az.LoadBalancerClient = mockLBsClient
+	mockLBsClient.EXPECT().CreateOrUpdate(gomock.Any(), az.ResourceGroup, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBsClient.EXPECT().Get(gomock.Any(), az.ResourceGroup, *expectedLBs[0].Name, gomock.Any()).Return(expectedLBs[0], nil).MaxTimes(2)
+	mockLBsClient.EXPECT().List(gomock.Any(), az.ResourceGroup).Return(expectedLBs, nil).MaxTimes(3)
+	mockLBsClient.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	mockPLSClient := az.PrivateLinkServiceClient.(*mockprivatelinkserviceclient.MockInterface)
+	mockPLSClient.EXPECT().List(gomock.Any(), az.Config.ResourceGroup).Return([]network.PrivateLinkService{}, nil).AnyTimes()
+
+	lb, err = az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, false /* wantLb */)
+	assert.NoError(t, err)
+	assert.Zero(t, len(*lb.FrontendIPConfigurations))
+	assert.Zero(t, len(*lb.OutboundRules))
+}
+
 // Test removing all services results in removing the frontend ip configuration
 func TestReconcileLoadBalancerRemoveService(t *testing.T) {
 	ctrl := gomock.NewController(t)
@@ -1084,7 +1144,7 @@ func TestReconcileLoadBalancerRemoveService(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	_, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
 	assert.Nil(t, err)
@@ -1122,7 +1182,7 @@ func TestReconcileLoadBalancerRemoveAllPortsRemovesFrontendConfig(t *testing.T)
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
 	assert.Nil(t, err)
@@ -1158,7 +1218,7 @@ func TestReconcileLoadBalancerRemovesPort(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	expectedLBs := make([]network.LoadBalancer, 0)
 	setMockLBsDualStack(az, ctrl, &expectedLBs, "service", 1, 1, false)
@@ -1192,7 +1252,7 @@ func TestReconcileLoadBalancerMultipleServices(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	expectedPLS := make([]network.PrivateLinkService, 0)
 	mockPLSClient := az.PrivateLinkServiceClient.(*mockprivatelinkserviceclient.MockInterface)
@@ -1254,7 +1314,7 @@ func TestServiceDefaultsToNoSessionPersistence(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
 	if err != nil {
@@ -1313,7 +1373,7 @@ func TestServiceRespectsNoSessionAffinity(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
 	if err != nil {
@@ -1374,7 +1434,7 @@ func TestServiceRespectsClientIPSessionAffinity(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, err := az.reconcileLoadBalancer(testClusterName, &svc, clusterResources.nodes, true /* wantLb */)
 	if err != nil {
@@ -1406,7 +1466,7 @@ func TestReconcileSecurityGroupNewServiceAddsPort(t *testing.T) {
 	setMockLBsDualStack(az, ctrl, &expectedLBs, "service", 1, 1, false)
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 	lb, _ := az.reconcileLoadBalancer(testClusterName, &svc1, clusterResources.nodes, true)
 	lbStatus, _, _, _ := az.getServiceLoadBalancerStatus(&svc1, lb)
 
@@ -1434,7 +1494,7 @@ func TestReconcileSecurityGroupNewInternalServiceAddsPort(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, _ := az.reconcileLoadBalancer(testClusterName, &svc1, clusterResources.nodes, true)
 	lbStatus, _, _, _ := az.getServiceLoadBalancerStatus(&svc1, lb)
@@ -1466,7 +1526,7 @@ func TestReconcileSecurityGroupRemoveService(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, _ := az.reconcileLoadBalancer(testClusterName, &service1, clusterResources.nodes, true)
 	_, _ = az.reconcileLoadBalancer(testClusterName, &service2, clusterResources.nodes, true)
@@ -1496,7 +1556,7 @@ func TestReconcileSecurityGroupRemoveServiceRemovesPort(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	getTestSecurityGroupDualStack(az, svc)
 	svcUpdated := getTestServiceDualStack("service1", v1.ProtocolTCP, nil, 80)
@@ -1537,7 +1597,7 @@ func TestReconcileSecurityWithSourceRanges(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	getTestSecurityGroupDualStack(az, svc)
 	expectedLBs := make([]network.LoadBalancer, 0)
@@ -1587,7 +1647,7 @@ func TestReconcileSecurityGroupEtagMismatch(t *testing.T) {
 
 	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
 	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
-	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
 
 	lb, _ := az.reconcileLoadBalancer(testClusterName, &svc1, clusterResources.nodes, true)
 	lbStatus, _, _, _ := az.getServiceLoadBalancerStatus(&svc1, lb)
@@ -1694,6 +1754,215 @@ func TestReconcilePublicIPsWithExternalAndInternalSwitch(t *testing.T) {
 	validatePublicIPs(t, pips, &svc, true)
 }
 
+func TestReconcilePublicIPsWithPublicToPrivateLinkServiceSwitch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	svc := getTestService("service1", v1.ProtocolTCP, nil, false, 80)
+	pipName := "testCluster-aservice1"
+	existingPIP := network.PublicIPAddress{
+		Name:     pointer.String(pipName),
+		ID:       pointer.String(pipName),
+		Location: &az.Location,
+		PublicIPAddressPropertiesFormat: &network.PublicIPAddressPropertiesFormat{
+			PublicIPAllocationMethod: network.Static,
+			PublicIPAddressVersion:   network.IPv4,
+			IPAddress:                pointer.String("1.2.3.4"),
+		},
+		Tags: map[string]*string{
+			consts.ServiceTagKey:  pointer.String("default/service1"),
+			consts.ClusterNameKey: pointer.String(testClusterName),
+		},
+	}
+
+	mockPIPsClient := mockpublicipclient.NewMockInterface(ctrl)
+	az.PublicIPAddressesClient = mockPIPsClient
+	mockPIPsClient.EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.PublicIPAddress{existingPIP}, nil).AnyTimes()
+	mockPIPsClient.EXPECT().Get(gomock.Any(), az.ResourceGroup, pipName, gomock.Any()).Return(existingPIP, nil).AnyTimes()
+	mockPIPsClient.EXPECT().CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	pips, err := az.reconcilePublicIPs(testClusterName, &svc, "", true /* wantLb*/)
+	assert.Nil(t, err)
+	validatePublicIPs(t, pips, &svc, true)
+
+	// Update to internal service exposed by private link service, the owned public IP should be released
+	svcUpdated := getInternalTestService("service1", 80)
+	svcUpdated.Annotations[consts.ServiceAnnotationPLSCreation] = "true"
+	mockPIPsClient.EXPECT().Delete(gomock.Any(), az.ResourceGroup, pipName).Return(nil).Times(1)
+	pips, err = az.reconcilePublicIPs(testClusterName, &svcUpdated, "", true /* wantLb*/)
+	assert.Nil(t, err)
+	assert.Empty(t, pips)
+	validatePublicIPs(t, pips, &svcUpdated, true)
+}
+
+func TestReconcileServiceWithPublicToPrivateLinkServiceSwitch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	clusterResources, expectedInterfaces, expectedVirtualMachines := getClusterResources(az, 1, 1)
+	setMockEnv(az, ctrl, expectedInterfaces, expectedVirtualMachines, 1)
+
+	mockLBBackendPool := az.LoadBalancerBackendPool.(*MockBackendPool)
+	mockLBBackendPool.EXPECT().ReconcileBackendPools(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, false, false, nil).AnyTimes()
+	mockLBBackendPool.EXPECT().EnsureHostsInPool(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
+	mockLBBackendPool.EXPECT().GetBackendPrivateIPs(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	// the public load balancer and public IP of the service before it switches to the private link service
+	expectedLBs := make([]network.LoadBalancer, 0)
+	setMockLBs(az, ctrl, &expectedLBs, "service", 1, 1, false)
+	lbs := map[string]network.LoadBalancer{testClusterName: expectedLBs[0]}
+	mockLBsClient := mockloadbalancerclient.NewMockInterface(ctrl)
+	az.LoadBalancerClient = mockLBsClient
+	mockLBsClient.EXPECT().List(gomock.Any(), az.ResourceGroup).DoAndReturn(func(ctx context.Context, resourceGroupName string) ([]network.LoadBalancer, *retry.Error) {
+		result := make([]network.LoadBalancer, 0, len(lbs))
+		for _, lb := range lbs {
+			result = append(result, lb)
+		}
+		return result, nil
+	}).AnyTimes()
+	mockLBsClient.EXPECT().Get(gomock.Any(), az.ResourceGroup, gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName, lbName, expand string) (network.LoadBalancer, *retry.Error) {
+		if lb, ok := lbs[lbName]; ok {
+			return lb, nil
+		}
+		return network.LoadBalancer{}, &retry.Error{HTTPStatusCode: http.StatusNotFound, RawError: cloudprovider.InstanceNotFound}
+	}).AnyTimes()
+	mockLBsClient.EXPECT().CreateOrUpdate(gomock.Any(), az.ResourceGroup, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName, lbName string, lb network.LoadBalancer, etag string) *retry.Error {
+		lbs[lbName] = lb
+		return nil
+	}).AnyTimes()
+	mockLBsClient.EXPECT().Delete(gomock.Any(), az.ResourceGroup, gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName, lbName string) *retry.Error {
+		delete(lbs, lbName)
+		return nil
+	}).AnyTimes()
+	pipName := "testCluster-aservice1"
+	existingPIP := network.PublicIPAddress{
+		Name:     pointer.String(pipName),
+		ID:       pointer.String(pipName),
+		Location: &az.Location,
+		PublicIPAddressPropertiesFormat: &network.PublicIPAddressPropertiesFormat{
+			PublicIPAllocationMethod: network.Static,
+			PublicIPAddressVersion:   network.IPv4,
+			IPAddress:                pointer.String("1.2.3.4"),
+		},
+		Tags: map[string]*string{
+			consts.ServiceTagKey:  pointer.String("default/service1"),
+			consts.ClusterNameKey: pointer.String(testClusterName),
+		},
+	}
+	mockPIPsClient := mockpublicipclient.NewMockInterface(ctrl)
+	az.PublicIPAddressesClient = mockPIPsClient
+	mockPIPsClient.EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.PublicIPAddress{existingPIP}, nil).AnyTimes()
+	mockPIPsClient.EXPECT().Get(gomock.Any(), az.ResourceGroup, pipName, gomock.Any()).Return(existingPIP, nil).AnyTimes()
+
+	svc := getInternalTestService("service1", 80)
+	svc.Annotations[consts.ServiceAnnotationPLSCreation] = "true"
+	subnetID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s", az.SubscriptionID, az.VnetResourceGroup, az.VnetName, az.SubnetName)
+	mockSubnetsClient := az.SubnetsClient.(*mocksubnetclient.MockInterface)
+	mockSubnetsClient.EXPECT().Get(gomock.Any(), az.VnetResourceGroup, az.VnetName, az.SubnetName, "").Return(network.Subnet{
+		ID:   &subnetID,
+		Name: &az.SubnetName,
+		SubnetPropertiesFormat: &network.SubnetPropertiesFormat{
+			PrivateLinkServiceNetworkPolicies: network.VirtualNetworkPrivateLinkServiceNetworkPoliciesDisabled,
+		},
+	}, nil).AnyTimes()
+
+	mockPLSClient := mockprivatelinkserviceclient.NewMockInterface(ctrl)
+	az.PrivateLinkServiceClient = mockPLSClient
+	mockPLSClient.EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.PrivateLinkService{}, nil).AnyTimes()
+	mockPLSClient.EXPECT().Get(gomock.Any(), az.ResourceGroup, gomock.Any(), gomock.Any()).Return(network.PrivateLinkService{}, nil).AnyTimes()
+
+	// the public IP of the flipped service is deleted before the private link service is created
+	gomock.InOrder(
+		mockPIPsClient.EXPECT().Delete(gomock.Any(), az.ResourceGroup, pipName).Return(nil).Times(1),
+		mockPLSClient.EXPECT().CreateOrUpdate(gomock.Any(), az.ResourceGroup, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1),
+	)
+
+	_, _, err := az.reconcileService(context.TODO(), testClusterName, &svc, clusterResources.nodes)
+	assert.NoError(t, err)
+}
+
+func TestReconcilePublicIPsWithDualStackToIPv4Switch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	svc := getTestServiceDualStack("service1", v1.ProtocolTCP, nil, 80)
+	newPIP := func(name string, ipVersion network.IPVersion, ip string) network.PublicIPAddress {
+		return network.PublicIPAddress{
+			Name:     pointer.String(name),
+			ID:       pointer.String(name),
+			Location: &az.Location,
+			PublicIPAddressPropertiesFormat: &network.PublicIPAddressPropertiesFormat{
+				PublicIPAllocationMethod: network.Static,
+				PublicIPAddressVersion:   ipVersion,
+				IPAddress:                pointer.String(ip),
+			},
+			Tags: map[string]*string{
+				consts.ServiceTagKey:  pointer.String("default/service1"),
+				consts.ClusterNameKey: pointer.String(testClusterName),
+			},
+		}
+	}
+	existingPIPv4 := newPIP("testCluster-aservice1", network.IPv4, "1.2.3.4")
+	existingPIPv6 := newPIP("testCluster-aservice1-IPv6", network.IPv6, "fe::1")
+
+	mockPIPsClient := mockpublicipclient.NewMockInterface(ctrl)
+	az.PublicIPAddressesClient = mockPIPsClient
+	mockPIPsClient.EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.PublicIPAddress{existingPIPv4, existingPIPv6}, nil).AnyTimes()
+	mockPIPsClient.EXPECT().Get(gomock.Any(), az.ResourceGroup, *existingPIPv4.Name, gomock.Any()).Return(existingPIPv4, nil).AnyTimes()
+	mockPIPsClient.EXPECT().Get(gomock.Any(), az.ResourceGroup, *existingPIPv6.Name, gomock.Any()).Return(existingPIPv6, nil).AnyTimes()
+	mockPIPsClient.EXPECT().CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	pips, err := az.reconcilePublicIPs(testClusterName, &svc, "", true /* wantLb*/)
+	assert.Nil(t, err)
+	assert.Len(t, pips, 2)
+
+	// Drop IPv6 from the service, the owned IPv6 public IP should be released
+	svcUpdated := getTestService("service1", v1.ProtocolTCP, nil, false, 80)
+	mockPIPsClient.EXPECT().Delete(gomock.Any(), az.ResourceGroup, *existingPIPv6.Name).Return(nil).Times(1)
+	pips, err = az.reconcilePublicIPs(testClusterName, &svcUpdated, "", true /* wantLb*/)
+	assert.Nil(t, err)
+	assert.Len(t, pips, 1)
+	assert.Equal(t, *existingPIPv4.Name, pointer.StringDeref(pips[0].Name, ""))
+}
+
+func TestReconcilePublicIPWithQuotaExceeded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	recorder := record.NewFakeRecorder(10)
+	az.eventRecorder = recorder
+	svc := getTestService("service1", v1.ProtocolTCP, nil, false, 80)
+
+	quotaErr := &retry.Error{
+		HTTPStatusCode: http.StatusBadRequest,
+		Retriable:      true,
+		RawError: fmt.Errorf(`{"error": {"code": "PublicIPCountLimitReached", ` +
+			`"message": "Cannot create more than 10 public IP addresses for this subscription in this region."}}`),
+	}
+	mockPIPsClient := mockpublicipclient.NewMockInterface(ctrl)
+	az.PublicIPAddressesClient = mockPIPsClient
+	mockPIPsClient.EXPECT().List(gomock.Any(), az.ResourceGroup).Return([]network.PublicIPAddress{}, nil).AnyTimes()
+	mockPIPsClient.EXPECT().CreateOrUpdate(gomock.Any(), az.ResourceGroup, "testCluster-aservice1", gomock.Any()).Return(quotaErr).Times(1)
+
+	_, err := az.reconcilePublicIP(nil, testClusterName, &svc, "", true /* wantLb */, false /* isIPv6 */)
+	assert.Error(t, err)
+	assert.False(t, retry.IsErrorRetriable(err), "the quota error should not be retried")
+
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	assert.Equal(t, []string{
+		"Warning PublicIPQuotaExceeded Failed to create public IP testCluster-aservice1 in resource group rg because the public IP address quota is exceeded, " +
+			"request a quota increase or delete the unused public IPs: Cannot create more than 10 public IP addresses for this subscription in this region.",
+	}, events)
+}
+
 const networkInterfacesIDTemplate = "/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkInterfaces/%s"
 const primaryIPConfigIDTemplate = "%s/ipConfigurations/ipconfig"
 
@@ -2588,6 +2857,15 @@ func validateConfig(t *testing.T, config string) { //nolint
 	}
 }
 
+func TestParseConfigRejectsNegativeVmssFlexCacheTTL(t *testing.T) {
+	_, err := ParseConfig(strings.NewReader(`{"vmssFlexCacheTTLInSeconds": -1}`))
+	assert.EqualError(t, err, "vmssFlexCacheTTLInSeconds -1 must not be negative")
+
+	config, err := ParseConfig(strings.NewReader(`{"vmssFlexCacheTTLInSeconds": 0}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, config.VmssFlexCacheTTLInSeconds)
+}
+
 func getCloudFromConfig(t *testing.T, config string) *Cloud {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -2740,6 +3018,74 @@ func TestIfServiceSpecifiesSharedRuleAndRuleDoesNotExistItIsCreated(t *testing.T
 	}
 }
 
+func TestReconcileSecurityGroupServiceWithoutPorts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		desc            string
+		useSharedRule   bool
+		expectRuleCount int
+	}{
+		{
+			desc:            "the rules of the service should be removed while it has no ports",
+			expectRuleCount: 0,
+		},
+		{
+			desc:            "the shared rules should be kept while the service has no ports",
+			useSharedRule:   true,
+			expectRuleCount: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			az := GetTestCloud(ctrl)
+			svc := getTestServiceDualStack("servicea", v1.ProtocolTCP, nil, 80)
+			setServiceLoadBalancerIP(&svc, testIPs[0][false])
+			setServiceLoadBalancerIP(&svc, testIPs[0][true])
+			if tc.useSharedRule {
+				svc.Annotations[consts.ServiceAnnotationSharedSecurityRule] = consts.TrueAnnotationValue
+			}
+			lbIPs := &[]string{getServiceLoadBalancerIP(&svc, false), getServiceLoadBalancerIP(&svc, true)}
+
+			sg := getTestSecurityGroupDualStack(az)
+			setMockSecurityGroup(az, ctrl, sg)
+			sg, err := az.reconcileSecurityGroup(testClusterName, &svc, lbIPs, nil, true)
+			assert.NoError(t, err)
+			validateSecurityGroupDualStack(t, az, sg, svc)
+
+			svcWithoutPorts := svc.DeepCopy()
+			svcWithoutPorts.Spec.Ports = nil
+			setMockSecurityGroup(az, ctrl, sg)
+			sg, err = az.reconcileSecurityGroup(testClusterName, svcWithoutPorts, lbIPs, nil, true)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectRuleCount, len(*sg.SecurityRules))
+
+			// the rules converge once the ports return
+			setMockSecurityGroup(az, ctrl, sg)
+			sg, err = az.reconcileSecurityGroup(testClusterName, &svc, lbIPs, nil, true)
+			assert.NoError(t, err)
+			validateSecurityGroupDualStack(t, az, sg, svc)
+
+			// the shared rules of the deleted service without ports could not be found, which is an error
+			// rather than leaking them
+			mockSGsClient := mocksecuritygroupclient.NewMockInterface(ctrl)
+			az.SecurityGroupsClient = mockSGsClient
+			mockSGsClient.EXPECT().Get(gomock.Any(), az.SecurityGroupResourceGroup, az.SecurityGroupName, gomock.Any()).Return(*sg, nil).AnyTimes()
+			if tc.useSharedRule {
+				_, err = az.reconcileSecurityGroup(testClusterName, svcWithoutPorts, lbIPs, nil, false)
+				assert.ErrorContains(t, err, "no port info for reconciling shared rule for service servicea")
+				return
+			}
+			mockSGsClient.EXPECT().CreateOrUpdate(gomock.Any(), az.SecurityGroupResourceGroup, az.SecurityGroupName, gomock.Any(), gomock.Any()).Return(nil).Times(1)
+			sg, err = az.reconcileSecurityGroup(testClusterName, svcWithoutPorts, lbIPs, nil, false)
+			assert.NoError(t, err)
+			assert.Empty(t, *sg.SecurityRules)
+		})
+	}
+}
+
 func TestIfServiceSpecifiesSharedRuleAndRuleExistsThenTheServicesPortAndAddressAreAdded(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -3483,6 +3829,49 @@ func TestGetNodeResourceGroup(t *testing.T) {
 	}
 }
 
+func TestGetNodeResourceGroupFromLabel(t *testing.T) {
+	tests := []struct {
+		name               string
+		nodeResourceGroups map[string]string
+		node               string
+		informerSynced     bool
+		expected           string
+		expectedOK         bool
+	}{
+		{
+			name:               "no RG should be returned if the node has no RG label",
+			nodeResourceGroups: map[string]string{},
+			informerSynced:     true,
+			node:               "node1",
+		},
+		{
+			name:               "node RGs should be returned",
+			nodeResourceGroups: map[string]string{"node1": "rg1", "node2": "rg2"},
+			informerSynced:     true,
+			node:               "node1",
+			expected:           "rg1",
+			expectedOK:         true,
+		},
+		{
+			name:               "no RG should be returned if informer hasn't synced yet",
+			nodeResourceGroups: map[string]string{"node1": "rg1", "node2": "rg2"},
+			node:               "node1",
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	az := GetTestCloud(ctrl)
+	for _, test := range tests {
+		az.nodeResourceGroups = test.nodeResourceGroups
+		informerSynced := test.informerSynced
+		az.nodeInformerSynced = func() bool { return informerSynced }
+		actual, ok := az.getNodeResourceGroupFromLabel(test.node)
+		assert.Equal(t, test.expectedOK, ok, test.name)
+		assert.Equal(t, test.expected, actual, test.name)
+	}
+}
+
 func TestSetInformers(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -447,6 +447,25 @@ func getServicePIPPrefixID(service *v1.Service, isIPv6 bool) string {
 	return service.Annotations[consts.ServiceAnnotationPIPPrefixIDDualStack[isIPv6]]
 }
 
+// isScaleSetBeingDeleted returns true if the scale set is being deleted or deallocated according to its
+// provisioning state, in which case the CreateOrUpdate requests on it or its instances would be rejected.
+func isScaleSetBeingDeleted(provisioningState *string) bool {
+	state := pointer.StringDeref(provisioningState, "")
+	return strings.EqualFold(state, consts.ProvisioningStateDeleting) ||
+		strings.EqualFold(state, consts.VirtualMachineScaleSetsDeallocating)
+}
+
+// getPublicIPDdosProtectionPlanID returns the DDoS protection plan of the public IPs created for the service.
+// The Service annotation takes precedence over the cloud config.
+func (az *Cloud) getPublicIPDdosProtectionPlanID(service *v1.Service) string {
+	if service != nil {
+		if id := strings.TrimSpace(service.Annotations[consts.ServiceAnnotationPIPDdosProtectionPlanID]); id != "" {
+			return id
+		}
+	}
+	return az.PublicIPDdosProtectionPlanID
+}
+
 // getResourceByIPFamily returns the resource name of with IPv6 suffix when
 // it is a dual-stack Service and the resource is of IPv6.
 // NOTICE: For PIPs of IPv6 Services created with CCM v1.27.1, after the CCM is upgraded,
@@ -693,16 +693,16 @@ func (ss *ScaleSet) getPrimaryInterfaceID(vm *virtualmachine.VirtualMachine) (st
 		return "", fmt.Errorf("failed to find the network interfaces for vm %s", pointer.StringDeref(machine.Name, ""))
 	}
 
-	if len(*machine.NetworkProfile.NetworkInterfaces) == 1 {
-		return *(*machine.NetworkProfile.NetworkInterfaces)[0].ID, nil
-	}
-
 	for _, ref := range *machine.NetworkProfile.NetworkInterfaces {
-		if pointer.BoolDeref(ref.Primary, false) {
+		if ref.NetworkInterfaceReferenceProperties != nil && pointer.BoolDeref(ref.Primary, false) {
 			return *ref.ID, nil
 		}
 	}
 
+	if len(*machine.NetworkProfile.NetworkInterfaces) == 1 {
+		return *(*machine.NetworkProfile.NetworkInterfaces)[0].ID, nil
+	}
+
 	return "", fmt.Errorf("failed to find a primary nic for the vm. vmname=%q", pointer.StringDeref(machine.Name, ""))
 }
 
@@ -1065,6 +1065,35 @@ func getPrimaryIPConfigFromVMSSNetworkConfig(config *compute.VirtualMachineScale
 	return nil, fmt.Errorf("failed to find a primary IP configuration (IPv6=%t) for the VMSS VM or VMSS %q", isIPv6, resource)
 }
 
+// removeBackendPoolFromStaleVMSSIPConfigs removes the backend pool from the IP configurations of the network
+// configuration other than ipConfig, as removeBackendPoolFromStaleIPConfigs does for the nics of the VMs.
+// It returns true if any IP configuration is changed.
+func removeBackendPoolFromStaleVMSSIPConfigs(config *compute.VirtualMachineScaleSetNetworkConfiguration, ipConfig *compute.VirtualMachineScaleSetIPConfiguration, backendPoolID, resource string) bool {
+	if config == nil || config.VirtualMachineScaleSetNetworkConfigurationProperties == nil || config.IPConfigurations == nil || ipConfig == nil {
+		return false
+	}
+
+	changed := false
+	for _, ref := range *config.IPConfigurations {
+		if ref.VirtualMachineScaleSetIPConfigurationProperties == nil || ref.LoadBalancerBackendAddressPools == nil ||
+			strings.EqualFold(pointer.StringDeref(ref.Name, ""), pointer.StringDeref(ipConfig.Name, "")) {
+			continue
+		}
+		backendPools := make([]compute.SubResource, 0, len(*ref.LoadBalancerBackendAddressPools))
+		for _, pool := range *ref.LoadBalancerBackendAddressPools {
+			if strings.EqualFold(pointer.StringDeref(pool.ID, ""), backendPoolID) {
+				klog.V(2).Infof("removeBackendPoolFromStaleVMSSIPConfigs: moving the backend pool %s of %s from ipconfig %s to %s",
+					backendPoolID, resource, pointer.StringDeref(ref.Name, ""), pointer.StringDeref(ipConfig.Name, ""))
+				changed = true
+				continue
+			}
+			backendPools = append(backendPools, pool)
+		}
+		ref.LoadBalancerBackendAddressPools = &backendPools
+	}
+	return changed
+}
+
 // EnsureHostInPool ensures the given VM's Primary NIC's Primary IP Configuration is
 // participating in the specified LoadBalancer Backend Pool, which returns (resourceGroup, vmasName, instanceID, vmssVM, error).
 func (ss *ScaleSet) EnsureHostInPool(service *v1.Service, nodeName types.NodeName, backendPoolID string, vmSetNameOfLB string) (string, string, string, *compute.VirtualMachineScaleSetVM, error) {
@@ -1132,38 +1161,42 @@ func (ss *ScaleSet) EnsureHostInPool(service *v1.Service, nodeName types.NodeNam
 		}
 	}
 
+	isMovedFromStaleIPConfig := removeBackendPoolFromStaleVMSSIPConfigs(primaryNetworkInterfaceConfiguration, primaryIPConfiguration, backendPoolID, vmName)
+
 	// The backendPoolID has already been found from existing LoadBalancerBackendAddressPools.
-	if foundPool {
+	if foundPool && !isMovedFromStaleIPConfig {
 		return "", "", "", nil, nil
 	}
 
-	if ss.useStandardLoadBalancer() && len(newBackendPools) > 0 {
-		// Although standard load balancer supports backends from multiple scale
-		// sets, the same network interface couldn't be added to more than one load balancer of
-		// the same type. Omit those nodes (e.g. masters) so Azure ARM won't complain
-		// about this.
-		newBackendPoolsIDs := make([]string, 0, len(newBackendPools))
-		for _, pool := range newBackendPools {
-			if pool.ID != nil {
-				newBackendPoolsIDs = append(newBackendPoolsIDs, *pool.ID)
+	if !foundPool {
+		if ss.useStandardLoadBalancer() && len(newBackendPools) > 0 {
+			// Although standard load balancer supports backends from multiple scale
+			// sets, the same network interface couldn't be added to more than one load balancer of
+			// the same type. Omit those nodes (e.g. masters) so Azure ARM won't complain
+			// about this.
+			newBackendPoolsIDs := make([]string, 0, len(newBackendPools))
+			for _, pool := range newBackendPools {
+				if pool.ID != nil {
+					newBackendPoolsIDs = append(newBackendPoolsIDs, *pool.ID)
+				}
+			}
+			isSameLB, oldLBName, err := isBackendPoolOnSameLB(backendPoolID, newBackendPoolsIDs)
+			if err != nil {
+				return "", "", "", nil, err
+			}
+			if !isSameLB {
+				klog.V(4).Infof("Node %q has already been added to LB %q, omit adding it to a new one", nodeName, oldLBName)
+				return "", "", "", nil, nil
 			}
 		}
-		isSameLB, oldLBName, err := isBackendPoolOnSameLB(backendPoolID, newBackendPoolsIDs)
-		if err != nil {
-			return "", "", "", nil, err
-		}
-		if !isSameLB {
-			klog.V(4).Infof("Node %q has already been added to LB %q, omit adding it to a new one", nodeName, oldLBName)
-			return "", "", "", nil, nil
-		}
-	}
 
-	// Compose a new vmssVM with added backendPoolID.
-	newBackendPools = append(newBackendPools,
-		compute.SubResource{
-			ID: pointer.String(backendPoolID),
-		})
-	primaryIPConfiguration.LoadBalancerBackendAddressPools = &newBackendPools
+		// Compose a new vmssVM with added backendPoolID.
+		newBackendPools = append(newBackendPools,
+			compute.SubResource{
+				ID: pointer.String(backendPoolID),
+			})
+		primaryIPConfiguration.LoadBalancerBackendAddressPools = &newBackendPools
+	}
 	newVM := &compute.VirtualMachineScaleSetVM{
 		Location: &vm.Location,
 		VirtualMachineScaleSetVMProperties: &compute.VirtualMachineScaleSetVMProperties{
@@ -1260,7 +1293,7 @@ func (ss *ScaleSet) ensureVMSSInPool(service *v1.Service, nodes []*v1.Node, back
 
 		// When vmss is being deleted, CreateOrUpdate API would report "the vmss is being deleted" error.
 		// Since it is being deleted, we shouldn't send more CreateOrUpdate requests for it.
-		if vmss.ProvisioningState != nil && strings.EqualFold(*vmss.ProvisioningState, consts.VirtualMachineScaleSetsDeallocating) {
+		if isScaleSetBeingDeleted(vmss.ProvisioningState) {
 			klog.V(3).Infof("ensureVMSSInPool: found vmss %s being deleted, skipping", vmssName)
 			continue
 		}
@@ -1344,13 +1377,16 @@ func (ss *ScaleSet) ensureVMSSInPool(service *v1.Service, nodes []*v1.Node, back
 	return nil
 }
 
-func (ss *ScaleSet) ensureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID string, vmSetNameOfLB string) error {
+// ensureHostsInPool ensures the primary IP configurations of the VMSS uniform nodes are participating in the
+// backend pool, and returns the number of the VMs updated.
+func (ss *ScaleSet) ensureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID string, vmSetNameOfLB string) (int, error) {
 	mc := metrics.NewMetricContext("services", "vmss_ensure_hosts_in_pool", ss.ResourceGroup, ss.SubscriptionID, getServiceName(service))
 	isOperationSucceeded := false
 	defer func() {
 		mc.ObserveOperationWithResult(isOperationSucceeded)
 	}()
 
+	var updatedVMs atomic.Int32
 	hostUpdates := make([]func() error, 0, len(nodes))
 	nodeUpdates := make(map[vmssMetaInfo]map[string]compute.VirtualMachineScaleSetVM)
 	errors := make([]error, 0)
@@ -1365,7 +1401,7 @@ func (ss *ScaleSet) ensureHostsInPool(service *v1.Service, nodes []*v1.Node, bac
 		shouldExcludeLoadBalancer, err := ss.ShouldNodeExcludedFromLoadBalancer(localNodeName)
 		if err != nil {
 			klog.Errorf("ShouldNodeExcludedFromLoadBalancer(%s) failed with error: %v", localNodeName, err)
-			return err
+			return 0, err
 		}
 		if shouldExcludeLoadBalancer {
 			klog.V(4).Infof("Excluding unmanaged/external-resource-group node %q", localNodeName)
@@ -1428,33 +1464,34 @@ func (ss *ScaleSet) ensureHostsInPool(service *v1.Service, nodes []*v1.Node, bac
 				return rerr.Error()
 			}
 
+			updatedVMs.Add(int32(len(update)))
 			return nil
 		})
 	}
 	errs := utilerrors.AggregateGoroutines(hostUpdates...)
 	if errs != nil {
-		return utilerrors.Flatten(errs)
+		return int(updatedVMs.Load()), utilerrors.Flatten(errs)
 	}
 
 	// Fail if there are other errors.
 	if len(errors) > 0 {
-		return utilerrors.Flatten(utilerrors.NewAggregate(errors))
+		return int(updatedVMs.Load()), utilerrors.Flatten(utilerrors.NewAggregate(errors))
 	}
 
 	// Ensure the backendPoolID is also added on VMSS itself.
 	// Refer to issue kubernetes/kubernetes#80365 for detailed information
 	err := ss.ensureVMSSInPool(service, nodes, backendPoolID, vmSetNameOfLB)
 	if err != nil {
-		return err
+		return int(updatedVMs.Load()), err
 	}
 
 	isOperationSucceeded = true
-	return nil
+	return int(updatedVMs.Load()), nil
 }
 
 // EnsureHostsInPool ensures the given Node's primary IP configurations are
-// participating in the specified LoadBalancer Backend Pool.
-func (ss *ScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID string, vmSetNameOfLB string) error {
+// participating in the specified LoadBalancer Backend Pool. It returns the number of the VMs updated to join it.
+func (ss *ScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID string, vmSetNameOfLB string) (int, error) {
 	if ss.DisableAvailabilitySetNodes && !ss.EnableVmssFlexNodes {
 		return ss.ensureHostsInPool(service, nodes, backendPoolID, vmSetNameOfLB)
 	}
@@ -1473,7 +1510,7 @@ func (ss *ScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, bac
 		shouldExcludeLoadBalancer, err := ss.ShouldNodeExcludedFromLoadBalancer(localNodeName)
 		if err != nil {
 			klog.Errorf("ShouldNodeExcludedFromLoadBalancer(%s) failed with error: %v", localNodeName, err)
-			return err
+			return 0, err
 		}
 		if shouldExcludeLoadBalancer {
 			klog.V(4).Infof("Excluding unmanaged/external-resource-group node %q", localNodeName)
@@ -1509,24 +1546,28 @@ func (ss *ScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, bac
 		vmssUniformNodes = append(vmssUniformNodes, node)
 	}
 
+	updatedVMs := 0
 	if len(vmssFlexNodes) > 0 {
-		vmssFlexError := ss.flexScaleSet.EnsureHostsInPool(service, vmssFlexNodes, backendPoolID, vmSetNameOfLB)
+		vmssFlexUpdatedVMs, vmssFlexError := ss.flexScaleSet.EnsureHostsInPool(service, vmssFlexNodes, backendPoolID, vmSetNameOfLB)
+		updatedVMs += vmssFlexUpdatedVMs
 		errors = append(errors, vmssFlexError)
 	}
 
 	if len(vmasNodes) > 0 {
-		vmasError := ss.availabilitySet.EnsureHostsInPool(service, vmasNodes, backendPoolID, vmSetNameOfLB)
+		vmasUpdatedVMs, vmasError := ss.availabilitySet.EnsureHostsInPool(service, vmasNodes, backendPoolID, vmSetNameOfLB)
+		updatedVMs += vmasUpdatedVMs
 		errors = append(errors, vmasError)
 	}
 
 	if len(vmssUniformNodes) > 0 {
-		vmssUniformError := ss.ensureHostsInPool(service, vmssUniformNodes, backendPoolID, vmSetNameOfLB)
+		vmssUniformUpdatedVMs, vmssUniformError := ss.ensureHostsInPool(service, vmssUniformNodes, backendPoolID, vmSetNameOfLB)
+		updatedVMs += vmssUniformUpdatedVMs
 		errors = append(errors, vmssUniformError)
 	}
 
 	allErrors := utilerrors.Flatten(utilerrors.NewAggregate(errors))
 
-	return allErrors
+	return updatedVMs, allErrors
 }
 
 // ensureBackendPoolDeletedFromNode ensures the loadBalancer backendAddressPools deleted
@@ -1662,12 +1703,11 @@ func (ss *ScaleSet) ensureBackendPoolDeletedFromVMSS(backendPoolIDs []string, vm
 		}
 
 		flexScaleSet := ss.flexScaleSet.(*FlexScaleSet)
-		cachedFlex, err := flexScaleSet.vmssFlexCache.Get(consts.VmssFlexKey, azcache.CacheReadTypeDefault)
+		vmssFlexMap, err := flexScaleSet.getVmssFlexes(azcache.CacheReadTypeDefault)
 		if err != nil {
 			klog.Errorf("ensureBackendPoolDeletedFromVMSS: failed to get vmss flex from cache: %v", err)
 			return err
 		}
-		vmssFlexMap := cachedFlex.(*sync.Map)
 		vmssFlexMap.Range(func(key, value interface{}) bool {
 			vmssFlex := value.(*compute.VirtualMachineScaleSet)
 			if pointer.StringDeref(vmssFlex.Name, "") == vmSetName {
@@ -1718,7 +1758,7 @@ func (ss *ScaleSet) ensureBackendPoolDeletedFromVmssUniform(backendPoolIDs []str
 
 			// When vmss is being deleted, CreateOrUpdate API would report "the vmss is being deleted" error.
 			// Since it is being deleted, we shouldn't send more CreateOrUpdate requests for it.
-			if vmss.ProvisioningState != nil && strings.EqualFold(*vmss.ProvisioningState, consts.VirtualMachineScaleSetsDeallocating) {
+			if isScaleSetBeingDeleted(vmss.ProvisioningState) {
 				klog.V(3).Infof("ensureBackendPoolDeletedFromVMSS: found vmss %s being deleted, skipping", pointer.StringDeref(vmss.Name, ""))
 				return true
 			}
@@ -2125,7 +2165,7 @@ func (ss *ScaleSet) EnsureBackendPoolDeletedFromVMSets(vmssNamesMap map[string]b
 
 		// When vmss is being deleted, CreateOrUpdate API would report "the vmss is being deleted" error.
 		// Since it is being deleted, we shouldn't send more CreateOrUpdate requests for it.
-		if vmss.ProvisioningState != nil && strings.EqualFold(*vmss.ProvisioningState, consts.VirtualMachineScaleSetsDeallocating) {
+		if isScaleSetBeingDeleted(vmss.ProvisioningState) {
 			klog.V(3).Infof("EnsureBackendPoolDeletedFromVMSets: found vmss %s being deleted, skipping", vmssName)
 			continue
 		}
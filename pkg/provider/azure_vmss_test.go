@@ -2300,6 +2300,68 @@ func TestEnsureHostInPool(t *testing.T) {
 	}
 }
 
+func TestEnsureHostInPoolWithRenamedIPConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	backendPoolID := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb-internal/backendAddressPools/backendpool-1"
+	testCases := []struct {
+		description         string
+		primaryBackendPools []compute.SubResource
+	}{
+		{
+			description:         "EnsureHostInPool should move the backend pool from the old IP config to the primary one",
+			primaryBackendPools: []compute.SubResource{{ID: pointer.String(testLBBackendpoolID0)}},
+		},
+		{
+			description:         "EnsureHostInPool should remove the backend pool from the old IP config if the primary one has it",
+			primaryBackendPools: []compute.SubResource{{ID: pointer.String(testLBBackendpoolID0)}, {ID: pointer.String(backendPoolID)}},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.description, func(t *testing.T) {
+			ss, err := NewTestScaleSet(ctrl)
+			assert.NoError(t, err)
+			ss.LoadBalancerSku = consts.LoadBalancerSkuStandard
+
+			expectedVMSS := buildTestVMSS(testVMSSName, "vmss-vm-")
+			mockVMSSClient := ss.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+			mockVMSSClient.EXPECT().List(gomock.Any(), ss.ResourceGroup).Return([]compute.VirtualMachineScaleSet{expectedVMSS}, nil).AnyTimes()
+
+			expectedVMSSVMs, _, _ := buildTestVirtualMachineEnv(ss.cloud, testVMSSName, "", 0, []string{"vmss-vm-000000"}, "", false)
+			// the membership is left on the old IP config after the primary IP config is renamed
+			networkConfig := &(*expectedVMSSVMs[0].NetworkProfileConfiguration.NetworkInterfaceConfigurations)[0]
+			networkConfig.IPConfigurations = &[]compute.VirtualMachineScaleSetIPConfiguration{
+				{
+					Name: pointer.String("ipconfig-old"),
+					VirtualMachineScaleSetIPConfigurationProperties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+						Primary:                         pointer.Bool(false),
+						LoadBalancerBackendAddressPools: &[]compute.SubResource{{ID: pointer.String(backendPoolID)}},
+					},
+				},
+				{
+					Name: pointer.String("ipconfig1"),
+					VirtualMachineScaleSetIPConfigurationProperties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+						Primary:                         pointer.Bool(true),
+						LoadBalancerBackendAddressPools: &test.primaryBackendPools,
+						PrivateIPAddressVersion:         compute.IPv4,
+					},
+				},
+			}
+			mockVMSSVMClient := ss.cloud.VirtualMachineScaleSetVMsClient.(*mockvmssvmclient.MockInterface)
+			mockVMSSVMClient.EXPECT().List(gomock.Any(), ss.ResourceGroup, testVMSSName, gomock.Any()).Return(expectedVMSSVMs, nil).AnyTimes()
+
+			_, _, _, vm, err := ss.EnsureHostInPool(&v1.Service{Spec: v1.ServiceSpec{ClusterIP: "clusterIP"}}, "vmss-vm-000000", backendPoolID, "vmss")
+			assert.NoError(t, err)
+			assert.NotNil(t, vm, "the VM should be updated")
+			ipConfigs := *(*vm.NetworkProfileConfiguration.NetworkInterfaceConfigurations)[0].IPConfigurations
+			assert.Empty(t, *ipConfigs[0].LoadBalancerBackendAddressPools, "the backend pool should be removed from the old IP config")
+			assert.Equal(t, []compute.SubResource{{ID: pointer.String(testLBBackendpoolID0)}, {ID: pointer.String(backendPoolID)}}, *ipConfigs[1].LoadBalancerBackendAddressPools)
+		})
+	}
+}
+
 func TestGetVmssAndResourceGroupNameByVMProviderID(t *testing.T) {
 	providerID := "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/0"
 	rgName, vmssName, err := getVmssAndResourceGroupNameByVMProviderID(providerID)
@@ -2552,6 +2614,7 @@ func TestEnsureHostsInPool(t *testing.T) {
 		backendpoolID          string
 		vmSetName              string
 		expectedVMSSVMPutTimes int
+		expectedUpdatedVMs     int
 		expectedErr            bool
 	}{
 		{
@@ -2587,6 +2650,7 @@ func TestEnsureHostsInPool(t *testing.T) {
 			backendpoolID:          testLBBackendpoolID1,
 			vmSetName:              testVMSSName,
 			expectedVMSSVMPutTimes: 1,
+			expectedUpdatedVMs:     2,
 		},
 		{
 			description: "EnsureHostsInPool should skip not found nodes",
@@ -2628,8 +2692,9 @@ func TestEnsureHostsInPool(t *testing.T) {
 		mockVMClient := ss.cloud.VirtualMachinesClient.(*mockvmclient.MockInterface)
 		mockVMClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
-		err = ss.EnsureHostsInPool(&v1.Service{}, test.nodes, test.backendpoolID, test.vmSetName)
+		updatedVMs, err := ss.EnsureHostsInPool(&v1.Service{}, test.nodes, test.backendpoolID, test.vmSetName)
 		assert.Equal(t, test.expectedErr, err != nil, test.description+", but an error occurs")
+		assert.Equal(t, test.expectedUpdatedVMs, updatedVMs, test.description)
 	}
 }
 
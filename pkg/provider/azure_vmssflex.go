@@ -20,53 +20,248 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2022-07-01/network"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 	"k8s.io/utils/pointer"
 
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmssclient"
 	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
 	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
 	"sigs.k8s.io/cloud-provider-azure/pkg/metrics"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
 )
 
 var (
 	// ErrorVmssIDIsEmpty indicates the vmss id is empty.
 	ErrorVmssIDIsEmpty = errors.New("VMSS ID is empty")
+	// ErrorVmssFlexComputerNameAmbiguous indicates the computer name is shared by multiple vmss flex.
+	ErrorVmssFlexComputerNameAmbiguous = errors.New("computer name is shared by multiple VMSS Flex")
+	// ErrorVmssFlexNameAmbiguous indicates the vmss flex name is shared by multiple vmss flex in different resource groups or subscriptions.
+	ErrorVmssFlexNameAmbiguous = errors.New("name is shared by multiple VMSS Flex")
+	// ErrInstanceNotReady indicates the vm exists but is still being provisioned, so that it could not be used as a node yet.
+	// Different from cloudprovider.InstanceNotFound, the callers are expected to retry later.
+	ErrInstanceNotReady = errors.New("instance is not ready")
+	// ErrorVmssFlexSubscriptionNotConfigured indicates the vmss flex is in a subscription which is not configured.
+	ErrorVmssFlexSubscriptionNotConfigured = errors.New("subscription of VMSS Flex is not configured")
+	// ErrorVmssFlexInvalidProviderID indicates the providerID could not be parsed into a vmss flex VM name.
+	ErrorVmssFlexInvalidProviderID = errors.New("invalid VMSS Flex providerID")
+	// ErrorVmssFlexCacheCircuitOpen indicates the refresh of the vmss flex caches is skipped since the circuit breaker is open.
+	ErrorVmssFlexCacheCircuitOpen = errors.New("circuit breaker of VMSS Flex cache refreshes is open")
+	// ErrorVmssFlexClientNotInitialized indicates the client required by the vmss flex caches is nil, e.g. the provider
+	// is partially initialized.
+	ErrorVmssFlexClientNotInitialized = errors.New("client of VMSS Flex is not initialized")
+	// ErrorVmssFlexNodeAddressesNotCached indicates the addresses of the node could not be derived from the
+	// vmss flex caches, so that they should be got from ARM.
+	ErrorVmssFlexNodeAddressesNotCached = errors.New("addresses of the VMSS Flex node are not cached")
+
+	vmssFlexIDRE = regexp.MustCompile(`(?i)^/?subscriptions/[^/]+/resourceGroups/([^/]+)/providers/Microsoft.Compute/virtualMachineScaleSets/[^/]+$`)
+	// vmssFlexLegacyProviderIDRE matches the legacy scale set style providerID of the vmss flex VMs.
+	vmssFlexLegacyProviderIDRE = regexp.MustCompile(`.*/subscriptions/(?:.*)/Microsoft.Compute/virtualMachineScaleSets/[^/]+/virtualMachines/([^/]+)$`)
+	// subscriptionIDRE matches the subscription ID of an ARM resource ID.
+	subscriptionIDRE = regexp.MustCompile(`(?i)^/?subscriptions/([^/]+)/`)
 )
 
+// ThrottledError indicates the request to ARM is throttled. The callers are expected to wait for
+// RetryAfter, which is suggested by the Retry-After header of the throttled response, before retrying.
+// RetryAfter is zero if the header is absent.
+type ThrottledError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// newThrottledError converts the throttled rerr into a *ThrottledError with the Retry-After relative to now,
+// and returns the other errors as is.
+func newThrottledError(rerr *retry.Error, now time.Time) error {
+	if !rerr.IsThrottled() {
+		return rerr.Error()
+	}
+	var retryAfter time.Duration
+	if rerr.RetryAfter.After(now) {
+		retryAfter = rerr.RetryAfter.Sub(now)
+	}
+	return &ThrottledError{RetryAfter: retryAfter, Err: rerr.Error()}
+}
+
+// vmssFlexSubscriptionClients are the clients to list the vmss flex and their vms from a subscription.
+type vmssFlexSubscriptionClients struct {
+	vmssClient vmssclient.Interface
+	vmClient   vmclient.Interface
+}
+
 // FlexScaleSet implements VMSet interface for Azure Flexible VMSS.
 type FlexScaleSet struct {
 	*Cloud
 
 	vmssFlexCache azcache.Resource
 
-	vmssFlexVMNameToVmssID   *sync.Map
-	vmssFlexVMNameToNodeName *sync.Map
-	vmssFlexVMCache          azcache.Resource
+	// vmssFlexVMNameToVmssID maps the node names to the vmssFlexIDs.
+	vmssFlexVMNameToVmssID FlexCacheStore
+	// vmssFlexVMNameToNodeName maps the lower-case VM names to the node names, since
+	// the VM names are case-insensitive.
+	vmssFlexVMNameToNodeName FlexCacheStore
+	// vmssFlexIDToNodeNames is the reverse index of vmssFlexVMNameToVmssID, keyed by the
+	// vmssFlexID with a *sync.Map of the node names as the value.
+	vmssFlexIDToNodeNames *sync.Map
+	// vmssFlexIDToInstanceIDIndex indexes the cached VMs of the vmss flex by their instance IDs, keyed by the
+	// lower-case vmssFlexID with the *vmssFlexInstanceIDIndex as the value.
+	vmssFlexIDToInstanceIDIndex *sync.Map
+	// vmssFlexNodeNameToCachedOn records when the entries of the node were written, keyed by
+	// the node name with the time.Time as the value.
+	vmssFlexNodeNameToCachedOn FlexCacheStore
+	// vmssFlexNotReadyVMNames records the lower-case names of the listed VMs without a usable computer name,
+	// e.g. the VMs being provisioned, with the vmssFlexID as the value.
+	vmssFlexNotReadyVMNames FlexCacheStore
+	// vmssFlexPrivateIPToNodeName maps the private IPs of the NICs fetched for the cached VMs to the node names,
+	// with the vmssFlexPrivateIPEntry as the value.
+	vmssFlexPrivateIPToNodeName FlexCacheStore
+	vmssFlexVMCache             azcache.Resource
+	// vmssFlexVMInstanceViewCache caches the instance views of the vmss flex vms keyed by the vm name.
+	// It has a shorter TTL than vmssFlexVMCache since the power and provisioning states change frequently.
+	vmssFlexVMInstanceViewCache azcache.Resource
+
+	// vmssFlexAmbiguousNodeNames records the node names claimed by more than one vmss flex,
+	// keyed by the node name with the set of the claiming vmssFlexIDs as the value.
+	vmssFlexAmbiguousNodeNames     map[string]sets.Set[string]
+	vmssFlexAmbiguousNodeNamesLock sync.Mutex
+
+	// vmssFlexForceRefreshedOn records when the last force refresh of the cache entries completed, keyed by
+	// the cache name and the entry key with the time.Time as the value.
+	vmssFlexForceRefreshedOn *sync.Map
+
+	// vmssFlexRefreshedIDs is the snapshot of the vmssFlexIDs taken by the last RefreshVmssFlexCache, which
+	// the next one diffs against. It is guarded by vmssFlexRefreshLock.
+	vmssFlexRefreshedIDs sets.Set[string]
+	vmssFlexRefreshLock  sync.Mutex
+	// vmssFlexRefreshStatuses records the status of the refreshes of the vmss flex cache partitions, keyed by the
+	// lower-case resource group. It is guarded by vmssFlexRefreshStatusesLock.
+	vmssFlexRefreshStatuses     map[string]VmssFlexResourceGroupRefreshStatus
+	vmssFlexRefreshStatusesLock sync.Mutex
+	// vmssFlexCachedCounts records the number of the VMSS Flex stored in the vmss flex cache partitions, keyed by
+	// the lower-case resource group, which are capped by VmssFlexCacheMaxEntries. It is guarded by
+	// vmssFlexCachedCountsLock.
+	vmssFlexCachedCounts     map[string]int
+	vmssFlexCachedCountsLock sync.Mutex
+
+	// resourceGroupsSource returns the resource groups to list the vmss flex from.
+	// It defaults to GetResourceGroups.
+	resourceGroupsSource func() (sets.Set[string], error)
+	// vmssFlexResourceGroupCursor is the index of the sorted resource groups where the next round of
+	// getVmssFlexes starts, and vmssFlexResourceGroupRound are the resource groups of the last round, if
+	// VmssFlexCacheMaxResourceGroupsPerRefresh is set. They are guarded by vmssFlexResourceGroupRoundLock.
+	vmssFlexResourceGroupCursor    int
+	vmssFlexResourceGroupRound     sets.Set[string]
+	vmssFlexResourceGroupRoundLock sync.Mutex
+
+	// cacheAllOrchestrationModes makes the cache getter also record the scale sets of the other orchestration
+	// modes than Flexible in vmssFlexOtherModeScaleSets, which are never served by the vmss flex lookups.
+	cacheAllOrchestrationModes bool
+	// vmssFlexOtherModeScaleSets records the scale sets of the other orchestration modes listed by the cache
+	// getter, keyed by the lower-case resource group with a *sync.Map of the scale sets keyed by the ID as the value.
+	vmssFlexOtherModeScaleSets *sync.Map
+
+	// computerNameStripRE is removed from the lower-case computer names to derive the node names, if set.
+	computerNameStripRE *regexp.Regexp
+	// vmssFlexVMCacheTTLOverrides are the compiled VmssFlexVMCacheTTLOverrides.
+	vmssFlexVMCacheTTLOverrides []vmssFlexVMCacheTTLOverride
+
+	// circuitBreaker makes the getters of the vmss flex caches fail fast after consecutive failures.
+	circuitBreaker *circuitBreaker
+	// retryPolicy decides the retries of the ARM calls of the vmss flex caches.
+	retryPolicy RetryPolicy
+
+	// verificationSample returns a random number in [0, 1) to sample the lookups verified by
+	// VmssFlexCacheVerificationSampleRate, which defaults to rand.Float64 if nil. vmssFlexVerifications
+	// tracks the verifications in flight.
+	verificationSample    func() float64
+	vmssFlexVerifications sync.WaitGroup
+
+	// clock is used by the vmss flex caches and the time-based logic around them, e.g. the debouncing of the
+	// force refreshes and the serving of the stale entries. It is the real clock except in the tests.
+	clock clock.Clock
+
+	// onNodeCacheEvicted is called after the cache of a node is evicted, if set.
+	onNodeCacheEvicted func(nodeName, vmName, vmssFlexID string)
 
 	// lockMap in cache refresh
 	lockMap *lockMap
 }
 
 func newFlexScaleSet(ctx context.Context, az *Cloud) (VMSet, error) {
-	fs := &FlexScaleSet{
-		Cloud:                    az,
-		vmssFlexVMNameToVmssID:   &sync.Map{},
-		vmssFlexVMNameToNodeName: &sync.Map{},
-		lockMap:                  newLockMap(),
+	if maxEntries := az.Config.VmssFlexNodeCacheMaxEntries; maxEntries > 0 {
+		return NewFlexScaleSetWithCacheStore(ctx, az, func() FlexCacheStore {
+			return newLRUFlexCacheStore(maxEntries)
+		})
 	}
+	return NewFlexScaleSetWithCacheStore(ctx, az, newSyncMapFlexCacheStore)
+}
+
+// NewFlexScaleSetWithCacheStore creates the VMSet of the Flexible VMSS whose node name maps are stored
+// in the FlexCacheStores created by newStore.
+func NewFlexScaleSetWithCacheStore(ctx context.Context, az *Cloud, newStore func() FlexCacheStore) (VMSet, error) {
+	fs := &FlexScaleSet{
+		Cloud:                       az,
+		vmssFlexVMNameToVmssID:      newStore(),
+		vmssFlexVMNameToNodeName:    newStore(),
+		vmssFlexIDToNodeNames:       &sync.Map{},
+		vmssFlexIDToInstanceIDIndex: &sync.Map{},
+		vmssFlexNodeNameToCachedOn:  newStore(),
+		vmssFlexNotReadyVMNames:     newStore(),
+		vmssFlexPrivateIPToNodeName: newStore(),
+		vmssFlexForceRefreshedOn:    &sync.Map{},
+		vmssFlexOtherModeScaleSets:  &sync.Map{},
+		vmssFlexAmbiguousNodeNames:  map[string]sets.Set[string]{},
+		vmssFlexRefreshStatuses:     map[string]VmssFlexResourceGroupRefreshStatus{},
+		vmssFlexCachedCounts:        map[string]int{},
+		lockMap:                     newLockMap(),
+	}
+	fs.resourceGroupsSource = fs.GetResourceGroups
+	fs.retryPolicy = newVmssFlexRetryPolicy(fs.Config.VmssFlexARMRetryAttempts)
+	fs.circuitBreaker = newCircuitBreaker("vmss_flex", fs.Config.VmssFlexCacheCircuitBreakerThreshold, getVmssFlexCacheCircuitBreakerCooldown(fs.Config.VmssFlexCacheCircuitBreakerCooldownInSeconds))
 
 	var err error
+	if fs.Config.VmssFlexComputerNameStripPattern != "" {
+		fs.computerNameStripRE, err = regexp.Compile(fs.Config.VmssFlexComputerNameStripPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vmssFlexComputerNameStripPattern %q: %w", fs.Config.VmssFlexComputerNameStripPattern, err)
+		}
+	}
+	for _, override := range fs.Config.VmssFlexVMCacheTTLOverrides {
+		re, err := regexp.Compile(override.ScaleSetNamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scaleSetNamePattern %q of vmssFlexVMCacheTTLOverrides: %w", override.ScaleSetNamePattern, err)
+		}
+		if override.TTLInSeconds <= 0 {
+			return nil, fmt.Errorf("ttlInSeconds %d of vmssFlexVMCacheTTLOverrides for %q must be positive", override.TTLInSeconds, override.ScaleSetNamePattern)
+		}
+		fs.vmssFlexVMCacheTTLOverrides = append(fs.vmssFlexVMCacheTTLOverrides, vmssFlexVMCacheTTLOverride{
+			scaleSetNameRE: re,
+			ttl:            time.Duration(override.TTLInSeconds) * time.Second,
+		})
+	}
 	fs.vmssFlexCache, err = fs.newVmssFlexCache(ctx)
 	if err != nil {
 		return nil, err
@@ -75,10 +270,58 @@ func newFlexScaleSet(ctx context.Context, az *Cloud) (VMSet, error) {
 	if err != nil {
 		return nil, err
 	}
+	fs.vmssFlexVMInstanceViewCache, err = fs.newVmssFlexVMInstanceViewCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.setClock(clock.RealClock{})
+
+	if fs.Config.VmssFlexNodeCacheSweepIntervalInSeconds > 0 {
+		interval := time.Duration(fs.Config.VmssFlexNodeCacheSweepIntervalInSeconds) * time.Second
+		go wait.UntilWithContext(ctx, func(context.Context) { fs.sweepExpiredNodeCache() }, interval)
+	}
 
 	return fs, nil
 }
 
+// setClock sets the clock of the vmss flex caches, the time-based logic around them and the circuit breaker.
+func (fs *FlexScaleSet) setClock(c clock.Clock) {
+	fs.clock = c
+	fs.circuitBreaker.now = c.Now
+	for _, cache := range []azcache.Resource{fs.vmssFlexCache, fs.vmssFlexVMCache, fs.vmssFlexVMInstanceViewCache} {
+		if timedCache, ok := cache.(*azcache.TimedCache); ok {
+			timedCache.Clock = c
+		}
+	}
+}
+
+// SetResourceGroupsSource sets the function returning the resource groups to list the vmss flex from,
+// e.g. to restrict the scanning to the relevant resource groups. The default GetResourceGroups is
+// restored if source is nil.
+func (fs *FlexScaleSet) SetResourceGroupsSource(source func() (sets.Set[string], error)) {
+	if source == nil {
+		source = fs.GetResourceGroups
+	}
+	fs.resourceGroupsSource = source
+}
+
+// SetCacheAllOrchestrationModes sets whether the scale sets of the other orchestration modes than Flexible are
+// also cached, so that they could be listed by ListScaleSetsByOrchestrationMode, e.g. to warn about the resource
+// groups mixing the orchestration modes. The vmss flex lookups are not affected. It takes effect from the next
+// refresh of the cache.
+func (fs *FlexScaleSet) SetCacheAllOrchestrationModes(enabled bool) {
+	fs.cacheAllOrchestrationModes = enabled
+}
+
+// SetOnNodeCacheEvicted sets the function called after the cache of a node is evicted, either by
+// DeleteCacheForNode, DeleteCacheForNodes or the background sweeping of the expired nodes, so that the
+// components deriving their state from the cache could evict theirs. It is called outside of the locks,
+// and the vmName is empty if it is no longer known. It is not called if DisableAPICallCache is set.
+func (fs *FlexScaleSet) SetOnNodeCacheEvicted(hook func(nodeName, vmName, vmssFlexID string)) {
+	fs.onNodeCacheEvicted = hook
+}
+
 // GetPrimaryVMSetName returns the VM set name depending on the configured vmType.
 // It returns config.PrimaryScaleSetName for vmss and config.PrimaryAvailabilitySetName for standard vmType.
 func (fs *FlexScaleSet) GetPrimaryVMSetName() string {
@@ -161,21 +404,61 @@ func (fs *FlexScaleSet) GetVMSetNames(service *v1.Service, nodes []*v1.Node) (*[
 // providerID example:
 // azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/flexprofile-mp-0_df53ee36
 // Different from vmas where vm name is always equal to nodeName, we need to further map vmName to actual nodeName in vmssflex.
-// Note: nodeName is always equal pointer.StringDerefs.ToLower(*vm.OsProfile.ComputerName, "")
+// Nodes registered by older versions may carry the legacy scale set style providerID instead:
+// azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/flexprofile-mp-0/virtualMachines/flexprofile-mp-0_df53ee36
+// Note: nodeName is always equal to the lower-case computer name of the vm, with VmssFlexComputerNameStripPattern removed if set.
 func (fs *FlexScaleSet) GetNodeNameByProviderID(providerID string) (types.NodeName, error) {
-	// NodeName is part of providerID for standard instances.
-	matches := providerIDRE.FindStringSubmatch(providerID)
-	if len(matches) != 2 {
-		return "", errors.New("error splitting providerID")
+	vmName, err := getVmssFlexVMNameFromProviderID(providerID)
+	if err != nil {
+		return "", err
 	}
 
-	nodeName, err := fs.getNodeNameByVMName(matches[1])
+	nodeName, err := fs.getNodeNameByVMName(vmName)
 	if err != nil {
 		return "", err
 	}
 	return types.NodeName(nodeName), nil
 }
 
+// GetVmssFlexVMByProviderID returns the cached vmss flex VM by the providerID. The VM name in the providerID
+// is matched case-insensitively against the cached VMs.
+func (fs *FlexScaleSet) GetVmssFlexVMByProviderID(providerID string) (compute.VirtualMachine, error) {
+	vmName, err := getVmssFlexVMNameFromProviderID(providerID)
+	if err != nil {
+		return compute.VirtualMachine{}, err
+	}
+	return fs.getVmssFlexVMByVMName(vmName, azcache.CacheReadTypeDefault)
+}
+
+// GetVmssFlexByProviderID returns the vmss flex owning the VM of the providerID. ErrorVmssFlexInvalidProviderID
+// is returned if the providerID is malformed, and cloudprovider.InstanceNotFound if the VM is not found.
+func (fs *FlexScaleSet) GetVmssFlexByProviderID(providerID string, crt azcache.AzureCacheReadType) (*compute.VirtualMachineScaleSet, error) {
+	vmName, err := getVmssFlexVMNameFromProviderID(providerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorVmssFlexInvalidProviderID, err)
+	}
+	nodeName, err := fs.getNodeNameByVMName(vmName)
+	if err != nil {
+		return nil, err
+	}
+	return fs.getVmssFlexByNodeName(nodeName, crt)
+}
+
+// getVmssFlexVMNameFromProviderID parses the VM name out of a flex or legacy scale set style providerID.
+func getVmssFlexVMNameFromProviderID(providerID string) (string, error) {
+	// VM name is part of providerID for flex instances.
+	if matches := providerIDRE.FindStringSubmatch(providerID); len(matches) == 2 {
+		return matches[1], nil
+	}
+	// Uniform instances are addressed by numeric instance IDs which cannot be mapped to a flex VM name.
+	if matches := vmssFlexLegacyProviderIDRE.FindStringSubmatch(providerID); len(matches) == 2 {
+		if _, err := strconv.Atoi(matches[1]); err != nil {
+			return matches[1], nil
+		}
+	}
+	return "", fmt.Errorf("error splitting providerID: unrecognized providerID format %q", providerID)
+}
+
 // GetInstanceIDByNodeName gets the cloud provider ID by node name.
 // It must return ("", cloudprovider.InstanceNotFound) if the instance does
 // not exist or is no longer running.
@@ -312,6 +595,7 @@ func (fs *FlexScaleSet) GetPrimaryInterface(nodeName string) (network.Interface,
 		return network.Interface{}, rerr.Error()
 	}
 
+	fs.cacheNodePrivateIPs(nodeName, nic)
 	return nic, nil
 }
 
@@ -436,7 +720,9 @@ func (fs *FlexScaleSet) GetNodeCIDRMasksByProviderID(providerID string) (int, in
 	}
 	nodeName := mapNodeNameToVMName(nodeNameWrapper)
 
-	vmssFlex, err := fs.getVmssFlexByNodeName(nodeName, azcache.CacheReadTypeDefault)
+	// the resource group is only a hint to narrow the lookup, so it is only passed when the node label names it
+	resourceGroup, _ := fs.getNodeResourceGroupFromLabel(nodeName)
+	vmssFlex, err := fs.getVmssFlexByNodeNameWithResourceGroupHint(nodeName, resourceGroup, azcache.CacheReadTypeDefault)
 	if err != nil {
 		if errors.Is(err, cloudprovider.InstanceNotFound) {
 			return consts.DefaultNodeMaskCIDRIPv4, consts.DefaultNodeMaskCIDRIPv6, nil
@@ -466,11 +752,23 @@ func (fs *FlexScaleSet) GetNodeCIDRMasksByProviderID(providerID string) (int, in
 func (fs *FlexScaleSet) EnsureHostInPool(service *v1.Service, nodeName types.NodeName, backendPoolID string, vmSetNameOfLB string) (string, string, string, *compute.VirtualMachineScaleSetVM, error) {
 	serviceName := getServiceName(service)
 	name := mapNodeNameToVMName(nodeName)
-	vmssFlexName, err := fs.getNodeVmssFlexName(name)
+	vmssFlexID, err := fs.getNodeVmssFlexID(name)
 	if err != nil {
 		klog.Errorf("EnsureHostInPool: failed to get VMSS Flex Name %s: %v", name, err)
 		return "", "", "", nil, nil
 	}
+	vmssFlex, err := fs.getVmssFlexByVmssFlexID(vmssFlexID, azcache.CacheReadTypeDefault)
+	if err != nil {
+		klog.Errorf("EnsureHostInPool: failed to get VMSS Flex %s: %v", vmssFlexID, err)
+		return "", "", "", nil, nil
+	}
+	vmssFlexName := pointer.StringDeref(vmssFlex.Name, "")
+
+	// When vmss is being deleted, its VMs are going away and the NIC updates would be futile.
+	if isScaleSetBeingDeleted(vmssFlex.ProvisioningState) {
+		klog.V(3).Infof("EnsureHostInPool skips node %s because its vmss %s is being deleted", name, vmssFlexName)
+		return "", "", "", nil, nil
+	}
 
 	// Check scale set name:
 	// - For basic SKU load balancer, return error as VMSS Flex does not support basic load balancer.
@@ -522,12 +820,13 @@ func (fs *FlexScaleSet) EnsureHostInPool(service *v1.Service, nodeName types.Nod
 			break
 		}
 	}
+	isMovedFromStaleIPConfig := removeBackendPoolFromStaleIPConfigs(nic, primaryIPConfig, backendPoolID)
 	// The backendPoolID has already been found from existing LoadBalancerBackendAddressPools.
-	if foundPool {
+	if foundPool && !isMovedFromStaleIPConfig {
 		return "", "", "", nil, nil
 	}
 
-	if fs.useStandardLoadBalancer() && len(newBackendPools) > 0 {
+	if !foundPool && fs.useStandardLoadBalancer() && len(newBackendPools) > 0 {
 		// Although standard load balancer supports backends from multiple availability
 		// sets, the same network interface couldn't be added to more than one load balancer of
 		// the same type. Omit those nodes (e.g. masters) so Azure ARM won't complain
@@ -548,12 +847,13 @@ func (fs *FlexScaleSet) EnsureHostInPool(service *v1.Service, nodeName types.Nod
 		}
 	}
 
-	newBackendPools = append(newBackendPools,
-		network.BackendAddressPool{
-			ID: pointer.String(backendPoolID),
-		})
-
-	primaryIPConfig.LoadBalancerBackendAddressPools = &newBackendPools
+	if !foundPool {
+		newBackendPools = append(newBackendPools,
+			network.BackendAddressPool{
+				ID: pointer.String(backendPoolID),
+			})
+		primaryIPConfig.LoadBalancerBackendAddressPools = &newBackendPools
+	}
 
 	nicName := *nic.Name
 	klog.V(3).Infof("nicupdate(%s): nic(%s) - updating", serviceName, nicName)
@@ -634,7 +934,7 @@ func (fs *FlexScaleSet) ensureVMSSFlexInPool(service *v1.Service, nodes []*v1.No
 
 		// When vmss is being deleted, CreateOrUpdate API would report "the vmss is being deleted" error.
 		// Since it is being deleted, we shouldn't send more CreateOrUpdate requests for it.
-		if vmssFlex.ProvisioningState != nil && strings.EqualFold(*vmssFlex.ProvisioningState, consts.VirtualMachineScaleSetsDeallocating) {
+		if isScaleSetBeingDeleted(vmssFlex.ProvisioningState) {
 			klog.V(3).Infof("ensureVMSSFlexInPool: found vmss %s being deleted, skipping", vmssFlexID)
 			continue
 		}
@@ -709,7 +1009,7 @@ func (fs *FlexScaleSet) ensureVMSSFlexInPool(service *v1.Service, nodes []*v1.No
 		}
 
 		defer func() {
-			_ = fs.vmssFlexCache.Delete(consts.VmssFlexKey)
+			_ = fs.vmssFlexCache.Delete(getVmssFlexCachePartitionKeyByResourceGroup(fs.ResourceGroup))
 		}()
 
 		klog.V(2).Infof("ensureVMSSFlexInPool begins to add vmss(%s) with new backendPoolID %s", vmssFlexName, backendPoolID)
@@ -723,13 +1023,14 @@ func (fs *FlexScaleSet) ensureVMSSFlexInPool(service *v1.Service, nodes []*v1.No
 }
 
 // EnsureHostsInPool ensures the given Node's primary IP configurations are
-// participating in the specified LoadBalancer Backend Pool.
-func (fs *FlexScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID string, vmSetNameOfLB string) error {
+// participating in the specified LoadBalancer Backend Pool. It returns the number of the VMs whose NICs are updated.
+func (fs *FlexScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node, backendPoolID string, vmSetNameOfLB string) (int, error) {
 	mc := metrics.NewMetricContext("services", "vmssflex_ensure_hosts_in_pool", fs.ResourceGroup, fs.SubscriptionID, getServiceName(service))
 	isOperationSucceeded := false
 	defer func() {
 		mc.ObserveOperationWithResult(isOperationSucceeded)
 	}()
+	var updatedVMs atomic.Int32
 	hostUpdates := make([]func() error, 0, len(nodes))
 
 	for _, node := range nodes {
@@ -742,7 +1043,7 @@ func (fs *FlexScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node,
 		shouldExcludeLoadBalancer, err := fs.ShouldNodeExcludedFromLoadBalancer(localNodeName)
 		if err != nil {
 			klog.Errorf("ShouldNodeExcludedFromLoadBalancer(%s) failed with error: %v", localNodeName, err)
-			return err
+			return 0, err
 		}
 		if shouldExcludeLoadBalancer {
 			klog.V(4).Infof("Excluding unmanaged/external-resource-group node %q", localNodeName)
@@ -750,10 +1051,14 @@ func (fs *FlexScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node,
 		}
 
 		f := func() error {
-			_, _, _, _, err := fs.EnsureHostInPool(service, types.NodeName(localNodeName), backendPoolID, vmSetNameOfLB)
+			// the VM name is only returned if its NIC is updated
+			_, _, vmName, _, err := fs.EnsureHostInPool(service, types.NodeName(localNodeName), backendPoolID, vmSetNameOfLB)
 			if err != nil {
 				return fmt.Errorf("ensure(%s): backendPoolID(%s) - failed to ensure host in pool: %w", getServiceName(service), backendPoolID, err)
 			}
+			if vmName != "" {
+				updatedVMs.Add(1)
+			}
 			return nil
 		}
 		hostUpdates = append(hostUpdates, f)
@@ -761,27 +1066,26 @@ func (fs *FlexScaleSet) EnsureHostsInPool(service *v1.Service, nodes []*v1.Node,
 
 	errs := utilerrors.AggregateGoroutines(hostUpdates...)
 	if errs != nil {
-		return utilerrors.Flatten(errs)
+		return int(updatedVMs.Load()), utilerrors.Flatten(errs)
 	}
 
 	err := fs.ensureVMSSFlexInPool(service, nodes, backendPoolID, vmSetNameOfLB)
 	if err != nil {
-		return err
+		return int(updatedVMs.Load()), err
 	}
 
 	isOperationSucceeded = true
-	return nil
+	return int(updatedVMs.Load()), nil
 }
 
 func (fs *FlexScaleSet) ensureBackendPoolDeletedFromVmssFlex(backendPoolIDs []string, vmSetName string) error {
 	vmssNamesMap := make(map[string]bool)
 	if fs.useStandardLoadBalancer() {
-		cached, err := fs.vmssFlexCache.Get(consts.VmssFlexKey, azcache.CacheReadTypeDefault)
+		vmssFlexes, err := fs.getVmssFlexes(azcache.CacheReadTypeDefault)
 		if err != nil {
 			klog.Errorf("ensureBackendPoolDeletedFromVmssFlex: failed to get vmss flex from cache: %v", err)
 			return err
 		}
-		vmssFlexes := cached.(*sync.Map)
 		vmssFlexes.Range(func(key, value interface{}) bool {
 			vmssFlex := value.(*compute.VirtualMachineScaleSet)
 			vmssNamesMap[pointer.StringDeref(vmssFlex.Name, "")] = true
@@ -808,7 +1112,7 @@ func (fs *FlexScaleSet) EnsureBackendPoolDeletedFromVMSets(vmssNamesMap map[stri
 
 		// When vmss is being deleted, CreateOrUpdate API would report "the vmss is being deleted" error.
 		// Since it is being deleted, we shouldn't send more CreateOrUpdate requests for it.
-		if vmss.ProvisioningState != nil && strings.EqualFold(*vmss.ProvisioningState, consts.VirtualMachineScaleSetsDeallocating) {
+		if isScaleSetBeingDeleted(vmss.ProvisioningState) {
 			klog.V(3).Infof("fs.EnsureBackendPoolDeletedFromVMSets: found vmss %s being deleted, skipping", vmssName)
 			continue
 		}
@@ -853,7 +1157,7 @@ func (fs *FlexScaleSet) EnsureBackendPoolDeletedFromVMSets(vmssNamesMap map[stri
 			}
 
 			defer func() {
-				_ = fs.vmssFlexCache.Delete(consts.VmssFlexKey)
+				_ = fs.vmssFlexCache.Delete(getVmssFlexCachePartitionKeyByResourceGroup(fs.ResourceGroup))
 			}()
 
 			klog.V(2).Infof("fs.EnsureBackendPoolDeletedFromVMSets begins to delete backendPoolIDs %q from vmss(%s)", backendPoolIDs, vmssName)
@@ -17,349 +17,2678 @@ limitations under the License.
 package provider
 
 import (
+	"container/list"
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"regexp"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2022-07-01/network"
+	"github.com/Azure/go-autorest/autorest/azure"
 
+	v1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
 
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmssclient"
 	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
 	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
+	"sigs.k8s.io/cloud-provider-azure/pkg/metrics"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
+	"sigs.k8s.io/cloud-provider-azure/pkg/util/deepcopy"
 )
 
+// FlexCacheStore stores the name maps of the vmss flex nodes. The default store is in memory, and an external
+// store could be plugged in by NewFlexScaleSetWithCacheStore, e.g. to share the maps across the replicas.
+type FlexCacheStore interface {
+	// Get returns the value of the key and whether it is found.
+	Get(key string) (interface{}, bool)
+	// Set sets the value of the key.
+	Set(key string, value interface{})
+	// Delete deletes the key. It is a no-op if the key is not found.
+	Delete(key string)
+	// Range calls f for each key and value until f returns false.
+	Range(f func(key string, value interface{}) bool)
+}
+
+// syncMapFlexCacheStore is the default in-memory FlexCacheStore backed by a sync.Map.
+type syncMapFlexCacheStore struct {
+	m sync.Map
+}
+
+func newSyncMapFlexCacheStore() FlexCacheStore {
+	return &syncMapFlexCacheStore{}
+}
+
+func (s *syncMapFlexCacheStore) Get(key string) (interface{}, bool) {
+	return s.m.Load(key)
+}
+
+func (s *syncMapFlexCacheStore) Set(key string, value interface{}) {
+	s.m.Store(key, value)
+}
+
+func (s *syncMapFlexCacheStore) Delete(key string) {
+	s.m.Delete(key)
+}
+
+func (s *syncMapFlexCacheStore) Range(f func(key string, value interface{}) bool) {
+	s.m.Range(func(key, value interface{}) bool {
+		return f(key.(string), value)
+	})
+}
+
+// lruFlexCacheStore is an in-memory FlexCacheStore bounded by maxEntries. Once the cap is exceeded, the least
+// recently used key is evicted, so that the memory use is predictable even if the deletes of the nodes are
+// missed. Both Get and Set count as the use of the key, while Range does not.
+type lruFlexCacheStore struct {
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	// recency lists the lruFlexCacheEntry from the most to the least recently used.
+	recency *list.List
+}
+
+type lruFlexCacheEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRUFlexCacheStore(maxEntries int) FlexCacheStore {
+	return &lruFlexCacheStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		recency:    list.New(),
+	}
+}
+
+func (s *lruFlexCacheStore) Get(key string) (interface{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.recency.MoveToFront(element)
+	return element.Value.(*lruFlexCacheEntry).value, true
+}
+
+func (s *lruFlexCacheStore) Set(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*lruFlexCacheEntry).value = value
+		s.recency.MoveToFront(element)
+		return
+	}
+	s.entries[key] = s.recency.PushFront(&lruFlexCacheEntry{key: key, value: value})
+	for s.recency.Len() > s.maxEntries {
+		oldest := s.recency.Back()
+		s.recency.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruFlexCacheEntry).key)
+		klog.V(4).InfoS("Evicted the least recently used entry of the vmss flex name map", "key", oldest.Value.(*lruFlexCacheEntry).key, "maxEntries", s.maxEntries)
+	}
+}
+
+func (s *lruFlexCacheStore) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if element, ok := s.entries[key]; ok {
+		s.recency.Remove(element)
+		delete(s.entries, key)
+	}
+}
+
+// Range iterates a snapshot of the entries, so that f could modify the store.
+func (s *lruFlexCacheStore) Range(f func(key string, value interface{}) bool) {
+	s.lock.Lock()
+	snapshot := make([]lruFlexCacheEntry, 0, s.recency.Len())
+	for element := s.recency.Front(); element != nil; element = element.Next() {
+		snapshot = append(snapshot, *element.Value.(*lruFlexCacheEntry))
+	}
+	s.lock.Unlock()
+
+	for _, entry := range snapshot {
+		if !f(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
+// newVmssFlexCache creates the vmss flex cache partitioned by the lower-case resource groups, so that
+// the VMSS Flex of one resource group could be refreshed without listing the others.
 func (fs *FlexScaleSet) newVmssFlexCache(ctx context.Context) (azcache.Resource, error) {
+	// On timeout, the getter returns an error and the timed cache keeps the stale entry untouched,
+	// so it is still available to the callers reading with CacheReadTypeUnsafe.
+	list := func(ctx context.Context, key string) (vmssFlexes *sync.Map, err error) {
+		defer recoverCacheGetterPanic("vmss_flex", key, &err)
+		return fs.listVmssFlexes(ctx, getResourceGroupByVmssFlexCachePartitionKey(key))
+	}
 	getter := func(key string) (interface{}, error) {
+		if fs.Config.VmssFlexCacheRefreshTimeoutSeconds <= 0 {
+			return list(ctx, key)
+		}
+
+		timeout := time.Duration(fs.Config.VmssFlexCacheRefreshTimeoutSeconds) * time.Second
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		type listResult struct {
+			vmssFlexes *sync.Map
+			err        error
+		}
+		// buffered so that the listing goroutine would not leak if the refresh times out
+		resultCh := make(chan listResult, 1)
+		go func() {
+			vmssFlexes, err := list(ctx, key)
+			resultCh <- listResult{vmssFlexes: vmssFlexes, err: err}
+		}()
+
+		select {
+		case result := <-resultCh:
+			if result.err != nil {
+				return nil, result.err
+			}
+			return result.vmssFlexes, nil
+		case <-ctx.Done():
+			klog.ErrorS(ctx.Err(), "Refreshing VMSS Flex cache timed out", "resourceGroup", key, "timeout", timeout)
+			return nil, fmt.Errorf("refreshing VMSS Flex cache timed out after %v: %w", timeout, ctx.Err())
+		}
+	}
+
+	recordedGetter := func(key string) (interface{}, error) {
+		data, err := getter(key)
+		fs.recordVmssFlexRefresh(getResourceGroupByVmssFlexCachePartitionKey(key), err)
+		return data, err
+	}
+
+	fs.Config.VmssFlexCacheTTLInSeconds = getVmssFlexCacheTTLInSeconds(fs.Config.VmssFlexCacheTTLInSeconds)
+	return azcache.NewTimedCacheWithTTLJitter(time.Duration(fs.Config.VmssFlexCacheTTLInSeconds)*time.Second, getVmssFlexCacheTTLJitter(fs.Config.VmssFlexCacheTTLJitter), fs.circuitBreaker.wrap(recordedGetter), fs.Cloud.Config.DisableAPICallCache)
+}
+
+// VmssFlexResourceGroupRefreshStatus is the status of the refreshes of the vmss flex cache partition of a resource group.
+type VmssFlexResourceGroupRefreshStatus struct {
+	// LastSucceededOn is when the last successful refresh completed, which is zero if none has succeeded.
+	LastSucceededOn time.Time
+	// LastFailedOn is when the last failed refresh completed, which is zero if none has failed.
+	LastFailedOn time.Time
+	// LastError is the error of the last refresh, which is nil if it succeeded.
+	LastError error
+}
+
+// recordVmssFlexRefresh records the result of the refresh of the vmss flex cache partition of the resource group,
+// and its staleness metric. The refreshes skipped by the open circuit breaker are not recorded.
+func (fs *FlexScaleSet) recordVmssFlexRefresh(resourceGroup string, err error) {
+	fs.vmssFlexRefreshStatusesLock.Lock()
+	defer fs.vmssFlexRefreshStatusesLock.Unlock()
+
+	now := fs.clock.Now()
+	status := fs.vmssFlexRefreshStatuses[resourceGroup]
+	status.LastError = err
+	staleness := float64(-1)
+	if err == nil {
+		status.LastSucceededOn = now
+		staleness = 0
+	} else {
+		status.LastFailedOn = now
+		if !status.LastSucceededOn.IsZero() {
+			staleness = now.Sub(status.LastSucceededOn).Seconds()
+		}
+	}
+	fs.vmssFlexRefreshStatuses[resourceGroup] = status
+	metrics.SetCacheRefreshStaleness("vmss_flex", resourceGroup, staleness)
+}
+
+// GetVmssFlexRefreshStatuses returns the status of the refreshes of the vmss flex cache partitions, keyed by the
+// lower-case resource group, so that the resource groups whose listings keep failing, e.g. due to the missing
+// permissions or the deleted resource group, would stand out from the others.
+func (fs *FlexScaleSet) GetVmssFlexRefreshStatuses() map[string]VmssFlexResourceGroupRefreshStatus {
+	fs.vmssFlexRefreshStatusesLock.Lock()
+	defer fs.vmssFlexRefreshStatusesLock.Unlock()
+
+	statuses := make(map[string]VmssFlexResourceGroupRefreshStatus, len(fs.vmssFlexRefreshStatuses))
+	for resourceGroup, status := range fs.vmssFlexRefreshStatuses {
+		statuses[resourceGroup] = status
+	}
+	return statuses
+}
+
+// recoverCacheGetterPanic converts a panic of the cache getter into an error, so that the caller survives
+// and the entry is refreshed again by the next read. It must be deferred by the getter, which must only update
+// the shared state once the listing completes, so that the recovered panic would leave the state untouched.
+func recoverCacheGetterPanic(cacheName, key string, err *error) {
+	if r := recover(); r != nil {
+		klog.ErrorS(nil, "Recovered from panic in the cache getter", "cache", cacheName, "key", key, "panic", r, "stack", string(debug.Stack()))
+		metrics.CountCacheGetterPanic(cacheName)
+		*err = fmt.Errorf("panic in the %s cache getter of key %s: %v", cacheName, key, r)
+	}
+}
+
+// getVmssFlexCacheTTLInSeconds returns the TTL of the vmss flex cache. It defaults to
+// VmssFlexCacheTTLDefaultInSeconds if not set or negative, and is clamped to VmssFlexCacheTTLMaxInSeconds.
+func getVmssFlexCacheTTLInSeconds(ttl int) int {
+	switch {
+	case ttl == 0:
+		return consts.VmssFlexCacheTTLDefaultInSeconds
+	case ttl < 0:
+		klog.Warningf("vmssFlexCacheTTLInSeconds %d is negative, using the default value %d", ttl, consts.VmssFlexCacheTTLDefaultInSeconds)
+		return consts.VmssFlexCacheTTLDefaultInSeconds
+	case ttl > consts.VmssFlexCacheTTLMaxInSeconds:
+		klog.Warningf("vmssFlexCacheTTLInSeconds %d exceeds the max value, clamping it to %d", ttl, consts.VmssFlexCacheTTLMaxInSeconds)
+		return consts.VmssFlexCacheTTLMaxInSeconds
+	}
+	return ttl
+}
+
+// getVmssFlexCacheTTLJitter returns the TTL jitter of the vmss flex caches. It defaults to
+// VmssFlexCacheTTLJitterDefault if not set or not less than 1, and a negative value disables the jitter.
+func getVmssFlexCacheTTLJitter(jitter float64) float64 {
+	switch {
+	case jitter == 0:
+		return consts.VmssFlexCacheTTLJitterDefault
+	case jitter < 0:
+		return 0
+	case jitter >= 1:
+		klog.Warningf("vmssFlexCacheTTLJitter %v is not less than 1, using the default value %v", jitter, consts.VmssFlexCacheTTLJitterDefault)
+		return consts.VmssFlexCacheTTLJitterDefault
+	}
+	return jitter
+}
+
+// listVmssFlexes lists the VMSS Flex in the resource group of all the configured subscriptions, keyed by
+// the vmssFlexID. The vmssFlexID carries the subscription, so the VMSS Flex with the same name and resource
+// group in different subscriptions would not collide.
+func (fs *FlexScaleSet) listVmssFlexes(ctx context.Context, resourceGroup string) (*sync.Map, error) {
+	var vmssFlexes []*compute.VirtualMachineScaleSet
+	otherModeScaleSets := &sync.Map{}
+	for _, subscriptionID := range fs.getVmssFlexSubscriptionIDs() {
+		vmssClient := fs.getVmssFlexSubscriptionClients(subscriptionID).vmssClient
+		if err := checkVmssFlexClient(vmssClient, "VirtualMachineScaleSetsClient", subscriptionID); err != nil {
+			return nil, err
+		}
+		// the slot is only held during each call, not while waiting to retry
+		var allScaleSets []compute.VirtualMachineScaleSet
+		var slotErr error
+		rerr := fs.retryARMCall(ctx, "VirtualMachineScaleSetsClient.List", func() *retry.Error {
+			release, err := fs.acquireARMRequestSlot(ctx)
+			if err != nil {
+				slotErr = err
+				return nil
+			}
+			defer release()
+			var rerr *retry.Error
+			allScaleSets, rerr = vmssClient.List(ctx, resourceGroup)
+			return rerr
+		})
+		if slotErr != nil {
+			return nil, slotErr
+		}
+		if rerr != nil {
+			if rerr.IsNotFound() {
+				klog.Warningf("Skip caching vmss for resource group %s of subscription %s due to error: %v", resourceGroup, subscriptionID, rerr.Error())
+				continue
+			}
+			klog.ErrorS(rerr.Error(), "VirtualMachineScaleSetsClient.List failed", "subscriptionID", subscriptionID, "resourceGroup", resourceGroup)
+			return nil, newThrottledError(rerr, fs.clock.Now())
+		}
+
+		for i := range allScaleSets {
+			scaleSet := allScaleSets[i]
+			if scaleSet.ID == nil || *scaleSet.ID == "" {
+				refetched, err := fs.refetchScaleSetWithoutID(ctx, vmssClient, subscriptionID, resourceGroup, scaleSet.Name)
+				if err != nil {
+					return nil, err
+				}
+				if refetched == nil {
+					continue
+				}
+				scaleSet = *refetched
+			}
+
+			if scaleSet.OrchestrationMode == compute.Flexible {
+				// skip the malformed IDs here, otherwise they would be silently ignored by the name lookups
+				if !vmssFlexIDRE.MatchString(*scaleSet.ID) {
+					klog.Warningf("Skip caching VMSS Flex %s in resource group %s due to malformed resource ID", *scaleSet.ID, resourceGroup)
+					continue
+				}
+				vmssFlexes = append(vmssFlexes, &scaleSet)
+			} else if fs.cacheAllOrchestrationModes {
+				otherModeScaleSets.Store(*scaleSet.ID, &scaleSet)
+			}
+		}
+	}
+	if fs.cacheAllOrchestrationModes {
+		fs.vmssFlexOtherModeScaleSets.Store(resourceGroup, otherModeScaleSets)
+	} else {
+		fs.vmssFlexOtherModeScaleSets.Delete(resourceGroup)
+	}
+
+	// sorted, so that the same VMSS Flex are skipped across the refreshes if the cache is full
+	sort.Slice(vmssFlexes, func(i, j int) bool { return *vmssFlexes[i].ID < *vmssFlexes[j].ID })
+	maxEntries := fs.reserveVmssFlexCacheEntries(resourceGroup, len(vmssFlexes))
+	if skipped := len(vmssFlexes) - maxEntries; skipped > 0 {
+		klog.Warningf("Skip caching %d VMSS Flex of resource group %s since the cache is full, maxEntries: %d", skipped, resourceGroup, fs.Config.VmssFlexCacheMaxEntries)
+		vmssFlexes = vmssFlexes[:maxEntries]
+	}
+
+	localCache := &sync.Map{}
+	for _, vmssFlex := range vmssFlexes {
+		localCache.Store(*vmssFlex.ID, vmssFlex)
+	}
+	return localCache, nil
+}
+
+// reserveVmssFlexCacheEntries returns how many of the count VMSS Flex listed in the resource group could be
+// stored in its cache partition without exceeding VmssFlexCacheMaxEntries across the partitions, and records
+// them as the cached count of the resource group.
+func (fs *FlexScaleSet) reserveVmssFlexCacheEntries(resourceGroup string, count int) int {
+	fs.vmssFlexCachedCountsLock.Lock()
+	defer fs.vmssFlexCachedCountsLock.Unlock()
+
+	if maxEntries := fs.Config.VmssFlexCacheMaxEntries; maxEntries > 0 {
+		available := maxEntries
+		for cachedResourceGroup, cachedCount := range fs.vmssFlexCachedCounts {
+			if cachedResourceGroup != resourceGroup {
+				available -= cachedCount
+			}
+		}
+		if available < 0 {
+			available = 0
+		}
+		if count > available {
+			count = available
+		}
+	}
+	fs.vmssFlexCachedCounts[resourceGroup] = count
+	return count
+}
+
+// refetchScaleSetWithoutID gets the scale set listed without an ID by its name, since a page listed under
+// throttling may carry incomplete entries, so that the scale set is not missed until the next refresh.
+// It returns nil if the scale set still has no ID or could not be got, in which case it is skipped.
+func (fs *FlexScaleSet) refetchScaleSetWithoutID(ctx context.Context, vmssClient vmssclient.Interface, subscriptionID, resourceGroup string, name *string) (*compute.VirtualMachineScaleSet, error) {
+	if pointer.StringDeref(name, "") == "" {
+		klog.Warningf("Failed to get the ID of VMSS Flex in resource group %s of subscription %s", resourceGroup, subscriptionID)
+		return nil, nil
+	}
+
+	release, err := fs.acquireARMRequestSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scaleSet, rerr := vmssClient.Get(ctx, resourceGroup, *name)
+	release()
+	if rerr != nil {
+		klog.ErrorS(rerr.Error(), "Failed to get the VMSS Flex listed without ID, skipping it", "subscriptionID", subscriptionID, "resourceGroup", resourceGroup, "vmssName", *name)
+		return nil, nil
+	}
+	if pointer.StringDeref(scaleSet.ID, "") == "" {
+		klog.Warningf("Failed to get the ID of VMSS Flex %s in resource group %s of subscription %s", *name, resourceGroup, subscriptionID)
+		return nil, nil
+	}
+	klog.V(2).InfoS("Got the VMSS Flex listed without ID", "vmssFlexID", *scaleSet.ID)
+	return &scaleSet, nil
+}
+
+// getVmssFlexes returns the VMSS Flex of the cache partitions of all the resource groups from
+// resourceGroupsSource, keyed by the vmssFlexID.
+// If VmssFlexCacheMaxResourceGroupsPerRefresh is set, only the partitions of the resource groups in
+// the round are read with crt, and the others are served from the cache as is, or skipped if not cached.
+func (fs *FlexScaleSet) getVmssFlexes(crt azcache.AzureCacheReadType) (*sync.Map, error) {
+	allResourceGroups, err := fs.resourceGroupsSource()
+	if err != nil {
+		return nil, err
+	}
+	resourceGroups := sets.List(sets.New(lowerCaseResourceGroups(allResourceGroups)...))
+	round := fs.nextVmssFlexResourceGroupRound(resourceGroups, crt)
+
+	vmssFlexes := &sync.Map{}
+	for _, resourceGroup := range resourceGroups {
+		var partition *sync.Map
+		if round == nil || round.Has(resourceGroup) {
+			cached, err := fs.getVmssFlexCacheEntry(getVmssFlexCachePartitionKeyByResourceGroup(resourceGroup), crt)
+			if err != nil {
+				return nil, err
+			}
+			partition = cached.(*sync.Map)
+		} else if partition = fs.peekVmssFlexCachePartition(resourceGroup); partition == nil {
+			continue
+		}
+
+		partition.Range(func(key, value interface{}) bool {
+			vmssFlexes.Store(key, value)
+			return true
+		})
+	}
+	return vmssFlexes, nil
+}
+
+// nextVmssFlexResourceGroupRound returns the next VmssFlexCacheMaxResourceGroupsPerRefresh of the sorted resource
+// groups, starting from where the last round stopped, and records them as the last round. It returns nil if all the
+// resource groups should be read, i.e. the number is not limited or not exceeded, or the read is unsafe, which
+// lists nothing but the missing partitions.
+func (fs *FlexScaleSet) nextVmssFlexResourceGroupRound(resourceGroups []string, crt azcache.AzureCacheReadType) sets.Set[string] {
+	maxResourceGroups := fs.Config.VmssFlexCacheMaxResourceGroupsPerRefresh
+	if maxResourceGroups <= 0 || crt == azcache.CacheReadTypeUnsafe {
+		return nil
+	}
+
+	fs.vmssFlexResourceGroupRoundLock.Lock()
+	defer fs.vmssFlexResourceGroupRoundLock.Unlock()
+	if len(resourceGroups) <= maxResourceGroups {
+		fs.vmssFlexResourceGroupRound = nil
+		return nil
+	}
+	start := fs.vmssFlexResourceGroupCursor % len(resourceGroups)
+	round := sets.New[string]()
+	for i := 0; i < maxResourceGroups; i++ {
+		round.Insert(resourceGroups[(start+i)%len(resourceGroups)])
+	}
+	fs.vmssFlexResourceGroupCursor = (start + maxResourceGroups) % len(resourceGroups)
+	fs.vmssFlexResourceGroupRound = round
+	klog.V(4).InfoS("Reading the VMSS Flex of a round of the resource groups", "resourceGroups", sets.List(round), "total", len(resourceGroups))
+	return round
+}
+
+// isInLastVmssFlexResourceGroupRound returns true if the partition of the resource group was read by the last
+// round of getVmssFlexes, which is always the case if the resource groups are not rotated.
+func (fs *FlexScaleSet) isInLastVmssFlexResourceGroupRound(resourceGroup string) bool {
+	fs.vmssFlexResourceGroupRoundLock.Lock()
+	defer fs.vmssFlexResourceGroupRoundLock.Unlock()
+	return fs.vmssFlexResourceGroupRound == nil || fs.vmssFlexResourceGroupRound.Has(strings.ToLower(resourceGroup))
+}
+
+// peekVmssFlexCachePartition returns the cached partition of the resource group regardless of whether it is
+// expired, or nil if it is not cached, without listing the VMSS Flex.
+func (fs *FlexScaleSet) peekVmssFlexCachePartition(resourceGroup string) *sync.Map {
+	item, exists, err := fs.vmssFlexCache.GetStore().GetByKey(getVmssFlexCachePartitionKeyByResourceGroup(resourceGroup))
+	if err != nil || !exists {
+		return nil
+	}
+	entry, ok := item.(*azcache.AzureCacheEntry)
+	if !ok {
+		return nil
+	}
+	entry.Lock.Lock()
+	defer entry.Lock.Unlock()
+	partition, _ := entry.Data.(*sync.Map)
+	return partition
+}
+
+func lowerCaseResourceGroups(resourceGroups sets.Set[string]) []string {
+	lowerCased := make([]string, 0, resourceGroups.Len())
+	for resourceGroup := range resourceGroups {
+		lowerCased = append(lowerCased, strings.ToLower(resourceGroup))
+	}
+	return lowerCased
+}
+
+// getVmssFlexCachePartitionKey returns the key of the vmss flex cache partition of the vmssFlexID,
+// which is its lower-case resource group namespaced by VmssFlexCacheKeyPrefix.
+func getVmssFlexCachePartitionKey(vmssFlexID string) (string, error) {
+	matches := vmssFlexIDRE.FindStringSubmatch(vmssFlexID)
+	if len(matches) != 2 {
+		return "", fmt.Errorf("%w: malformed vmss flex ID %q", cloudprovider.InstanceNotFound, vmssFlexID)
+	}
+	return getVmssFlexCachePartitionKeyByResourceGroup(matches[1]), nil
+}
+
+// getVmssFlexCachePartitionKeyByResourceGroup returns the key of the vmss flex cache partition of the resource group.
+func getVmssFlexCachePartitionKeyByResourceGroup(resourceGroup string) string {
+	return consts.VmssFlexCacheKeyPrefix + strings.ToLower(resourceGroup)
+}
+
+// getResourceGroupByVmssFlexCachePartitionKey returns the lower-case resource group of the vmss flex cache partition.
+func getResourceGroupByVmssFlexCachePartitionKey(partitionKey string) string {
+	return strings.TrimPrefix(partitionKey, consts.VmssFlexCacheKeyPrefix)
+}
+
+// getVmssFlexSubscriptionIDs returns the lower-case IDs of the subscriptions to list the VMSS Flex from.
+// The subscription of the cluster comes first, followed by the sorted VmssFlexSubscriptionIDs.
+func (fs *FlexScaleSet) getVmssFlexSubscriptionIDs() []string {
+	subscriptionIDs := []string{strings.ToLower(fs.SubscriptionID)}
+	additional := make([]string, 0, len(fs.vmssFlexSubscriptionClients))
+	for subscriptionID := range fs.vmssFlexSubscriptionClients {
+		if !strings.EqualFold(subscriptionID, fs.SubscriptionID) {
+			additional = append(additional, subscriptionID)
+		}
+	}
+	sort.Strings(additional)
+	return append(subscriptionIDs, additional...)
+}
+
+// getVmssFlexSubscriptionClients returns the clients of the subscription. The clients of the cluster
+// subscription are returned if the subscription is not one of VmssFlexSubscriptionIDs.
+func (fs *FlexScaleSet) getVmssFlexSubscriptionClients(subscriptionID string) vmssFlexSubscriptionClients {
+	if clients, ok := fs.vmssFlexSubscriptionClients[strings.ToLower(subscriptionID)]; ok && !strings.EqualFold(subscriptionID, fs.SubscriptionID) {
+		return clients
+	}
+	return vmssFlexSubscriptionClients{
+		vmssClient: fs.VirtualMachineScaleSetsClient,
+		vmClient:   fs.VirtualMachinesClient,
+	}
+}
+
+// checkVmssFlexClient returns ErrorVmssFlexClientNotInitialized if the client used for the resource is nil, so that
+// the callers fail with an error instead of panicking while the provider is partially initialized.
+func checkVmssFlexClient(client interface{}, clientName, resource string) error {
+	if client == nil {
+		klog.ErrorS(ErrorVmssFlexClientNotInitialized, "Client of VMSS Flex is nil", "client", clientName, "resource", resource)
+		return fmt.Errorf("%w: %s is nil for %s", ErrorVmssFlexClientNotInitialized, clientName, resource)
+	}
+	return nil
+}
+
+// getVmssFlexSubscriptionClientsByResourceID routes the resource to the clients of the subscription parsed
+// from its ID. If VmssFlexSubscriptionIDs is not set, the clients of the cluster subscription are always
+// returned, otherwise ErrorVmssFlexSubscriptionNotConfigured is returned for the unknown subscriptions.
+func (fs *FlexScaleSet) getVmssFlexSubscriptionClientsByResourceID(resourceID string) (vmssFlexSubscriptionClients, error) {
+	if len(fs.vmssFlexSubscriptionClients) == 0 {
+		return fs.getVmssFlexSubscriptionClients(fs.SubscriptionID), nil
+	}
+
+	matches := subscriptionIDRE.FindStringSubmatch(resourceID)
+	if len(matches) != 2 {
+		return vmssFlexSubscriptionClients{}, fmt.Errorf("failed to parse the subscription ID of %q", resourceID)
+	}
+	subscriptionID := matches[1]
+	if _, ok := fs.vmssFlexSubscriptionClients[strings.ToLower(subscriptionID)]; !ok && !strings.EqualFold(subscriptionID, fs.SubscriptionID) {
+		return vmssFlexSubscriptionClients{}, fmt.Errorf("%w: %s of %s", ErrorVmssFlexSubscriptionNotConfigured, subscriptionID, resourceID)
+	}
+	return fs.getVmssFlexSubscriptionClients(subscriptionID), nil
+}
+
+func (fs *FlexScaleSet) newVmssFlexVMCache(ctx context.Context) (azcache.Resource, error) {
+	getter := func(key string) (_ interface{}, err error) {
+		defer recoverCacheGetterPanic("vmss_flex_vm", key, &err)
 		localCache := &sync.Map{}
 
-		allResourceGroups, err := fs.GetResourceGroups()
+		clients, err := fs.getVmssFlexSubscriptionClientsByResourceID(key)
 		if err != nil {
 			return nil, err
 		}
+		if err := checkVmssFlexClient(clients.vmClient, "VirtualMachinesClient", key); err != nil {
+			return nil, err
+		}
+		var vms []compute.VirtualMachine
+		rerr := fs.retryARMCall(ctx, "VirtualMachinesClient.ListVmssFlexVMsWithoutInstanceView", func() *retry.Error {
+			var rerr *retry.Error
+			vms, rerr = clients.vmClient.ListVmssFlexVMsWithoutInstanceView(ctx, key)
+			return rerr
+		})
+		if rerr != nil {
+			klog.ErrorS(rerr.Error(), "ListVmssFlexVMsWithoutInstanceView failed", "vmssFlexID", key)
+			return nil, rerr.Error()
+		}
+
+		// the shared name maps are only updated once the VMs are fully listed, so that a failed or panicked refresh
+		// would leave them untouched rather than partially updated.
+		vmNameToNodeName := make(map[string]string, len(vms))
+		listedVMs := make([]vmssFlexListedVM, 0, len(vms))
+		var notReadyVMNames []string
+		for i := range vms {
+			vm := vms[i]
+			if !hasUsableComputerName(&vm) {
+				reason := getUnusableComputerNameReason(&vm)
+				klog.V(4).InfoS("Skipped caching the vmss flex VM without computer name, which may be still booting", "vmName", pointer.StringDeref(vm.Name, ""), "vmssFlexID", key, "reason", reason)
+				metrics.CountVMSkippedFromCache("vmss_flex_vm", reason)
+				if vm.Name != nil {
+					notReadyVMNames = append(notReadyVMNames, strings.ToLower(*vm.Name))
+				}
+				continue
+			}
+			nodeName := fs.getNodeNameByComputerName(*vm.OsProfile.ComputerName)
+			// the most recently listed VM is kept if the computer name is duplicated in the vmss flex
+			if previous, loaded := localCache.Swap(nodeName, &vm); loaded {
+				fs.reportDuplicateComputerName(nodeName, vm.Name, key, previous.(*compute.VirtualMachine).Name, key)
+			}
+			vmNameToNodeName[strings.ToLower(pointer.StringDeref(vm.Name, ""))] = nodeName
+			listedVMs = append(listedVMs, vmssFlexListedVM{vmName: vm.Name, nodeName: nodeName})
+		}
+
+		rerr = fs.retryARMCall(ctx, "VirtualMachinesClient.ListVmssFlexVMsWithOnlyInstanceView", func() *retry.Error {
+			var rerr *retry.Error
+			vms, rerr = clients.vmClient.ListVmssFlexVMsWithOnlyInstanceView(ctx, key)
+			return rerr
+		})
+		if rerr != nil {
+			klog.ErrorS(rerr.Error(), "ListVmssFlexVMsWithOnlyInstanceView failed", "vmssFlexID", key)
+			return nil, rerr.Error()
+		}
 
-		for _, resourceGroup := range allResourceGroups.UnsortedList() {
-			allScaleSets, rerr := fs.VirtualMachineScaleSetsClient.List(ctx, resourceGroup)
-			if rerr != nil {
-				if rerr.IsNotFound() {
-					klog.Warningf("Skip caching vmss for resource group %s due to error: %v", resourceGroup, rerr.Error())
+		for i := range vms {
+			vm := vms[i]
+			if vm.Name != nil {
+				nodeName, ok := vmNameToNodeName[strings.ToLower(*vm.Name)]
+				if !ok {
 					continue
 				}
-				klog.Errorf("VirtualMachineScaleSetsClient.List failed: %v", rerr)
-				return nil, rerr.Error()
+
+				cached, ok := localCache.Load(nodeName)
+				if ok {
+					cachedVM := cached.(*compute.VirtualMachine)
+					cachedVM.VirtualMachineProperties.InstanceView = vm.VirtualMachineProperties.InstanceView
+				}
+			}
+		}
+
+		// the name maps are neither read nor written if the API call cache is disabled, so that the lookups
+		// would always go to ARM rather than being served from the stale maps
+		if !fs.Config.DisableAPICallCache {
+			fs.updateVmssFlexVMNameMaps(key, listedVMs, notReadyVMNames, localCache)
+		}
+		fs.vmssFlexIDToInstanceIDIndex.Store(strings.ToLower(key), newVmssFlexInstanceIDIndex(localCache))
+		return localCache, nil
+	}
+
+	if fs.Config.VmssFlexVMCacheTTLInSeconds == 0 {
+		fs.Config.VmssFlexVMCacheTTLInSeconds = consts.VmssFlexVMCacheTTLDefaultInSeconds
+	}
+	vmssFlexVMCache, err := azcache.NewTimedCacheWithTTLJitter(time.Duration(fs.Config.VmssFlexVMCacheTTLInSeconds)*time.Second, getVmssFlexCacheTTLJitter(fs.Config.VmssFlexCacheTTLJitter), fs.circuitBreaker.wrap(getter), fs.Cloud.Config.DisableAPICallCache)
+	if err != nil {
+		return nil, err
+	}
+	if timedCache, ok := vmssFlexVMCache.(*azcache.TimedCache); ok && len(fs.vmssFlexVMCacheTTLOverrides) > 0 {
+		timedCache.TTLFunc = fs.getVmssFlexVMCacheTTL
+	}
+	return vmssFlexVMCache, nil
+}
+
+// vmssFlexListedVM is the VM listed from the vmss flex with a usable computer name.
+type vmssFlexListedVM struct {
+	vmName   *string
+	nodeName string
+}
+
+// updateVmssFlexVMNameMaps replaces the entries of the name maps of the vmss flex with the listed VMs, whose
+// kept ones are cached in vms, and the names of the listed VMs without a usable computer name.
+func (fs *FlexScaleSet) updateVmssFlexVMNameMaps(vmssFlexID string, listedVMs []vmssFlexListedVM, notReadyVMNames []string, vms *sync.Map) {
+	fs.vmssFlexNotReadyVMNames.Range(func(vmName string, cachedVmssFlexID interface{}) bool {
+		if strings.EqualFold(cachedVmssFlexID.(string), vmssFlexID) {
+			fs.vmssFlexNotReadyVMNames.Delete(vmName)
+		}
+		return true
+	})
+	for _, vmName := range notReadyVMNames {
+		fs.vmssFlexNotReadyVMNames.Set(vmName, vmssFlexID)
+	}
+
+	nodeNames := &sync.Map{}
+	cachedOn := fs.clock.Now()
+	for _, vm := range listedVMs {
+		nodeNames.Store(vm.nodeName, struct{}{})
+		if cachedVmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get(vm.nodeName); isCached && !strings.EqualFold(cachedVmssFlexID.(string), vmssFlexID) {
+			fs.addAmbiguousNodeName(vm.nodeName, cachedVmssFlexID.(string), vmssFlexID)
+			fs.reportDuplicateComputerName(vm.nodeName, vm.vmName, vmssFlexID, fs.getOtherVMNameOfNodeName(vm.nodeName, pointer.StringDeref(vm.vmName, "")), cachedVmssFlexID.(string))
+		}
+		fs.vmssFlexVMNameToVmssID.Set(vm.nodeName, vmssFlexID)
+		if vm.vmName != nil {
+			fs.vmssFlexVMNameToNodeName.Set(strings.ToLower(*vm.vmName), vm.nodeName)
+		}
+		fs.vmssFlexNodeNameToCachedOn.Set(vm.nodeName, cachedOn)
+	}
+	// the set is replaced rather than updated in place, so that DeleteCacheForNode
+	// would never drop the set being repopulated here.
+	fs.vmssFlexIDToNodeNames.Store(vmssFlexID, nodeNames)
+	fs.pruneAmbiguousNodeNames(vmssFlexID, vms)
+}
+
+// vmssFlexVMCacheTTLOverride is the compiled VmssFlexVMCacheTTLOverride.
+type vmssFlexVMCacheTTLOverride struct {
+	scaleSetNameRE *regexp.Regexp
+	ttl            time.Duration
+}
+
+// getVmssFlexVMCacheTTL returns the TTL of the first VmssFlexVMCacheTTLOverrides matching the name of the vmss flex,
+// or VmssFlexVMCacheTTLInSeconds if none matches.
+func (fs *FlexScaleSet) getVmssFlexVMCacheTTL(vmssFlexID string) time.Duration {
+	if name, err := getLastSegment(vmssFlexID, "/"); err == nil {
+		for _, override := range fs.vmssFlexVMCacheTTLOverrides {
+			if override.scaleSetNameRE.MatchString(strings.ToLower(name)) {
+				return override.ttl
 			}
+		}
+	}
+	return time.Duration(fs.Config.VmssFlexVMCacheTTLInSeconds) * time.Second
+}
+
+func (fs *FlexScaleSet) newVmssFlexVMInstanceViewCache(ctx context.Context) (azcache.Resource, error) {
+	getter := func(key string) (interface{}, error) {
+		cachedNodeName, isCached := fs.vmssFlexVMNameToNodeName.Get(strings.ToLower(key))
+		if !isCached {
+			return nil, cloudprovider.InstanceNotFound
+		}
+		vm, err := fs.getVmssFlexVM(cachedNodeName.(string), azcache.CacheReadTypeUnsafe)
+		if err != nil {
+			return nil, err
+		}
+		resourceID, err := azure.ParseResourceID(pointer.StringDeref(vm.ID, ""))
+		if err != nil {
+			return nil, err
+		}
+		clients, err := fs.getVmssFlexSubscriptionClientsByResourceID(pointer.StringDeref(vm.ID, ""))
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVmssFlexClient(clients.vmClient, "VirtualMachinesClient", pointer.StringDeref(vm.ID, "")); err != nil {
+			return nil, err
+		}
+
+		// only the ARM call is guarded by the circuit breaker, since the vm lookup above is itself guarded
+		if err := fs.circuitBreaker.allow(); err != nil {
+			return nil, err
+		}
+		rerr := fs.retryARMCall(ctx, "VirtualMachinesClient.Get", func() *retry.Error {
+			var rerr *retry.Error
+			vm, rerr = fs.getVirtualMachineWithThrottledRetry(ctx, clients.vmClient, resourceID.ResourceGroup, key, compute.InstanceViewTypesInstanceView)
+			return rerr
+		})
+		if rerr != nil {
+			if rerr.IsNotFound() {
+				fs.circuitBreaker.record(nil)
+				return nil, nil
+			}
+			klog.ErrorS(rerr.Error(), "VirtualMachinesClient.Get failed", "vmName", key, "resourceGroup", resourceID.ResourceGroup)
+			fs.circuitBreaker.record(rerr.Error())
+			return nil, rerr.Error()
+		}
+		fs.circuitBreaker.record(nil)
+		if vm.VirtualMachineProperties == nil {
+			return nil, nil
+		}
+		return vm.VirtualMachineProperties.InstanceView, nil
+	}
+
+	if fs.Config.VmssFlexVMInstanceViewCacheTTLInSeconds == 0 {
+		fs.Config.VmssFlexVMInstanceViewCacheTTLInSeconds = consts.VmssFlexVMInstanceViewCacheTTLDefaultInSeconds
+	}
+	return azcache.NewTimedCacheWithTTLJitter(time.Duration(fs.Config.VmssFlexVMInstanceViewCacheTTLInSeconds)*time.Second, getVmssFlexCacheTTLJitter(fs.Config.VmssFlexCacheTTLJitter), getter, fs.Cloud.Config.DisableAPICallCache)
+}
+
+// getVirtualMachineWithThrottledRetry gets the VM, retrying the throttled GETs up to VmssFlexVMGetThrottledRetryAttempts
+// times so that a transient throttling does not flap the node resolution. Each retry waits until the Retry-After of the
+// throttled response, or with exponential backoff if it is absent, bounded by VmssFlexVMGetThrottledRetryMaxDelayInSeconds.
+func (fs *FlexScaleSet) getVirtualMachineWithThrottledRetry(ctx context.Context, vmClient vmclient.Interface, resourceGroup, vmName string, expand compute.InstanceViewTypes) (compute.VirtualMachine, *retry.Error) {
+	attempts := fs.Config.VmssFlexVMGetThrottledRetryAttempts
+	if attempts == 0 {
+		attempts = consts.VmssFlexVMGetThrottledRetryAttemptsDefault
+	}
+	maxDelayInSeconds := fs.Config.VmssFlexVMGetThrottledRetryMaxDelayInSeconds
+	if maxDelayInSeconds <= 0 {
+		maxDelayInSeconds = consts.VmssFlexVMGetThrottledRetryMaxDelayDefaultInSeconds
+	}
+	backoff := wait.Backoff{
+		Duration: consts.VmssFlexVMGetThrottledRetryMinIntervalInMilliseconds * time.Millisecond,
+		Factor:   2,
+		Steps:    attempts,
+		Cap:      time.Duration(maxDelayInSeconds) * time.Second,
+	}
+
+	vm, rerr := vmClient.Get(ctx, resourceGroup, vmName, expand)
+	for rerr.IsThrottled() && backoff.Steps > 0 {
+		delay := backoff.Step()
+		if retryAfter := rerr.RetryAfter.Sub(fs.clock.Now()); retryAfter > 0 {
+			delay = retryAfter
+		}
+		if delay > backoff.Cap {
+			delay = backoff.Cap
+		}
+		klog.V(2).InfoS("VirtualMachinesClient.Get is throttled, retrying", "vmName", vmName, "resourceGroup", resourceGroup, "delay", delay, "remainingAttempts", backoff.Steps)
+		select {
+		case <-ctx.Done():
+			return vm, rerr
+		case <-fs.clock.After(delay):
+		}
+		vm, rerr = vmClient.Get(ctx, resourceGroup, vmName, expand)
+	}
+	return vm, rerr
+}
+
+// getVmssFlexCacheEntry gets the entry of vmssFlexCache, debouncing the force refreshes.
+func (fs *FlexScaleSet) getVmssFlexCacheEntry(key string, crt azcache.AzureCacheReadType) (interface{}, error) {
+	return fs.getCacheEntryWithForceRefreshDebounce(fs.vmssFlexCache, "vmssFlex", key, crt)
+}
+
+// getVmssFlexVMCacheEntry gets the entry of vmssFlexVMCache, debouncing the force refreshes.
+func (fs *FlexScaleSet) getVmssFlexVMCacheEntry(vmssFlexID string, crt azcache.AzureCacheReadType) (interface{}, error) {
+	return fs.getCacheEntryWithForceRefreshDebounce(fs.vmssFlexVMCache, "vmssFlexVM", vmssFlexID, crt)
+}
+
+// getCacheEntryWithForceRefreshDebounce gets the entry of the cache. Once a force refresh of the entry completes,
+// the force refreshes within VmssFlexForceRefreshDebounceInMilliseconds reuse its result rather than listing again,
+// so that a burst of lookups for the missing nodes would not repeat the same listing. The concurrent force refreshes
+// of the same entry are serialized by the lock map, so the waiting ones reuse the result of the in-flight one.
+func (fs *FlexScaleSet) getCacheEntryWithForceRefreshDebounce(cache azcache.Resource, cacheName, key string, crt azcache.AzureCacheReadType) (interface{}, error) {
+	debounce, enabled := fs.getForceRefreshDebounce()
+	if crt != azcache.CacheReadTypeForceRefresh || !enabled {
+		return cache.Get(key, crt)
+	}
+
+	debounceKey := fmt.Sprintf("forceRefresh/%s/%s", cacheName, key)
+	fs.lockMap.LockEntry(debounceKey)
+	defer fs.lockMap.UnlockEntry(debounceKey)
+
+	if refreshedOn, ok := fs.vmssFlexForceRefreshedOn.Load(debounceKey); ok &&
+		fs.clock.Since(refreshedOn.(time.Time)) < debounce {
+		klog.V(4).InfoS("Reuse the just-completed force refresh of the cache", "cache", cacheName, "key", key)
+		return cache.Get(key, azcache.CacheReadTypeUnsafe)
+	}
+
+	cached, err := cache.Get(key, azcache.CacheReadTypeForceRefresh)
+	if err != nil {
+		return nil, err
+	}
+	fs.vmssFlexForceRefreshedOn.Store(debounceKey, fs.clock.Now())
+	return cached, nil
+}
+
+// getForceRefreshDebounce returns the window of VmssFlexForceRefreshDebounceInMilliseconds, and false if the
+// debouncing is disabled.
+func (fs *FlexScaleSet) getForceRefreshDebounce() (time.Duration, bool) {
+	debounce := fs.Config.VmssFlexForceRefreshDebounceInMilliseconds
+	if debounce == 0 {
+		debounce = consts.VmssFlexForceRefreshDebounceDefaultInMilliseconds
+	}
+	return time.Duration(debounce) * time.Millisecond, debounce > 0
+}
+
+// isNodeResolutionJustForceRefreshed returns true if getNodeNameByVMName or getNodeVmssFlexID has completed
+// a force refresh within the debounce window, so that the other one resolving the same missing node right
+// after would not force refresh again. The caller must hold the lock of GetNodeVmssFlexIDLockKey.
+func (fs *FlexScaleSet) isNodeResolutionJustForceRefreshed() bool {
+	debounce, enabled := fs.getForceRefreshDebounce()
+	if !enabled {
+		return false
+	}
+	refreshedOn, ok := fs.vmssFlexForceRefreshedOn.Load(consts.GetNodeVmssFlexIDLockKey)
+	return ok && fs.clock.Since(refreshedOn.(time.Time)) < debounce
+}
+
+// getVmssFlexVMReadTypeOnNotFound returns how the vm caches are read by getNodeNameByVMName and getNodeVmssFlexID
+// once the node is not found in the name maps, which is a force refresh unless VmssFlexDisableForceRefreshOnNotFound
+// is set, in which case the vm caches are only refreshed once expired.
+func (fs *FlexScaleSet) getVmssFlexVMReadTypeOnNotFound() azcache.AzureCacheReadType {
+	if fs.Config.VmssFlexDisableForceRefreshOnNotFound {
+		return azcache.CacheReadTypeDefault
+	}
+	return azcache.CacheReadTypeForceRefresh
+}
+
+// forceRefreshNodeResolution runs the force refresh of getNodeNameByVMName or getNodeVmssFlexID, unless the
+// other one has just completed one, in which case the missing node is reported as not found right away.
+// The caller must hold the lock of GetNodeVmssFlexIDLockKey.
+func (fs *FlexScaleSet) forceRefreshNodeResolution(name string, getter func(string, azcache.AzureCacheReadType) (string, error)) (string, error) {
+	if fs.isNodeResolutionJustForceRefreshed() {
+		klog.V(4).InfoS("Reuse the just-completed force refresh of the node resolution", "name", name)
+		return "", cloudprovider.InstanceNotFound
+	}
+
+	klog.V(2).InfoS("Could not find node in the existing cache. Forcely freshing the cache to check again...", "name", name)
+	result, err := getter(name, azcache.CacheReadTypeForceRefresh)
+	if err == nil || errors.Is(err, cloudprovider.InstanceNotFound) || errors.Is(err, ErrInstanceNotReady) {
+		fs.vmssFlexForceRefreshedOn.Store(consts.GetNodeVmssFlexIDLockKey, fs.clock.Now())
+	}
+	return result, err
+}
+
+// getNodeNameByComputerName derives the node name from the computer name of the vm, which is the lower-case
+// computer name with VmssFlexComputerNameStripPattern removed. The lower-case computer name is kept as is if
+// nothing would be left after the removal.
+func (fs *FlexScaleSet) getNodeNameByComputerName(computerName string) string {
+	nodeName := strings.ToLower(computerName)
+	if fs.computerNameStripRE == nil {
+		return nodeName
+	}
+	if stripped := fs.computerNameStripRE.ReplaceAllString(nodeName, ""); stripped != "" {
+		return stripped
+	}
+	klog.V(4).InfoS("Nothing is left after stripping the computer name, using it as the node name", "computerName", computerName, "pattern", fs.computerNameStripRE.String())
+	return nodeName
+}
+
+// hasUsableComputerName returns true if the computer name of the vm is set, which is used as the node name.
+func hasUsableComputerName(vm *compute.VirtualMachine) bool {
+	return vm.OsProfile != nil && pointer.StringDeref(vm.OsProfile.ComputerName, "") != ""
+}
+
+// getUnusableComputerNameReason returns the reason why the VM has no usable computer name, which labels the
+// metric of the VMs skipped from the cache.
+func getUnusableComputerNameReason(vm *compute.VirtualMachine) string {
+	if vm.OsProfile == nil {
+		return "nil_os_profile"
+	}
+	return "nil_computer_name"
+}
+
+// getVmssFlexVMByVMName returns the cached vmss flex vm by the vm name. ErrInstanceNotReady is returned if the
+// vm exists but has no computer name or is being created, and cloudprovider.InstanceNotFound if it does not exist.
+func (fs *FlexScaleSet) getVmssFlexVMByVMName(vmName string, crt azcache.AzureCacheReadType) (compute.VirtualMachine, error) {
+	nodeName, err := fs.getNodeNameByVMName(vmName)
+	if err != nil {
+		return compute.VirtualMachine{}, err
+	}
+	vm, err := fs.getVmssFlexVM(nodeName, crt)
+	if err != nil {
+		return vm, err
+	}
+	if vm.VirtualMachineProperties != nil && strings.EqualFold(pointer.StringDeref(vm.ProvisioningState, ""), consts.ProvisioningStateCreating) {
+		return vm, fmt.Errorf("%w: vm %s is being created", ErrInstanceNotReady, vmName)
+	}
+	return vm, nil
+}
+
+// getNodeNameByVMName returns the node name of the VM. The VM names are matched case-insensitively,
+// since the VM name parsed from a providerID may be in different cases from the one listed from ARM.
+// If DisableAPICallCache is set, the VMs are always listed from ARM rather than looked up in the name maps. If
+// VmssFlexDisableForceRefreshOnNotFound is set, the VM missing from the caches is reported as not found without
+// force refreshing them.
+func (fs *FlexScaleSet) getNodeNameByVMName(vmName string) (string, error) {
+	vmName = strings.ToLower(vmName)
+	if fs.Config.DisableAPICallCache {
+		_, nodeName, _, err := fs.findVmssFlexVMWithoutCache("", func(_ string, vm *compute.VirtualMachine) bool {
+			return strings.EqualFold(pointer.StringDeref(vm.Name, ""), vmName)
+		})
+		return nodeName, err
+	}
+	fs.lockMap.LockEntry(consts.GetNodeVmssFlexIDLockKey)
+	defer fs.lockMap.UnlockEntry(consts.GetNodeVmssFlexIDLockKey)
+	cachedNodeName, isCached := fs.vmssFlexVMNameToNodeName.Get(vmName)
+	if isCached {
+		return fmt.Sprintf("%v", cachedNodeName), nil
+	}
+
+	getter := func(vmName string, crt azcache.AzureCacheReadType) (string, error) {
+		vmssFlexes, err := fs.getVmssFlexes(crt)
+		if err != nil {
+			return "", err
+		}
+
+		vmssFlexes.Range(func(key, value interface{}) bool {
+			vmssFlexID := key.(string)
+			_, err := fs.getVmssFlexVMCacheEntry(vmssFlexID, fs.getVmssFlexVMReadTypeOnNotFound())
+			if err != nil {
+				klog.ErrorS(err, "Failed to refresh vmss flex VM cache", "vmssFlexID", vmssFlexID)
+			}
+			return true
+		})
+
+		cachedNodeName, isCached = fs.vmssFlexVMNameToNodeName.Get(vmName)
+		if isCached {
+			return fmt.Sprintf("%v", cachedNodeName), nil
+		}
+		if _, notReady := fs.vmssFlexNotReadyVMNames.Get(vmName); notReady {
+			return "", fmt.Errorf("%w: vm %s has no computer name", ErrInstanceNotReady, vmName)
+		}
+		return "", cloudprovider.InstanceNotFound
+	}
+
+	nodeName, err := getter(vmName, azcache.CacheReadTypeDefault)
+	if errors.Is(err, cloudprovider.InstanceNotFound) && !fs.Config.VmssFlexDisableForceRefreshOnNotFound {
+		return fs.forceRefreshNodeResolution(vmName, getter)
+	}
+	return nodeName, err
+
+}
+
+// addAmbiguousNodeName records the vmssFlexIDs claiming the same computer name.
+func (fs *FlexScaleSet) addAmbiguousNodeName(nodeName string, vmssFlexIDs ...string) {
+	fs.vmssFlexAmbiguousNodeNamesLock.Lock()
+	defer fs.vmssFlexAmbiguousNodeNamesLock.Unlock()
+
+	if _, ok := fs.vmssFlexAmbiguousNodeNames[nodeName]; !ok {
+		fs.vmssFlexAmbiguousNodeNames[nodeName] = sets.New[string]()
+	}
+	for _, vmssFlexID := range vmssFlexIDs {
+		fs.vmssFlexAmbiguousNodeNames[nodeName].Insert(vmssFlexID)
+	}
+	klog.Warningf("Computer name %s is claimed by multiple VMSS Flex: %s", nodeName, strings.Join(sets.List(fs.vmssFlexAmbiguousNodeNames[nodeName]), ", "))
+}
+
+// reportDuplicateComputerName makes the VM claiming the computer name of another cached VM observable,
+// since the node resolution may flip-flop between the two VMs.
+func (fs *FlexScaleSet) reportDuplicateComputerName(nodeName string, vmName *string, vmssFlexID string, previousVMName *string, previousVmssFlexID string) {
+	klog.ErrorS(nil, "Computer name of the VMSS Flex VM is already claimed by another VM", "node", nodeName,
+		"vmName", pointer.StringDeref(vmName, ""), "vmssFlexID", vmssFlexID,
+		"previousVMName", pointer.StringDeref(previousVMName, ""), "previousVmssFlexID", previousVmssFlexID)
+	metrics.CountDuplicateComputerName("vmss_flex_vm")
+}
+
+// getOtherVMNameOfNodeName returns the name of another cached VM with the computer name.
+func (fs *FlexScaleSet) getOtherVMNameOfNodeName(nodeName, vmName string) *string {
+	var otherVMName *string
+	fs.vmssFlexVMNameToNodeName.Range(func(key string, value interface{}) bool {
+		if value.(string) == nodeName && !strings.EqualFold(key, vmName) {
+			otherVMName = pointer.String(key)
+			return false
+		}
+		return true
+	})
+	return otherVMName
+}
+
+// pruneAmbiguousNodeNames removes the vmssFlexID from the ambiguous node names which
+// are no longer found in the refreshed vm map of the vmss flex.
+func (fs *FlexScaleSet) pruneAmbiguousNodeNames(vmssFlexID string, vmMap *sync.Map) {
+	fs.vmssFlexAmbiguousNodeNamesLock.Lock()
+	defer fs.vmssFlexAmbiguousNodeNamesLock.Unlock()
+
+	for nodeName, vmssFlexIDs := range fs.vmssFlexAmbiguousNodeNames {
+		if _, ok := vmMap.Load(nodeName); ok {
+			continue
+		}
+		vmssFlexIDs.Delete(vmssFlexID)
+		if vmssFlexIDs.Len() > 1 {
+			continue
+		}
+		delete(fs.vmssFlexAmbiguousNodeNames, nodeName)
+		if remaining, ok := vmssFlexIDs.PopAny(); ok {
+			fs.vmssFlexVMNameToVmssID.Set(nodeName, remaining)
+		}
+	}
+}
+
+// getCachedNodeVmssFlexID returns the cached vmssFlexID of the node. If the computer name
+// is claimed by multiple vmss flex, the one in the cluster resource group is picked as the
+// hint, otherwise ErrorVmssFlexComputerNameAmbiguous is returned with the conflicting IDs.
+func (fs *FlexScaleSet) getCachedNodeVmssFlexID(nodeName string) (string, bool, error) {
+	fs.vmssFlexAmbiguousNodeNamesLock.Lock()
+	vmssFlexIDs, isAmbiguous := fs.vmssFlexAmbiguousNodeNames[nodeName]
+	var candidates []string
+	if isAmbiguous {
+		candidates = sets.List(vmssFlexIDs)
+	}
+	fs.vmssFlexAmbiguousNodeNamesLock.Unlock()
+
+	if isAmbiguous {
+		var matched []string
+		for _, vmssFlexID := range candidates {
+			matches := vmssFlexIDRE.FindStringSubmatch(vmssFlexID)
+			if len(matches) == 2 && strings.EqualFold(matches[1], fs.ResourceGroup) {
+				matched = append(matched, vmssFlexID)
+			}
+		}
+		if len(matched) != 1 {
+			return "", true, fmt.Errorf("%w: node %s is claimed by %s", ErrorVmssFlexComputerNameAmbiguous, nodeName, strings.Join(candidates, ", "))
+		}
+		klog.V(2).InfoS("Node is claimed by multiple VMSS Flex, picking the one in the cluster resource group", "node", nodeName, "vmssFlexIDs", candidates, "vmssFlexID", matched[0], "resourceGroup", fs.ResourceGroup)
+		return matched[0], true, nil
+	}
+
+	cachedVmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName)
+	if isCached {
+		return fmt.Sprintf("%v", cachedVmssFlexID), true, nil
+	}
+	return "", false, nil
+}
+
+// getNodeVmssFlexID returns the vmssFlexID of the node. If DisableAPICallCache is set, the VMs are always listed
+// from ARM rather than looked up in the name maps. If VmssFlexDisableForceRefreshOnNotFound is set, the node missing
+// from the caches is reported as not found without force refreshing them or listing its resource group.
+func (fs *FlexScaleSet) getNodeVmssFlexID(nodeName string) (string, error) {
+	if fs.Config.DisableAPICallCache {
+		vmssFlexID, _, _, err := fs.findVmssFlexVMWithoutCache(nodeName, func(cachedNodeName string, _ *compute.VirtualMachine) bool {
+			return cachedNodeName == nodeName
+		})
+		return vmssFlexID, err
+	}
+	fs.lockMap.LockEntry(consts.GetNodeVmssFlexIDLockKey)
+	defer fs.lockMap.UnlockEntry(consts.GetNodeVmssFlexIDLockKey)
+	cachedVmssFlexID, isCached, err := fs.getCachedNodeVmssFlexID(nodeName)
+	if err != nil {
+		return "", err
+	}
+	if isCached {
+		return cachedVmssFlexID, nil
+	}
+
+	getter := func(nodeName string, crt azcache.AzureCacheReadType) (string, error) {
+		vmssFlexes, err := fs.getVmssFlexes(crt)
+		if err != nil {
+			return "", err
+		}
+
+		for _, vmssID := range getVmssFlexIDsInLookupOrder(vmssFlexes, nodeName) {
+			if _, err := fs.getVmssFlexVMCacheEntry(vmssID, fs.getVmssFlexVMReadTypeOnNotFound()); err != nil {
+				klog.ErrorS(err, "Failed to refresh vmss flex VM cache", "vmssFlexID", vmssID)
+			}
+			// if the vm is cached stop refreshing
+			cachedVmssFlexID, isCached, err := fs.getCachedNodeVmssFlexID(nodeName)
+			if err != nil {
+				return "", err
+			}
+			if isCached {
+				return cachedVmssFlexID, nil
+			}
+		}
+		return "", cloudprovider.InstanceNotFound
+	}
+
+	vmssFlexID, err := getter(nodeName, azcache.CacheReadTypeDefault)
+	if fs.Config.VmssFlexDisableForceRefreshOnNotFound {
+		return vmssFlexID, err
+	}
+	if errors.Is(err, cloudprovider.InstanceNotFound) {
+		vmssFlexID, err = fs.forceRefreshNodeResolution(nodeName, getter)
+	}
+	if errors.Is(err, cloudprovider.InstanceNotFound) {
+		return fs.getNodeVmssFlexIDOutOfRound(nodeName)
+	}
+	return vmssFlexID, err
+
+}
+
+// getVmssFlexIDsInLookupOrder returns the vmssFlexIDs of the vmss flex, the one whose computer name prefix or name
+// matches the node name coming first, since the node most likely belongs to it.
+func getVmssFlexIDsInLookupOrder(vmssFlexes *sync.Map, nodeName string) []string {
+	var vmssFlexIDs []string
+	vmssFlexes.Range(func(key, value interface{}) bool {
+		vmssFlexID := key.(string)
+		vmssFlex := value.(*compute.VirtualMachineScaleSet)
+		vmssPrefix := pointer.StringDeref(vmssFlex.Name, "")
+		if vmssFlex.VirtualMachineProfile != nil &&
+			vmssFlex.VirtualMachineProfile.OsProfile != nil &&
+			vmssFlex.VirtualMachineProfile.OsProfile.ComputerNamePrefix != nil {
+			vmssPrefix = pointer.StringDeref(vmssFlex.VirtualMachineProfile.OsProfile.ComputerNamePrefix, "")
+		}
+		if len(nodeName) > 6 && strings.EqualFold(vmssPrefix, nodeName[:len(nodeName)-6]) {
+			// we should check this vmss first since nodeName and vmssFlex.Name or
+			// ComputerNamePrefix belongs to same vmss, so prepend here
+			vmssFlexIDs = append([]string{vmssFlexID}, vmssFlexIDs...)
+		} else {
+			vmssFlexIDs = append(vmssFlexIDs, vmssFlexID)
+		}
+		return true
+	})
+	return vmssFlexIDs
+}
+
+// findVmssFlexVMWithoutCache lists the vmss flex and their VMs from ARM until match returns true for a VM and its
+// node name, without reading or writing the name maps, which are not maintained if DisableAPICallCache is set. The
+// vmss flex likely owning nodeNameHint are listed first. It returns the vmssFlexID, the node name and the matched VM,
+// or cloudprovider.InstanceNotFound if none matches. The VMs without a computer name are never matched.
+func (fs *FlexScaleSet) findVmssFlexVMWithoutCache(nodeNameHint string, match func(nodeName string, vm *compute.VirtualMachine) bool) (string, string, *compute.VirtualMachine, error) {
+	// the read type makes no difference if the cache is disabled, while the unsafe one lists all the resource groups
+	vmssFlexes, err := fs.getVmssFlexes(azcache.CacheReadTypeUnsafe)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	for _, vmssFlexID := range getVmssFlexIDsInLookupOrder(vmssFlexes, nodeNameHint) {
+		cached, err := fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeDefault)
+		if err != nil {
+			klog.ErrorS(err, "Failed to list vmss flex VMs", "vmssFlexID", vmssFlexID)
+			continue
+		}
+		var nodeName string
+		var vm *compute.VirtualMachine
+		cached.(*sync.Map).Range(func(key, value interface{}) bool {
+			if match(key.(string), value.(*compute.VirtualMachine)) {
+				nodeName, vm = key.(string), value.(*compute.VirtualMachine)
+				return false
+			}
+			return true
+		})
+		if vm != nil {
+			return vmssFlexID, nodeName, vm, nil
+		}
+	}
+	return "", "", nil, cloudprovider.InstanceNotFound
+}
+
+// getNodeVmssFlexIDOutOfRound looks up the node which is not found by listing the VMSS Flex in the resource group
+// of the node, if it was not read by the last round of getVmssFlexes. The caller must hold the lock of
+// GetNodeVmssFlexIDLockKey.
+func (fs *FlexScaleSet) getNodeVmssFlexIDOutOfRound(nodeName string) (string, error) {
+	resourceGroup, err := fs.GetNodeResourceGroup(nodeName)
+	if err != nil || fs.isInLastVmssFlexResourceGroupRound(resourceGroup) {
+		return "", cloudprovider.InstanceNotFound
+	}
+
+	klog.V(2).InfoS("Could not find node in the round of the resource groups, listing the VMSS Flex in its resource group", "node", nodeName, "resourceGroup", resourceGroup)
+	vmssFlex, err := fs.listNodeVmssFlexInResourceGroup(nodeName, resourceGroup)
+	if err != nil {
+		return "", err
+	}
+	return pointer.StringDeref(vmssFlex.ID, ""), nil
+}
+
+// IsNodeVmssFlex returns true if the node is a VM of a Flexible orchestration mode scale set, and false if it
+// resolves to no scale set or to a scale set of other orchestration modes. An error is only returned when the
+// lookup fails, so a node missing from the cache is not mistaken for a lookup failure, and vice versa.
+func (fs *FlexScaleSet) IsNodeVmssFlex(nodeName string) (bool, error) {
+	vmssFlexID, err := fs.getNodeVmssFlexID(nodeName)
+	if err != nil {
+		if errors.Is(err, cloudprovider.InstanceNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	vmssFlex, err := fs.getVmssFlexByVmssFlexID(vmssFlexID, azcache.CacheReadTypeDefault)
+	if err != nil {
+		if errors.Is(err, cloudprovider.InstanceNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return vmssFlex.OrchestrationMode == compute.Flexible, nil
+}
+
+// GetResolvedResourceGroup returns the resource group where the VM of the node was found, which may differ
+// from the cluster resource group when the node is resolved from the other resource groups of the cluster.
+func (fs *FlexScaleSet) GetResolvedResourceGroup(nodeName string) (string, error) {
+	vm, err := fs.getVmssFlexVM(nodeName, azcache.CacheReadTypeDefault)
+	if err != nil {
+		return "", err
+	}
+	resourceID, err := azure.ParseResourceID(pointer.StringDeref(vm.ID, ""))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the VM ID of node %s: %w", nodeName, err)
+	}
+	return resourceID.ResourceGroup, nil
+}
+
+// getVmssFlexVM returns the cached vmss flex vm of the node. A node created within VmssFlexNewVMRetryWindowInSeconds
+// which is not found is retried by force refreshing the caches with backoff, since the new VM may not be listed
+// until the ARM propagation completes. The other nodes which are not found are returned without delay.
+// ErrInstanceNotReady is returned instead of cloudprovider.InstanceNotFound if a vm named after the node is
+// listed without a computer name. The lookups found are sampled for the verification by VmssFlexCacheVerificationSampleRate.
+// If DisableAPICallCache is set, the vm is always looked up from ARM, and neither is done.
+func (fs *FlexScaleSet) getVmssFlexVM(nodeName string, crt azcache.AzureCacheReadType) (compute.VirtualMachine, error) {
+	_, vm, err := fs.getVmssFlexVMAndID(nodeName, crt)
+	return vm, err
+}
+
+// getVmssFlexVMAndID returns the VM of the node as getVmssFlexVM does, along with the vmssFlexID of the node
+// resolved to look it up, which is empty if the vmss flex of the node is not resolved.
+func (fs *FlexScaleSet) getVmssFlexVMAndID(nodeName string, crt azcache.AzureCacheReadType) (vmssFlexID string, vm compute.VirtualMachine, err error) {
+	defer func() {
+		if errors.Is(err, cloudprovider.InstanceNotFound) && !fs.Config.DisableAPICallCache {
+			if _, notReady := fs.vmssFlexNotReadyVMNames.Get(strings.ToLower(nodeName)); notReady {
+				err = fmt.Errorf("%w: vm %s has no computer name", ErrInstanceNotReady, nodeName)
+			}
+		}
+		if err == nil && !fs.Config.DisableAPICallCache {
+			fs.maybeVerifyVmssFlexVM(nodeName, vm)
+		}
+	}()
+
+	vmssFlexID, vm, err = fs.getCachedVmssFlexVM(nodeName, crt)
+	if !errors.Is(err, cloudprovider.InstanceNotFound) || fs.Config.VmssFlexNewVMRetryAttempts <= 0 {
+		return vmssFlexID, vm, err
+	}
+
+	window := fs.Config.VmssFlexNewVMRetryWindowInSeconds
+	if window <= 0 {
+		window = consts.VmssFlexNewVMRetryWindowDefaultInSeconds
+	}
+	if !fs.isNodeRecentlyCreated(nodeName, time.Duration(window)*time.Second, fs.clock) {
+		return vmssFlexID, vm, err
+	}
+
+	// the retries must be apart longer than the debounce window, otherwise the force refreshes would be skipped
+	interval := fs.Config.VmssFlexForceRefreshDebounceInMilliseconds
+	if interval == 0 {
+		interval = consts.VmssFlexForceRefreshDebounceDefaultInMilliseconds
+	}
+	if interval < consts.VmssFlexNewVMRetryMinIntervalInMilliseconds {
+		interval = consts.VmssFlexNewVMRetryMinIntervalInMilliseconds
+	}
+	backoff := wait.Backoff{
+		Duration: time.Duration(interval) * time.Millisecond,
+		Factor:   2,
+		Steps:    fs.Config.VmssFlexNewVMRetryAttempts,
+	}
+	for backoff.Steps > 0 {
+		delay := backoff.Step()
+		klog.V(2).InfoS("Recently created node is not found in the vmss flex cache, retrying", "node", nodeName, "delay", delay, "remainingAttempts", backoff.Steps)
+		fs.clock.Sleep(delay)
+		vmssFlexID, vm, err = fs.getCachedVmssFlexVM(nodeName, azcache.CacheReadTypeForceRefresh)
+		if !errors.Is(err, cloudprovider.InstanceNotFound) {
+			return vmssFlexID, vm, err
+		}
+	}
+	return vmssFlexID, vm, err
+}
+
+// getCachedVmssFlexVM returns the cached VM of the node and the vmssFlexID of the node, which is empty if the
+// vmss flex of the node is not resolved.
+func (fs *FlexScaleSet) getCachedVmssFlexVM(nodeName string, crt azcache.AzureCacheReadType) (string, compute.VirtualMachine, error) {
+	if fs.Config.DisableAPICallCache {
+		vmssFlexID, _, found, err := fs.findVmssFlexVMWithoutCache(nodeName, func(cachedNodeName string, _ *compute.VirtualMachine) bool {
+			return cachedNodeName == nodeName
+		})
+		if err != nil {
+			return "", compute.VirtualMachine{}, err
+		}
+		return vmssFlexID, *found, nil
+	}
+
+	vmssFlexID, err := fs.getNodeVmssFlexID(nodeName)
+	if err != nil {
+		return "", compute.VirtualMachine{}, err
+	}
+
+	cached, err := fs.getVmssFlexVMCacheEntry(vmssFlexID, crt)
+	if err != nil {
+		return vmssFlexID, compute.VirtualMachine{}, err
+	}
+	vmMap := cached.(*sync.Map)
+	cachedVM, ok := vmMap.Load(nodeName)
+	if !ok {
+		klog.V(2).InfoS("Did not find node in the existing cache, which means it is deleted...", "node", nodeName, "vmssFlexID", vmssFlexID)
+		return vmssFlexID, compute.VirtualMachine{}, cloudprovider.InstanceNotFound
+	}
+
+	return vmssFlexID, *(cachedVM.(*compute.VirtualMachine)), nil
+}
+
+// getVmssFlexVMWithMaxAge returns the cached VM of the node if the VMs of its vmss flex were listed within
+// maxAge, otherwise the VMs are listed again. It lets the callers tolerating stale data read cheaply beyond
+// the TTL, and the callers requiring fresh data get a tighter bound than the TTL.
+func (fs *FlexScaleSet) getVmssFlexVMWithMaxAge(nodeName string, maxAge time.Duration) (vm compute.VirtualMachine, err error) {
+	vmssFlexID, err := fs.getNodeVmssFlexID(nodeName)
+	if err != nil {
+		return vm, err
+	}
+
+	cached, err := fs.vmssFlexVMCache.GetWithMaxAge(vmssFlexID, maxAge)
+	if err != nil {
+		return vm, err
+	}
+	cachedVM, ok := cached.(*sync.Map).Load(nodeName)
+	if !ok {
+		klog.V(2).InfoS("Did not find node in the existing cache, which means it is deleted...", "node", nodeName, "vmssFlexID", vmssFlexID)
+		return vm, cloudprovider.InstanceNotFound
+	}
+
+	return *(cachedVM.(*compute.VirtualMachine)), nil
+}
+
+// getVmssFlexVMInstanceView returns the instance view of the vmss flex vm from its own cache.
+// The vm cache is only read with CacheReadTypeDefault to resolve the vm name, so that a force
+// refresh only refreshes the instance view rather than listing all the vms of the vmss flex.
+func (fs *FlexScaleSet) getVmssFlexVMInstanceView(nodeName string, crt azcache.AzureCacheReadType) (*compute.VirtualMachineInstanceView, error) {
+	vm, err := fs.getVmssFlexVM(nodeName, azcache.CacheReadTypeDefault)
+	if err != nil {
+		return nil, err
+	}
+	if vm.Name == nil {
+		return nil, fmt.Errorf("failed to get the vm name of node %s", nodeName)
+	}
+
+	cached, err := fs.vmssFlexVMInstanceViewCache.Get(*vm.Name, crt)
+	if err != nil {
+		klog.ErrorS(err, "vmssFlexVMInstanceViewCache.Get failed", "node", nodeName, "vmName", *vm.Name)
+		return nil, err
+	}
+	instanceView, ok := cached.(*compute.VirtualMachineInstanceView)
+	if !ok || instanceView == nil {
+		klog.V(2).InfoS("Did not find the instance view of node, which means it is deleted...", "node", nodeName, "vmName", *vm.Name)
+		return nil, cloudprovider.InstanceNotFound
+	}
+	return instanceView, nil
+}
+
+// GetVmssFlexByID returns the cached vmss flex of the vmssFlexID, refreshing the cache partition of its resource
+// group once if it is not cached. It returns cloudprovider.InstanceNotFound if the vmss flex is not found. The
+// returned object is shared with the cache and must not be modified.
+func (fs *FlexScaleSet) GetVmssFlexByID(vmssFlexID string, crt azcache.AzureCacheReadType) (*compute.VirtualMachineScaleSet, error) {
+	return fs.getVmssFlexByVmssFlexID(vmssFlexID, crt)
+}
+
+// RefreshVmssFlexCache force refreshes the vmss flex cache and returns the sorted vmssFlexIDs added and removed
+// since the snapshot taken by the last call, or since the cached snapshot for the first call. The concurrent calls
+// are serialized, so that each change is returned once, while the lookups keep being served from the cache.
+func (fs *FlexScaleSet) RefreshVmssFlexCache() (added, removed []string, err error) {
+	fs.vmssFlexRefreshLock.Lock()
+	defer fs.vmssFlexRefreshLock.Unlock()
+
+	previous := fs.vmssFlexRefreshedIDs
+	if previous == nil {
+		cached, err := fs.getVmssFlexes(azcache.CacheReadTypeUnsafe)
+		if err != nil {
+			return nil, nil, err
+		}
+		previous = getVmssFlexIDs(cached)
+	}
+
+	refreshed, err := fs.getVmssFlexes(azcache.CacheReadTypeForceRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	current := getVmssFlexIDs(refreshed)
+	fs.vmssFlexRefreshedIDs = current
+
+	added, removed = sets.List(current.Difference(previous)), sets.List(previous.Difference(current))
+	if len(added) > 0 || len(removed) > 0 {
+		klog.V(2).InfoS("Refreshed the vmss flex cache", "added", added, "removed", removed)
+	}
+	return added, removed, nil
+}
+
+// getVmssFlexIDs returns the keys of the vmss flex keyed by the vmssFlexID.
+func getVmssFlexIDs(vmssFlexes *sync.Map) sets.Set[string] {
+	vmssFlexIDs := sets.New[string]()
+	vmssFlexes.Range(func(key, _ interface{}) bool {
+		vmssFlexIDs.Insert(key.(string))
+		return true
+	})
+	return vmssFlexIDs
+}
+
+// InvalidateVmssFlexByID removes the vmss flex from the cache and evicts its nodes from the vm cache and the name
+// maps, e.g. once an external event source reports that the vmss flex is deleted, so that the lookups would not be
+// served from the cache until the TTL expires. It is a no-op if the vmss flex is not cached, or if DisableAPICallCache
+// is set. The eviction of each node is notified.
+func (fs *FlexScaleSet) InvalidateVmssFlexByID(vmssFlexID string) error {
+	if fs.Config.DisableAPICallCache {
+		return nil
+	}
+	partitionKey, err := getVmssFlexCachePartitionKey(vmssFlexID)
+	if err != nil {
+		return err
+	}
+
+	// the vmssFlexID reported by the event may be in different cases from the cached one
+	if partition := fs.peekVmssFlexCachePartition(getResourceGroupByVmssFlexCachePartitionKey(partitionKey)); partition != nil {
+		partition.Range(func(key, _ interface{}) bool {
+			if strings.EqualFold(key.(string), vmssFlexID) {
+				vmssFlexID = key.(string)
+				partition.Delete(key)
+				return false
+			}
+			return true
+		})
+	}
+
+	evictedVMNames := fs.evictVmssFlex(vmssFlexID)
+	for nodeName, vmName := range evictedVMNames {
+		fs.notifyNodeCacheEvicted(nodeName, vmName, vmssFlexID)
+	}
+	klog.V(2).InfoS("InvalidateVmssFlexByID successfully", "vmssFlexID", vmssFlexID, "evictedNodes", len(evictedVMNames))
+	return nil
+}
+
+// evictVmssFlex deletes the vm cache of the vmss flex and its nodes from the name maps under the lock of the vmss
+// flex. The nodes claimed by other vmss flex as well are handed over to them rather than evicted. It returns the
+// names of the evicted vms keyed by the node name.
+func (fs *FlexScaleSet) evictVmssFlex(vmssFlexID string) map[string]string {
+	fs.lockMap.LockEntry(vmssFlexID)
+	defer fs.lockMap.UnlockEntry(vmssFlexID)
+
+	if item, exists, err := fs.vmssFlexVMCache.GetStore().GetByKey(vmssFlexID); err == nil && exists {
+		if entry, ok := item.(*azcache.AzureCacheEntry); ok {
+			entry.Lock.Lock()
+			vmMap, _ := entry.Data.(*sync.Map)
+			entry.Lock.Unlock()
+			if vmMap != nil {
+				vmMap.Range(func(_, value interface{}) bool {
+					if vmName := value.(*compute.VirtualMachine).Name; vmName != nil {
+						_ = fs.vmssFlexVMInstanceViewCache.Delete(*vmName)
+					}
+					return true
+				})
+			}
+		}
+	}
+	_ = fs.vmssFlexVMCache.Delete(vmssFlexID)
+	fs.vmssFlexIDToNodeNames.Delete(vmssFlexID)
+	fs.vmssFlexIDToInstanceIDIndex.Delete(strings.ToLower(vmssFlexID))
+	fs.pruneAmbiguousNodeNames(vmssFlexID, &sync.Map{})
+	fs.vmssFlexNotReadyVMNames.Range(func(vmName string, cachedVmssFlexID interface{}) bool {
+		if strings.EqualFold(cachedVmssFlexID.(string), vmssFlexID) {
+			fs.vmssFlexNotReadyVMNames.Delete(vmName)
+		}
+		return true
+	})
+
+	nodeNames := sets.New[string]()
+	fs.vmssFlexVMNameToVmssID.Range(func(nodeName string, cachedVmssFlexID interface{}) bool {
+		if strings.EqualFold(cachedVmssFlexID.(string), vmssFlexID) {
+			nodeNames.Insert(nodeName)
+		}
+		return true
+	})
+	evictedVMNames := make(map[string]string, nodeNames.Len())
+	fs.vmssFlexVMNameToNodeName.Range(func(vmName string, nodeName interface{}) bool {
+		if nodeNames.Has(nodeName.(string)) {
+			fs.vmssFlexVMNameToNodeName.Delete(vmName)
+			evictedVMNames[nodeName.(string)] = vmName
+		}
+		return true
+	})
+	for nodeName := range nodeNames {
+		fs.vmssFlexVMNameToVmssID.Delete(nodeName)
+		fs.vmssFlexNodeNameToCachedOn.Delete(nodeName)
+		if _, ok := evictedVMNames[nodeName]; !ok {
+			evictedVMNames[nodeName] = ""
+		}
+	}
+	fs.deleteNodePrivateIPs(nodeNames)
+	return evictedVMNames
+}
+
+// FlexCacheSnapshot is a point-in-time copy of the vmss flex cache and its name maps, which is not mutated by the
+// later updates of the cache. Since the SDK omits the read-only fields when marshaling, e.g. the IDs and the names
+// of the scale sets, the JSON of the scale sets relies on the vmssFlexIDs keying them.
+type FlexCacheSnapshot struct {
+	// TakenOn is when the snapshot was taken.
+	TakenOn time.Time `json:"takenOn"`
+	// RefreshedOn is when the cache partition of each lower-case resource group was last refreshed.
+	RefreshedOn map[string]time.Time `json:"refreshedOn"`
+	// VmssFlexes are the cached vmss flex keyed by the vmssFlexID.
+	VmssFlexes map[string]*compute.VirtualMachineScaleSet `json:"vmssFlexes"`
+	// NodeNameToVmssFlexID is the copy of vmssFlexVMNameToVmssID.
+	NodeNameToVmssFlexID map[string]string `json:"nodeNameToVmssFlexID"`
+	// VMNameToNodeName is the copy of vmssFlexVMNameToNodeName.
+	VMNameToNodeName map[string]string `json:"vmNameToNodeName"`
+	// NodeNameToCachedOn is the copy of vmssFlexNodeNameToCachedOn.
+	NodeNameToCachedOn map[string]time.Time `json:"nodeNameToCachedOn"`
+}
+
+// ExportVmssFlexCacheSnapshot returns a deep copy of the cached vmss flex and the name maps, e.g. for debugging
+// or seeding another replica. It never refreshes the cache. The partitions being refreshed are copied once their
+// refresh completes, while the name maps are copied without blocking the lookups, so that a concurrent refresh of
+// the vmss flex VMs may be only partially reflected.
+func (fs *FlexScaleSet) ExportVmssFlexCacheSnapshot() (*FlexCacheSnapshot, error) {
+	snapshot := &FlexCacheSnapshot{
+		TakenOn:              fs.clock.Now(),
+		RefreshedOn:          map[string]time.Time{},
+		VmssFlexes:           map[string]*compute.VirtualMachineScaleSet{},
+		NodeNameToVmssFlexID: map[string]string{},
+		VMNameToNodeName:     map[string]string{},
+		NodeNameToCachedOn:   map[string]time.Time{},
+	}
+
+	for _, item := range fs.vmssFlexCache.GetStore().List() {
+		entry, ok := item.(*azcache.AzureCacheEntry)
+		if !ok {
+			return nil, fmt.Errorf("unexpected vmss flex cache entry of type %T", item)
+		}
+		entry.Lock.Lock()
+		data, refreshedOn := entry.Data, entry.CreatedOn
+		entry.Lock.Unlock()
+
+		partition, ok := data.(*sync.Map)
+		if !ok {
+			continue
+		}
+		snapshot.RefreshedOn[getResourceGroupByVmssFlexCachePartitionKey(entry.Key)] = refreshedOn
+		partition.Range(func(key, value interface{}) bool {
+			snapshot.VmssFlexes[key.(string)] = deepcopy.Copy(value).(*compute.VirtualMachineScaleSet)
+			return true
+		})
+	}
+
+	fs.vmssFlexVMNameToVmssID.Range(func(key string, value interface{}) bool {
+		snapshot.NodeNameToVmssFlexID[key] = value.(string)
+		return true
+	})
+	fs.vmssFlexVMNameToNodeName.Range(func(key string, value interface{}) bool {
+		snapshot.VMNameToNodeName[key] = value.(string)
+		return true
+	})
+	fs.vmssFlexNodeNameToCachedOn.Range(func(key string, value interface{}) bool {
+		snapshot.NodeNameToCachedOn[key] = value.(time.Time)
+		return true
+	})
+	return snapshot, nil
+}
+
+// GetNodeNameByVmssFlexInstanceID returns the node name of the VM of the vmss flex by its instance ID, e.g. as returned
+// by the ARM APIs of the scale set VMs, which is the name of the VM for the flexible orchestration mode. The VM is
+// looked up in the name maps first, and then in the index of the cached VMs of the vmss flex, which are only listed
+// again once expired. It returns cloudprovider.InstanceNotFound if the VM is not cached in the vmss flex.
+func (fs *FlexScaleSet) GetNodeNameByVmssFlexInstanceID(vmssFlexID, instanceID string) (string, error) {
+	if !vmssFlexIDRE.MatchString(vmssFlexID) {
+		return "", fmt.Errorf("%w: malformed vmss flex ID %q", cloudprovider.InstanceNotFound, vmssFlexID)
+	}
+
+	if !fs.Config.DisableAPICallCache {
+		if nodeName, isCached := fs.vmssFlexVMNameToNodeName.Get(strings.ToLower(instanceID)); isCached {
+			cachedVmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName.(string))
+			if (isCached && strings.EqualFold(cachedVmssFlexID.(string), vmssFlexID)) || fs.isAmbiguousNodeNameOf(nodeName.(string), vmssFlexID) {
+				return nodeName.(string), nil
+			}
+		}
+	}
+
+	cached, err := fs.getVmssFlexVMCacheEntry(vmssFlexID, azcache.CacheReadTypeDefault)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get vmss flex VM cache", "vmssFlexID", vmssFlexID)
+		return "", err
+	}
+	nodeName, ok := fs.getVmssFlexInstanceIDIndex(vmssFlexID, cached.(*sync.Map)).nodeNames[strings.ToLower(instanceID)]
+	if !ok {
+		klog.V(4).InfoS("Instance is not found in the VMSS Flex cache", "vmssFlexID", vmssFlexID, "instanceID", instanceID)
+		return "", cloudprovider.InstanceNotFound
+	}
+	return nodeName, nil
+}
+
+// vmssFlexInstanceIDIndex maps the lower-case instance IDs, i.e. the VM names, of the VMs cached in vms to the
+// node names.
+type vmssFlexInstanceIDIndex struct {
+	vms       *sync.Map
+	nodeNames map[string]string
+}
+
+func newVmssFlexInstanceIDIndex(vms *sync.Map) *vmssFlexInstanceIDIndex {
+	index := &vmssFlexInstanceIDIndex{vms: vms, nodeNames: map[string]string{}}
+	vms.Range(func(key, value interface{}) bool {
+		if vmName := value.(*compute.VirtualMachine).Name; vmName != nil {
+			index.nodeNames[strings.ToLower(*vmName)] = key.(string)
+		}
+		return true
+	})
+	return index
+}
+
+// getVmssFlexInstanceIDIndex returns the index of the cached VMs of the vmss flex, which is built when the VMs are
+// listed. The index is rebuilt if it was built for other VMs than the cached ones, e.g. after the cache entry is set
+// directly.
+func (fs *FlexScaleSet) getVmssFlexInstanceIDIndex(vmssFlexID string, vms *sync.Map) *vmssFlexInstanceIDIndex {
+	key := strings.ToLower(vmssFlexID)
+	if cached, ok := fs.vmssFlexIDToInstanceIDIndex.Load(key); ok && cached.(*vmssFlexInstanceIDIndex).vms == vms {
+		return cached.(*vmssFlexInstanceIDIndex)
+	}
+	index := newVmssFlexInstanceIDIndex(vms)
+	fs.vmssFlexIDToInstanceIDIndex.Store(key, index)
+	return index
+}
+
+// GetVmssFlexVMByNodeName returns the cached VM of the node, resolving the vmss flex of the node before looking up
+// the VM in the VMs listed from the vmss flex. The errors of both stages wrap the underlying errors, e.g.
+// cloudprovider.InstanceNotFound if the node is not found in any vmss flex or has been deleted from its vmss flex.
+func (fs *FlexScaleSet) GetVmssFlexVMByNodeName(nodeName string, crt azcache.AzureCacheReadType) (compute.VirtualMachine, error) {
+	vmssFlexID, vm, err := fs.getVmssFlexVMAndID(nodeName, crt)
+	if err != nil && vmssFlexID == "" {
+		return compute.VirtualMachine{}, fmt.Errorf("failed to get the vmss flex of node %s: %w", nodeName, err)
+	}
+	if err != nil {
+		return compute.VirtualMachine{}, fmt.Errorf("failed to get the vm of node %s from vmss flex %s: %w", nodeName, vmssFlexID, err)
+	}
+	return vm, nil
+}
+
+func (fs *FlexScaleSet) getVmssFlexByVmssFlexID(vmssFlexID string, crt azcache.AzureCacheReadType) (*compute.VirtualMachineScaleSet, error) {
+	// the VMSS Flex in a subscription which is not listed would never be found by refreshing the cache
+	if _, err := fs.getVmssFlexSubscriptionClientsByResourceID(vmssFlexID); err != nil {
+		klog.ErrorS(err, "Failed to route the VMSS Flex to a subscription", "vmssFlexID", vmssFlexID)
+		return nil, err
+	}
+	// only the partition of the resource group of the VMSS Flex is read and refreshed
+	partitionKey, err := getVmssFlexCachePartitionKey(vmssFlexID)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := fs.getVmssFlexCacheEntry(partitionKey, crt)
+	if err != nil {
+		if stale := fs.getStaleVmssFlexByVmssFlexID(vmssFlexID, partitionKey, err); stale != nil {
+			return stale, nil
+		}
+		return nil, err
+	}
+	vmssFlexes := cached.(*sync.Map)
+	if vmssFlex, ok := vmssFlexes.Load(vmssFlexID); ok {
+		result := vmssFlex.(*compute.VirtualMachineScaleSet)
+		return result, nil
+	}
+
+	klog.V(2).InfoS("Couldn't find VMSS Flex, refreshing the cache", "vmssFlexID", vmssFlexID)
+	cached, err = fs.getVmssFlexCacheEntry(partitionKey, azcache.CacheReadTypeForceRefresh)
+	if err != nil {
+		if stale := fs.getStaleVmssFlexByVmssFlexID(vmssFlexID, partitionKey, err); stale != nil {
+			return stale, nil
+		}
+		return nil, err
+	}
+	vmssFlexes = cached.(*sync.Map)
+	if vmssFlex, ok := vmssFlexes.Load(vmssFlexID); ok {
+		result := vmssFlex.(*compute.VirtualMachineScaleSet)
+		return result, nil
+	}
+	return nil, cloudprovider.InstanceNotFound
+}
+
+// getStaleVmssFlexByVmssFlexID returns the VMSS Flex from the previous snapshot of the cache partition after
+// the refresh failed, if VmssFlexCacheServeStaleOnError is enabled and the snapshot is not older than
+// VmssFlexCacheMaxStalenessInSeconds. It returns nil if no usable stale entry is found.
+func (fs *FlexScaleSet) getStaleVmssFlexByVmssFlexID(vmssFlexID, partitionKey string, refreshErr error) *compute.VirtualMachineScaleSet {
+	if !fs.Config.VmssFlexCacheServeStaleOnError {
+		return nil
+	}
+	store := fs.vmssFlexCache.GetStore()
+	if store == nil {
+		return nil
+	}
+	obj, exists, err := store.GetByKey(partitionKey)
+	if err != nil || !exists {
+		return nil
+	}
+
+	entry := obj.(*azcache.AzureCacheEntry)
+	entry.Lock.Lock()
+	data, createdOn := entry.Data, entry.CreatedOn
+	entry.Lock.Unlock()
+	if data == nil {
+		return nil
+	}
+
+	maxStaleness := time.Duration(fs.Config.VmssFlexCacheMaxStalenessInSeconds) * time.Second
+	if maxStaleness <= 0 {
+		maxStaleness = consts.VmssFlexCacheMaxStalenessDefaultInSeconds * time.Second
+	}
+	if age := fs.clock.Since(createdOn); age > maxStaleness {
+		klog.V(2).InfoS("The stale VMSS Flex cache exceeds the max staleness, not serving it", "vmssFlexID", vmssFlexID, "age", age, "maxStaleness", maxStaleness)
+		return nil
+	}
+
+	vmssFlex, ok := data.(*sync.Map).Load(vmssFlexID)
+	if !ok {
+		return nil
+	}
+	klog.InfoS("Serving stale VMSS Flex because refreshing the cache failed", "vmssFlexID", vmssFlexID, "cachedAt", createdOn, "err", refreshErr)
+	metrics.CountStaleCacheServed("vmss_flex")
+	return vmssFlex.(*compute.VirtualMachineScaleSet)
+}
+
+func (fs *FlexScaleSet) getVmssFlexByNodeName(nodeName string, crt azcache.AzureCacheReadType) (*compute.VirtualMachineScaleSet, error) {
+	vmssFlexID, err := fs.getNodeVmssFlexID(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	vmssFlex, err := fs.getVmssFlexByVmssFlexID(vmssFlexID, crt)
+	if err != nil {
+		return nil, err
+	}
+	return vmssFlex, nil
+}
+
+// getVmssFlexByNodeNameWithResourceGroupHint gets the vmss flex of the node. If the node is not cached
+// and the resource group of the node is known, only the vmss flex in that resource group are listed,
+// instead of refreshing the vmss flex in all resource groups. It falls back to getVmssFlexByNodeName
+// if the node is not found in the hinted resource group.
+func (fs *FlexScaleSet) getVmssFlexByNodeNameWithResourceGroupHint(nodeName, resourceGroup string, crt azcache.AzureCacheReadType) (*compute.VirtualMachineScaleSet, error) {
+	if resourceGroup == "" {
+		return fs.getVmssFlexByNodeName(nodeName, crt)
+	}
+
+	vmssFlex, err := fs.getVmssFlexByNodeNameInResourceGroup(nodeName, resourceGroup)
+	if err == nil {
+		return vmssFlex, nil
+	}
+	if !errors.Is(err, cloudprovider.InstanceNotFound) {
+		return nil, err
+	}
+	klog.V(2).InfoS("Could not find node in the hinted resource group, falling back to all resource groups", "node", nodeName, "resourceGroup", resourceGroup)
+	return fs.getVmssFlexByNodeName(nodeName, crt)
+}
+
+// getVmssFlexByNodeNameInResourceGroup returns the vmss flex of the node by only listing the vmss flex
+// in the given resource group on cache miss.
+func (fs *FlexScaleSet) getVmssFlexByNodeNameInResourceGroup(nodeName, resourceGroup string) (*compute.VirtualMachineScaleSet, error) {
+	fs.lockMap.LockEntry(consts.GetNodeVmssFlexIDLockKey)
+	defer fs.lockMap.UnlockEntry(consts.GetNodeVmssFlexIDLockKey)
+	cachedVmssFlexID, isCached, err := fs.getCachedNodeVmssFlexID(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	if isCached {
+		return fs.getVmssFlexByVmssFlexID(cachedVmssFlexID, azcache.CacheReadTypeDefault)
+	}
+	return fs.listNodeVmssFlexInResourceGroup(nodeName, resourceGroup)
+}
+
+// listNodeVmssFlexInResourceGroup force refreshes the vmss flex cache partition of the resource group and the VM
+// caches of the vmss flex in it until the node is found. The caller must hold the lock of GetNodeVmssFlexIDLockKey.
+func (fs *FlexScaleSet) listNodeVmssFlexInResourceGroup(nodeName, resourceGroup string) (*compute.VirtualMachineScaleSet, error) {
+	cached, err := fs.getVmssFlexCacheEntry(getVmssFlexCachePartitionKeyByResourceGroup(resourceGroup), azcache.CacheReadTypeForceRefresh)
+	if err != nil {
+		return nil, err
+	}
+	partition := cached.(*sync.Map)
+
+	var vmssFlexIDs []string
+	partition.Range(func(key, _ interface{}) bool {
+		vmssFlexIDs = append(vmssFlexIDs, key.(string))
+		return true
+	})
+	sort.Strings(vmssFlexIDs)
+	for _, vmssFlexID := range vmssFlexIDs {
+		if _, err := fs.getVmssFlexVMCacheEntry(vmssFlexID, azcache.CacheReadTypeForceRefresh); err != nil {
+			klog.ErrorS(err, "Failed to refresh vmss flex VM cache", "vmssFlexID", vmssFlexID)
+		}
+		cachedVmssFlexID, isCached, err := fs.getCachedNodeVmssFlexID(nodeName)
+		if err != nil {
+			return nil, err
+		}
+		if isCached && strings.EqualFold(cachedVmssFlexID, vmssFlexID) {
+			vmssFlex, _ := partition.Load(vmssFlexID)
+			return vmssFlex.(*compute.VirtualMachineScaleSet), nil
+		}
+	}
+	return nil, cloudprovider.InstanceNotFound
+}
+
+func (fs *FlexScaleSet) getVmssFlexIDByName(vmssFlexName string) (string, error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return "", err
+	}
+	return *vmssFlex.ID, nil
+}
+
+// getVmssFlexByName returns the cached vmss flex of the name. Since the name is only unique within a resource group,
+// ErrorVmssFlexNameAmbiguous is returned if the vmss flex of the same name are cached in different resource groups
+// or subscriptions, rather than an arbitrary one of them.
+func (fs *FlexScaleSet) getVmssFlexByName(vmssFlexName string) (*compute.VirtualMachineScaleSet, error) {
+	vmssFlexes, err := fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetVmssFlexIDs []string
+	var targetVmssFlex *compute.VirtualMachineScaleSet
+	vmssFlexes.Range(func(key, value interface{}) bool {
+		vmssFlexID := key.(string)
+		name, err := getLastSegment(vmssFlexID, "/")
+		if err != nil {
+			return true
+		}
+		if strings.EqualFold(name, vmssFlexName) {
+			targetVmssFlexIDs = append(targetVmssFlexIDs, vmssFlexID)
+			targetVmssFlex = value.(*compute.VirtualMachineScaleSet)
+		}
+		return true
+	})
+	switch len(targetVmssFlexIDs) {
+	case 0:
+		return nil, cloudprovider.InstanceNotFound
+	case 1:
+		return targetVmssFlex, nil
+	}
+	sort.Strings(targetVmssFlexIDs)
+	return nil, fmt.Errorf("%w: %s is shared by %s", ErrorVmssFlexNameAmbiguous, vmssFlexName, strings.Join(targetVmssFlexIDs, ", "))
+}
+
+// GetVmssFlexZoneInfo returns the zones and the platform fault domain count of the cached vmss flex.
+// An empty zone list means the vmss flex is not zonal.
+func (fs *FlexScaleSet) GetVmssFlexZoneInfo(vmssFlexName string) (zones []string, faultDomains int32, err error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	zones = []string{}
+	if vmssFlex.Zones != nil {
+		zones = append(zones, *vmssFlex.Zones...)
+	}
+	if vmssFlex.VirtualMachineScaleSetProperties != nil && vmssFlex.PlatformFaultDomainCount != nil {
+		faultDomains = *vmssFlex.PlatformFaultDomainCount
+	}
+	return zones, faultDomains, nil
+}
+
+// ListScaleSetsByOrchestrationMode returns the cached scale sets of the orchestration mode in the resource groups
+// from resourceGroupsSource, sorted by the ID. The scale sets without the orchestration mode are of the Uniform
+// mode. The scale sets of the other modes than Flexible are only cached if SetCacheAllOrchestrationModes is enabled.
+func (fs *FlexScaleSet) ListScaleSetsByOrchestrationMode(mode compute.OrchestrationMode) ([]compute.VirtualMachineScaleSet, error) {
+	if mode != compute.Flexible && !fs.cacheAllOrchestrationModes {
+		return nil, fmt.Errorf("the scale sets of orchestration mode %q are not cached", mode)
+	}
+
+	vmssFlexes, err := fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	scaleSets := []compute.VirtualMachineScaleSet{}
+	collect := func(_, value interface{}) bool {
+		scaleSet := value.(*compute.VirtualMachineScaleSet)
+		scaleSetMode := scaleSet.OrchestrationMode
+		if scaleSetMode == "" {
+			scaleSetMode = compute.Uniform
+		}
+		if scaleSetMode == mode {
+			scaleSets = append(scaleSets, *scaleSet)
+		}
+		return true
+	}
+	if mode == compute.Flexible {
+		vmssFlexes.Range(collect)
+	} else {
+		allResourceGroups, err := fs.resourceGroupsSource()
+		if err != nil {
+			return nil, err
+		}
+		for _, resourceGroup := range sets.List(sets.New(lowerCaseResourceGroups(allResourceGroups)...)) {
+			if cached, ok := fs.vmssFlexOtherModeScaleSets.Load(resourceGroup); ok {
+				cached.(*sync.Map).Range(collect)
+			}
+		}
+	}
+
+	sort.Slice(scaleSets, func(i, j int) bool {
+		return pointer.StringDeref(scaleSets[i].ID, "") < pointer.StringDeref(scaleSets[j].ID, "")
+	})
+	return scaleSets, nil
+}
+
+// GetVmssFlexCapacity returns the configured capacity of the cached vmss flex. The capacity is 0 if the
+// vmss flex has no sku.
+func (fs *FlexScaleSet) GetVmssFlexCapacity(vmssFlexName string) (int64, error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return 0, err
+	}
+
+	if vmssFlex.Sku == nil || vmssFlex.Sku.Capacity == nil {
+		return 0, nil
+	}
+	return *vmssFlex.Sku.Capacity, nil
+}
+
+// GetVmssFlexUpgradePolicy returns the upgrade mode of the cached vmss flex. The mode is empty if the vmss flex
+// has no upgrade policy.
+func (fs *FlexScaleSet) GetVmssFlexUpgradePolicy(vmssFlexName string) (compute.UpgradeMode, error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return "", err
+	}
+
+	if vmssFlex.VirtualMachineScaleSetProperties == nil || vmssFlex.UpgradePolicy == nil {
+		return "", nil
+	}
+	return vmssFlex.UpgradePolicy.Mode, nil
+}
+
+// GetVmssFlexNetworkProfile returns the network profile of the VM profile of the cached vmss flex, e.g. the
+// templates of the NIC and IP configurations of its VMs. It is nil if the vmss flex has no network profile. The
+// returned profile is shared with the cache and must not be modified.
+func (fs *FlexScaleSet) GetVmssFlexNetworkProfile(vmssFlexName string) (*compute.VirtualMachineScaleSetNetworkProfile, error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return nil, err
+	}
+
+	if vmssFlex.VirtualMachineScaleSetProperties == nil || vmssFlex.VirtualMachineProfile == nil {
+		return nil, nil
+	}
+	return vmssFlex.VirtualMachineProfile.NetworkProfile, nil
+}
+
+// GetVmssFlexImageReference returns the image reference and the OS type of the OS disk of the VM profile of the
+// cached vmss flex, e.g. to detect the drift between the desired image and the deployed one. The image reference is
+// nil and the OS type is empty if the vmss flex has no storage profile, or its OS disk has no OS type. The returned
+// image reference is shared with the cache and must not be modified.
+func (fs *FlexScaleSet) GetVmssFlexImageReference(vmssFlexName string) (*compute.ImageReference, compute.OperatingSystemTypes, error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if vmssFlex.VirtualMachineScaleSetProperties == nil || vmssFlex.VirtualMachineProfile == nil || vmssFlex.VirtualMachineProfile.StorageProfile == nil {
+		return nil, "", nil
+	}
+	storageProfile := vmssFlex.VirtualMachineProfile.StorageProfile
+	var osType compute.OperatingSystemTypes
+	if storageProfile.OsDisk != nil {
+		osType = storageProfile.OsDisk.OsType
+	}
+	return storageProfile.ImageReference, osType, nil
+}
+
+// GetVmssFlexProvisioningState returns the provisioning state of the cached vmss flex. Since the state changes
+// faster than the cache expires, it is logged at V(4) if the cached state is not terminal, i.e. neither Succeeded,
+// Failed nor Canceled, in which case the callers needing the live state should refresh the cache first, e.g. by
+// RefreshVmssFlexCache.
+func (fs *FlexScaleSet) GetVmssFlexProvisioningState(vmssFlexName string) (string, error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return "", err
+	}
+
+	if vmssFlex.VirtualMachineScaleSetProperties == nil || vmssFlex.ProvisioningState == nil {
+		return "", nil
+	}
+	provisioningState := *vmssFlex.ProvisioningState
+	if !isTerminalProvisioningState(provisioningState) {
+		klog.V(4).InfoS("The cached provisioning state of VMSS Flex may be stale, refresh the cache for the live state", "vmssFlexName", vmssFlexName, "provisioningState", provisioningState)
+	}
+	return provisioningState, nil
+}
+
+func isTerminalProvisioningState(provisioningState string) bool {
+	for _, terminal := range []string{consts.ProvisioningStateSucceeded, consts.ProvisioningStateFailed, consts.ProvisioningStateCanceled} {
+		if strings.EqualFold(provisioningState, terminal) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetVmssFlexTags returns a copy of the tags of the cached vmss flex, e.g. for the cost allocation, so that the
+// callers could not modify the cache through it. The tags with nil values are returned as empty strings, and the
+// untagged vmss flex has an empty map.
+func (fs *FlexScaleSet) GetVmssFlexTags(vmssFlexName string) (map[string]string, error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(vmssFlex.Tags))
+	for key, value := range vmssFlex.Tags {
+		tags[key] = pointer.StringDeref(value, "")
+	}
+	return tags, nil
+}
+
+// GetVmssFlexIdentities returns whether the system-assigned identity is enabled on the cached vmss flex, and the
+// sorted resource IDs of its user-assigned identities. The vmss flex without identity has neither.
+func (fs *FlexScaleSet) GetVmssFlexIdentities(vmssFlexName string) (systemAssigned bool, userAssigned []string, err error) {
+	vmssFlex, err := fs.getVmssFlexByName(vmssFlexName)
+	if err != nil {
+		return false, nil, err
+	}
+
+	userAssigned = []string{}
+	if vmssFlex.Identity == nil {
+		return false, userAssigned, nil
+	}
+	switch vmssFlex.Identity.Type {
+	case compute.ResourceIdentityTypeSystemAssigned, compute.ResourceIdentityTypeSystemAssignedUserAssigned:
+		systemAssigned = true
+	}
+	for identityID := range vmssFlex.Identity.UserAssignedIdentities {
+		userAssigned = append(userAssigned, identityID)
+	}
+	sort.Strings(userAssigned)
+	return systemAssigned, userAssigned, nil
+}
+
+// GetVmssFlexMemberCount returns the number of the cached member VMs of the vmss flex. The VMs still
+// being provisioned without a computer name are not counted.
+func (fs *FlexScaleSet) GetVmssFlexMemberCount(vmssFlexName string) (int, error) {
+	vmssFlexID, err := fs.getVmssFlexIDByName(vmssFlexName)
+	if err != nil {
+		return 0, err
+	}
+
+	cached, err := fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeDefault)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get vmss flex VM cache", "vmssFlexID", vmssFlexID)
+		return 0, err
+	}
+	if cached == nil {
+		return 0, nil
+	}
+
+	count := 0
+	cached.(*sync.Map).Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count, nil
+}
 
-			for i := range allScaleSets {
-				scaleSet := allScaleSets[i]
-				if scaleSet.ID == nil || *scaleSet.ID == "" {
-					klog.Warning("failed to get the ID of VMSS Flex")
-					continue
-				}
+// GetNodeNamesByVmssFlexID returns the names of the nodes known for the vmss flex.
+func (fs *FlexScaleSet) GetNodeNamesByVmssFlexID(vmssFlexID string) ([]string, error) {
+	return fs.getNodeNamesByVmssFlexID(vmssFlexID, azcache.CacheReadTypeDefault)
+}
 
-				if scaleSet.OrchestrationMode == compute.Flexible {
-					localCache.Store(*scaleSet.ID, &scaleSet)
-				}
-			}
-		}
+// GetVmssFlexNodes returns the names of the cached nodes of the vmss flex. The VMs of the vmss flex
+// are listed again if none of its nodes are cached.
+func (fs *FlexScaleSet) GetVmssFlexNodes(vmssFlexID string) ([]string, error) {
+	return fs.getNodeNamesByVmssFlexID(vmssFlexID, azcache.CacheReadTypeForceRefresh)
+}
 
-		return localCache, nil
+// getNodeNamesByVmssFlexID returns the sorted names of the cached nodes of the vmss flex. If none of its nodes
+// are cached, or DisableAPICallCache is set, the VM cache of the vmss flex is read with crt first.
+func (fs *FlexScaleSet) getNodeNamesByVmssFlexID(vmssFlexID string, crt azcache.AzureCacheReadType) ([]string, error) {
+	if cached, isCached := fs.vmssFlexIDToNodeNames.Load(vmssFlexID); isCached && !fs.Config.DisableAPICallCache {
+		return getSortedNodeNames(cached.(*sync.Map)), nil
 	}
 
-	if fs.Config.VmssFlexCacheTTLInSeconds == 0 {
-		fs.Config.VmssFlexCacheTTLInSeconds = consts.VmssFlexCacheTTLDefaultInSeconds
+	if _, err := fs.getVmssFlexVMCacheEntry(vmssFlexID, crt); err != nil {
+		klog.ErrorS(err, "Failed to get vmss flex VM cache", "vmssFlexID", vmssFlexID)
+		return nil, err
 	}
-	return azcache.NewTimedCache(time.Duration(fs.Config.VmssFlexCacheTTLInSeconds)*time.Second, getter, fs.Cloud.Config.DisableAPICallCache)
+	cached, isCached := fs.vmssFlexIDToNodeNames.Load(vmssFlexID)
+	if !isCached {
+		return []string{}, nil
+	}
+	return getSortedNodeNames(cached.(*sync.Map)), nil
 }
 
-func (fs *FlexScaleSet) newVmssFlexVMCache(ctx context.Context) (azcache.Resource, error) {
-	getter := func(key string) (interface{}, error) {
-		localCache := &sync.Map{}
+// GetVmssFlexNodesByResourceGroup returns the sorted names of the cached nodes of the vmss flex, keyed by the
+// lower-case resource group of their vmss flex, e.g. for the per resource group views or the targeted drains. It
+// never lists the VMs, so it only reflects the nodes cached when it is called, which may be stale for up to
+// VmssFlexVMCacheTTLInSeconds, or the TTL overridden by VmssFlexVMCacheTTLOverrides, extended by the jitter. An
+// error is returned if DisableAPICallCache is set, since no node is cached then.
+func (fs *FlexScaleSet) GetVmssFlexNodesByResourceGroup() (map[string][]string, error) {
+	if fs.Config.DisableAPICallCache {
+		return nil, fmt.Errorf("the vmss flex nodes are not cached since DisableAPICallCache is set")
+	}
 
-		vms, rerr := fs.VirtualMachinesClient.ListVmssFlexVMsWithoutInstanceView(ctx, key)
-		if rerr != nil {
-			klog.Errorf("ListVmssFlexVMsWithoutInstanceView failed: %v", rerr)
-			return nil, rerr.Error()
+	nodeNamesByResourceGroup := make(map[string]sets.Set[string])
+	fs.vmssFlexIDToNodeNames.Range(func(key, value interface{}) bool {
+		vmssFlexID := key.(string)
+		matches := vmssFlexIDRE.FindStringSubmatch(vmssFlexID)
+		if len(matches) != 2 {
+			klog.V(4).InfoS("Skip the nodes of VMSS Flex due to malformed resource ID", "vmssFlexID", vmssFlexID)
+			return true
 		}
+		resourceGroup := strings.ToLower(matches[1])
+		if _, ok := nodeNamesByResourceGroup[resourceGroup]; !ok {
+			nodeNamesByResourceGroup[resourceGroup] = sets.New[string]()
+		}
+		nodeNamesByResourceGroup[resourceGroup].Insert(getSortedNodeNames(value.(*sync.Map))...)
+		return true
+	})
 
-		for i := range vms {
-			vm := vms[i]
-			if vm.OsProfile != nil && vm.OsProfile.ComputerName != nil {
-				localCache.Store(strings.ToLower(*vm.OsProfile.ComputerName), &vm)
-				fs.vmssFlexVMNameToVmssID.Store(strings.ToLower(*vm.OsProfile.ComputerName), key)
-				fs.vmssFlexVMNameToNodeName.Store(*vm.Name, strings.ToLower(*vm.OsProfile.ComputerName))
+	result := make(map[string][]string, len(nodeNamesByResourceGroup))
+	for resourceGroup, nodeNames := range nodeNamesByResourceGroup {
+		result[resourceGroup] = sets.List(nodeNames)
+	}
+	return result, nil
+}
+
+func getSortedNodeNames(nodeNames *sync.Map) []string {
+	sortedNodeNames := make([]string, 0)
+	nodeNames.Range(func(key, _ interface{}) bool {
+		sortedNodeNames = append(sortedNodeNames, key.(string))
+		return true
+	})
+	sort.Strings(sortedNodeNames)
+	return sortedNodeNames
+}
+
+// GetVmssFlexNodesInFailedState returns the names of the cached nodes whose instance view reports a failed
+// provisioning state or a power state other than running. Only the instance views already cached are read, even
+// if expired, so it never calls ARM, and the nodes without a cached instance view are treated as unknown rather
+// than failed.
+func (fs *FlexScaleSet) GetVmssFlexNodesInFailedState() ([]string, error) {
+	// the instance views are keyed by the vm names in the cases listed from ARM
+	instanceViews := make(map[string]*compute.VirtualMachineInstanceView)
+	if store := fs.vmssFlexVMInstanceViewCache.GetStore(); store != nil {
+		for _, item := range store.List() {
+			entry, ok := item.(*azcache.AzureCacheEntry)
+			if !ok {
+				continue
+			}
+			entry.Lock.Lock()
+			instanceView, _ := entry.Data.(*compute.VirtualMachineInstanceView)
+			entry.Lock.Unlock()
+			if instanceView != nil {
+				instanceViews[strings.ToLower(entry.Key)] = instanceView
 			}
 		}
+	}
 
-		vms, rerr = fs.VirtualMachinesClient.ListVmssFlexVMsWithOnlyInstanceView(ctx, key)
-		if rerr != nil {
-			klog.Errorf("ListVmssFlexVMsWithOnlyInstanceView failed: %v", rerr)
-			return nil, rerr.Error()
+	failedNodeNames := sets.New[string]()
+	fs.vmssFlexVMNameToNodeName.Range(func(vmName string, nodeName interface{}) bool {
+		if instanceView, ok := instanceViews[vmName]; ok && isVMInstanceViewInFailedState(instanceView) {
+			failedNodeNames.Insert(nodeName.(string))
 		}
+		return true
+	})
+	return sets.List(failedNodeNames), nil
+}
 
-		for i := range vms {
-			vm := vms[i]
-			if vm.Name != nil {
-				nodeName, ok := fs.vmssFlexVMNameToNodeName.Load(*vm.Name)
-				if !ok {
-					continue
-				}
+// isVMInstanceViewInFailedState returns true if the instance view reports a failed provisioning state or
+// a power state other than running. The missing states are not treated as failed.
+func isVMInstanceViewInFailedState(instanceView *compute.VirtualMachineInstanceView) bool {
+	if instanceView.Statuses == nil {
+		return false
+	}
+	for _, status := range *instanceView.Statuses {
+		code := strings.ToLower(pointer.StringDeref(status.Code, ""))
+		if strings.HasPrefix(code, strings.ToLower(vmProvisioningStatePrefix)+vmProvisioningStateFailed) {
+			return true
+		}
+		if strings.HasPrefix(code, strings.ToLower(vmPowerStatePrefix)) && strings.TrimPrefix(code, strings.ToLower(vmPowerStatePrefix)) != vmPowerStateRunning {
+			return true
+		}
+	}
+	return false
+}
 
-				cached, ok := localCache.Load(nodeName)
-				if ok {
-					cachedVM := cached.(*compute.VirtualMachine)
-					cachedVM.VirtualMachineProperties.InstanceView = vm.VirtualMachineProperties.InstanceView
-				}
-			}
+// GetVmssFlexNodeCacheAge returns how long ago the cached entries of the node were written.
+// It returns false if the node is not cached.
+func (fs *FlexScaleSet) GetVmssFlexNodeCacheAge(nodeName string) (time.Duration, bool) {
+	cachedOn, isCached := fs.vmssFlexNodeNameToCachedOn.Get(strings.ToLower(nodeName))
+	if !isCached {
+		return 0, false
+	}
+	return fs.clock.Since(cachedOn.(time.Time)), true
+}
+
+// sweepExpiredNodeCache removes the nodes cached longer than the TTL of the vm cache of their vmss flex from
+// the name maps. The TTL is extended by the jitter, so that the nodes are only swept once their vm cache entries
+// have expired, and the next lookups refresh the vm cache rather than missing the nodes it still holds.
+// It returns the number of the swept nodes.
+func (fs *FlexScaleSet) sweepExpiredNodeCache() int {
+	jitter := 1 + getVmssFlexCacheTTLJitter(fs.Config.VmssFlexCacheTTLJitter)
+	maxAgeOf := func(nodeName string) time.Duration {
+		ttl := time.Duration(fs.Config.VmssFlexVMCacheTTLInSeconds) * time.Second
+		if vmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName); isCached {
+			ttl = fs.getVmssFlexVMCacheTTL(vmssFlexID.(string))
+		}
+		return time.Duration(float64(ttl) * jitter)
+	}
+	expiredNodeNames := sets.New[string]()
+	evictedVmssFlexIDs := make(map[string]string)
+	fs.vmssFlexNodeNameToCachedOn.Range(func(nodeName string, cachedOn interface{}) bool {
+		if fs.clock.Since(cachedOn.(time.Time)) > maxAgeOf(nodeName) {
+			expiredNodeNames.Insert(nodeName)
+		}
+		return true
+	})
+	for nodeName := range expiredNodeNames {
+		// the node may have been cached again by a refresh in the meantime
+		if cachedOn, isCached := fs.vmssFlexNodeNameToCachedOn.Get(nodeName); !isCached || fs.clock.Since(cachedOn.(time.Time)) <= maxAgeOf(nodeName) {
+			expiredNodeNames.Delete(nodeName)
+			continue
 		}
+		if vmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName); isCached {
+			fs.deleteNodeNameFromVmssFlexIndex(vmssFlexID.(string), nodeName)
+			evictedVmssFlexIDs[nodeName] = vmssFlexID.(string)
+		}
+		fs.vmssFlexVMNameToVmssID.Delete(nodeName)
+		fs.vmssFlexNodeNameToCachedOn.Delete(nodeName)
+	}
+	fs.deleteNodePrivateIPs(expiredNodeNames)
+	evictedVMNames := make(map[string]string)
+	fs.vmssFlexVMNameToNodeName.Range(func(vmName string, nodeName interface{}) bool {
+		if expiredNodeNames.Has(nodeName.(string)) {
+			fs.vmssFlexVMNameToNodeName.Delete(vmName)
+			evictedVMNames[nodeName.(string)] = vmName
+		}
+		return true
+	})
 
-		return localCache, nil
+	klog.V(4).InfoS("Swept the expired VMSS Flex nodes from the name maps", "count", expiredNodeNames.Len())
+	for nodeName := range expiredNodeNames {
+		fs.notifyNodeCacheEvicted(nodeName, evictedVMNames[nodeName], evictedVmssFlexIDs[nodeName])
 	}
+	return expiredNodeNames.Len()
+}
 
-	if fs.Config.VmssFlexVMCacheTTLInSeconds == 0 {
-		fs.Config.VmssFlexVMCacheTTLInSeconds = consts.VmssFlexVMCacheTTLDefaultInSeconds
+// notifyNodeCacheEvicted calls the onNodeCacheEvicted hook if set. It must be called outside of the locks.
+func (fs *FlexScaleSet) notifyNodeCacheEvicted(nodeName, vmName, vmssFlexID string) {
+	if fs.onNodeCacheEvicted == nil || fs.Config.DisableAPICallCache {
+		return
 	}
-	return azcache.NewTimedCache(time.Duration(fs.Config.VmssFlexVMCacheTTLInSeconds)*time.Second, getter, fs.Cloud.Config.DisableAPICallCache)
+	fs.onNodeCacheEvicted(nodeName, vmName, vmssFlexID)
 }
 
-func (fs *FlexScaleSet) getNodeNameByVMName(vmName string) (string, error) {
+// validateNameMaps detects the inconsistencies between the name maps of the cached nodes and repairs
+// the ones which could be derived from the other maps. It returns the detected inconsistencies.
+func (fs *FlexScaleSet) validateNameMaps() []string {
+	var inconsistencies []string
+	report := func(repaired bool, msg string, keysAndValues ...interface{}) {
+		klog.InfoS("Inconsistent VMSS Flex name maps: "+msg, append(keysAndValues, "repaired", repaired)...)
+		inconsistencies = append(inconsistencies, fmt.Sprintf("%s %v", msg, keysAndValues))
+	}
+
 	fs.lockMap.LockEntry(consts.GetNodeVmssFlexIDLockKey)
 	defer fs.lockMap.UnlockEntry(consts.GetNodeVmssFlexIDLockKey)
-	cachedNodeName, isCached := fs.vmssFlexVMNameToNodeName.Load(vmName)
-	if isCached {
-		return fmt.Sprintf("%v", cachedNodeName), nil
-	}
 
-	getter := func(vmName string, crt azcache.AzureCacheReadType) (string, error) {
-		cached, err := fs.vmssFlexCache.Get(consts.VmssFlexKey, crt)
-		if err != nil {
-			return "", err
+	vmNamesByNodeName := make(map[string]string)
+	fs.vmssFlexVMNameToNodeName.Range(func(vmName string, value interface{}) bool {
+		nodeName := value.(string)
+		if _, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName); !isCached {
+			report(true, "vm name is mapped to a node which is not cached", "vmName", vmName, "node", nodeName)
+			fs.vmssFlexVMNameToNodeName.Delete(vmName)
+			return true
 		}
-		vmssFlexes := cached.(*sync.Map)
+		vmNamesByNodeName[nodeName] = vmName
+		return true
+	})
 
-		vmssFlexes.Range(func(key, value interface{}) bool {
-			vmssFlexID := key.(string)
-			_, err := fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeForceRefresh)
-			if err != nil {
-				klog.Errorf("failed to refresh vmss flex VM cache for vmssFlexID %s", vmssFlexID)
+	fs.vmssFlexVMNameToVmssID.Range(func(nodeName string, value interface{}) bool {
+		vmssFlexID := value.(string)
+		if _, ok := vmNamesByNodeName[nodeName]; !ok {
+			report(false, "node is not mapped from any vm name", "node", nodeName, "vmssFlexID", vmssFlexID)
+		}
+		cached, _ := fs.vmssFlexIDToNodeNames.LoadOrStore(vmssFlexID, &sync.Map{})
+		if _, ok := cached.(*sync.Map).LoadOrStore(nodeName, struct{}{}); !ok {
+			report(true, "node is missing from the node names of the vmss flex", "node", nodeName, "vmssFlexID", vmssFlexID)
+		}
+		return true
+	})
+
+	fs.vmssFlexIDToNodeNames.Range(func(key, value interface{}) bool {
+		vmssFlexID := key.(string)
+		value.(*sync.Map).Range(func(key, _ interface{}) bool {
+			nodeName := key.(string)
+			cachedVmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName)
+			if isCached && strings.EqualFold(cachedVmssFlexID.(string), vmssFlexID) {
+				return true
 			}
+			if fs.isAmbiguousNodeNameOf(nodeName, vmssFlexID) {
+				return true
+			}
+			report(true, "node is indexed by a vmss flex it does not belong to", "node", nodeName, "vmssFlexID", vmssFlexID)
+			fs.deleteNodeNameFromVmssFlexIndex(vmssFlexID, nodeName)
 			return true
 		})
+		return true
+	})
 
-		cachedNodeName, isCached = fs.vmssFlexVMNameToNodeName.Load(vmName)
-		if isCached {
-			return fmt.Sprintf("%v", cachedNodeName), nil
+	fs.vmssFlexNodeNameToCachedOn.Range(func(nodeName string, _ interface{}) bool {
+		if _, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName); !isCached {
+			report(true, "cached time is recorded for a node which is not cached", "node", nodeName)
+			fs.vmssFlexNodeNameToCachedOn.Delete(nodeName)
 		}
-		return "", cloudprovider.InstanceNotFound
-	}
+		return true
+	})
 
-	nodeName, err := getter(vmName, azcache.CacheReadTypeDefault)
-	if errors.Is(err, cloudprovider.InstanceNotFound) {
-		klog.V(2).Infof("Could not find node (%s) in the existing cache. Forcely freshing the cache to check again...", nodeName)
-		return getter(vmName, azcache.CacheReadTypeForceRefresh)
-	}
-	return nodeName, err
+	return inconsistencies
+}
 
+// isAmbiguousNodeNameOf returns true if the node name is claimed by the vmss flex and others.
+func (fs *FlexScaleSet) isAmbiguousNodeNameOf(nodeName, vmssFlexID string) bool {
+	fs.vmssFlexAmbiguousNodeNamesLock.Lock()
+	defer fs.vmssFlexAmbiguousNodeNamesLock.Unlock()
+	vmssFlexIDs, isAmbiguous := fs.vmssFlexAmbiguousNodeNames[nodeName]
+	return isAmbiguous && vmssFlexIDs.Has(vmssFlexID)
 }
 
-func (fs *FlexScaleSet) getNodeVmssFlexID(nodeName string) (string, error) {
-	fs.lockMap.LockEntry(consts.GetNodeVmssFlexIDLockKey)
-	defer fs.lockMap.UnlockEntry(consts.GetNodeVmssFlexIDLockKey)
-	cachedVmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Load(nodeName)
+// vmssFlexPrivateIPEntry is the value of vmssFlexPrivateIPToNodeName. The NIC is recorded so that the
+// IPs of the different NICs of the same node would be replaced separately.
+type vmssFlexPrivateIPEntry struct {
+	nodeName string
+	nicID    string
+	// hasPublicIP is true if the IP configuration of the IP references a public IP, whose address is not cached.
+	hasPublicIP bool
+}
 
-	if isCached {
-		return fmt.Sprintf("%v", cachedVmssFlexID), nil
+// GetNodeNameByPrivateIP returns the name of the node owning the private IP without calling ARM. The IPs of
+// all the IP configurations of the NICs fetched for the cached VMs, e.g. by GetPrivateIPsByNodeName or
+// GetIPByNodeName, are looked up first, and then the private IPs reported by the nodes, which also cover
+// the secondary NICs. It returns cloudprovider.InstanceNotFound if the IP is found in neither, e.g. if the
+// NICs of the node have not been fetched yet and the node has not reported it.
+func (fs *FlexScaleSet) GetNodeNameByPrivateIP(ip string) (string, error) {
+	normalizedIP := normalizePrivateIP(ip)
+	if entry, isCached := fs.vmssFlexPrivateIPToNodeName.Get(normalizedIP); isCached {
+		return entry.(vmssFlexPrivateIPEntry).nodeName, nil
 	}
 
-	getter := func(nodeName string, crt azcache.AzureCacheReadType) (string, error) {
-		cached, err := fs.vmssFlexCache.Get(consts.VmssFlexKey, crt)
-		if err != nil {
-			return "", err
+	fs.nodeCachesLock.RLock()
+	defer fs.nodeCachesLock.RUnlock()
+	for _, address := range []string{ip, normalizedIP} {
+		if nodeName, ok := fs.nodePrivateIPToNodeNameMap[address]; ok {
+			return nodeName, nil
 		}
-		vmssFlexes := cached.(*sync.Map)
+	}
+	klog.V(4).InfoS("Private IP is not found in the VMSS Flex cache", "ip", ip)
+	return "", cloudprovider.InstanceNotFound
+}
 
-		var vmssFlexIDs []string
-		vmssFlexes.Range(func(key, value interface{}) bool {
-			vmssFlexID := key.(string)
-			vmssFlex := value.(*compute.VirtualMachineScaleSet)
-			vmssPrefix := pointer.StringDeref(vmssFlex.Name, "")
-			if vmssFlex.VirtualMachineProfile != nil &&
-				vmssFlex.VirtualMachineProfile.OsProfile != nil &&
-				vmssFlex.VirtualMachineProfile.OsProfile.ComputerNamePrefix != nil {
-				vmssPrefix = pointer.StringDeref(vmssFlex.VirtualMachineProfile.OsProfile.ComputerNamePrefix, "")
-			}
-			if strings.EqualFold(vmssPrefix, nodeName[:len(nodeName)-6]) {
-				// we should check this vmss first since nodeName and vmssFlex.Name or
-				// ComputerNamePrefix belongs to same vmss, so prepend here
-				vmssFlexIDs = append([]string{vmssFlexID}, vmssFlexIDs...)
-			} else {
-				vmssFlexIDs = append(vmssFlexIDs, vmssFlexID)
-			}
-			return true
-		})
+// cacheNodePrivateIPs records the private IPs of all the IP configurations of the NIC of the node. The
+// previous IPs of the node are replaced, so that the IPs released by the node would not be resolved to it.
+func (fs *FlexScaleSet) cacheNodePrivateIPs(nodeName string, nic network.Interface) {
+	if nic.InterfacePropertiesFormat == nil || nic.IPConfigurations == nil {
+		return
+	}
+	nodeName = strings.ToLower(nodeName)
 
-		for _, vmssID := range vmssFlexIDs {
-			if _, err := fs.vmssFlexVMCache.Get(vmssID, azcache.CacheReadTypeForceRefresh); err != nil {
-				klog.Errorf("failed to refresh vmss flex VM cache for vmssFlexID %s", vmssID)
-			}
-			// if the vm is cached stop refreshing
-			cachedVmssFlexID, isCached = fs.vmssFlexVMNameToVmssID.Load(nodeName)
-			if isCached {
-				return fmt.Sprintf("%v", cachedVmssFlexID), nil
-			}
+	// ips maps the private IPs to whether their IP configurations reference a public IP
+	ips := make(map[string]bool)
+	for _, ipConfig := range *nic.IPConfigurations {
+		if ipConfig.InterfaceIPConfigurationPropertiesFormat != nil && ipConfig.PrivateIPAddress != nil {
+			ips[normalizePrivateIP(*ipConfig.PrivateIPAddress)] = ipConfig.PublicIPAddress != nil
 		}
-		return "", cloudprovider.InstanceNotFound
 	}
 
-	vmssFlexID, err := getter(nodeName, azcache.CacheReadTypeDefault)
-	if errors.Is(err, cloudprovider.InstanceNotFound) {
-		klog.V(2).Infof("Could not find node (%s) in the existing cache. Forcely freshing the cache to check again...", nodeName)
-		return getter(nodeName, azcache.CacheReadTypeForceRefresh)
+	nicID := pointer.StringDeref(nic.ID, "")
+	fs.vmssFlexPrivateIPToNodeName.Range(func(ip string, value interface{}) bool {
+		entry := value.(vmssFlexPrivateIPEntry)
+		if _, isCurrent := ips[ip]; entry.nodeName == nodeName && strings.EqualFold(entry.nicID, nicID) && !isCurrent {
+			fs.vmssFlexPrivateIPToNodeName.Delete(ip)
+		}
+		return true
+	})
+	for ip, hasPublicIP := range ips {
+		fs.vmssFlexPrivateIPToNodeName.Set(ip, vmssFlexPrivateIPEntry{nodeName: nodeName, nicID: nicID, hasPublicIP: hasPublicIP})
 	}
-	return vmssFlexID, err
-
 }
 
-func (fs *FlexScaleSet) getVmssFlexVM(nodeName string, crt azcache.AzureCacheReadType) (vm compute.VirtualMachine, err error) {
-	vmssFlexID, err := fs.getNodeVmssFlexID(nodeName)
+// GetNodeAddressesFromCache returns the addresses of the node derived from the cached VM and the private
+// IPs cached for its primary NIC, e.g. by GetPrivateIPsByNodeName or GetIPByNodeName, without calling the
+// NIC or public IP APIs. The internal IPs are followed by the hostname, IPv4 before IPv6. Rather than a
+// partial result, it returns ErrorVmssFlexNodeAddressesNotCached if the cached VM has no primary NIC, the
+// IPs of the primary NIC have not been cached, or any of them has a public IP, so that the caller would fall
+// back to the authoritative NodeAddresses.
+func (fs *FlexScaleSet) GetNodeAddressesFromCache(nodeName string) ([]v1.NodeAddress, error) {
+	vm, err := fs.getVmssFlexVM(nodeName, azcache.CacheReadTypeDefault)
 	if err != nil {
-		return vm, err
+		return nil, err
 	}
-
-	cached, err := fs.vmssFlexVMCache.Get(vmssFlexID, crt)
+	primaryNicID, err := getPrimaryInterfaceID(vm)
 	if err != nil {
-		return vm, err
+		return nil, fmt.Errorf("%w: %v", ErrorVmssFlexNodeAddressesNotCached, err)
 	}
-	vmMap := cached.(*sync.Map)
-	cachedVM, ok := vmMap.Load(nodeName)
-	if !ok {
-		klog.V(2).Infof("did not find node (%s) in the existing cache, which means it is deleted...", nodeName)
-		return vm, cloudprovider.InstanceNotFound
+
+	lowerNodeName := strings.ToLower(nodeName)
+	var ips []string
+	var hasPublicIP bool
+	fs.vmssFlexPrivateIPToNodeName.Range(func(ip string, value interface{}) bool {
+		entry := value.(vmssFlexPrivateIPEntry)
+		if entry.nodeName == lowerNodeName && strings.EqualFold(entry.nicID, primaryNicID) {
+			ips = append(ips, ip)
+			hasPublicIP = hasPublicIP || entry.hasPublicIP
+		}
+		return true
+	})
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%w: the private IPs of nic %s of node %s are not cached", ErrorVmssFlexNodeAddressesNotCached, primaryNicID, nodeName)
+	}
+	if hasPublicIP {
+		return nil, fmt.Errorf("%w: the public IP of nic %s of node %s is not cached", ErrorVmssFlexNodeAddressesNotCached, primaryNicID, nodeName)
 	}
 
-	return *(cachedVM.(*compute.VirtualMachine)), nil
+	sort.Slice(ips, func(i, j int) bool {
+		isIPv4I, isIPv4J := net.ParseIP(ips[i]).To4() != nil, net.ParseIP(ips[j]).To4() != nil
+		if isIPv4I != isIPv4J {
+			return isIPv4I
+		}
+		return ips[i] < ips[j]
+	})
+	addresses := make([]v1.NodeAddress, 0, len(ips)+1)
+	for _, ip := range ips {
+		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip})
+	}
+	addresses = append(addresses, v1.NodeAddress{Type: v1.NodeHostName, Address: nodeName})
+	return addresses, nil
 }
 
-func (fs *FlexScaleSet) getVmssFlexByVmssFlexID(vmssFlexID string, crt azcache.AzureCacheReadType) (*compute.VirtualMachineScaleSet, error) {
-	cached, err := fs.vmssFlexCache.Get(consts.VmssFlexKey, crt)
-	if err != nil {
-		return nil, err
-	}
-	vmssFlexes := cached.(*sync.Map)
-	if vmssFlex, ok := vmssFlexes.Load(vmssFlexID); ok {
-		result := vmssFlex.(*compute.VirtualMachineScaleSet)
-		return result, nil
+// deleteNodePrivateIPs removes the private IPs of the nodes.
+func (fs *FlexScaleSet) deleteNodePrivateIPs(nodeNames sets.Set[string]) {
+	fs.vmssFlexPrivateIPToNodeName.Range(func(ip string, value interface{}) bool {
+		if nodeNames.Has(value.(vmssFlexPrivateIPEntry).nodeName) {
+			fs.vmssFlexPrivateIPToNodeName.Delete(ip)
+		}
+		return true
+	})
+}
+
+// normalizePrivateIP returns the canonical form of the IP, so that the differently formatted IPv6 addresses
+// would match. The IP is returned as is if it could not be parsed.
+func normalizePrivateIP(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		return parsed.String()
 	}
+	return ip
+}
 
-	klog.V(2).Infof("Couldn't find VMSS Flex with ID %s, refreshing the cache", vmssFlexID)
-	cached, err = fs.vmssFlexCache.Get(consts.VmssFlexKey, azcache.CacheReadTypeForceRefresh)
-	if err != nil {
-		return nil, err
+// deleteNodeNameFromVmssFlexIndex removes the node from the node names of the vmss flex,
+// and removes the set once it is empty.
+func (fs *FlexScaleSet) deleteNodeNameFromVmssFlexIndex(vmssFlexID, nodeName string) {
+	cached, isCached := fs.vmssFlexIDToNodeNames.Load(vmssFlexID)
+	if !isCached {
+		return
 	}
-	vmssFlexes = cached.(*sync.Map)
-	if vmssFlex, ok := vmssFlexes.Load(vmssFlexID); ok {
-		result := vmssFlex.(*compute.VirtualMachineScaleSet)
-		return result, nil
+	nodeNames := cached.(*sync.Map)
+	nodeNames.Delete(nodeName)
+
+	isEmpty := true
+	nodeNames.Range(func(_, _ interface{}) bool {
+		isEmpty = false
+		return false
+	})
+	if isEmpty {
+		// only delete the set if it has not been replaced by a cache refresh in the meantime
+		fs.vmssFlexIDToNodeNames.CompareAndDelete(vmssFlexID, nodeNames)
 	}
-	return nil, cloudprovider.InstanceNotFound
 }
 
-func (fs *FlexScaleSet) getVmssFlexByNodeName(nodeName string, crt azcache.AzureCacheReadType) (*compute.VirtualMachineScaleSet, error) {
+func (fs *FlexScaleSet) DeleteCacheForNode(nodeName string) error {
+	if fs.Config.DisableAPICallCache {
+		return nil
+	}
 	vmssFlexID, err := fs.getNodeVmssFlexID(nodeName)
 	if err != nil {
-		return nil, err
+		klog.ErrorS(err, "getNodeVmssFlexID failed", "node", nodeName)
+		return err
 	}
-	vmssFlex, err := fs.getVmssFlexByVmssFlexID(vmssFlexID, crt)
-	if err != nil {
-		return nil, err
+
+	if err := fs.deleteCacheForVmssFlexNodes(vmssFlexID, []string{nodeName}); err != nil {
+		return err
 	}
-	return vmssFlex, nil
+
+	klog.V(2).InfoS("DeleteCacheForNode successfully", "vmssFlexID", vmssFlexID, "node", nodeName)
+	return nil
 }
 
-func (fs *FlexScaleSet) getVmssFlexIDByName(vmssFlexName string) (string, error) {
-	cached, err := fs.vmssFlexCache.Get(consts.VmssFlexKey, azcache.CacheReadTypeDefault)
-	if err != nil {
-		return "", err
+// PreviewDeleteCacheForNode reports the mappings of the node which DeleteCacheForNode would remove, i.e. the
+// lower-case name of its vm and its vmssFlexID, without modifying the cache or refreshing it. It only reads
+// the name maps without taking any lock, so found is false if the node is not cached, or if DisableAPICallCache
+// is set since DeleteCacheForNode is a no-op then. The nodes claimed by multiple vmss flex are reported with
+// the last cached vmssFlexID, which may differ from the one picked by DeleteCacheForNode.
+func (fs *FlexScaleSet) PreviewDeleteCacheForNode(nodeName string) (vmName, vmssFlexID string, found bool) {
+	if fs.Config.DisableAPICallCache {
+		return "", "", false
 	}
-	var targetVmssFlexID string
-	vmssFlexes := cached.(*sync.Map)
-	vmssFlexes.Range(func(key, value interface{}) bool {
-		vmssFlexID := key.(string)
-		name, err := getLastSegment(vmssFlexID, "/")
-		if err != nil {
-			return true
-		}
-		if strings.EqualFold(name, vmssFlexName) {
-			targetVmssFlexID = vmssFlexID
+	cachedVmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName)
+	if !isCached {
+		return "", "", false
+	}
+
+	fs.vmssFlexVMNameToNodeName.Range(func(cachedVMName string, cachedNodeName interface{}) bool {
+		if cachedNodeName.(string) == nodeName {
+			vmName = cachedVMName
 			return false
 		}
 		return true
 	})
-	if targetVmssFlexID != "" {
-		return targetVmssFlexID, nil
-	}
-	return "", cloudprovider.InstanceNotFound
+	return vmName, cachedVmssFlexID.(string), true
 }
 
-func (fs *FlexScaleSet) getVmssFlexByName(vmssFlexName string) (*compute.VirtualMachineScaleSet, error) {
-	cached, err := fs.vmssFlexCache.Get(consts.VmssFlexKey, azcache.CacheReadTypeDefault)
-	if err != nil {
-		return nil, err
+// DeleteCacheForNodes deletes the cache of the nodes in one pass, e.g. when a node pool is scaled in.
+// The nodes are grouped by their cached vmssFlexID so that the vm cache of each vmss flex is only
+// updated once. The nodes which are not cached are skipped without refreshing the cache.
+func (fs *FlexScaleSet) DeleteCacheForNodes(nodeNames []string) error {
+	if fs.Config.DisableAPICallCache {
+		return nil
 	}
 
-	var targetVmssFlex *compute.VirtualMachineScaleSet
-	vmssFlexes := cached.(*sync.Map)
-	vmssFlexes.Range(func(key, value interface{}) bool {
-		vmssFlexID := key.(string)
-		vmssFlex := value.(*compute.VirtualMachineScaleSet)
-		name, err := getLastSegment(vmssFlexID, "/")
+	var errs []error
+	vmssFlexIDToNodeNames := make(map[string][]string)
+	for _, nodeName := range nodeNames {
+		vmssFlexID, isCached, err := fs.getCachedNodeVmssFlexID(nodeName)
 		if err != nil {
-			return true
+			klog.ErrorS(err, "getCachedNodeVmssFlexID failed", "node", nodeName)
+			errs = append(errs, err)
+			continue
 		}
-		if strings.EqualFold(name, vmssFlexName) {
-			targetVmssFlex = vmssFlex
-			return false
+		if !isCached {
+			klog.V(4).InfoS("Skip deleting the cache of node which is not cached", "node", nodeName)
+			continue
 		}
-		return true
-	})
-	if targetVmssFlex != nil {
-		return targetVmssFlex, nil
+		vmssFlexIDToNodeNames[vmssFlexID] = append(vmssFlexIDToNodeNames[vmssFlexID], nodeName)
 	}
-	return nil, cloudprovider.InstanceNotFound
-}
 
-func (fs *FlexScaleSet) DeleteCacheForNode(nodeName string) error {
-	if fs.Config.DisableAPICallCache {
-		return nil
+	for vmssFlexID, names := range vmssFlexIDToNodeNames {
+		if err := fs.deleteCacheForVmssFlexNodes(vmssFlexID, names); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		klog.V(2).InfoS("DeleteCacheForNodes successfully", "vmssFlexID", vmssFlexID, "nodes", names)
 	}
-	vmssFlexID, err := fs.getNodeVmssFlexID(nodeName)
+	return utilerrors.NewAggregate(errs)
+}
+
+// deleteCacheForVmssFlexNodes deletes the nodes from the vm cache of the vmss flex and the name maps,
+// and then notifies the eviction of each node.
+func (fs *FlexScaleSet) deleteCacheForVmssFlexNodes(vmssFlexID string, nodeNames []string) error {
+	evictedVMNames, err := fs.evictVmssFlexNodes(vmssFlexID, nodeNames)
 	if err != nil {
-		klog.Errorf("getNodeVmssFlexID(%s) failed with %v", nodeName, err)
 		return err
 	}
+	for _, nodeName := range nodeNames {
+		fs.notifyNodeCacheEvicted(nodeName, evictedVMNames[nodeName], vmssFlexID)
+	}
+	return nil
+}
 
+// evictVmssFlexNodes deletes the nodes from the vm cache of the vmss flex and the name maps under the
+// lock of the vmss flex. It returns the names of the evicted vms keyed by the node name.
+func (fs *FlexScaleSet) evictVmssFlexNodes(vmssFlexID string, nodeNames []string) (map[string]string, error) {
 	fs.lockMap.LockEntry(vmssFlexID)
 	defer fs.lockMap.UnlockEntry(vmssFlexID)
 	cached, err := fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeDefault)
 	if err != nil {
-		klog.Errorf("vmssFlexVMCache.Get(%s, %s) failed with %v", vmssFlexID, nodeName, err)
-		return err
+		klog.ErrorS(err, "vmssFlexVMCache.Get failed", "vmssFlexID", vmssFlexID, "nodes", nodeNames)
+		return nil, err
 	}
 	if cached == nil {
 		err := fmt.Errorf("nil cache returned from %s", vmssFlexID)
-		klog.Errorf("DeleteCacheForNode(%s, %s) failed with %v", vmssFlexID, nodeName, err)
-		return err
+		klog.ErrorS(err, "DeleteCacheForNode failed", "vmssFlexID", vmssFlexID, "nodes", nodeNames)
+		return nil, err
 	}
 	vmMap := cached.(*sync.Map)
-	vmMap.Delete(nodeName)
+	evictedVMNames := make(map[string]string)
+	for _, nodeName := range nodeNames {
+		if cachedVM, ok := vmMap.Load(nodeName); ok {
+			if vmName := cachedVM.(*compute.VirtualMachine).Name; vmName != nil {
+				_ = fs.vmssFlexVMInstanceViewCache.Delete(*vmName)
+				evictedVMNames[nodeName] = *vmName
+			}
+		}
+		vmMap.Delete(nodeName)
+		fs.vmssFlexVMNameToVmssID.Delete(nodeName)
+		fs.vmssFlexNodeNameToCachedOn.Delete(nodeName)
+		fs.deleteNodeNameFromVmssFlexIndex(vmssFlexID, nodeName)
+	}
 
-	fs.vmssFlexVMCache.Update(vmssFlexID, vmMap)
-	fs.vmssFlexVMNameToVmssID.Delete(nodeName)
+	evictedNodeNames := sets.New(nodeNames...)
+	fs.vmssFlexVMNameToNodeName.Range(func(vmName string, nodeName interface{}) bool {
+		if evictedNodeNames.Has(nodeName.(string)) {
+			fs.vmssFlexVMNameToNodeName.Delete(vmName)
+		}
+		return true
+	})
 
-	klog.V(2).Infof("DeleteCacheForNode(%s, %s) successfully", vmssFlexID, nodeName)
-	return nil
+	fs.vmssFlexVMCache.Update(vmssFlexID, vmMap)
+	fs.pruneAmbiguousNodeNames(vmssFlexID, vmMap)
+	fs.deleteNodePrivateIPs(evictedNodeNames)
+	return evictedVMNames, nil
 }
@@ -17,17 +17,31 @@ limitations under the License.
 package provider
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2022-07-01/network"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/component-base/metrics/legacyregistry"
+	testingclock "k8s.io/utils/clock/testing"
 	"k8s.io/utils/pointer"
 
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/interfaceclient/mockinterfaceclient"
 	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmclient/mockvmclient"
 	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmssclient/mockvmssclient"
 	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
@@ -274,6 +288,92 @@ func TestGetNodeNameByVMName(t *testing.T) {
 	}
 }
 
+func TestVmssFlexInstanceNotReady(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vmList := generateTestVMListWithoutInstanceView()
+	// the vm being provisioned has no computer name yet
+	vmWithoutComputerName := generateVmssFlexTestVMWithoutInstanceView(VmssFlexTestVMSpec{
+		VMName:     "testvm4",
+		VMID:       "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm4",
+		VmssFlexID: testVmssFlex1ID,
+	})
+	vmWithoutComputerName.OsProfile.ComputerName = nil
+	vmList = append(vmList, vmWithoutComputerName)
+	vmList[1].ProvisioningState = pointer.String(consts.ProvisioningStateCreating)
+
+	testCases := []struct {
+		description      string
+		vmName           string
+		nodeName         string
+		expectedNodeName string
+		expectedErr      error
+	}{
+		{
+			description: "should return ErrInstanceNotReady if the vm has no computer name",
+			vmName:      "testvm4",
+			nodeName:    "testvm4",
+			expectedErr: ErrInstanceNotReady,
+		},
+		{
+			description:      "should return ErrInstanceNotReady if the vm is being created",
+			vmName:           "testvm2",
+			nodeName:         "vmssflex1000002",
+			expectedNodeName: "vmssflex1000002",
+			expectedErr:      ErrInstanceNotReady,
+		},
+		{
+			description: "should return InstanceNotFound if the vm does not exist",
+			vmName:      nonExistingNodeName,
+			nodeName:    nonExistingNodeName,
+			expectedErr: cloudprovider.InstanceNotFound,
+		},
+		{
+			description:      "should return the vm if it is ready",
+			vmName:           "testvm1",
+			nodeName:         "vmssflex1000001",
+			expectedNodeName: "vmssflex1000001",
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(vmList, nil).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+		nodeName, err := fs.getNodeNameByVMName(tc.vmName)
+		if tc.expectedNodeName != "" {
+			assert.NoError(t, err, tc.description)
+		} else {
+			assert.ErrorIs(t, err, tc.expectedErr, tc.description)
+		}
+		assert.Equal(t, tc.expectedNodeName, nodeName, tc.description)
+
+		vm, err := fs.getVmssFlexVMByVMName(tc.vmName, azcache.CacheReadTypeDefault)
+		if tc.expectedErr != nil {
+			assert.ErrorIs(t, err, tc.expectedErr, tc.description)
+		} else {
+			assert.NoError(t, err, tc.description)
+			assert.Equal(t, tc.vmName, pointer.StringDeref(vm.Name, ""), tc.description)
+		}
+		assert.False(t, errors.Is(err, ErrInstanceNotReady) && errors.Is(err, cloudprovider.InstanceNotFound), tc.description)
+
+		_, err = fs.getVmssFlexVM(tc.nodeName, azcache.CacheReadTypeDefault)
+		if tc.expectedNodeName != "" {
+			assert.NoError(t, err, "getVmssFlexVM should return the vm being created")
+		} else {
+			assert.ErrorIs(t, err, tc.expectedErr, tc.description)
+		}
+	}
+}
+
 func TestGetNodeVmssFlexID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -324,6 +424,226 @@ func TestGetNodeVmssFlexID(t *testing.T) {
 	}
 }
 
+func TestIsNodeVmssFlex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uniformVmss := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	uniformVmss.OrchestrationMode = compute.Uniform
+
+	testCases := []struct {
+		description      string
+		nodeName         string
+		cachedVmss       *compute.VirtualMachineScaleSet
+		vmssListErr      *retry.Error
+		expectedIsFlex   bool
+		expectedErrorMsg string
+	}{
+		{
+			description:    "IsNodeVmssFlex should return true if the node belongs to a VMSS Flex",
+			nodeName:       "vmssflex1000001",
+			expectedIsFlex: true,
+		},
+		{
+			description:    "IsNodeVmssFlex should return false without error if the node cannot be found",
+			nodeName:       nonExistingNodeName,
+			expectedIsFlex: false,
+		},
+		{
+			description:    "IsNodeVmssFlex should return false without error if the node belongs to a scale set of other orchestration modes",
+			nodeName:       "vmssflex1000001",
+			cachedVmss:     &uniformVmss,
+			expectedIsFlex: false,
+		},
+		{
+			description:      "IsNodeVmssFlex should return the error if failing to list the scale sets",
+			nodeName:         "vmssflex1000001",
+			vmssListErr:      &retry.Error{RawError: fmt.Errorf("failed to list vmss")},
+			expectedErrorMsg: "failed to list vmss",
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, tc.vmssListErr).AnyTimes()
+
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+		if tc.cachedVmss != nil {
+			vmssFlexes := &sync.Map{}
+			vmssFlexes.Store(pointer.StringDeref(tc.cachedVmss.ID, ""), tc.cachedVmss)
+			fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+		}
+
+		isFlex, err := fs.IsNodeVmssFlex(tc.nodeName)
+		if tc.expectedErrorMsg != "" {
+			assert.ErrorContains(t, err, tc.expectedErrorMsg, tc.description)
+		} else {
+			assert.NoError(t, err, tc.description)
+		}
+		assert.Equal(t, tc.expectedIsFlex, isFlex, tc.description)
+	}
+}
+
+func TestGetResolvedResourceGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vmInOtherResourceGroup := generateTestVMListWithoutInstanceView()
+	vmInOtherResourceGroup[0].ID = pointer.String("/subscriptions/sub/resourceGroups/OtherRG/providers/Microsoft.Compute/virtualMachines/testvm1")
+
+	vmWithoutID := generateTestVMListWithoutInstanceView()
+	vmWithoutID[0].ID = nil
+
+	testCases := []struct {
+		description                   string
+		nodeName                      string
+		testVMListWithoutInstanceView []compute.VirtualMachine
+		expectedResourceGroup         string
+		expectedErr                   error
+		expectedErrorMsg              string
+	}{
+		{
+			description:                   "GetResolvedResourceGroup should return the resource group of the VM",
+			nodeName:                      "vmssflex1000001",
+			testVMListWithoutInstanceView: testVMListWithoutInstanceView,
+			expectedResourceGroup:         "rg",
+		},
+		{
+			description:                   "GetResolvedResourceGroup should return the resource group of the VM even if it differs from the cluster resource group",
+			nodeName:                      "vmssflex1000001",
+			testVMListWithoutInstanceView: vmInOtherResourceGroup,
+			expectedResourceGroup:         "OtherRG",
+		},
+		{
+			description:                   "GetResolvedResourceGroup should return InstanceNotFound if the node cannot be found",
+			nodeName:                      nonExistingNodeName,
+			testVMListWithoutInstanceView: testVMListWithoutInstanceView,
+			expectedErr:                   cloudprovider.InstanceNotFound,
+		},
+		{
+			description:                   "GetResolvedResourceGroup should return the error if the VM ID cannot be parsed",
+			nodeName:                      "vmssflex1000001",
+			testVMListWithoutInstanceView: vmWithoutID,
+			expectedErrorMsg:              "failed to parse the VM ID of node vmssflex1000001",
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(tc.testVMListWithoutInstanceView, nil).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+		resourceGroup, err := fs.GetResolvedResourceGroup(tc.nodeName)
+		switch {
+		case tc.expectedErr != nil:
+			assert.ErrorIs(t, err, tc.expectedErr, tc.description)
+		case tc.expectedErrorMsg != "":
+			assert.ErrorContains(t, err, tc.expectedErrorMsg, tc.description)
+		default:
+			assert.NoError(t, err, tc.description)
+		}
+		assert.Equal(t, tc.expectedResourceGroup, resourceGroup, tc.description)
+	}
+}
+
+func TestGetNodeVmssFlexIDWithAmbiguousComputerName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description        string
+		otherVmssFlexID    string
+		expectedVmssFlexID string
+		expectedErr        error
+	}{
+		{
+			description:        "getNodeVmssFlexID should pick the VmssFlex in the cluster resource group if the computer name is ambiguous",
+			otherVmssFlexID:    "subscriptions/sub/resourceGroups/otherrg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex2",
+			expectedVmssFlexID: testVmssFlex1ID,
+		},
+		{
+			description:     "getNodeVmssFlexID should return an error if the ambiguous computer name cannot be resolved by the resource group",
+			otherVmssFlexID: testVmssFlex2ID,
+			expectedErr:     ErrorVmssFlexComputerNameAmbiguous,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexList := []compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex1", testVmssFlex1ID), genreteTestVmssFlex("vmssflex2", tc.otherVmssFlexID)}
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(vmssFlexList, nil).AnyTimes()
+
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachine{testVMWithoutInstanceView1}, nil).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachine{}, nil).AnyTimes()
+
+		// both of the scale sets report the same computer name
+		for _, vmssFlexID := range []string{testVmssFlex1ID, tc.otherVmssFlexID} {
+			_, err = fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeDefault)
+			assert.NoError(t, err, tc.description)
+		}
+
+		vmssFlexID, err := fs.getNodeVmssFlexID("vmssflex1000001")
+		if tc.expectedErr != nil {
+			assert.ErrorIs(t, err, tc.expectedErr, tc.description)
+			assert.Contains(t, err.Error(), testVmssFlex1ID, tc.description)
+			assert.Contains(t, err.Error(), tc.otherVmssFlexID, tc.description)
+		} else {
+			assert.NoError(t, err, tc.description)
+		}
+		assert.Equal(t, tc.expectedVmssFlexID, vmssFlexID, tc.description)
+	}
+}
+
+func TestGetNodeVmssFlexIDAmbiguityIsPrunedOnRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	vmssFlexList := []compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex1", testVmssFlex1ID), genreteTestVmssFlex("vmssflex2", testVmssFlex2ID)}
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(vmssFlexList, nil).AnyTimes()
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return([]compute.VirtualMachine{testVMWithoutInstanceView1}, nil).AnyTimes()
+	gomock.InOrder(
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{testVMWithoutInstanceView1}, nil).Times(1),
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{}, nil).Times(1),
+	)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachine{}, nil).AnyTimes()
+
+	for _, vmssFlexID := range []string{testVmssFlex1ID, testVmssFlex2ID} {
+		_, err = fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeDefault)
+		assert.NoError(t, err)
+	}
+	_, err = fs.getNodeVmssFlexID("vmssflex1000001")
+	assert.ErrorIs(t, err, ErrorVmssFlexComputerNameAmbiguous)
+
+	// the VM is gone from vmssflex2 after refreshing, so the node belongs to vmssflex1 only
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex2ID, azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	vmssFlexID, err := fs.getNodeVmssFlexID("vmssflex1000001")
+	assert.NoError(t, err)
+	assert.Equal(t, testVmssFlex1ID, vmssFlexID)
+}
+
 func TestGetVmssFlexVM(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -444,6 +764,62 @@ func TestGetVmssFlexByVmssFlexID(t *testing.T) {
 	}
 }
 
+func TestGetVmssFlexByVmssFlexIDServeStaleOnError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description      string
+		serveStale       bool
+		snapshotAge      time.Duration
+		expectedVmssFlex *compute.VirtualMachineScaleSet
+		expectedErr      error
+	}{
+		{
+			description: "getVmssFlexByVmssFlexID should return the refresh error if serving stale is disabled",
+			snapshotAge: time.Minute,
+			expectedErr: fmt.Errorf("Retriable: false, RetryAfter: 0s, HTTPStatusCode: 429, RawError: throttled"),
+		},
+		{
+			description:      "getVmssFlexByVmssFlexID should return the stale VmssFlex if the refresh fails",
+			serveStale:       true,
+			snapshotAge:      time.Minute,
+			expectedVmssFlex: &testVmssFlex1,
+		},
+		{
+			description: "getVmssFlexByVmssFlexID should return the refresh error if the snapshot exceeds the max staleness",
+			serveStale:  true,
+			snapshotAge: 2 * time.Hour,
+			expectedErr: fmt.Errorf("Retriable: false, RetryAfter: 0s, HTTPStatusCode: 429, RawError: throttled"),
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+		fs.Config.VmssFlexCacheServeStaleOnError = tc.serveStale
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, &retry.Error{HTTPStatusCode: http.StatusTooManyRequests, RawError: fmt.Errorf("throttled")}).AnyTimes()
+
+		snapshot := &sync.Map{}
+		snapshot.Store(testVmssFlex1ID, &testVmssFlex1)
+		_ = fs.vmssFlexCache.GetStore().Add(&azcache.AzureCacheEntry{
+			Key:       getVmssFlexCachePartitionKeyByResourceGroup("rg"),
+			Data:      snapshot,
+			CreatedOn: time.Now().Add(-tc.snapshotAge),
+		})
+
+		vmssFlex, err := fs.getVmssFlexByVmssFlexID(testVmssFlex1ID, azcache.CacheReadTypeForceRefresh)
+		if tc.expectedErr != nil {
+			assert.EqualError(t, err, tc.expectedErr.Error(), tc.description)
+		} else {
+			assert.NoError(t, err, tc.description)
+		}
+		assert.Equal(t, tc.expectedVmssFlex, vmssFlex, tc.description)
+	}
+}
+
 func TestGetVmssFlexIDByName(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -554,35 +930,46 @@ func TestGetVmssFlexByName(t *testing.T) {
 
 }
 
-func TestGetVmssFlexByNodeName(t *testing.T) {
+func TestNewVmssFlexCacheSkipsMalformedIDs(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	testCases := []struct {
-		description                    string
-		nodeName                       string
-		testVM                         compute.VirtualMachine
-		vmGetErr                       *retry.Error
-		testVMListWithoutInstanceView  []compute.VirtualMachine
-		testVMListWithOnlyInstanceView []compute.VirtualMachine
-		vmListErr                      error
-		testVmssFlexList               []compute.VirtualMachineScaleSet
-		vmssFlexListErr                *retry.Error
-		expectedVmssFlex               *compute.VirtualMachineScaleSet
-		expectedErr                    error
+		description        string
+		malformedID        string
+		vmssFlexName       string
+		expectedVmssFlexID string
+		expectedErr        error
 	}{
 		{
-			description:                    "getVmssFlexByName should return the VmssFlex ID that the node belongs to",
-			nodeName:                       "vmssflex1000001",
-			testVM:                         testVMWithoutInstanceView1,
-			vmGetErr:                       nil,
-			testVMListWithoutInstanceView:  testVMListWithoutInstanceView,
-			testVMListWithOnlyInstanceView: testVMListWithOnlyInstanceView,
-			vmListErr:                      nil,
-			testVmssFlexList:               testVmssFlexList,
-			vmssFlexListErr:                nil,
-			expectedVmssFlex:               &testVmssFlex1,
-			expectedErr:                    nil,
+			description:  "newVmssFlexCache should skip the VMSS Flex whose ID is not an ARM resource ID",
+			malformedID:  "vmssflex2",
+			vmssFlexName: "vmssflex2",
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+		{
+			description:  "newVmssFlexCache should skip the VMSS Flex whose ID has an empty name segment",
+			malformedID:  "subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/",
+			vmssFlexName: "",
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+		{
+			description:  "newVmssFlexCache should skip the VMSS Flex whose ID has a wrong resource type",
+			malformedID:  "subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vmssflex2",
+			vmssFlexName: "vmssflex2",
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+		{
+			description:  "newVmssFlexCache should skip the VMSS Flex whose ID has trailing segments",
+			malformedID:  "subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1/virtualMachines/vm",
+			vmssFlexName: "vm",
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+		{
+			description:        "malformed IDs should not poison the lookups of the valid VMSS Flex",
+			malformedID:        "/subscriptions/sub/resourceGroups/rg/vmssflex1",
+			vmssFlexName:       "vmssflex1",
+			expectedVmssFlexID: testVmssFlex1ID,
 		},
 	}
 
@@ -590,16 +977,3297 @@ func TestGetVmssFlexByNodeName(t *testing.T) {
 		fs, err := NewTestFlexScaleSet(ctrl)
 		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
 
-		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
-		mockVMClient.EXPECT().Get(gomock.Any(), fs.ResourceGroup, tc.nodeName, gomock.Any()).Return(tc.testVM, tc.vmGetErr).AnyTimes()
-		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(tc.testVMListWithoutInstanceView, tc.vmListErr).AnyTimes()
-		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(tc.testVMListWithOnlyInstanceView, tc.vmListErr).AnyTimes()
+		malformedVmssFlex := genreteTestVmssFlex(tc.vmssFlexName, tc.malformedID)
 		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
-		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(tc.testVmssFlexList, tc.vmssFlexListErr).AnyTimes()
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachineScaleSet{malformedVmssFlex, testVmssFlex1}, nil).AnyTimes()
 
-		vmssFlex, err := fs.getVmssFlexByNodeName(tc.nodeName, azcache.CacheReadTypeDefault)
+		cached, err := fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+		assert.NoError(t, err, tc.description)
+		_, found := cached.Load(tc.malformedID)
+		assert.False(t, found, tc.description)
+
+		vmssFlexID, err := fs.getVmssFlexIDByName(tc.vmssFlexName)
 		assert.Equal(t, tc.expectedErr, err, tc.description)
-		assert.Equal(t, tc.expectedVmssFlex, vmssFlex, tc.description)
+		assert.Equal(t, tc.expectedVmssFlexID, vmssFlexID, tc.description)
+	}
+}
+
+func TestNewVmssFlexCacheRefetchesVmssFlexWithoutID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vmssFlexWithoutID := testVmssFlex1
+	vmssFlexWithoutID.ID = nil
+
+	testCases := []struct {
+		description        string
+		getVmssFlex        compute.VirtualMachineScaleSet
+		getErr             *retry.Error
+		expectedVmssFlexID string
+		expectedErr        error
+	}{
+		{
+			description:        "newVmssFlexCache should cache the VMSS Flex listed without ID if it can be got by name",
+			getVmssFlex:        testVmssFlex1,
+			expectedVmssFlexID: testVmssFlex1ID,
+		},
+		{
+			description: "newVmssFlexCache should skip the VMSS Flex listed without ID if it cannot be got by name",
+			getErr:      &retry.Error{HTTPStatusCode: http.StatusTooManyRequests, RawError: fmt.Errorf("throttled")},
+			expectedErr: cloudprovider.InstanceNotFound,
+		},
+		{
+			description: "newVmssFlexCache should skip the VMSS Flex which is still got without ID",
+			getVmssFlex: vmssFlexWithoutID,
+			expectedErr: cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachineScaleSet{vmssFlexWithoutID}, nil).Times(1)
+		mockVMSSClient.EXPECT().Get(gomock.Any(), "rg", "vmssflex1").Return(tc.getVmssFlex, tc.getErr).Times(1)
+
+		_, err = fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+		assert.NoError(t, err, tc.description)
+
+		vmssFlexID, err := fs.getVmssFlexIDByName("vmssflex1")
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedVmssFlexID, vmssFlexID, tc.description)
 	}
+}
+
+func TestNewVmssFlexCacheThrottledRetryAfter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fs.setClock(fakeClock)
+	fs.circuitBreaker.threshold = 3
+	fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, &retry.Error{
+		HTTPStatusCode: http.StatusTooManyRequests,
+		RetryAfter:     fakeClock.Now().Add(30 * time.Second),
+		RawError:       fmt.Errorf("throttled"),
+	}).Times(1)
+	key := getVmssFlexCachePartitionKeyByResourceGroup("rg")
+
+	// the Retry-After is surfaced to the caller
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeForceRefresh)
+	var throttledErr *ThrottledError
+	assert.True(t, errors.As(err, &throttledErr))
+	assert.Equal(t, 30*time.Second, throttledErr.RetryAfter)
+
+	// the refreshes wait for the Retry-After instead of listing again immediately
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeForceRefresh)
+	assert.ErrorIs(t, err, ErrorVmssFlexCacheCircuitOpen)
+
+	fakeClock.Step(30 * time.Second)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(1)
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+}
+
+func TestVmssFlexCacheWithNilClients(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Cloud.VirtualMachineScaleSetsClient = nil
+	fs.Cloud.VirtualMachinesClient = nil
+
+	_, err = fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+	assert.ErrorIs(t, err, ErrorVmssFlexClientNotInitialized)
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.ErrorIs(t, err, ErrorVmssFlexClientNotInitialized)
+
+	_, err = fs.getVmssFlexByNodeNameInResourceGroup("vmssflex1000001", "rg")
+	assert.ErrorIs(t, err, ErrorVmssFlexClientNotInitialized)
+}
+
+func TestNewVmssFlexCacheRefreshTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Config.VmssFlexCacheRefreshTimeoutSeconds = 1
+
+	// the slow ARM endpoint never honors the context
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ string) ([]compute.VirtualMachineScaleSet, *retry.Error) {
+		time.Sleep(3 * time.Second)
+		return testVmssFlexList, nil
+	}).AnyTimes()
+
+	staleVmssFlexes := &sync.Map{}
+	staleVmssFlexes.Store(testVmssFlex1ID, &testVmssFlex1)
+	fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), staleVmssFlexes)
+
+	start := time.Now()
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeForceRefresh)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 3*time.Second, "the refresh should fail fast on timeout")
+
+	// the stale entry is kept for the unsafe reads
+	cached, err := fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeUnsafe)
+	assert.NoError(t, err)
+	assert.Equal(t, staleVmssFlexes, cached)
+}
+
+func TestGetVmssFlexByNodeName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description                    string
+		nodeName                       string
+		testVM                         compute.VirtualMachine
+		vmGetErr                       *retry.Error
+		testVMListWithoutInstanceView  []compute.VirtualMachine
+		testVMListWithOnlyInstanceView []compute.VirtualMachine
+		vmListErr                      error
+		testVmssFlexList               []compute.VirtualMachineScaleSet
+		vmssFlexListErr                *retry.Error
+		expectedVmssFlex               *compute.VirtualMachineScaleSet
+		expectedErr                    error
+	}{
+		{
+			description:                    "getVmssFlexByName should return the VmssFlex ID that the node belongs to",
+			nodeName:                       "vmssflex1000001",
+			testVM:                         testVMWithoutInstanceView1,
+			vmGetErr:                       nil,
+			testVMListWithoutInstanceView:  testVMListWithoutInstanceView,
+			testVMListWithOnlyInstanceView: testVMListWithOnlyInstanceView,
+			vmListErr:                      nil,
+			testVmssFlexList:               testVmssFlexList,
+			vmssFlexListErr:                nil,
+			expectedVmssFlex:               &testVmssFlex1,
+			expectedErr:                    nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().Get(gomock.Any(), fs.ResourceGroup, tc.nodeName, gomock.Any()).Return(tc.testVM, tc.vmGetErr).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(tc.testVMListWithoutInstanceView, tc.vmListErr).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(tc.testVMListWithOnlyInstanceView, tc.vmListErr).AnyTimes()
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(tc.testVmssFlexList, tc.vmssFlexListErr).AnyTimes()
+
+		vmssFlex, err := fs.getVmssFlexByNodeName(tc.nodeName, azcache.CacheReadTypeDefault)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedVmssFlex, vmssFlex, tc.description)
+	}
+
+}
+
+func TestGetNodeNamesByVmssFlexID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex2ID).Return(nil, &retry.Error{RawError: fmt.Errorf("failed to list VMs")}).Times(1)
+
+	nodeNames, err := fs.GetNodeNamesByVmssFlexID(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex1000001", "vmssflex1000002", "vmssflex1000003"}, nodeNames)
+
+	_, err = fs.GetNodeNamesByVmssFlexID(testVmssFlex2ID)
+	assert.EqualError(t, err, "Retriable: false, RetryAfter: 0s, HTTPStatusCode: 0, RawError: failed to list VMs")
+
+	// the deleted nodes should be removed from the index without refreshing the cache
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex1000001"))
+	nodeNames, err = fs.GetNodeNamesByVmssFlexID(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex1000002", "vmssflex1000003"}, nodeNames)
+
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex1000002"))
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex1000003"))
+	_, isCached := fs.vmssFlexIDToNodeNames.Load(testVmssFlex1ID)
+	assert.False(t, isCached, "the empty set of node names should be cleaned up")
+}
+
+func TestGetVmssFlexByNodeNameWithResourceGroupHint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description          string
+		resourceGroupHint    string
+		expectedHintedListed int
+		expectedFullListed   int
+	}{
+		{
+			description:          "getVmssFlexByNodeNameWithResourceGroupHint should only list the vmss flex in the hinted resource group",
+			resourceGroupHint:    "rg",
+			expectedHintedListed: 1,
+		},
+		{
+			description:          "getVmssFlexByNodeNameWithResourceGroupHint should fall back to all resource groups if the node is not in the hinted resource group",
+			resourceGroupHint:    "rg2",
+			expectedHintedListed: 1,
+			expectedFullListed:   1,
+		},
+		{
+			description:        "getVmssFlexByNodeNameWithResourceGroupHint should list all resource groups without a hint",
+			expectedFullListed: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		if tc.resourceGroupHint != "" && tc.resourceGroupHint != fs.ResourceGroup {
+			mockVMSSClient.EXPECT().List(gomock.Any(), tc.resourceGroupHint).Return([]compute.VirtualMachineScaleSet{}, nil).Times(tc.expectedHintedListed)
+			mockVMSSClient.EXPECT().List(gomock.Any(), fs.ResourceGroup).Return(testVmssFlexList, nil).Times(tc.expectedFullListed)
+		} else {
+			mockVMSSClient.EXPECT().List(gomock.Any(), fs.ResourceGroup).Return(testVmssFlexList, nil).Times(tc.expectedHintedListed + tc.expectedFullListed)
+		}
+
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+		vmssFlex, err := fs.getVmssFlexByNodeNameWithResourceGroupHint("vmssflex1000001", tc.resourceGroupHint, azcache.CacheReadTypeDefault)
+		assert.NoError(t, err, tc.description)
+		assert.Equal(t, testVmssFlex1ID, pointer.StringDeref(vmssFlex.ID, ""), tc.description)
+
+		// the hinted listing fills the cache partition of the resource group, so reading it again lists nothing
+		_, err = fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+		assert.NoError(t, err, tc.description)
+	}
+}
+
+func TestNewVmssFlexCacheTTL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description string
+		ttl         int
+		expectedTTL time.Duration
+	}{
+		{
+			description: "newVmssFlexCache should use the default TTL if not set",
+			ttl:         0,
+			expectedTTL: consts.VmssFlexCacheTTLDefaultInSeconds * time.Second,
+		},
+		{
+			description: "newVmssFlexCache should use the default TTL if negative",
+			ttl:         -1,
+			expectedTTL: consts.VmssFlexCacheTTLDefaultInSeconds * time.Second,
+		},
+		{
+			description: "newVmssFlexCache should clamp the very large TTL",
+			ttl:         math.MaxInt,
+			expectedTTL: consts.VmssFlexCacheTTLMaxInSeconds * time.Second,
+		},
+		{
+			description: "newVmssFlexCache should use the configured TTL",
+			ttl:         300,
+			expectedTTL: 300 * time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+		fs.Config.VmssFlexCacheTTLInSeconds = tc.ttl
+
+		cache, err := fs.newVmssFlexCache(context.Background())
+		assert.NoError(t, err, tc.description)
+		assert.Equal(t, tc.expectedTTL, cache.(*azcache.TimedCache).TTL, tc.description)
+	}
+}
+
+func TestNewVmssFlexCacheTTLJitter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description    string
+		jitter         float64
+		expectedJitter float64
+	}{
+		{
+			description:    "newVmssFlexCache should use the default jitter if not set",
+			jitter:         0,
+			expectedJitter: consts.VmssFlexCacheTTLJitterDefault,
+		},
+		{
+			description:    "newVmssFlexCache should disable the jitter if negative",
+			jitter:         -1,
+			expectedJitter: 0,
+		},
+		{
+			description:    "newVmssFlexCache should use the default jitter if not less than 1",
+			jitter:         1,
+			expectedJitter: consts.VmssFlexCacheTTLJitterDefault,
+		},
+		{
+			description:    "newVmssFlexCache should use the configured jitter",
+			jitter:         0.2,
+			expectedJitter: 0.2,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+		fs.Config.VmssFlexCacheTTLJitter = tc.jitter
+
+		cache, err := fs.newVmssFlexCache(context.Background())
+		assert.NoError(t, err, tc.description)
+		assert.Equal(t, tc.expectedJitter, cache.(*azcache.TimedCache).TTLJitter, tc.description)
+	}
+
+	// the partitions of the resource groups refreshed together should expire at different times
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(2)
+
+	var expiresOn []time.Time
+	for _, resourceGroup := range []string{"rg", "rg1"} {
+		_, err := fs.vmssFlexCache.Get(resourceGroup, azcache.CacheReadTypeDefault)
+		assert.NoError(t, err)
+		entry, exists, err := fs.vmssFlexCache.GetStore().GetByKey(resourceGroup)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		expiresOn = append(expiresOn, entry.(*azcache.AzureCacheEntry).CreatedOn.Add(entry.(*azcache.AzureCacheEntry).TTL))
+	}
+	assert.NotEqual(t, expiresOn[0], expiresOn[1])
+}
+
+func TestGetVmssFlexNodeCacheAge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	_, isCached := fs.GetVmssFlexNodeCacheAge("vmssflex1000001")
+	assert.False(t, isCached, "the node should not be cached before refreshing the cache")
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	age, isCached := fs.GetVmssFlexNodeCacheAge("vmssflex1000001")
+	assert.True(t, isCached)
+	time.Sleep(10 * time.Millisecond)
+	newAge, isCached := fs.GetVmssFlexNodeCacheAge("VMSSFLEX1000001")
+	assert.True(t, isCached)
+	assert.Greater(t, newAge, age, "the age should increase over time")
+
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex1000001"))
+	_, isCached = fs.GetVmssFlexNodeCacheAge("vmssflex1000001")
+	assert.False(t, isCached, "the node should not be cached after deleting its cache")
+}
+
+func TestSweepExpiredNodeCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fs.sweepExpiredNodeCache(), "the fresh nodes should not be swept")
+
+	fs.vmssFlexNodeNameToCachedOn.Set("vmssflex1000001", time.Now().Add(-2*time.Duration(fs.Config.VmssFlexVMCacheTTLInSeconds)*time.Second))
+	assert.Equal(t, 1, fs.sweepExpiredNodeCache())
+
+	_, isCached := fs.vmssFlexVMNameToVmssID.Get("vmssflex1000001")
+	assert.False(t, isCached, "the expired node should be swept from vmssFlexVMNameToVmssID")
+	_, isCached = fs.vmssFlexNodeNameToCachedOn.Get("vmssflex1000001")
+	assert.False(t, isCached, "the expired node should be swept from vmssFlexNodeNameToCachedOn")
+	_, isCached = fs.vmssFlexVMNameToNodeName.Get("testvm1")
+	assert.False(t, isCached, "the expired node should be swept from vmssFlexVMNameToNodeName")
+	nodeNames, err := fs.GetNodeNamesByVmssFlexID(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.NotContains(t, nodeNames, "vmssflex1000001")
+
+	_, isCached = fs.vmssFlexVMNameToVmssID.Get("vmssflex1000002")
+	assert.True(t, isCached, "the fresh node should be kept")
+	_, isCached = fs.vmssFlexVMNameToNodeName.Get("testvm2")
+	assert.True(t, isCached, "the fresh node should be kept")
+}
+
+func TestSweepExpiredNodeCacheInBackground(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description   string
+		sweepInterval int
+		expectSwept   bool
+	}{
+		{
+			description:   "the expired nodes should be swept in the background if the sweep interval is set",
+			sweepInterval: 1,
+			expectSwept:   true,
+		},
+		{
+			description:   "the expired nodes should not be swept in the background if the sweep interval is not set",
+			sweepInterval: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			cloud := GetTestCloud(ctrl)
+			cloud.Config.VmssFlexNodeCacheSweepIntervalInSeconds = tc.sweepInterval
+			vmSet, err := newFlexScaleSet(ctx, cloud)
+			assert.NoError(t, err)
+			fs := vmSet.(*FlexScaleSet)
+
+			fs.vmssFlexVMNameToVmssID.Set("vmssflex1000001", testVmssFlex1ID)
+			fs.vmssFlexVMNameToNodeName.Set("testvm1", "vmssflex1000001")
+			fs.vmssFlexNodeNameToCachedOn.Set("vmssflex1000001", time.Now().Add(-2*time.Duration(fs.Config.VmssFlexVMCacheTTLInSeconds)*time.Second))
+
+			isSwept := func() bool {
+				_, isCached := fs.vmssFlexVMNameToNodeName.Get("testvm1")
+				return !isCached
+			}
+			if tc.expectSwept {
+				assert.Eventually(t, isSwept, 5*time.Second, 100*time.Millisecond)
+			} else {
+				time.Sleep(100 * time.Millisecond)
+				assert.False(t, isSwept())
+			}
+		})
+	}
+}
+
+func TestListScaleSetsByOrchestrationMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uniformID := "/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssuniform"
+	legacyUniformID := "/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmsslegacy"
+	vmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	uniformVmss := genreteTestVmssFlex("vmssuniform", uniformID)
+	uniformVmss.OrchestrationMode = compute.Uniform
+	legacyUniformVmss := genreteTestVmssFlex("vmsslegacy", legacyUniformID)
+	legacyUniformVmss.OrchestrationMode = ""
+	mixedScaleSets := []compute.VirtualMachineScaleSet{uniformVmss, vmssFlex, legacyUniformVmss}
+
+	testCases := []struct {
+		description                string
+		cacheAllOrchestrationModes bool
+		mode                       compute.OrchestrationMode
+		expectedIDs                []string
+		expectedErr                bool
+	}{
+		{
+			description: "ListScaleSetsByOrchestrationMode should return the vmss flex by default",
+			mode:        compute.Flexible,
+			expectedIDs: []string{testVmssFlex1ID},
+		},
+		{
+			description: "ListScaleSetsByOrchestrationMode should return an error for the uniform scale sets by default",
+			mode:        compute.Uniform,
+			expectedErr: true,
+		},
+		{
+			description:                "ListScaleSetsByOrchestrationMode should return the uniform scale sets if all orchestration modes are cached",
+			cacheAllOrchestrationModes: true,
+			mode:                       compute.Uniform,
+			expectedIDs:                []string{legacyUniformID, uniformID},
+		},
+		{
+			description:                "ListScaleSetsByOrchestrationMode should still only return the vmss flex for the flexible mode if all orchestration modes are cached",
+			cacheAllOrchestrationModes: true,
+			mode:                       compute.Flexible,
+			expectedIDs:                []string{testVmssFlex1ID},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			fs, err := NewTestFlexScaleSet(ctrl)
+			assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+			fs.SetCacheAllOrchestrationModes(tc.cacheAllOrchestrationModes)
+
+			mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+			mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(mixedScaleSets, nil).AnyTimes()
+
+			scaleSets, err := fs.ListScaleSetsByOrchestrationMode(tc.mode)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			var ids []string
+			for _, scaleSet := range scaleSets {
+				ids = append(ids, *scaleSet.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+
+			// the vmss flex lookups should never serve the uniform scale sets
+			_, err = fs.getVmssFlexByVmssFlexID(uniformID, azcache.CacheReadTypeDefault)
+			assert.Equal(t, cloudprovider.InstanceNotFound, err)
+			vmssFlexes, err := fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+			assert.NoError(t, err)
+			_, found := vmssFlexes.Load(uniformID)
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestNewVmssFlexCacheWithResourceGroupsSource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description        string
+		source             func() (sets.Set[string], error)
+		expectedListedRGs  []string
+		expectedVmssFlexes []string
+		expectedErr        error
+	}{
+		{
+			description:        "newVmssFlexCache should list the vmss flex in the resource groups from the custom source",
+			source:             func() (sets.Set[string], error) { return sets.New("rg1", "rg2"), nil },
+			expectedListedRGs:  []string{"rg1", "rg2"},
+			expectedVmssFlexes: []string{testVmssFlex1ID},
+		},
+		{
+			description: "newVmssFlexCache should return the error of the custom source",
+			source:      func() (sets.Set[string], error) { return nil, fmt.Errorf("failed to get resource groups") },
+			expectedErr: fmt.Errorf("failed to get resource groups"),
+		},
+		{
+			description:        "newVmssFlexCache should fall back to GetResourceGroups if the source is reset",
+			expectedListedRGs:  []string{"rg"},
+			expectedVmssFlexes: []string{testVmssFlex1ID},
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+		fs.SetResourceGroupsSource(tc.source)
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		for _, rg := range tc.expectedListedRGs {
+			vmssFlexes := []compute.VirtualMachineScaleSet{}
+			if rg != "rg2" {
+				vmssFlexes = testVmssFlexList
+			}
+			mockVMSSClient.EXPECT().List(gomock.Any(), rg).Return(vmssFlexes, nil).Times(1)
+		}
+
+		cached, err := fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		if tc.expectedErr != nil {
+			continue
+		}
+		var vmssFlexIDs []string
+		cached.Range(func(key, _ interface{}) bool {
+			vmssFlexIDs = append(vmssFlexIDs, key.(string))
+			return true
+		})
+		assert.Equal(t, tc.expectedVmssFlexes, vmssFlexIDs, tc.description)
+	}
+}
+
+func TestGetVmssFlexVMInstanceView(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	// the vm cache should not be refreshed by the force refresh of the instance view
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	testVM := generateVmssFlexTestVM(testVM1Spec)
+	mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm1", compute.InstanceViewTypesInstanceView).Return(testVM, nil).Times(2)
+
+	instanceView, err := fs.getVmssFlexVMInstanceView("vmssflex1000001", azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, testVM.InstanceView, instanceView)
+
+	// served from the instance view cache
+	instanceView, err = fs.getVmssFlexVMInstanceView("vmssflex1000001", azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, testVM.InstanceView, instanceView)
+
+	instanceView, err = fs.getVmssFlexVMInstanceView("vmssflex1000001", azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	assert.Equal(t, testVM.InstanceView, instanceView)
+
+	mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm2", compute.InstanceViewTypesInstanceView).Return(compute.VirtualMachine{}, &retry.Error{HTTPStatusCode: http.StatusNotFound}).Times(1)
+	_, err = fs.getVmssFlexVMInstanceView("vmssflex1000002", azcache.CacheReadTypeDefault)
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+}
+
+func TestGetVmssFlexVMInstanceViewThrottledRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fs.setClock(fakeClock)
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+	throttled := func() *retry.Error {
+		return &retry.Error{
+			HTTPStatusCode: http.StatusTooManyRequests,
+			RetryAfter:     fakeClock.Now().Add(5 * time.Second),
+			RawError:       fmt.Errorf("throttled"),
+		}
+	}
+	// getInstanceView gets the instance view in the background, and steps the clock to the Retry-After
+	// once the retry waits for it
+	getInstanceView := func(nodeName string) (*compute.VirtualMachineInstanceView, error) {
+		var instanceView *compute.VirtualMachineInstanceView
+		var err error
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			instanceView, err = fs.getVmssFlexVMInstanceView(nodeName, azcache.CacheReadTypeDefault)
+		}()
+		for {
+			select {
+			case <-done:
+				return instanceView, err
+			case <-time.After(time.Millisecond):
+			}
+			if fakeClock.HasWaiters() {
+				fakeClock.Step(4 * time.Second)
+				assert.True(t, fakeClock.HasWaiters(), "the retry should wait until the Retry-After")
+				fakeClock.Step(time.Second)
+			}
+		}
+	}
+
+	// the throttled GET is retried after the Retry-After
+	testVM := generateVmssFlexTestVM(testVM1Spec)
+	gomock.InOrder(
+		mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm1", compute.InstanceViewTypesInstanceView).Return(compute.VirtualMachine{}, throttled()).Times(1),
+		mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm1", compute.InstanceViewTypesInstanceView).Return(testVM, nil).Times(1),
+	)
+	instanceView, err := getInstanceView("vmssflex1000001")
+	assert.NoError(t, err)
+	assert.Equal(t, testVM.InstanceView, instanceView)
+
+	// the error is returned once the retries are exhausted
+	fs.Config.VmssFlexVMGetThrottledRetryAttempts = 1
+	mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm2", compute.InstanceViewTypesInstanceView).DoAndReturn(
+		func(_ context.Context, _, _ string, _ compute.InstanceViewTypes) (compute.VirtualMachine, *retry.Error) {
+			return compute.VirtualMachine{}, throttled()
+		}).Times(2)
+	_, err = getInstanceView("vmssflex1000002")
+	assert.ErrorContains(t, err, "throttled")
+
+	// the throttled GET is not retried if disabled
+	fs.Config.VmssFlexVMGetThrottledRetryAttempts = -1
+	mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm2", compute.InstanceViewTypesInstanceView).Return(compute.VirtualMachine{}, throttled()).Times(1)
+	_, err = fs.getVmssFlexVMInstanceView("vmssflex1000002", azcache.CacheReadTypeForceRefresh)
+	assert.ErrorContains(t, err, "throttled")
+}
+
+func TestDeleteCacheForNodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	// the cache should not be refreshed by deleting the nodes which are not cached
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	assert.NoError(t, fs.DeleteCacheForNodes([]string{"vmssflex1000001", "unknownnode"}))
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fs.DeleteCacheForNodes([]string{"vmssflex1000001", "vmssflex1000002", "unknownnode"}))
+	nodeNames, err := fs.GetNodeNamesByVmssFlexID(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex1000003"}, nodeNames)
+	for _, nodeName := range []string{"vmssflex1000001", "vmssflex1000002"} {
+		_, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName)
+		assert.False(t, isCached, "node %s should be removed from the name map", nodeName)
+		_, isCached = fs.GetVmssFlexNodeCacheAge(nodeName)
+		assert.False(t, isCached, "node %s should be removed from the cached-on map", nodeName)
+	}
+
+	fs.Config.DisableAPICallCache = true
+	assert.NoError(t, fs.DeleteCacheForNodes([]string{"vmssflex1000003"}))
+	_, isCached := fs.vmssFlexVMNameToVmssID.Get("vmssflex1000003")
+	assert.True(t, isCached, "the cache should not be touched if the API call cache is disabled")
+}
+
+func TestOnNodeCacheEvicted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	var evicted [][]string
+	fs.SetOnNodeCacheEvicted(func(nodeName, vmName, vmssFlexID string) {
+		// the hook should be called outside of the lock of the vmss flex
+		fs.lockMap.LockEntry(vmssFlexID)
+		fs.lockMap.UnlockEntry(vmssFlexID)
+		evicted = append(evicted, []string{nodeName, vmName, vmssFlexID})
+	})
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex1000001"))
+	assert.Equal(t, [][]string{{"vmssflex1000001", "testvm1", testVmssFlex1ID}}, evicted)
+
+	evicted = nil
+	assert.NoError(t, fs.DeleteCacheForNodes([]string{"vmssflex1000002", "unknownnode"}))
+	assert.Equal(t, [][]string{{"vmssflex1000002", "testvm2", testVmssFlex1ID}}, evicted)
+
+	evicted = nil
+	fs.vmssFlexNodeNameToCachedOn.Set("vmssflex1000003", time.Now().Add(-2*time.Duration(fs.Config.VmssFlexVMCacheTTLInSeconds)*time.Second))
+	assert.Equal(t, 1, fs.sweepExpiredNodeCache())
+	assert.Equal(t, [][]string{{"vmssflex1000003", "testvm3", testVmssFlex1ID}}, evicted)
+
+	evicted = nil
+	fs.Config.DisableAPICallCache = true
+	fs.vmssFlexVMNameToVmssID.Set("vmssflex1000003", testVmssFlex1ID)
+	fs.vmssFlexNodeNameToCachedOn.Set("vmssflex1000003", time.Now().Add(-2*time.Duration(fs.Config.VmssFlexVMCacheTTLInSeconds)*time.Second))
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex1000003"))
+	assert.Equal(t, 1, fs.sweepExpiredNodeCache())
+	assert.Empty(t, evicted, "the hook should not be called if the API call cache is disabled")
+}
+
+func TestPreviewDeleteCacheForNode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	var evicted [][]string
+	fs.SetOnNodeCacheEvicted(func(nodeName, vmName, vmssFlexID string) {
+		evicted = append(evicted, []string{nodeName, vmName, vmssFlexID})
+	})
+
+	_, _, found := fs.PreviewDeleteCacheForNode("vmssflex1000001")
+	assert.False(t, found, "the preview should not refresh the cache")
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	vmName, vmssFlexID, found := fs.PreviewDeleteCacheForNode("vmssflex1000001")
+	assert.True(t, found)
+	assert.Equal(t, "testvm1", vmName)
+	assert.Equal(t, testVmssFlex1ID, vmssFlexID)
+
+	// the preview should not modify the cache
+	cachedVmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get("vmssflex1000001")
+	assert.True(t, isCached)
+	assert.Equal(t, testVmssFlex1ID, cachedVmssFlexID)
+	nodeNames, err := fs.GetNodeNamesByVmssFlexID(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.Contains(t, nodeNames, "vmssflex1000001")
+
+	// the preview should match the actual deletion
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex1000001"))
+	assert.Equal(t, [][]string{{"vmssflex1000001", vmName, vmssFlexID}}, evicted)
+	_, isCached = fs.vmssFlexVMNameToNodeName.Get(vmName)
+	assert.False(t, isCached, "the vm name of the node should be removed")
+
+	_, _, found = fs.PreviewDeleteCacheForNode("vmssflex1000001")
+	assert.False(t, found)
+
+	fs.Config.DisableAPICallCache = true
+	_, _, found = fs.PreviewDeleteCacheForNode("vmssflex1000002")
+	assert.False(t, found, "nothing is deleted if the API call cache is disabled")
+}
+
+func TestValidateNameMaps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Empty(t, fs.validateNameMaps(), "the name maps should be consistent after refreshing the cache")
+
+	// drift the name maps
+	cached, _ := fs.vmssFlexIDToNodeNames.Load(testVmssFlex1ID)
+	cached.(*sync.Map).Delete("vmssflex1000001")
+	cached.(*sync.Map).Store("stalenode", struct{}{})
+	fs.vmssFlexVMNameToNodeName.Delete("testvm2")
+	fs.vmssFlexNodeNameToCachedOn.Set("deletednode", time.Now())
+	fs.vmssFlexVMNameToNodeName.Set("deletedvm", "deletednode")
+
+	inconsistencies := fs.validateNameMaps()
+	assert.Len(t, inconsistencies, 5)
+
+	nodeNames, err := fs.GetNodeNamesByVmssFlexID(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex1000001", "vmssflex1000002", "vmssflex1000003"}, nodeNames)
+	_, isCached := fs.GetVmssFlexNodeCacheAge("deletednode")
+	assert.False(t, isCached)
+	_, isCached = fs.vmssFlexVMNameToNodeName.Get("deletedvm")
+	assert.False(t, isCached, "the vm name mapped to the node which is not cached should be deleted")
+
+	// the missing vm name could not be repaired without refreshing the cache
+	assert.Len(t, fs.validateNameMaps(), 1)
+}
+
+func TestNewVmssFlexCacheMaxEntries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Config.VmssFlexCacheMaxEntries = 1
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex2", testVmssFlex2ID), testVmssFlex1}, nil).Times(1)
+
+	cached, err := fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	_, found := cached.Load(testVmssFlex1ID)
+	assert.True(t, found, "the VMSS Flex within the limit should be cached")
+	_, found = cached.Load(testVmssFlex2ID)
+	assert.False(t, found, "the VMSS Flex beyond the limit should not be cached")
+
+	// the limit is enforced when the partition is stored, so that all the lookups agree on the cached VMSS Flex
+	partition := fs.peekVmssFlexCachePartition("rg")
+	assert.NotNil(t, partition)
+	_, found = partition.Load(testVmssFlex2ID)
+	assert.False(t, found, "the VMSS Flex beyond the limit should not be stored")
+	vmssFlexID, err := fs.getVmssFlexIDByName("vmssflex1")
+	assert.NoError(t, err)
+	assert.Equal(t, testVmssFlex1ID, vmssFlexID)
+
+	// the other resource groups could not exceed the limit either
+	assert.Equal(t, 0, fs.reserveVmssFlexCacheEntries("rg2", 3))
+	assert.Equal(t, 1, fs.reserveVmssFlexCacheEntries("rg", 3))
+}
+
+func TestNewVmssFlexCacheMultipleSubscriptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	// the VMSS Flex with the same name and resource group in different subscriptions
+	vmssFlexID := "/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1"
+	sub2VmssFlexID := "/subscriptions/sub2/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1"
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), "rg").Return([]compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex1", vmssFlexID)}, nil).Times(1)
+	sub2VMSSClient := mockvmssclient.NewMockInterface(ctrl)
+	sub2VMSSClient.EXPECT().List(gomock.Any(), "rg").Return([]compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex1", sub2VmssFlexID)}, nil).Times(1)
+	sub2VMClient := mockvmclient.NewMockInterface(ctrl)
+	fs.vmssFlexSubscriptionClients = map[string]vmssFlexSubscriptionClients{
+		"sub2": {vmssClient: sub2VMSSClient, vmClient: sub2VMClient},
+	}
+
+	vmssFlex, err := fs.getVmssFlexByVmssFlexID(vmssFlexID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, vmssFlexID, *vmssFlex.ID)
+	vmssFlex, err = fs.getVmssFlexByVmssFlexID(sub2VmssFlexID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, sub2VmssFlexID, *vmssFlex.ID)
+
+	// the VMs should be listed by the client of the subscription of the VMSS Flex
+	sub2VMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), sub2VmssFlexID).Return([]compute.VirtualMachine{}, nil).Times(1)
+	sub2VMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), sub2VmssFlexID).Return([]compute.VirtualMachine{}, nil).Times(1)
+	_, err = fs.vmssFlexVMCache.Get(sub2VmssFlexID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	// the cache should not be refreshed for the subscriptions which are not configured
+	_, err = fs.getVmssFlexByVmssFlexID("/subscriptions/sub3/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1", azcache.CacheReadTypeDefault)
+	assert.ErrorIs(t, err, ErrorVmssFlexSubscriptionNotConfigured)
+	assert.Equal(t, []string{"subscription", "sub2"}, fs.getVmssFlexSubscriptionIDs())
+
+	// the name shared across the subscriptions should not be resolved to an arbitrary one of them
+	_, err = fs.getVmssFlexIDByName("vmssflex1")
+	assert.ErrorIs(t, err, ErrorVmssFlexNameAmbiguous)
+	_, _, err = fs.GetVmssFlexZoneInfo("vmssflex1")
+	assert.ErrorIs(t, err, ErrorVmssFlexNameAmbiguous)
+}
+
+func TestGetCacheEntryWithForceRefreshDebounce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = 100
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(1)
+
+	// the burst of force refreshes should only list once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cached, err := fs.getVmssFlexCacheEntry(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeForceRefresh)
+			assert.NoError(t, err)
+			_, found := cached.(*sync.Map).Load(testVmssFlex1ID)
+			assert.True(t, found)
+		}()
+	}
+	wg.Wait()
+
+	// the force refresh after the window should list again
+	time.Sleep(150 * time.Millisecond)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(1)
+	_, err = fs.getVmssFlexCacheEntry(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+
+	// the debounce is disabled by a negative window
+	fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err = fs.getVmssFlexCacheEntry(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeForceRefresh)
+		assert.NoError(t, err)
+	}
+}
+
+func TestGetVmssFlexZoneInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zonalVmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	zonalVmssFlex.Zones = &[]string{"1", "2", "3"}
+	zonalVmssFlex.PlatformFaultDomainCount = pointer.Int32(1)
+
+	nonZonalVmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	nonZonalVmssFlex.PlatformFaultDomainCount = pointer.Int32(3)
+
+	nilPropertiesVmssFlex := compute.VirtualMachineScaleSet{
+		ID:   pointer.String(testVmssFlex1ID),
+		Name: pointer.String("vmssflex1"),
+	}
+
+	testCases := []struct {
+		description          string
+		vmssFlexName         string
+		vmssFlex             *compute.VirtualMachineScaleSet
+		expectedZones        []string
+		expectedFaultDomains int32
+		expectedErr          error
+	}{
+		{
+			description:          "GetVmssFlexZoneInfo should return the zones and the fault domain count of a zonal vmss flex",
+			vmssFlexName:         "vmssflex1",
+			vmssFlex:             &zonalVmssFlex,
+			expectedZones:        []string{"1", "2", "3"},
+			expectedFaultDomains: 1,
+		},
+		{
+			description:          "GetVmssFlexZoneInfo should return no zones for a non-zonal vmss flex",
+			vmssFlexName:         "vmssflex1",
+			vmssFlex:             &nonZonalVmssFlex,
+			expectedZones:        []string{},
+			expectedFaultDomains: 3,
+		},
+		{
+			description:          "GetVmssFlexZoneInfo should tolerate a vmss flex without properties",
+			vmssFlexName:         "vmssflex1",
+			vmssFlex:             &nilPropertiesVmssFlex,
+			expectedZones:        []string{},
+			expectedFaultDomains: 0,
+		},
+		{
+			description:  "GetVmssFlexZoneInfo should return cloudprovider.InstanceNotFound if the vmss flex is not cached",
+			vmssFlexName: "vmssflex2",
+			vmssFlex:     &zonalVmssFlex,
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(*tc.vmssFlex.ID, tc.vmssFlex)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+		zones, faultDomains, err := fs.GetVmssFlexZoneInfo(tc.vmssFlexName)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedZones, zones, tc.description)
+		assert.Equal(t, tc.expectedFaultDomains, faultDomains, tc.description)
+	}
+}
+
+func TestGetVmssFlexCapacity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vmssFlexWithCapacity := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexWithCapacity.Sku = &compute.Sku{Name: pointer.String("Standard_D2s_v3"), Capacity: pointer.Int64(3)}
+
+	vmssFlexWithoutCapacity := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexWithoutCapacity.Sku = &compute.Sku{Name: pointer.String("Standard_D2s_v3")}
+
+	vmssFlexWithoutSku := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexWithoutSku.Sku = nil
+
+	testCases := []struct {
+		description      string
+		vmssFlexName     string
+		vmssFlex         *compute.VirtualMachineScaleSet
+		expectedCapacity int64
+		expectedErr      error
+	}{
+		{
+			description:      "GetVmssFlexCapacity should return the capacity of the cached vmss flex",
+			vmssFlexName:     "VMSSFLEX1",
+			vmssFlex:         &vmssFlexWithCapacity,
+			expectedCapacity: 3,
+		},
+		{
+			description:      "GetVmssFlexCapacity should return 0 if the sku has no capacity",
+			vmssFlexName:     "vmssflex1",
+			vmssFlex:         &vmssFlexWithoutCapacity,
+			expectedCapacity: 0,
+		},
+		{
+			description:      "GetVmssFlexCapacity should return 0 if the vmss flex has no sku",
+			vmssFlexName:     "vmssflex1",
+			vmssFlex:         &vmssFlexWithoutSku,
+			expectedCapacity: 0,
+		},
+		{
+			description:  "GetVmssFlexCapacity should return cloudprovider.InstanceNotFound if the vmss flex is not cached",
+			vmssFlexName: "vmssflex2",
+			vmssFlex:     &vmssFlexWithCapacity,
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(*tc.vmssFlex.ID, tc.vmssFlex)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+		capacity, err := fs.GetVmssFlexCapacity(tc.vmssFlexName)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedCapacity, capacity, tc.description)
+	}
+}
+
+func TestGetVmssFlexUpgradePolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vmssFlexWithUpgradeMode := func(mode compute.UpgradeMode) *compute.VirtualMachineScaleSet {
+		vmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+		vmssFlex.UpgradePolicy = &compute.UpgradePolicy{Mode: mode}
+		return &vmssFlex
+	}
+	vmssFlexWithoutUpgradePolicy := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexWithoutUpgradePolicy.UpgradePolicy = nil
+
+	testCases := []struct {
+		description         string
+		vmssFlexName        string
+		vmssFlex            *compute.VirtualMachineScaleSet
+		expectedUpgradeMode compute.UpgradeMode
+		expectedErr         error
+	}{
+		{
+			description:         "GetVmssFlexUpgradePolicy should return the manual upgrade mode",
+			vmssFlexName:        "vmssflex1",
+			vmssFlex:            vmssFlexWithUpgradeMode(compute.UpgradeModeManual),
+			expectedUpgradeMode: compute.UpgradeModeManual,
+		},
+		{
+			description:         "GetVmssFlexUpgradePolicy should return the automatic upgrade mode",
+			vmssFlexName:        "vmssflex1",
+			vmssFlex:            vmssFlexWithUpgradeMode(compute.UpgradeModeAutomatic),
+			expectedUpgradeMode: compute.UpgradeModeAutomatic,
+		},
+		{
+			description:         "GetVmssFlexUpgradePolicy should return the rolling upgrade mode",
+			vmssFlexName:        "vmssflex1",
+			vmssFlex:            vmssFlexWithUpgradeMode(compute.UpgradeModeRolling),
+			expectedUpgradeMode: compute.UpgradeModeRolling,
+		},
+		{
+			description:  "GetVmssFlexUpgradePolicy should return an empty mode if the vmss flex has no upgrade policy",
+			vmssFlexName: "vmssflex1",
+			vmssFlex:     &vmssFlexWithoutUpgradePolicy,
+		},
+		{
+			description:  "GetVmssFlexUpgradePolicy should return cloudprovider.InstanceNotFound if the vmss flex is not cached",
+			vmssFlexName: "vmssflex2",
+			vmssFlex:     vmssFlexWithUpgradeMode(compute.UpgradeModeManual),
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(*tc.vmssFlex.ID, tc.vmssFlex)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+		upgradeMode, err := fs.GetVmssFlexUpgradePolicy(tc.vmssFlexName)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedUpgradeMode, upgradeMode, tc.description)
+	}
+}
+
+func TestGetVmssFlexNetworkProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vmssFlexWithNetworkProfile := func(acceleratedNetworking bool) *compute.VirtualMachineScaleSet {
+		vmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+		vmssFlex.VirtualMachineProfile.NetworkProfile = &compute.VirtualMachineScaleSetNetworkProfile{
+			NetworkInterfaceConfigurations: &[]compute.VirtualMachineScaleSetNetworkConfiguration{
+				{
+					Name: pointer.String("nic"),
+					VirtualMachineScaleSetNetworkConfigurationProperties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+						Primary:                     pointer.Bool(true),
+						EnableAcceleratedNetworking: pointer.Bool(acceleratedNetworking),
+						IPConfigurations: &[]compute.VirtualMachineScaleSetIPConfiguration{
+							{
+								Name: pointer.String("ipconfig"),
+								VirtualMachineScaleSetIPConfigurationProperties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+									Subnet: &compute.APIEntityReference{ID: pointer.String("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet")},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		return &vmssFlex
+	}
+	vmssFlexWithoutNetworkProfile := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexWithoutNetworkProfile.VirtualMachineProfile.NetworkProfile = nil
+	vmssFlexWithoutVMProfile := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexWithoutVMProfile.VirtualMachineProfile = nil
+
+	testCases := []struct {
+		description                   string
+		vmssFlexName                  string
+		vmssFlex                      *compute.VirtualMachineScaleSet
+		expectedAcceleratedNetworking *bool
+		expectedErr                   error
+	}{
+		{
+			description:                   "GetVmssFlexNetworkProfile should return the network profile with accelerated networking enabled",
+			vmssFlexName:                  "vmssflex1",
+			vmssFlex:                      vmssFlexWithNetworkProfile(true),
+			expectedAcceleratedNetworking: pointer.Bool(true),
+		},
+		{
+			description:                   "GetVmssFlexNetworkProfile should return the network profile with accelerated networking disabled",
+			vmssFlexName:                  "vmssflex1",
+			vmssFlex:                      vmssFlexWithNetworkProfile(false),
+			expectedAcceleratedNetworking: pointer.Bool(false),
+		},
+		{
+			description:  "GetVmssFlexNetworkProfile should return nil if the vmss flex has no network profile",
+			vmssFlexName: "vmssflex1",
+			vmssFlex:     &vmssFlexWithoutNetworkProfile,
+		},
+		{
+			description:  "GetVmssFlexNetworkProfile should return nil if the vmss flex has no VM profile",
+			vmssFlexName: "vmssflex1",
+			vmssFlex:     &vmssFlexWithoutVMProfile,
+		},
+		{
+			description:  "GetVmssFlexNetworkProfile should return cloudprovider.InstanceNotFound if the vmss flex is not cached",
+			vmssFlexName: "vmssflex2",
+			vmssFlex:     vmssFlexWithNetworkProfile(true),
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(*tc.vmssFlex.ID, tc.vmssFlex)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+		networkProfile, err := fs.GetVmssFlexNetworkProfile(tc.vmssFlexName)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		if tc.expectedAcceleratedNetworking == nil {
+			assert.Nil(t, networkProfile, tc.description)
+			continue
+		}
+		assert.NotNil(t, networkProfile, tc.description)
+		nicConfig := (*networkProfile.NetworkInterfaceConfigurations)[0]
+		assert.Equal(t, tc.expectedAcceleratedNetworking, nicConfig.EnableAcceleratedNetworking, tc.description)
+		assert.Equal(t, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet", *(*nicConfig.IPConfigurations)[0].Subnet.ID, tc.description)
+	}
+}
+
+func TestGetVmssFlexImageReference(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	marketplaceImage := &compute.ImageReference{
+		Publisher: pointer.String("Canonical"),
+		Offer:     pointer.String("0001-com-ubuntu-server-jammy"),
+		Sku:       pointer.String("22_04-lts-gen2"),
+		Version:   pointer.String("latest"),
+	}
+	galleryImage := &compute.ImageReference{
+		ID: pointer.String("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/galleries/gallery/images/windows/versions/1.0.0"),
+	}
+	vmssFlexWithImage := func(imageReference *compute.ImageReference, osType compute.OperatingSystemTypes) *compute.VirtualMachineScaleSet {
+		vmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+		vmssFlex.VirtualMachineProfile.StorageProfile = &compute.VirtualMachineScaleSetStorageProfile{
+			ImageReference: imageReference,
+			OsDisk:         &compute.VirtualMachineScaleSetOSDisk{OsType: osType},
+		}
+		return &vmssFlex
+	}
+	vmssFlexWithoutStorageProfile := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexWithoutStorageProfile.VirtualMachineProfile.StorageProfile = nil
+	vmssFlexWithoutVMProfile := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexWithoutVMProfile.VirtualMachineProfile = nil
+
+	testCases := []struct {
+		description            string
+		vmssFlexName           string
+		vmssFlex               *compute.VirtualMachineScaleSet
+		expectedImageReference *compute.ImageReference
+		expectedOSType         compute.OperatingSystemTypes
+		expectedErr            error
+	}{
+		{
+			description:            "GetVmssFlexImageReference should return the marketplace image of the vmss flex",
+			vmssFlexName:           "vmssflex1",
+			vmssFlex:               vmssFlexWithImage(marketplaceImage, compute.OperatingSystemTypesLinux),
+			expectedImageReference: marketplaceImage,
+			expectedOSType:         compute.OperatingSystemTypesLinux,
+		},
+		{
+			description:            "GetVmssFlexImageReference should return the gallery image of the vmss flex",
+			vmssFlexName:           "VMSSFLEX1",
+			vmssFlex:               vmssFlexWithImage(galleryImage, compute.OperatingSystemTypesWindows),
+			expectedImageReference: galleryImage,
+			expectedOSType:         compute.OperatingSystemTypesWindows,
+		},
+		{
+			description:  "GetVmssFlexImageReference should return nil if the vmss flex has no storage profile",
+			vmssFlexName: "vmssflex1",
+			vmssFlex:     &vmssFlexWithoutStorageProfile,
+		},
+		{
+			description:  "GetVmssFlexImageReference should return nil if the vmss flex has no VM profile",
+			vmssFlexName: "vmssflex1",
+			vmssFlex:     &vmssFlexWithoutVMProfile,
+		},
+		{
+			description:  "GetVmssFlexImageReference should return cloudprovider.InstanceNotFound if the vmss flex is not cached",
+			vmssFlexName: "vmssflex2",
+			vmssFlex:     vmssFlexWithImage(marketplaceImage, compute.OperatingSystemTypesLinux),
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(*tc.vmssFlex.ID, tc.vmssFlex)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+		imageReference, osType, err := fs.GetVmssFlexImageReference(tc.vmssFlexName)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedImageReference, imageReference, tc.description)
+		assert.Equal(t, tc.expectedOSType, osType, tc.description)
+	}
+}
+
+func TestGetVmssFlexProvisioningState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vmssFlexWithProvisioningState := func(provisioningState string) *compute.VirtualMachineScaleSet {
+		vmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+		vmssFlex.ProvisioningState = pointer.String(provisioningState)
+		return &vmssFlex
+	}
+
+	testCases := []struct {
+		description               string
+		vmssFlexName              string
+		vmssFlex                  *compute.VirtualMachineScaleSet
+		expectedProvisioningState string
+		expectedErr               error
+	}{
+		{
+			description:               "GetVmssFlexProvisioningState should return the succeeded state",
+			vmssFlexName:              "vmssflex1",
+			vmssFlex:                  vmssFlexWithProvisioningState("Succeeded"),
+			expectedProvisioningState: "Succeeded",
+		},
+		{
+			description:               "GetVmssFlexProvisioningState should return the updating state",
+			vmssFlexName:              "vmssflex1",
+			vmssFlex:                  vmssFlexWithProvisioningState("Updating"),
+			expectedProvisioningState: "Updating",
+		},
+		{
+			description:               "GetVmssFlexProvisioningState should return the failed state",
+			vmssFlexName:              "vmssflex1",
+			vmssFlex:                  vmssFlexWithProvisioningState("Failed"),
+			expectedProvisioningState: "Failed",
+		},
+		{
+			description:  "GetVmssFlexProvisioningState should return cloudprovider.InstanceNotFound if the vmss flex is not cached",
+			vmssFlexName: "vmssflex2",
+			vmssFlex:     vmssFlexWithProvisioningState("Succeeded"),
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(*tc.vmssFlex.ID, tc.vmssFlex)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+		provisioningState, err := fs.GetVmssFlexProvisioningState(tc.vmssFlexName)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedProvisioningState, provisioningState, tc.description)
+	}
+}
+
+func TestIsTerminalProvisioningState(t *testing.T) {
+	for provisioningState, expected := range map[string]bool{
+		"Succeeded": true,
+		"failed":    true,
+		"Canceled":  true,
+		"Updating":  false,
+		"Creating":  false,
+		"":          false,
+	} {
+		assert.Equal(t, expected, isTerminalProvisioningState(provisioningState), provisioningState)
+	}
+}
+
+func TestGetVmssFlexTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taggedVmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	taggedVmssFlex.Tags = map[string]*string{
+		"costcenter": pointer.String("1234"),
+		"owner":      pointer.String("team-a"),
+		"empty":      nil,
+	}
+	untaggedVmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	untaggedVmssFlex.Tags = nil
+
+	testCases := []struct {
+		description  string
+		vmssFlexName string
+		vmssFlex     *compute.VirtualMachineScaleSet
+		expectedTags map[string]string
+		expectedErr  error
+	}{
+		{
+			description:  "GetVmssFlexTags should return the tags of the vmss flex",
+			vmssFlexName: "vmssflex1",
+			vmssFlex:     &taggedVmssFlex,
+			expectedTags: map[string]string{"costcenter": "1234", "owner": "team-a", "empty": ""},
+		},
+		{
+			description:  "GetVmssFlexTags should return an empty map if the vmss flex is untagged",
+			vmssFlexName: "vmssflex1",
+			vmssFlex:     &untaggedVmssFlex,
+			expectedTags: map[string]string{},
+		},
+		{
+			description:  "GetVmssFlexTags should return cloudprovider.InstanceNotFound if the vmss flex is not cached",
+			vmssFlexName: "vmssflex2",
+			vmssFlex:     &taggedVmssFlex,
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(*tc.vmssFlex.ID, tc.vmssFlex)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+		tags, err := fs.GetVmssFlexTags(tc.vmssFlexName)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedTags, tags, tc.description)
+	}
+
+	// modifying the returned tags should not affect the cache
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	vmssFlexes := &sync.Map{}
+	vmssFlexes.Store(testVmssFlex1ID, &taggedVmssFlex)
+	fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+	tags, err := fs.GetVmssFlexTags("vmssflex1")
+	assert.NoError(t, err)
+	tags["owner"] = "team-b"
+	delete(tags, "costcenter")
+	assert.Equal(t, "team-a", *taggedVmssFlex.Tags["owner"])
+	assert.Equal(t, 3, len(taggedVmssFlex.Tags))
+}
+
+func TestGetVmssFlexIdentities(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	identityID1 := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/identity1"
+	identityID2 := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/identity2"
+
+	systemAssignedVmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	systemAssignedVmssFlex.Identity = &compute.VirtualMachineScaleSetIdentity{
+		Type: compute.ResourceIdentityTypeSystemAssigned,
+	}
+
+	userAssignedVmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	userAssignedVmssFlex.Identity = &compute.VirtualMachineScaleSetIdentity{
+		Type: compute.ResourceIdentityTypeSystemAssignedUserAssigned,
+		UserAssignedIdentities: map[string]*compute.UserAssignedIdentitiesValue{
+			identityID2: {},
+			identityID1: {},
+		},
+	}
+
+	noIdentityVmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	noIdentityVmssFlex.Identity = nil
+
+	testCases := []struct {
+		description            string
+		vmssFlexName           string
+		vmssFlex               *compute.VirtualMachineScaleSet
+		expectedSystemAssigned bool
+		expectedUserAssigned   []string
+		expectedErr            error
+	}{
+		{
+			description:            "GetVmssFlexIdentities should return the system-assigned identity only",
+			vmssFlexName:           "vmssflex1",
+			vmssFlex:               &systemAssignedVmssFlex,
+			expectedSystemAssigned: true,
+			expectedUserAssigned:   []string{},
+		},
+		{
+			description:            "GetVmssFlexIdentities should return the sorted user-assigned identities",
+			vmssFlexName:           "vmssflex1",
+			vmssFlex:               &userAssignedVmssFlex,
+			expectedSystemAssigned: true,
+			expectedUserAssigned:   []string{identityID1, identityID2},
+		},
+		{
+			description:          "GetVmssFlexIdentities should return no identity if the vmss flex has none",
+			vmssFlexName:         "vmssflex1",
+			vmssFlex:             &noIdentityVmssFlex,
+			expectedUserAssigned: []string{},
+		},
+		{
+			description:  "GetVmssFlexIdentities should return cloudprovider.InstanceNotFound if the vmss flex is not cached",
+			vmssFlexName: "vmssflex2",
+			vmssFlex:     &systemAssignedVmssFlex,
+			expectedErr:  cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(*tc.vmssFlex.ID, tc.vmssFlex)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+		systemAssigned, userAssigned, err := fs.GetVmssFlexIdentities(tc.vmssFlexName)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedSystemAssigned, systemAssigned, tc.description)
+		assert.Equal(t, tc.expectedUserAssigned, userAssigned, tc.description)
+	}
+}
+
+func TestGetVmssFlexMemberCount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	vmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlexes := &sync.Map{}
+	vmssFlexes.Store(testVmssFlex1ID, &vmssFlex)
+	fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	count, err := fs.GetVmssFlexMemberCount("vmssflex1")
+	assert.NoError(t, err)
+	assert.Equal(t, len(testVMListWithoutInstanceView), count)
+
+	// the member VMs are counted from the cache without listing them again
+	count, err = fs.GetVmssFlexMemberCount("vmssflex1")
+	assert.NoError(t, err)
+	assert.Equal(t, len(testVMListWithoutInstanceView), count)
+
+	_, err = fs.GetVmssFlexMemberCount("vmssflex2")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+}
+
+func TestNodeResolutionForceRefreshedOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = 100
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(1)
+	_, err = fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(1)
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	// resolving both properties of the missing node should only list once
+	_, err = fs.getNodeNameByVMName("testvm9")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+	assert.True(t, fs.isNodeResolutionJustForceRefreshed())
+	_, err = fs.getNodeVmssFlexID("vmssflex1000009")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+
+	// the resolution after the window should force refresh again
+	time.Sleep(150 * time.Millisecond)
+	assert.False(t, fs.isNodeResolutionJustForceRefreshed())
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+	_, err = fs.getNodeVmssFlexID("vmssflex1000009")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+}
+
+func TestVmssFlexDisableForceRefreshOnNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description          string
+		disabled             bool
+		expectedVmssListings int
+		expectedVMListings   int
+	}{
+		{
+			description:          "the missing node should be force refreshed by default",
+			expectedVmssListings: 3,
+			expectedVMListings:   4,
+		},
+		{
+			description:          "the missing node should be reported as not found from the cache if the force refresh is disabled",
+			disabled:             true,
+			expectedVmssListings: 1,
+			expectedVMListings:   1,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+		fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+		fs.Config.VmssFlexDisableForceRefreshOnNotFound = tc.disabled
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(tc.expectedVmssListings)
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(tc.expectedVMListings)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(tc.expectedVMListings)
+
+		_, err = fs.getNodeNameByVMName("testvm9")
+		assert.Equal(t, cloudprovider.InstanceNotFound, err, tc.description)
+		_, err = fs.getNodeVmssFlexID("vmssflex1000009")
+		assert.Equal(t, cloudprovider.InstanceNotFound, err, tc.description)
+
+		// the cached nodes are still resolved
+		vmssFlexID, err := fs.getNodeVmssFlexID("vmssflex1000001")
+		assert.NoError(t, err, tc.description)
+		assert.Equal(t, testVmssFlex1ID, vmssFlexID, tc.description)
+	}
+}
+
+func TestGetVmssFlexNodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	// the refreshes on miss should not be debounced in this test
+	fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+
+	testVM4Spec := VmssFlexTestVMSpec{
+		VMName:       "testvm4",
+		VMID:         "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm4",
+		ComputerName: "vmssflex2000001",
+		VmssFlexID:   testVmssFlex2ID,
+		NicID:        "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/testvm4-nic",
+	}
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{generateVmssFlexTestVMWithoutInstanceView(testVM4Spec)}, nil).Times(2)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{generateVmssFlexTestVMWithOnlyInstanceView(testVM4Spec)}, nil).Times(2)
+
+	nodeNames, err := fs.GetVmssFlexNodes(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex1000001", "vmssflex1000002", "vmssflex1000003"}, nodeNames)
+
+	nodeNames, err = fs.GetVmssFlexNodes(testVmssFlex2ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex2000001"}, nodeNames)
+
+	// the cached nodes should be returned without listing the VMs again
+	nodeNames, err = fs.GetVmssFlexNodes(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex1000001", "vmssflex1000002", "vmssflex1000003"}, nodeNames)
+
+	// the VMs should be listed again if no nodes of the vmss flex are cached
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex2000001"))
+	nodeNames, err = fs.GetVmssFlexNodes(testVmssFlex2ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex2000001"}, nodeNames)
+}
+
+func TestGetVmssFlexNodesByResourceGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	otherRGVmssFlexID := "subscriptions/sub/resourceGroups/OtherRG/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex3"
+	testVM4Spec := VmssFlexTestVMSpec{
+		VMName:       "testvm4",
+		VMID:         "/subscriptions/sub/resourceGroups/OtherRG/providers/Microsoft.Compute/virtualMachines/testvm4",
+		ComputerName: "vmssflex3000001",
+		VmssFlexID:   otherRGVmssFlexID,
+		NicID:        "/subscriptions/sub/resourceGroups/OtherRG/providers/Microsoft.Network/networkInterfaces/testvm4-nic",
+	}
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), otherRGVmssFlexID).Return([]compute.VirtualMachine{generateVmssFlexTestVMWithoutInstanceView(testVM4Spec)}, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), otherRGVmssFlexID).Return([]compute.VirtualMachine{generateVmssFlexTestVMWithOnlyInstanceView(testVM4Spec)}, nil).Times(1)
+
+	nodeNamesByResourceGroup, err := fs.GetVmssFlexNodesByResourceGroup()
+	assert.NoError(t, err)
+	assert.Empty(t, nodeNamesByResourceGroup, "the VMs should not be listed")
+
+	for _, vmssFlexID := range []string{testVmssFlex1ID, otherRGVmssFlexID} {
+		_, err = fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeDefault)
+		assert.NoError(t, err)
+	}
+	nodeNamesByResourceGroup, err = fs.GetVmssFlexNodesByResourceGroup()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"rg":      {"vmssflex1000001", "vmssflex1000002", "vmssflex1000003"},
+		"otherrg": {"vmssflex3000001"},
+	}, nodeNamesByResourceGroup)
+
+	// the deleted nodes should not be returned
+	assert.NoError(t, fs.DeleteCacheForNodes([]string{"vmssflex1000002", "vmssflex3000001"}))
+	nodeNamesByResourceGroup, err = fs.GetVmssFlexNodesByResourceGroup()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"rg": {"vmssflex1000001", "vmssflex1000003"}}, nodeNamesByResourceGroup)
+
+	fs.Config.DisableAPICallCache = true
+	_, err = fs.GetVmssFlexNodesByResourceGroup()
+	assert.Error(t, err)
+}
+
+// getCacheCounterMetricValue returns the value of the cache counter metric of the given cache name,
+// and of the given values of the other labels if any, e.g. "reason", "nil_os_profile".
+func getCacheCounterMetricValue(t *testing.T, metricName, cacheName string, labelAndValues ...string) float64 {
+	return getCacheMetricValue(t, metricName, cacheName, false, labelAndValues...)
+}
+
+// getCacheGaugeMetricValue is getCacheCounterMetricValue of the cache gauge metrics.
+func getCacheGaugeMetricValue(t *testing.T, metricName, cacheName string, labelAndValues ...string) float64 {
+	return getCacheMetricValue(t, metricName, cacheName, true, labelAndValues...)
+}
+
+func getCacheMetricValue(t *testing.T, metricName, cacheName string, isGauge bool, labelAndValues ...string) float64 {
+	wanted := map[string]string{"cache": cacheName}
+	for i := 0; i+1 < len(labelAndValues); i += 2 {
+		wanted[labelAndValues[i]] = labelAndValues[i+1]
+	}
+
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != metricName {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			matched := 0
+			for _, label := range m.GetLabel() {
+				if value, ok := wanted[label.GetName()]; ok && value == label.GetValue() {
+					matched++
+				}
+			}
+			if matched == len(wanted) {
+				if isGauge {
+					return m.GetGauge().GetValue()
+				}
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestVmssFlexVMSkippedFromCacheCounted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	// the vm being provisioned has no os profile yet
+	vmWithoutOsProfile := generateVmssFlexTestVMWithoutInstanceView(VmssFlexTestVMSpec{
+		VMName:     "testvm4",
+		VMID:       "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm4",
+		VmssFlexID: testVmssFlex1ID,
+	})
+	vmWithoutOsProfile.OsProfile = nil
+	vmList := append(generateTestVMListWithoutInstanceView(), vmWithoutOsProfile)
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(vmList, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	metricName := "cloudprovider_azure_cache_vm_skipped_count"
+	nilOsProfileBefore := getCacheCounterMetricValue(t, metricName, "vmss_flex_vm", "reason", "nil_os_profile")
+	nilComputerNameBefore := getCacheCounterMetricValue(t, metricName, "vmss_flex_vm", "reason", "nil_computer_name")
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, nilOsProfileBefore+1, getCacheCounterMetricValue(t, metricName, "vmss_flex_vm", "reason", "nil_os_profile"))
+	assert.Equal(t, nilComputerNameBefore, getCacheCounterMetricValue(t, metricName, "vmss_flex_vm", "reason", "nil_computer_name"))
+}
+
+func TestVmssFlexCacheGetterPanicRecovered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	panicky := func() {
+		var unexpected interface{} = "unexpected"
+		_ = unexpected.(*compute.VirtualMachineScaleSet)
+	}
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	first := mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName string) ([]compute.VirtualMachineScaleSet, *retry.Error) {
+		panicky()
+		return nil, nil
+	}).Times(1)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).After(first).Times(1)
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	firstVMList := mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, vmssFlexID string) ([]compute.VirtualMachine, *retry.Error) {
+		panicky()
+		return nil, nil
+	}).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).After(firstVMList).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+	vmssFlexPanics := getCacheCounterMetricValue(t, "cloudprovider_azure_cache_getter_panic_count", "vmss_flex")
+	vmssFlexVMPanics := getCacheCounterMetricValue(t, "cloudprovider_azure_cache_getter_panic_count", "vmss_flex_vm")
+
+	// the panics are returned as errors and the entries are refreshed again by the next reads
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeDefault)
+	assert.ErrorContains(t, err, "panic in the vmss_flex cache getter of key vmssflex:rg")
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.ErrorContains(t, err, "panic in the vmss_flex_vm cache getter of key "+testVmssFlex1ID)
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	assert.Equal(t, vmssFlexPanics+1, getCacheCounterMetricValue(t, "cloudprovider_azure_cache_getter_panic_count", "vmss_flex"))
+	assert.Equal(t, vmssFlexVMPanics+1, getCacheCounterMetricValue(t, "cloudprovider_azure_cache_getter_panic_count", "vmss_flex_vm"))
+}
+
+func TestVmssFlexVMCacheGetterPanicLeavesNameMapsUntouched(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(2)
+	first := mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).DoAndReturn(func(ctx context.Context, vmssFlexID string) ([]compute.VirtualMachine, *retry.Error) {
+		var unexpected interface{} = "unexpected"
+		_ = unexpected.(*compute.VirtualMachine)
+		return nil, nil
+	}).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).After(first).Times(1)
+
+	// the panic after listing the VMs leaves the name maps untouched
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.ErrorContains(t, err, "panic in the vmss_flex_vm cache getter of key "+testVmssFlex1ID)
+	_, isCached := fs.vmssFlexVMNameToVmssID.Get("vmssflex1000001")
+	assert.False(t, isCached)
+	_, isCached = fs.vmssFlexIDToNodeNames.Load(testVmssFlex1ID)
+	assert.False(t, isCached)
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	vmssFlexID, isCached := fs.vmssFlexVMNameToVmssID.Get("vmssflex1000001")
+	assert.True(t, isCached)
+	assert.Equal(t, testVmssFlex1ID, vmssFlexID)
+}
+
+func TestNewVmssFlexVMCacheWithDuplicateComputerName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	getDuplicateComputerNameCount := func() float64 {
+		return getCacheCounterMetricValue(t, "cloudprovider_azure_cache_duplicate_computer_name_count", "vmss_flex_vm")
+	}
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	duplicateVMSpec := VmssFlexTestVMSpec{
+		VMName:       "testvm4",
+		VMID:         "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm4",
+		ComputerName: "vmssflex1000001",
+		VmssFlexID:   testVmssFlex2ID,
+		NicID:        "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/testvm4-nic",
+	}
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return([]compute.VirtualMachine{testVMWithoutInstanceView1}, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return([]compute.VirtualMachine{}, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{generateVmssFlexTestVMWithoutInstanceView(duplicateVMSpec)}, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{}, nil).Times(1)
+
+	before := getDuplicateComputerNameCount()
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, before, getDuplicateComputerNameCount(), "the computer name should not be reported before it is duplicated")
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex2ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, getDuplicateComputerNameCount(), "the duplicated computer name should be counted")
+	assert.Equal(t, pointer.String("testvm1"), fs.getOtherVMNameOfNodeName("vmssflex1000001", "testvm4"))
+
+	// the most recently seen mapping is kept, and the overwrite is recorded as ambiguous
+	cachedVmssFlexID, _ := fs.vmssFlexVMNameToVmssID.Get("vmssflex1000001")
+	assert.Equal(t, testVmssFlex2ID, cachedVmssFlexID)
+	assert.Equal(t, []string{testVmssFlex1ID, testVmssFlex2ID}, sets.List(fs.vmssFlexAmbiguousNodeNames["vmssflex1000001"]))
+}
+
+func TestGetVmssFlexNodesInFailedState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	failedVM := generateVmssFlexTestVM(testVM2Spec)
+	failedVM.InstanceView.Statuses = &[]compute.InstanceViewStatus{
+		{Code: pointer.String("ProvisioningState/failed/InternalExecutionError")},
+		{Code: pointer.String("PowerState/running")},
+	}
+	mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm1", compute.InstanceViewTypesInstanceView).Return(generateVmssFlexTestVM(testVM1Spec), nil).Times(1)
+	mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm2", compute.InstanceViewTypesInstanceView).Return(failedVM, nil).Times(1)
+
+	nodeNames, err := fs.GetVmssFlexNodesInFailedState()
+	assert.NoError(t, err)
+	assert.Empty(t, nodeNames, "nothing is cached yet")
+
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	for _, nodeName := range []string{"vmssflex1000001", "vmssflex1000002"} {
+		_, err = fs.getVmssFlexVMInstanceView(nodeName, azcache.CacheReadTypeDefault)
+		assert.NoError(t, err)
+	}
+
+	// the instance view of testvm3 is not cached, so it is unknown rather than failed, and not fetched
+	nodeNames, err = fs.GetVmssFlexNodesInFailedState()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex1000002"}, nodeNames)
+
+	// the expired instance views are still read without calling ARM
+	fakeClock := testingclock.NewFakeClock(time.Now().Add(time.Hour))
+	fs.setClock(fakeClock)
+	nodeNames, err = fs.GetVmssFlexNodesInFailedState()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmssflex1000002"}, nodeNames)
+}
+
+func TestIsVMInstanceViewInFailedState(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		statuses    *[]compute.InstanceViewStatus
+		expected    bool
+	}{
+		{
+			description: "running VM should not be failed",
+			statuses:    &[]compute.InstanceViewStatus{{Code: pointer.String("ProvisioningState/succeeded")}, {Code: pointer.String("PowerState/running")}},
+		},
+		{
+			description: "VM with failed provisioning state should be failed",
+			statuses:    &[]compute.InstanceViewStatus{{Code: pointer.String("ProvisioningState/failed/OSProvisioningTimedOut")}},
+			expected:    true,
+		},
+		{
+			description: "stopped VM should be failed",
+			statuses:    &[]compute.InstanceViewStatus{{Code: pointer.String("ProvisioningState/succeeded")}, {Code: pointer.String("PowerState/stopped")}},
+			expected:    true,
+		},
+		{
+			description: "deallocated VM should be failed",
+			statuses:    &[]compute.InstanceViewStatus{{Code: pointer.String("PowerState/deallocated")}},
+			expected:    true,
+		},
+		{
+			description: "VM without states should not be failed",
+			statuses:    &[]compute.InstanceViewStatus{},
+		},
+		{
+			description: "VM without statuses should not be failed",
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isVMInstanceViewInFailedState(&compute.VirtualMachineInstanceView{Statuses: tc.statuses}))
+		})
+	}
+}
+
+func TestNewVmssFlexCacheWithMaxConcurrentARMRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.MaxConcurrentARMRequests = 2
+
+	var inFlight, maxInFlight int32
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName string) ([]compute.VirtualMachineScaleSet, *retry.Error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return []compute.VirtualMachineScaleSet{}, nil
+	}).Times(6)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(resourceGroup string) {
+			defer wg.Done()
+			_, err := fs.vmssFlexCache.Get(resourceGroup, azcache.CacheReadTypeForceRefresh)
+			assert.NoError(t, err)
+		}(fmt.Sprintf("rg%d", i))
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+}
+
+func TestNewVmssFlexCachePartitionedByResourceGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.SetResourceGroupsSource(func() (sets.Set[string], error) { return sets.New("RG1", "rg2"), nil })
+
+	rg1VmssFlexID := "subscriptions/sub/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1"
+	rg2VmssFlexID := "subscriptions/sub/resourceGroups/rg2/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex2"
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), "rg1").Return([]compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex1", rg1VmssFlexID)}, nil).Times(1)
+	gomock.InOrder(
+		mockVMSSClient.EXPECT().List(gomock.Any(), "rg2").Return([]compute.VirtualMachineScaleSet{}, nil).Times(1),
+		mockVMSSClient.EXPECT().List(gomock.Any(), "rg2").Return([]compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex2", rg2VmssFlexID)}, nil).Times(1),
+	)
+
+	// the name lookups iterate all the partitions
+	vmssFlexID, err := fs.getVmssFlexIDByName("vmssflex1")
+	assert.NoError(t, err)
+	assert.Equal(t, rg1VmssFlexID, vmssFlexID)
+	_, err = fs.getVmssFlexIDByName("vmssflex2")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+
+	// only the partition of the resource group of the new VMSS Flex is refreshed
+	vmssFlex, err := fs.getVmssFlexByVmssFlexID(rg2VmssFlexID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, rg2VmssFlexID, *vmssFlex.ID)
+
+	vmssFlexID, err = fs.getVmssFlexIDByName("vmssflex2")
+	assert.NoError(t, err)
+	assert.Equal(t, rg2VmssFlexID, vmssFlexID)
+
+	_, err = fs.getVmssFlexByVmssFlexID("subscriptions/sub/resourceGroups/rg2/vmssflex2", azcache.CacheReadTypeDefault)
+	assert.ErrorIs(t, err, cloudprovider.InstanceNotFound)
+}
+
+func TestGetVmssFlexVMRetriesRecentlyCreatedNode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newVMSpec := VmssFlexTestVMSpec{
+		VMName:       "testvm4",
+		VMID:         "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm4",
+		ComputerName: "vmssflex1000004",
+		VmssFlexID:   testVmssFlex1ID,
+		NicID:        "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/testvm4-nic",
+	}
+	vmListWithNewVM := append(generateTestVMListWithoutInstanceView(), generateVmssFlexTestVMWithoutInstanceView(newVMSpec))
+
+	testCases := []struct {
+		description       string
+		nodeName          string
+		isRecentlyCreated bool
+		expectedVMName    string
+		expectedErr       error
+		expectedDelay     time.Duration
+	}{
+		{
+			description:       "getVmssFlexVM should retry the recently created node until the VM is listed",
+			nodeName:          "vmssflex1000004",
+			isRecentlyCreated: true,
+			expectedVMName:    "testvm4",
+			expectedDelay:     consts.VmssFlexNewVMRetryMinIntervalInMilliseconds * time.Millisecond,
+		},
+		{
+			description: "getVmssFlexVM should not retry the node which is not recently created",
+			nodeName:    "vmssflex1000004",
+			expectedErr: cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+		fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+		fs.Config.VmssFlexNewVMRetryAttempts = 2
+		fakeClock := testingclock.NewFakeClock(time.Now())
+		fs.setClock(fakeClock)
+		if tc.isRecentlyCreated {
+			fs.nodeCreationTimestamps[tc.nodeName] = fakeClock.Now()
+		}
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+		// the new VM is only listed after the ARM propagation delay
+		start := fakeClock.Now()
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, vmssFlexID string) ([]compute.VirtualMachine, *retry.Error) {
+			if tc.isRecentlyCreated && fakeClock.Since(start) > 100*time.Millisecond {
+				return vmListWithNewVM, nil
+			}
+			return testVMListWithoutInstanceView, nil
+		}).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+		vm, err := fs.getVmssFlexVM(tc.nodeName, azcache.CacheReadTypeDefault)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		assert.Equal(t, tc.expectedVMName, pointer.StringDeref(vm.Name, ""), tc.description)
+		assert.Equal(t, tc.expectedDelay, fakeClock.Since(start), tc.description)
+	}
+}
+
+func TestGetVmssFlexVMWithMaxAge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(2)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(2)
+
+	vm, err := fs.getVmssFlexVMWithMaxAge("vmssflex1000001", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, testVM1, vm)
+
+	// the tolerant callers should be served from the cache
+	vm, err = fs.getVmssFlexVMWithMaxAge("vmssflex1000001", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, testVM1, vm)
+
+	// the VMs should be listed again for the callers requiring fresher data
+	time.Sleep(10 * time.Millisecond)
+	vm, err = fs.getVmssFlexVMWithMaxAge("vmssflex1000001", time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, testVM1, vm)
+
+	_, err = fs.getVmssFlexVMWithMaxAge("vmssflex1000004", time.Hour)
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+}
+
+// fakeFlexCacheStore is an in-memory FlexCacheStore backed by a map.
+type fakeFlexCacheStore struct {
+	lock sync.Mutex
+	data map[string]interface{}
+}
+
+func newFakeFlexCacheStore() *fakeFlexCacheStore {
+	return &fakeFlexCacheStore{data: map[string]interface{}{}}
+}
+
+func (s *fakeFlexCacheStore) Get(key string) (interface{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	value, ok := s.data[key]
+	return value, ok
+}
+
+func (s *fakeFlexCacheStore) Set(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data[key] = value
+}
+
+func (s *fakeFlexCacheStore) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.data, key)
+}
+
+func (s *fakeFlexCacheStore) Range(f func(key string, value interface{}) bool) {
+	s.lock.Lock()
+	data := make(map[string]interface{}, len(s.data))
+	for key, value := range s.data {
+		data[key] = value
+	}
+	s.lock.Unlock()
+	for key, value := range data {
+		if !f(key, value) {
+			return
+		}
+	}
+}
+
+func TestFlexScaleSetWithFakeCacheStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var stores []*fakeFlexCacheStore
+	newStore := func() FlexCacheStore {
+		store := newFakeFlexCacheStore()
+		stores = append(stores, store)
+		return store
+	}
+	vmSet, err := NewFlexScaleSetWithCacheStore(context.Background(), GetTestCloud(ctrl), newStore)
+	assert.NoError(t, err)
+	fs := vmSet.(*FlexScaleSet)
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+	nodeName, err := fs.getNodeNameByVMName("testvm1")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex1000001", nodeName)
+	vmssFlexID, err := fs.getNodeVmssFlexID("vmssflex1000001")
+	assert.NoError(t, err)
+	assert.Equal(t, testVmssFlex1ID, vmssFlexID)
+
+	// the stores are created in the order of vmssFlexVMNameToVmssID and vmssFlexVMNameToNodeName
+	cachedVmssFlexID, ok := stores[0].Get("vmssflex1000001")
+	assert.True(t, ok)
+	assert.Equal(t, testVmssFlex1ID, cachedVmssFlexID)
+	cachedNodeName, ok := stores[1].Get("testvm1")
+	assert.True(t, ok)
+	assert.Equal(t, "vmssflex1000001", cachedNodeName)
+
+	// the node names in the stores are resolved without listing again
+	stores[1].Set("testvm9", "vmssflex1000009")
+	nodeName, err = fs.getNodeNameByVMName("TestVM9")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex1000009", nodeName)
+
+	assert.NoError(t, fs.DeleteCacheForNode("vmssflex1000001"))
+	_, ok = stores[0].Get("vmssflex1000001")
+	assert.False(t, ok, "the deleted node should be removed from the store")
+}
+
+func TestLRUFlexCacheStore(t *testing.T) {
+	store := newLRUFlexCacheStore(2)
+
+	store.Set("node1", "vmssflex1")
+	store.Set("node2", "vmssflex1")
+	// the lookup makes node1 the most recently used
+	value, ok := store.Get("node1")
+	assert.True(t, ok)
+	assert.Equal(t, "vmssflex1", value)
+
+	// node2 is the least recently used and evicted at the cap
+	store.Set("node3", "vmssflex2")
+	_, ok = store.Get("node2")
+	assert.False(t, ok, "the least recently used entry should be evicted")
+	_, ok = store.Get("node1")
+	assert.True(t, ok, "the recently used entry should survive")
+	_, ok = store.Get("node3")
+	assert.True(t, ok)
+
+	// updating the value also counts as the use
+	store.Set("node1", "vmssflex2")
+	store.Set("node4", "vmssflex2")
+	_, ok = store.Get("node3")
+	assert.False(t, ok)
+	value, ok = store.Get("node1")
+	assert.True(t, ok)
+	assert.Equal(t, "vmssflex2", value)
+
+	// the entries could be deleted while ranging
+	var keys []string
+	store.Range(func(key string, _ interface{}) bool {
+		keys = append(keys, key)
+		store.Delete(key)
+		return true
+	})
+	assert.ElementsMatch(t, []string{"node1", "node4"}, keys)
+	_, ok = store.Get("node1")
+	assert.False(t, ok)
+}
+
+func TestFlexScaleSetWithNodeCacheMaxEntries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.Config.VmssFlexNodeCacheMaxEntries = 3
+	// the re-resolution should not reuse the last force refresh
+	az.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+	vmSet, err := newFlexScaleSet(context.Background(), az)
+	assert.NoError(t, err)
+	fs := vmSet.(*FlexScaleSet)
+	assert.IsType(t, &lruFlexCacheStore{}, fs.vmssFlexVMNameToVmssID)
+	assert.IsType(t, &lruFlexCacheStore{}, fs.vmssFlexVMNameToNodeName)
+	assert.IsType(t, &lruFlexCacheStore{}, fs.vmssFlexNodeNameToCachedOn)
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+	nodeName, err := fs.getNodeNameByVMName("testvm1")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex1000001", nodeName)
+
+	// the least recently resolved node is evicted, and resolved again on the next lookup
+	fs.vmssFlexVMNameToNodeName.Set("testvm9", "vmssflex1000009")
+	_, ok := fs.vmssFlexVMNameToNodeName.Get("testvm2")
+	assert.False(t, ok)
+	fs.vmssFlexVMNameToNodeName.Delete("testvm9")
+	nodeName, err = fs.getNodeNameByVMName("testvm2")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex1000002", nodeName)
+}
+
+// unusedFlexCacheStore is a FlexCacheStore failing the test on any use.
+type unusedFlexCacheStore struct {
+	t *testing.T
+}
+
+func (s *unusedFlexCacheStore) Get(key string) (interface{}, bool) {
+	s.t.Errorf("unexpected Get of key %s", key)
+	return nil, false
+}
+
+func (s *unusedFlexCacheStore) Set(key string, _ interface{}) {
+	s.t.Errorf("unexpected Set of key %s", key)
+}
+
+func (s *unusedFlexCacheStore) Delete(key string) {
+	s.t.Errorf("unexpected Delete of key %s", key)
+}
+
+func (s *unusedFlexCacheStore) Range(_ func(key string, value interface{}) bool) {
+	s.t.Errorf("unexpected Range")
+}
+
+func TestVmssFlexLookupsWithAPICallCacheDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.Config.DisableAPICallCache = true
+	vmSet, err := NewFlexScaleSetWithCacheStore(context.Background(), az, func() FlexCacheStore {
+		return &unusedFlexCacheStore{t: t}
+	})
+	assert.NoError(t, err)
+	fs := vmSet.(*FlexScaleSet)
+
+	// every lookup should list the VMs from ARM
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(5)
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(5)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(5)
+
+	nodeName, err := fs.getNodeNameByVMName("TestVM1")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex1000001", nodeName)
+
+	vmssFlexID, err := fs.getNodeVmssFlexID("vmssflex1000002")
+	assert.NoError(t, err)
+	assert.Equal(t, testVmssFlex1ID, vmssFlexID)
+
+	vm, err := fs.getVmssFlexVM("vmssflex1000001", azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, testVM1, vm)
+
+	_, err = fs.getNodeNameByVMName("unknownvm")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+
+	_, err = fs.getNodeVmssFlexID("unknownnode")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+}
+
+func TestVmssFlexCacheWithFakeClock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fs.setClock(fakeClock)
+	timedCache := fs.vmssFlexCache.(*azcache.TimedCache)
+	timedCache.TTLJitter = 0
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(4)
+	key := getVmssFlexCachePartitionKeyByResourceGroup("rg")
+
+	// the entry expires at the TTL
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	fakeClock.Step(timedCache.TTL - time.Second)
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	fakeClock.Step(time.Second)
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	// the force refresh within the debounce window reuses the last one
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	fakeClock.Step(consts.VmssFlexForceRefreshDebounceDefaultInMilliseconds*time.Millisecond - time.Millisecond)
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	fakeClock.Step(time.Millisecond)
+	_, err = fs.getVmssFlexCacheEntry(key, azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+}
+
+func TestVmssFlexCachePartitionKeyNamespaced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ss, err := NewTestScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test ScaleSet")
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	// the flex and uniform caches share the same store
+	fs.vmssFlexCache.(*azcache.TimedCache).Store = ss.vmssCache.GetStore()
+
+	for _, resourceGroup := range []string{"rg", strings.ToLower(consts.VMSSKey)} {
+		uniformVmss := &sync.Map{}
+		uniformVmss.Store("vmss", &VMSSEntry{ResourceGroup: resourceGroup})
+		ss.vmssCache.Set(resourceGroup, uniformVmss)
+		ss.vmssCache.Set(consts.VMSSKey, uniformVmss)
+
+		vmssFlexes := &sync.Map{}
+		vmssFlexes.Store(testVmssFlex1ID, &testVmssFlex1)
+		fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup(resourceGroup), vmssFlexes)
+
+		for _, key := range []string{resourceGroup, consts.VMSSKey} {
+			cached, err := ss.vmssCache.Get(key, azcache.CacheReadTypeUnsafe)
+			assert.NoError(t, err)
+			_, isUniform := cached.(*sync.Map).Load("vmss")
+			assert.True(t, isUniform, "the uniform entry %s should not be overwritten by the flex entry", key)
+		}
+
+		cached, err := fs.getVmssFlexCacheEntry(getVmssFlexCachePartitionKeyByResourceGroup(resourceGroup), azcache.CacheReadTypeUnsafe)
+		assert.NoError(t, err)
+		_, isUniform := cached.(*sync.Map).Load("vmss")
+		assert.False(t, isUniform, "the flex entry of %s should not be overwritten by the uniform entry", resourceGroup)
+		vmssFlex, ok := cached.(*sync.Map).Load(testVmssFlex1ID)
+		assert.True(t, ok)
+		assert.Equal(t, &testVmssFlex1, vmssFlex)
+	}
+}
+
+func TestGetVmssFlexCachePartitionKey(t *testing.T) {
+	partitionKey, err := getVmssFlexCachePartitionKey("/subscriptions/sub/resourceGroups/RG/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex:rg", partitionKey)
+	assert.Equal(t, "rg", getResourceGroupByVmssFlexCachePartitionKey(partitionKey))
+
+	_, err = getVmssFlexCachePartitionKey("vmssflex1")
+	assert.ErrorIs(t, err, cloudprovider.InstanceNotFound)
+}
+
+func TestGetNodeNameByPrivateIP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+	dualStackNic := generateTestNic("testvm1-nic", false, network.ProvisioningStateSucceeded, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm1")
+	dualStackNic.IPConfigurations = &[]network.InterfaceIPConfiguration{
+		{InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{Primary: pointer.Bool(true), PrivateIPAddress: pointer.String("10.0.0.4")}},
+		{InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{PrivateIPAddress: pointer.String("10.0.0.5")}},
+		{InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{PrivateIPAddress: pointer.String("fd00::0004")}},
+	}
+	updatedNic := generateTestNic("testvm1-nic", false, network.ProvisioningStateSucceeded, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm1")
+	updatedNic.IPConfigurations = &[]network.InterfaceIPConfiguration{
+		{InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{Primary: pointer.Bool(true), PrivateIPAddress: pointer.String("10.0.0.4")}},
+	}
+	mockInterfacesClient := fs.InterfacesClient.(*mockinterfaceclient.MockInterface)
+	first := mockInterfacesClient.EXPECT().Get(gomock.Any(), gomock.Any(), "testvm1-nic", gomock.Any()).Return(dualStackNic, nil).Times(1)
+	mockInterfacesClient.EXPECT().Get(gomock.Any(), gomock.Any(), "testvm1-nic", gomock.Any()).Return(updatedNic, nil).After(first).Times(1)
+
+	// the NIC of the node has not been fetched yet
+	_, err = fs.GetNodeNameByPrivateIP("10.0.0.4")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+
+	// all the IPs of the NIC are resolved once it is fetched
+	_, err = fs.GetPrivateIPsByNodeName(testNodeName1)
+	assert.NoError(t, err)
+	for _, ip := range []string{"10.0.0.4", "10.0.0.5", "fd00::4"} {
+		nodeName, err := fs.GetNodeNameByPrivateIP(ip)
+		assert.NoError(t, err, ip)
+		assert.Equal(t, testNodeName1, nodeName, ip)
+	}
+
+	// the IPs released by the node are no longer resolved
+	_, err = fs.GetPrivateIPsByNodeName(testNodeName1)
+	assert.NoError(t, err)
+	_, err = fs.GetNodeNameByPrivateIP("10.0.0.5")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+
+	// the IPs reported by the nodes are used as the fallback
+	fs.nodePrivateIPToNodeNameMap = map[string]string{"10.0.1.4": "node"}
+	nodeName, err := fs.GetNodeNameByPrivateIP("10.0.1.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "node", nodeName)
+
+	// the IPs are evicted with the node
+	assert.NoError(t, fs.DeleteCacheForNode(testNodeName1))
+	_, err = fs.GetNodeNameByPrivateIP("10.0.0.4")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+}
+
+func TestGetVmssFlexByID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	// cached hit
+	vmssFlexes := &sync.Map{}
+	vmssFlexes.Store(testVmssFlex1ID, &testVmssFlex1)
+	fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+	vmssFlex, err := fs.GetVmssFlexByID(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, &testVmssFlex1, vmssFlex)
+
+	// the miss should force refresh the partition once
+	vmssFlex2ID := "subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex2"
+	vmssFlex2 := genreteTestVmssFlex("vmssflex2", vmssFlex2ID)
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), "rg").Return([]compute.VirtualMachineScaleSet{testVmssFlex1, vmssFlex2}, nil).Times(1)
+	vmssFlex, err = fs.GetVmssFlexByID(vmssFlex2ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex2", *vmssFlex.Name)
+
+	// the vmss flex which is still not found after the refresh, which is debounced after the last one
+	_ = fs.vmssFlexCache.Delete(getVmssFlexCachePartitionKeyByResourceGroup("rg"))
+	mockVMSSClient.EXPECT().List(gomock.Any(), "rg").Return([]compute.VirtualMachineScaleSet{testVmssFlex1}, nil).Times(1)
+	_, err = fs.GetVmssFlexByID(vmssFlex2ID, azcache.CacheReadTypeDefault)
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+}
+
+func TestRefreshVmssFlexCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+
+	testVmssFlex2 := genreteTestVmssFlex("vmssflex2", testVmssFlex2ID)
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	gomock.InOrder(
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachineScaleSet{testVmssFlex1}, nil),
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachineScaleSet{testVmssFlex1, testVmssFlex2}, nil),
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachineScaleSet{testVmssFlex2}, nil),
+	)
+
+	_, err = fs.getVmssFlexes(azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	added, removed, err := fs.RefreshVmssFlexCache()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{testVmssFlex2ID}, added)
+	assert.Empty(t, removed)
+
+	added, removed, err = fs.RefreshVmssFlexCache()
+	assert.NoError(t, err)
+	assert.Empty(t, added)
+	assert.Equal(t, []string{testVmssFlex1ID}, removed)
+
+	vmssFlex, err := fs.GetVmssFlexByID(testVmssFlex2ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, &testVmssFlex2, vmssFlex)
+}
+
+func TestInvalidateVmssFlexByID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(1)
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	_, err = fs.GetVmssFlexByID(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	_, err = fs.vmssFlexVMCache.Get(testVmssFlex1ID, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+
+	var evicted []string
+	fs.SetOnNodeCacheEvicted(func(nodeName, _, _ string) {
+		evicted = append(evicted, nodeName)
+	})
+
+	// the ID not cached is a no-op
+	assert.NoError(t, fs.InvalidateVmssFlexByID(testVmssFlex2ID))
+	assert.Empty(t, evicted)
+
+	assert.NoError(t, fs.InvalidateVmssFlexByID(strings.ToUpper(testVmssFlex1ID)))
+	assert.ElementsMatch(t, []string{"vmssflex1000001", "vmssflex1000002", "vmssflex1000003"}, evicted)
+
+	_, ok := fs.peekVmssFlexCachePartition("rg").Load(testVmssFlex1ID)
+	assert.False(t, ok, "the vmss flex should be removed from the cache")
+	_, exists, err := fs.vmssFlexVMCache.GetStore().GetByKey(testVmssFlex1ID)
+	assert.NoError(t, err)
+	assert.False(t, exists, "the vm cache of the vmss flex should be removed")
+	_, isCached := fs.vmssFlexIDToNodeNames.Load(testVmssFlex1ID)
+	assert.False(t, isCached, "the node names of the vmss flex should be removed")
+	for _, nodeName := range []string{"vmssflex1000001", "vmssflex1000002", "vmssflex1000003"} {
+		_, isCached := fs.vmssFlexVMNameToVmssID.Get(nodeName)
+		assert.False(t, isCached, "node %s should be removed from the name map", nodeName)
+		_, isCached = fs.GetVmssFlexNodeCacheAge(nodeName)
+		assert.False(t, isCached, "node %s should be removed from the cached-on map", nodeName)
+	}
+	for _, vmName := range []string{"testvm1", "testvm2", "testvm3"} {
+		_, isCached := fs.vmssFlexVMNameToNodeName.Get(vmName)
+		assert.False(t, isCached, "vm %s should be removed from the name map", vmName)
+	}
+
+	// the invalidation is a no-op if the API call cache is disabled
+	fs.vmssFlexVMNameToVmssID.Set("vmssflex1000001", testVmssFlex1ID)
+	fs.Config.DisableAPICallCache = true
+	assert.NoError(t, fs.InvalidateVmssFlexByID(testVmssFlex1ID))
+	_, isCached = fs.vmssFlexVMNameToVmssID.Get("vmssflex1000001")
+	assert.True(t, isCached, "the cache should not be touched if the API call cache is disabled")
+}
+
+func TestExportVmssFlexCacheSnapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fs.setClock(fakeClock)
+
+	vmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	vmssFlex.Tags = map[string]*string{"pool": pointer.String("system")}
+	vmssFlexes := &sync.Map{}
+	vmssFlexes.Store(testVmssFlex1ID, &vmssFlex)
+	fs.vmssFlexCache.Set(getVmssFlexCachePartitionKeyByResourceGroup("rg"), vmssFlexes)
+	fs.vmssFlexVMNameToVmssID.Set("vmssflex1000001", testVmssFlex1ID)
+	fs.vmssFlexVMNameToNodeName.Set("testvm1", "vmssflex1000001")
+	fs.vmssFlexNodeNameToCachedOn.Set("vmssflex1000001", fakeClock.Now())
+
+	snapshot, err := fs.ExportVmssFlexCacheSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, fakeClock.Now(), snapshot.TakenOn)
+	assert.Equal(t, 1, len(snapshot.RefreshedOn))
+	assert.True(t, fakeClock.Now().Equal(snapshot.RefreshedOn["rg"]))
+	assert.Equal(t, &vmssFlex, snapshot.VmssFlexes[testVmssFlex1ID])
+	assert.Equal(t, map[string]string{"vmssflex1000001": testVmssFlex1ID}, snapshot.NodeNameToVmssFlexID)
+	assert.Equal(t, map[string]string{"testvm1": "vmssflex1000001"}, snapshot.VMNameToNodeName)
+	assert.Equal(t, map[string]time.Time{"vmssflex1000001": fakeClock.Now()}, snapshot.NodeNameToCachedOn)
+	_, err = json.Marshal(snapshot)
+	assert.NoError(t, err)
+
+	// the later cache mutations do not affect the exported snapshot
+	*vmssFlex.Tags["pool"] = "spot"
+	vmssFlex.Zones = &[]string{"1"}
+	vmssFlexes.Store(testVmssFlex2ID, &testVmssFlex1)
+	fs.vmssFlexVMNameToVmssID.Set("vmssflex1000001", testVmssFlex2ID)
+	fs.vmssFlexVMNameToNodeName.Delete("testvm1")
+	assert.Equal(t, "system", *snapshot.VmssFlexes[testVmssFlex1ID].Tags["pool"])
+	assert.Nil(t, snapshot.VmssFlexes[testVmssFlex1ID].Zones)
+	assert.Equal(t, 1, len(snapshot.VmssFlexes))
+	assert.Equal(t, testVmssFlex1ID, snapshot.NodeNameToVmssFlexID["vmssflex1000001"])
+	assert.Equal(t, "vmssflex1000001", snapshot.VMNameToNodeName["testvm1"])
+}
+
+func TestGetVmssFlexVMByNodeName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description     string
+		nodeName        string
+		vmssFlexListErr *retry.Error
+		expectedVMName  string
+		expectedErr     error
+		expectedErrMsg  string
+	}{
+		{
+			description:    "GetVmssFlexVMByNodeName should return the cached VM of the node",
+			nodeName:       "vmssflex1000001",
+			expectedVMName: "testvm1",
+		},
+		{
+			description:    "GetVmssFlexVMByNodeName should return InstanceNotFound if the node is not in any vmss flex",
+			nodeName:       "vmssflex1000004",
+			expectedErr:    cloudprovider.InstanceNotFound,
+			expectedErrMsg: "failed to get the vmss flex of node vmssflex1000004",
+		},
+		{
+			description:     "GetVmssFlexVMByNodeName should return the error of listing the vmss flex",
+			nodeName:        "vmssflex1000001",
+			vmssFlexListErr: &retry.Error{RawError: fmt.Errorf("list vmss error")},
+			expectedErrMsg:  "failed to get the vmss flex of node vmssflex1000001",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			fs, err := NewTestFlexScaleSet(ctrl)
+			assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+			mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+			mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+			mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+			mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+			mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, tc.vmssFlexListErr).AnyTimes()
+
+			vm, err := fs.GetVmssFlexVMByNodeName(tc.nodeName, azcache.CacheReadTypeDefault)
+			if tc.expectedErrMsg != "" {
+				assert.ErrorContains(t, err, tc.expectedErrMsg)
+				if tc.expectedErr != nil {
+					assert.ErrorIs(t, err, tc.expectedErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedVMName, pointer.StringDeref(vm.Name, ""))
+		})
+	}
+
+	// the vmss flex of the node is resolved once, so the VMs are only listed once without the API call cache
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Config.DisableAPICallCache = true
+	fs.vmssFlexCache, err = fs.newVmssFlexCache(context.Background())
+	assert.NoError(t, err)
+	fs.vmssFlexVMCache, err = fs.newVmssFlexVMCache(context.Background())
+	assert.NoError(t, err)
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+	vm, err := fs.GetVmssFlexVMByNodeName("vmssflex1000001", azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, "testvm1", pointer.StringDeref(vm.Name, ""))
+}
+
+func TestGetNodeNameByVmssFlexInstanceID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithoutInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{}, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{}, nil).Times(1)
+
+	nodeName, err := fs.GetNodeNameByVmssFlexInstanceID(testVmssFlex1ID, "TestVM2")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex1000002", nodeName)
+
+	// the index is built when the VMs are listed
+	cached, isCached := fs.vmssFlexIDToInstanceIDIndex.Load(strings.ToLower(testVmssFlex1ID))
+	assert.True(t, isCached)
+	assert.Equal(t, "vmssflex1000003", cached.(*vmssFlexInstanceIDIndex).nodeNames["testvm3"])
+
+	// the cached instance is resolved without listing the VMs again
+	nodeName, err = fs.GetNodeNameByVmssFlexInstanceID(testVmssFlex1ID, "testvm3")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmssflex1000003", nodeName)
+
+	// the index built for other VMs than the cached ones is rebuilt
+	vms, err := fs.getVmssFlexVMCacheEntry(testVmssFlex1ID, azcache.CacheReadTypeUnsafe)
+	assert.NoError(t, err)
+	fs.vmssFlexIDToInstanceIDIndex.Store(strings.ToLower(testVmssFlex1ID), newVmssFlexInstanceIDIndex(&sync.Map{}))
+	assert.Equal(t, "vmssflex1000003", fs.getVmssFlexInstanceIDIndex(testVmssFlex1ID, vms.(*sync.Map)).nodeNames["testvm3"])
+
+	_, err = fs.GetNodeNameByVmssFlexInstanceID(testVmssFlex1ID, "testvm9")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+
+	// the instance of another vmss flex is not found
+	_, err = fs.GetNodeNameByVmssFlexInstanceID(testVmssFlex2ID, "testvm1")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+
+	_, err = fs.GetNodeNameByVmssFlexInstanceID("malformed", "testvm1")
+	assert.ErrorIs(t, err, cloudprovider.InstanceNotFound)
+}
+
+func TestVmssFlexComputerNameStripPattern(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fqdnVMSpec := testVM1Spec
+	fqdnVMSpec.ComputerName = "VMSSFLEX1000001.internal.cloudapp.net"
+
+	testCases := []struct {
+		description      string
+		pattern          string
+		expectedNodeName string
+	}{
+		{
+			description:      "the lower-case computer name should be the node name if the pattern is not set",
+			expectedNodeName: "vmssflex1000001.internal.cloudapp.net",
+		},
+		{
+			description:      "the domain suffix should be stripped from the node name",
+			pattern:          `\.internal\.cloudapp\.net$`,
+			expectedNodeName: "vmssflex1000001",
+		},
+		{
+			description:      "the domain should be stripped from the node name",
+			pattern:          `\..*$`,
+			expectedNodeName: "vmssflex1000001",
+		},
+		{
+			description:      "the computer name should be kept if nothing is left after stripping",
+			pattern:          `.*`,
+			expectedNodeName: "vmssflex1000001.internal.cloudapp.net",
+		},
+	}
+
+	for _, tc := range testCases {
+		az := GetTestCloud(ctrl)
+		az.Config.VmssFlexComputerNameStripPattern = tc.pattern
+		vmSet, err := newFlexScaleSet(context.Background(), az)
+		assert.NoError(t, err, tc.description)
+		fs := vmSet.(*FlexScaleSet)
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return([]compute.VirtualMachine{generateVmssFlexTestVMWithoutInstanceView(fqdnVMSpec)}, nil).Times(1)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return([]compute.VirtualMachine{generateVmssFlexTestVMWithOnlyInstanceView(fqdnVMSpec)}, nil).Times(1)
+
+		nodeName, err := fs.getNodeNameByVMName("testvm1")
+		assert.NoError(t, err, tc.description)
+		assert.Equal(t, tc.expectedNodeName, nodeName, tc.description)
+
+		vmssFlexID, err := fs.getNodeVmssFlexID(tc.expectedNodeName)
+		assert.NoError(t, err, tc.description)
+		assert.Equal(t, testVmssFlex1ID, vmssFlexID, tc.description)
+	}
+}
+
+func TestVmssFlexComputerNameStripPatternInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.Config.VmssFlexComputerNameStripPattern = `(`
+	_, err := newFlexScaleSet(context.Background(), az)
+	assert.ErrorContains(t, err, "invalid vmssFlexComputerNameStripPattern")
+}
+
+func TestVmssFlexVMCacheTTLOverrides(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.Config.VmssFlexVMCacheTTLInSeconds = 600
+	az.Config.VmssFlexCacheTTLJitter = -1
+	az.Config.VmssFlexVMCacheTTLOverrides = []VmssFlexVMCacheTTLOverride{
+		{ScaleSetNamePattern: "^spot", TTLInSeconds: 60},
+		{ScaleSetNamePattern: "^system", TTLInSeconds: 3600},
+	}
+	vmSet, err := newFlexScaleSet(context.Background(), az)
+	assert.NoError(t, err)
+	fs := vmSet.(*FlexScaleSet)
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fs.setClock(fakeClock)
+
+	spotVmssFlexID := "subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/SpotPool1"
+	systemVmssFlexID := "subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/systempool1"
+	assert.Equal(t, 60*time.Second, fs.getVmssFlexVMCacheTTL(spotVmssFlexID))
+	assert.Equal(t, time.Hour, fs.getVmssFlexVMCacheTTL(systemVmssFlexID))
+	assert.Equal(t, 600*time.Second, fs.getVmssFlexVMCacheTTL(testVmssFlex1ID))
+
+	for _, vmssFlexID := range []string{spotVmssFlexID, systemVmssFlexID, testVmssFlex1ID} {
+		fs.vmssFlexVMCache.Set(vmssFlexID, &sync.Map{})
+	}
+
+	// only the VMs of the matched pool with the shorter TTL are refreshed
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), spotVmssFlexID).Return([]compute.VirtualMachine{}, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), spotVmssFlexID).Return([]compute.VirtualMachine{}, nil).Times(1)
+	fakeClock.Step(time.Minute)
+	for _, vmssFlexID := range []string{spotVmssFlexID, systemVmssFlexID, testVmssFlex1ID} {
+		_, err = fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeDefault)
+		assert.NoError(t, err)
+	}
+
+	// the VMs of the unmatched pool fall back to the default TTL
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachine{}, nil).Times(2)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachine{}, nil).Times(2)
+	fakeClock.Step(9 * time.Minute)
+	for _, vmssFlexID := range []string{spotVmssFlexID, systemVmssFlexID, testVmssFlex1ID} {
+		_, err = fs.vmssFlexVMCache.Get(vmssFlexID, azcache.CacheReadTypeDefault)
+		assert.NoError(t, err)
+	}
+}
+
+func TestVmssFlexVMCacheTTLOverridesInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	az := GetTestCloud(ctrl)
+	az.Config.VmssFlexVMCacheTTLOverrides = []VmssFlexVMCacheTTLOverride{{ScaleSetNamePattern: `(`, TTLInSeconds: 60}}
+	_, err := newFlexScaleSet(context.Background(), az)
+	assert.ErrorContains(t, err, "invalid scaleSetNamePattern")
+
+	az.Config.VmssFlexVMCacheTTLOverrides = []VmssFlexVMCacheTTLOverride{{ScaleSetNamePattern: "^spot"}}
+	_, err = newFlexScaleSet(context.Background(), az)
+	assert.ErrorContains(t, err, "must be positive")
+}
+
+func TestGetNodeAddressesFromCache(t *testing.T) {
+	vmListWithoutNics := generateTestVMListWithoutInstanceView()
+	vmListWithoutNics[0].NetworkProfile = nil
+
+	dualStackNic := generateTestNic("testvm1-nic", false, network.ProvisioningStateSucceeded, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm1")
+	dualStackNic.IPConfigurations = &[]network.InterfaceIPConfiguration{
+		{InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{PrivateIPAddress: pointer.String("fd00::4")}},
+		{InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{Primary: pointer.Bool(true), PrivateIPAddress: pointer.String("10.0.0.4")}},
+	}
+	publicIPNic := generateTestNic("testvm1-nic", false, network.ProvisioningStateSucceeded, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm1")
+	publicIPNic.IPConfigurations = &[]network.InterfaceIPConfiguration{
+		{InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+			Primary:          pointer.Bool(true),
+			PrivateIPAddress: pointer.String("10.0.0.4"),
+			PublicIPAddress:  &network.PublicIPAddress{ID: pointer.String("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/publicIPAddresses/pip")},
+		}},
+	}
+
+	testCases := []struct {
+		description       string
+		vmList            []compute.VirtualMachine
+		nic               *network.Interface
+		expectedAddresses []v1.NodeAddress
+		expectedErr       error
+	}{
+		{
+			description: "GetNodeAddressesFromCache should return the cached private IPs of the primary NIC and the hostname",
+			vmList:      testVMListWithoutInstanceView,
+			nic:         &dualStackNic,
+			expectedAddresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.4"},
+				{Type: v1.NodeInternalIP, Address: "fd00::4"},
+				{Type: v1.NodeHostName, Address: testNodeName1},
+			},
+		},
+		{
+			description: "GetNodeAddressesFromCache should return an error if the NIC has not been fetched",
+			vmList:      testVMListWithoutInstanceView,
+			expectedErr: ErrorVmssFlexNodeAddressesNotCached,
+		},
+		{
+			description: "GetNodeAddressesFromCache should return an error if the NIC has a public IP",
+			vmList:      testVMListWithoutInstanceView,
+			nic:         &publicIPNic,
+			expectedErr: ErrorVmssFlexNodeAddressesNotCached,
+		},
+		{
+			description: "GetNodeAddressesFromCache should return an error if the cached VM has no NIC",
+			vmList:      vmListWithoutNics,
+			nic:         &dualStackNic,
+			expectedErr: ErrorVmssFlexNodeAddressesNotCached,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fs, err := NewTestFlexScaleSet(ctrl)
+			assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+			mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+			mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+			mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+			mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(tc.vmList, nil).AnyTimes()
+			mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+			if tc.nic != nil {
+				fs.cacheNodePrivateIPs(testNodeName1, *tc.nic)
+			}
+
+			addresses, err := fs.GetNodeAddressesFromCache(testNodeName1)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedAddresses, addresses)
+		})
+	}
+}
+
+func TestGetVmssFlexesResourceGroupRotation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+	fs.Config.VmssFlexCacheMaxResourceGroupsPerRefresh = 2
+	fs.SetResourceGroupsSource(func() (sets.Set[string], error) { return sets.New("rg1", "RG2", "rg3"), nil })
+
+	rg2VmssFlexID := "subscriptions/sub/resourceGroups/rg2/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex2"
+	var listedRGs []string
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, rg string) ([]compute.VirtualMachineScaleSet, *retry.Error) {
+		listedRGs = append(listedRGs, rg)
+		if rg == "rg2" {
+			return []compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex2", rg2VmssFlexID)}, nil
+		}
+		return []compute.VirtualMachineScaleSet{}, nil
+	}).AnyTimes()
+
+	// the partitions in the round are read in the sorted order
+	for _, expected := range [][]string{{"rg1", "rg2"}, {"rg1", "rg3"}, {"rg2", "rg3"}} {
+		listedRGs = nil
+		vmssFlexes, err := fs.getVmssFlexes(azcache.CacheReadTypeForceRefresh)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, listedRGs)
+
+		// the cached vmss flex of rg2 is served even if rg2 is not in the round
+		_, ok := vmssFlexes.Load(rg2VmssFlexID)
+		assert.True(t, ok)
+	}
+
+	// the unsafe reads list nothing and do not rotate
+	listedRGs = nil
+	_, err = fs.getVmssFlexes(azcache.CacheReadTypeUnsafe)
+	assert.NoError(t, err)
+	assert.Empty(t, listedRGs)
+	assert.Equal(t, 0, fs.vmssFlexResourceGroupCursor)
+
+	// all the resource groups are read if they do not exceed the limit
+	fs.Config.VmssFlexCacheMaxResourceGroupsPerRefresh = 3
+	listedRGs = nil
+	_, err = fs.getVmssFlexes(azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rg1", "rg2", "rg3"}, listedRGs)
+	assert.True(t, fs.isInLastVmssFlexResourceGroupRound("rg1"))
+}
+
+func TestGetNodeVmssFlexIDOutOfRound(t *testing.T) {
+	testCases := []struct {
+		description        string
+		nodeName           string
+		cursor             int
+		expectedListedRGs  []string
+		expectedVmssFlexID string
+		expectedErr        error
+	}{
+		{
+			description:        "getNodeVmssFlexID should list the resource group of the node if it was not in the round",
+			nodeName:           testNodeName1,
+			cursor:             1,
+			expectedListedRGs:  []string{"rga", "rgb", "rg"},
+			expectedVmssFlexID: testVmssFlex1ID,
+		},
+		{
+			description:       "getNodeVmssFlexID should not list the resource group of the node again if it was in the round",
+			nodeName:          "vmssflex1000009",
+			cursor:            2,
+			expectedListedRGs: []string{"rgb", "rg"},
+			expectedErr:       cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fs, err := NewTestFlexScaleSet(ctrl)
+			assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+			fs.Config.VmssFlexForceRefreshDebounceInMilliseconds = -1
+			fs.Config.VmssFlexCacheMaxResourceGroupsPerRefresh = 1
+			fs.SetResourceGroupsSource(func() (sets.Set[string], error) { return sets.New("rg", "rga", "rgb"), nil })
+			fs.vmssFlexResourceGroupCursor = tc.cursor
+
+			var listedRGs []string
+			mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+			mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, rg string) ([]compute.VirtualMachineScaleSet, *retry.Error) {
+				listedRGs = append(listedRGs, rg)
+				if rg == "rg" {
+					return testVmssFlexList, nil
+				}
+				return []compute.VirtualMachineScaleSet{}, nil
+			}).AnyTimes()
+			mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+			mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+			mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+			vmssFlexID, err := fs.getNodeVmssFlexID(tc.nodeName)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedVmssFlexID, vmssFlexID)
+			assert.Equal(t, tc.expectedListedRGs, listedRGs)
+		})
+	}
+}
+
+func TestGetVmssFlexVMInOtherResourceGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.SetResourceGroupsSource(func() (sets.Set[string], error) { return sets.New("rg", "OtherRG"), nil })
+
+	otherVmssFlexID := "subscriptions/sub/resourceGroups/OtherRG/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1"
+	vmList := generateTestVMListWithoutInstanceView()
+	for i := range vmList {
+		vmList[i].ID = pointer.String(strings.Replace(*vmList[i].ID, "/resourceGroups/rg/", "/resourceGroups/OtherRG/", 1))
+	}
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), "rg").Return([]compute.VirtualMachineScaleSet{}, nil).Times(1)
+	mockVMSSClient.EXPECT().List(gomock.Any(), "otherrg").Return([]compute.VirtualMachineScaleSet{genreteTestVmssFlex("vmssflex1", otherVmssFlexID)}, nil).Times(1)
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), otherVmssFlexID).Return(vmList, nil).Times(1)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), otherVmssFlexID).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+	vm, err := fs.getVmssFlexVM(testNodeName1, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, "/subscriptions/sub/resourceGroups/OtherRG/providers/Microsoft.Compute/virtualMachines/testvm1", pointer.StringDeref(vm.ID, ""))
+
+	resourceGroup, err := fs.GetResolvedResourceGroup(testNodeName1)
+	assert.NoError(t, err)
+	assert.Equal(t, "OtherRG", resourceGroup)
+}
+
+func TestGetVmssFlexRefreshStatuses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fs.setClock(fakeClock)
+
+	forbidden := &retry.Error{HTTPStatusCode: http.StatusForbidden, RawError: fmt.Errorf("forbidden")}
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), "rg1").Return(testVmssFlexList, nil).Times(1)
+	mockVMSSClient.EXPECT().List(gomock.Any(), "rg2").Return(nil, forbidden).Times(2)
+
+	// rg1 succeeds while rg2 fails
+	succeededOn := fakeClock.Now()
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg1"), azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg2"), azcache.CacheReadTypeForceRefresh)
+	assert.Error(t, err)
+
+	statuses := fs.GetVmssFlexRefreshStatuses()
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, VmssFlexResourceGroupRefreshStatus{LastSucceededOn: succeededOn}, statuses["rg1"])
+	assert.True(t, statuses["rg2"].LastSucceededOn.IsZero())
+	assert.Equal(t, succeededOn, statuses["rg2"].LastFailedOn)
+	assert.ErrorContains(t, statuses["rg2"].LastError, "forbidden")
+	assert.Equal(t, float64(0), getCacheGaugeMetricValue(t, "cloudprovider_azure_cache_refresh_staleness_seconds", "vmss_flex", "resource_group", "rg1"))
+	assert.Equal(t, float64(-1), getCacheGaugeMetricValue(t, "cloudprovider_azure_cache_refresh_staleness_seconds", "vmss_flex", "resource_group", "rg2"))
+
+	// rg1 becomes stale once its refresh fails after the last success
+	mockVMSSClient.EXPECT().List(gomock.Any(), "rg1").Return(nil, forbidden).Times(1)
+	fakeClock.Step(time.Minute)
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg1"), azcache.CacheReadTypeForceRefresh)
+	assert.Error(t, err)
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg2"), azcache.CacheReadTypeForceRefresh)
+	assert.Error(t, err)
 
+	statuses = fs.GetVmssFlexRefreshStatuses()
+	assert.Equal(t, succeededOn, statuses["rg1"].LastSucceededOn)
+	assert.Equal(t, fakeClock.Now(), statuses["rg1"].LastFailedOn)
+	assert.Error(t, statuses["rg1"].LastError)
+	assert.Equal(t, float64(60), getCacheGaugeMetricValue(t, "cloudprovider_azure_cache_refresh_staleness_seconds", "vmss_flex", "resource_group", "rg1"))
+	assert.Equal(t, float64(-1), getCacheGaugeMetricValue(t, "cloudprovider_azure_cache_refresh_staleness_seconds", "vmss_flex", "resource_group", "rg2"))
 }
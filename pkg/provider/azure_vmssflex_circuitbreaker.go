@@ -0,0 +1,178 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
+	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
+	"sigs.k8s.io/cloud-provider-azure/pkg/metrics"
+)
+
+// circuitBreakerState is the state of a circuitBreaker, whose value is reported by the metric.
+type circuitBreakerState int
+
+const (
+	// circuitBreakerClosed lets all the calls through.
+	circuitBreakerClosed circuitBreakerState = iota
+	// circuitBreakerOpen fails all the calls fast until the cooldown elapses.
+	circuitBreakerOpen
+	// circuitBreakerHalfOpen lets a single trial call through to test the recovery.
+	circuitBreakerHalfOpen
+)
+
+// circuitBreaker opens after threshold consecutive failures, so that the calls fail fast with
+// ErrorVmssFlexCacheCircuitOpen instead of amplifying e.g. the throttling of ARM. After the cooldown,
+// a single trial call is let through, whose success closes the circuit and whose failure opens it again.
+// A throttled failure with a Retry-After opens the circuit for at least the Retry-After, even below the
+// threshold, so that the calls honor the wait suggested by ARM instead of retrying immediately.
+// A nil circuitBreaker or a non-positive threshold lets all the calls through.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	lock     sync.Mutex
+	state    circuitBreakerState
+	failures int
+	openedOn time.Time
+	// openedFor is how long the circuit stays open since openedOn.
+	openedFor time.Duration
+}
+
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// getVmssFlexCacheCircuitBreakerCooldown returns the cooldown of the circuit breaker of the vmss flex caches.
+// It defaults to VmssFlexCacheCircuitBreakerCooldownDefaultInSeconds if not set or non-positive.
+func getVmssFlexCacheCircuitBreakerCooldown(cooldownInSeconds int) time.Duration {
+	if cooldownInSeconds <= 0 {
+		cooldownInSeconds = consts.VmssFlexCacheCircuitBreakerCooldownDefaultInSeconds
+	}
+	return time.Duration(cooldownInSeconds) * time.Second
+}
+
+func (cb *circuitBreaker) enabled() bool {
+	return cb != nil && cb.threshold > 0
+}
+
+// allow returns ErrorVmssFlexCacheCircuitOpen if the call should fail fast. Otherwise, the result of
+// the call must be reported by record.
+func (cb *circuitBreaker) allow() error {
+	if !cb.enabled() {
+		return nil
+	}
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch cb.state {
+	case circuitBreakerOpen:
+		if cb.now().Sub(cb.openedOn) < cb.openedFor {
+			return ErrorVmssFlexCacheCircuitOpen
+		}
+		klog.V(2).InfoS("Circuit breaker is half-open, allowing a trial call", "circuitBreaker", cb.name)
+		cb.setState(circuitBreakerHalfOpen)
+		return nil
+	case circuitBreakerHalfOpen:
+		// the trial call is still in flight
+		return ErrorVmssFlexCacheCircuitOpen
+	}
+	return nil
+}
+
+// record reports the result of a call allowed by allow. The errors not caused by ARM, e.g. the
+// vmss flex in a subscription which is not configured, are neither failures nor successes.
+func (cb *circuitBreaker) record(err error) {
+	if !cb.enabled() {
+		return
+	}
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	if errors.Is(err, ErrorVmssFlexCacheCircuitOpen) || errors.Is(err, ErrorVmssFlexSubscriptionNotConfigured) {
+		if cb.state == circuitBreakerHalfOpen {
+			// the trial is inconclusive, so that the next call is allowed as a new trial
+			cb.setState(circuitBreakerOpen)
+		}
+		return
+	}
+
+	if err == nil {
+		if cb.state != circuitBreakerClosed {
+			klog.InfoS("Circuit breaker is closed", "circuitBreaker", cb.name)
+		}
+		cb.failures = 0
+		cb.setState(circuitBreakerClosed)
+		return
+	}
+
+	cb.failures++
+	var retryAfter time.Duration
+	var throttledErr *ThrottledError
+	if errors.As(err, &throttledErr) {
+		retryAfter = throttledErr.RetryAfter
+	}
+	if cb.state == circuitBreakerHalfOpen || cb.failures >= cb.threshold {
+		openedFor := cb.cooldown
+		if retryAfter > openedFor {
+			openedFor = retryAfter
+		}
+		cb.open(err, openedFor)
+	} else if retryAfter > 0 {
+		cb.open(err, retryAfter)
+	}
+}
+
+// open must be called with the lock held.
+func (cb *circuitBreaker) open(err error, openedFor time.Duration) {
+	klog.ErrorS(err, "Circuit breaker is open", "circuitBreaker", cb.name, "consecutiveFailures", cb.failures, "openedFor", openedFor)
+	cb.openedOn = cb.now()
+	cb.openedFor = openedFor
+	cb.setState(circuitBreakerOpen)
+}
+
+// setState must be called with the lock held.
+func (cb *circuitBreaker) setState(state circuitBreakerState) {
+	cb.state = state
+	metrics.SetCacheCircuitBreakerState(cb.name, int(state))
+}
+
+// wrap guards the cache getter with the circuit breaker.
+func (cb *circuitBreaker) wrap(getter azcache.GetFunc) azcache.GetFunc {
+	return func(key string) (interface{}, error) {
+		if err := cb.allow(); err != nil {
+			return nil, err
+		}
+		data, err := getter(key)
+		cb.record(err)
+		return data, err
+	}
+}
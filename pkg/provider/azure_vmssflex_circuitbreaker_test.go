@@ -0,0 +1,175 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmssclient/mockvmssclient"
+	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	now := time.Now()
+	cb := newCircuitBreaker("test", 2, time.Minute)
+	cb.now = func() time.Time { return now }
+	errThrottled := fmt.Errorf("throttled")
+
+	// closed: the failures below the threshold do not open the circuit
+	assert.NoError(t, cb.allow())
+	cb.record(errThrottled)
+	assert.Equal(t, circuitBreakerClosed, cb.state)
+
+	// a success resets the consecutive failures
+	assert.NoError(t, cb.allow())
+	cb.record(nil)
+	assert.NoError(t, cb.allow())
+	cb.record(errThrottled)
+	assert.Equal(t, circuitBreakerClosed, cb.state)
+
+	// open: the threshold is reached
+	assert.NoError(t, cb.allow())
+	cb.record(errThrottled)
+	assert.Equal(t, circuitBreakerOpen, cb.state)
+	assert.Equal(t, ErrorVmssFlexCacheCircuitOpen, cb.allow())
+
+	// still open within the cooldown
+	now = now.Add(59 * time.Second)
+	assert.Equal(t, ErrorVmssFlexCacheCircuitOpen, cb.allow())
+
+	// half-open: a single trial is allowed after the cooldown
+	now = now.Add(time.Second)
+	assert.NoError(t, cb.allow())
+	assert.Equal(t, circuitBreakerHalfOpen, cb.state)
+	assert.Equal(t, ErrorVmssFlexCacheCircuitOpen, cb.allow())
+
+	// a failed trial opens the circuit again for another cooldown
+	cb.record(errThrottled)
+	assert.Equal(t, circuitBreakerOpen, cb.state)
+	assert.Equal(t, ErrorVmssFlexCacheCircuitOpen, cb.allow())
+
+	// an inconclusive trial allows the next call as a new trial
+	now = now.Add(time.Minute)
+	assert.NoError(t, cb.allow())
+	cb.record(ErrorVmssFlexSubscriptionNotConfigured)
+	assert.Equal(t, circuitBreakerOpen, cb.state)
+
+	// closed: a successful trial closes the circuit
+	assert.NoError(t, cb.allow())
+	cb.record(nil)
+	assert.Equal(t, circuitBreakerClosed, cb.state)
+	assert.Equal(t, 0, cb.failures)
+	assert.NoError(t, cb.allow())
+}
+
+func TestCircuitBreakerRetryAfter(t *testing.T) {
+	now := time.Now()
+	cb := newCircuitBreaker("test", 2, time.Minute)
+	cb.now = func() time.Time { return now }
+	errThrottled := fmt.Errorf("throttled")
+
+	// the Retry-After opens the circuit below the threshold
+	assert.NoError(t, cb.allow())
+	cb.record(&ThrottledError{RetryAfter: 10 * time.Second, Err: errThrottled})
+	assert.Equal(t, circuitBreakerOpen, cb.state)
+	now = now.Add(9 * time.Second)
+	assert.Equal(t, ErrorVmssFlexCacheCircuitOpen, cb.allow())
+	now = now.Add(time.Second)
+	assert.NoError(t, cb.allow())
+	cb.record(nil)
+	assert.Equal(t, circuitBreakerClosed, cb.state)
+
+	// the Retry-After longer than the cooldown extends it
+	assert.NoError(t, cb.allow())
+	cb.record(errThrottled)
+	assert.NoError(t, cb.allow())
+	cb.record(&ThrottledError{RetryAfter: 2 * time.Minute, Err: errThrottled})
+	assert.Equal(t, circuitBreakerOpen, cb.state)
+	now = now.Add(time.Minute)
+	assert.Equal(t, ErrorVmssFlexCacheCircuitOpen, cb.allow())
+	now = now.Add(time.Minute)
+	assert.NoError(t, cb.allow())
+	assert.Equal(t, circuitBreakerHalfOpen, cb.state)
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	var nilBreaker *circuitBreaker
+	assert.NoError(t, nilBreaker.allow())
+	nilBreaker.record(fmt.Errorf("throttled"))
+
+	cb := newCircuitBreaker("test", 0, time.Minute)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, cb.allow())
+		cb.record(fmt.Errorf("throttled"))
+	}
+	assert.Equal(t, circuitBreakerClosed, cb.state)
+}
+
+func TestVmssFlexCacheCircuitBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	now := time.Now()
+	fs.circuitBreaker.threshold = 2
+	fs.circuitBreaker.cooldown = time.Minute
+	fs.circuitBreaker.now = func() time.Time { return now }
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	throttled := &retry.Error{HTTPStatusCode: http.StatusTooManyRequests, RawError: fmt.Errorf("throttled")}
+
+	// the circuit opens after two throttled refreshes
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, throttled).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeForceRefresh)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, circuitBreakerOpen, fs.circuitBreaker.state)
+
+	// the refreshes fail fast without listing during the cooldown
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeForceRefresh)
+	assert.ErrorIs(t, err, ErrorVmssFlexCacheCircuitOpen)
+
+	// the stale entries are served if configured
+	fs.Config.VmssFlexCacheServeStaleOnError = true
+	snapshot := &sync.Map{}
+	snapshot.Store(testVmssFlex1ID, &testVmssFlex1)
+	_ = fs.vmssFlexCache.GetStore().Add(&azcache.AzureCacheEntry{
+		Key:       getVmssFlexCachePartitionKeyByResourceGroup("rg"),
+		Data:      snapshot,
+		CreatedOn: now.Add(-time.Minute),
+	})
+	vmssFlex, err := fs.getVmssFlexByVmssFlexID(testVmssFlex1ID, azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	assert.Equal(t, &testVmssFlex1, vmssFlex)
+
+	// the trial refresh after the cooldown closes the circuit on success
+	now = now.Add(time.Minute)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).Times(1)
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	assert.Equal(t, circuitBreakerClosed, fs.circuitBreaker.state)
+}
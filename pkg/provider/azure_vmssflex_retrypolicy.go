@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
+)
+
+// RetryPolicy decides whether a failed ARM call of the vmss flex caches is retried, and the delay before the
+// retry. attempt is the number of the calls made so far, starting from 1. A throttled err is a *ThrottledError
+// carrying the Retry-After of ARM. The calls whose resource is not found are never retried.
+type RetryPolicy interface {
+	ShouldRetry(err error, attempt int) (bool, time.Duration)
+}
+
+// NoRetryPolicy never retries, which is the default RetryPolicy of FlexScaleSet.
+type NoRetryPolicy struct{}
+
+// ShouldRetry implements RetryPolicy.
+func (NoRetryPolicy) ShouldRetry(_ error, _ int) (bool, time.Duration) {
+	return false, 0
+}
+
+// ExponentialBackoffRetryPolicy makes up to MaxAttempts calls, waiting InitialDelay multiplied by Factor after
+// each failed one, or the Retry-After of a throttled failure if longer. The delay is bounded by MaxDelay if positive.
+type ExponentialBackoffRetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	Factor       float64
+	MaxDelay     time.Duration
+}
+
+// ShouldRetry implements RetryPolicy.
+func (p ExponentialBackoffRetryPolicy) ShouldRetry(err error, attempt int) (bool, time.Duration) {
+	if attempt >= p.MaxAttempts {
+		return false, 0
+	}
+
+	factor := p.Factor
+	if factor < 1 {
+		factor = 1
+	}
+	delay := time.Duration(float64(p.InitialDelay) * math.Pow(factor, float64(attempt-1)))
+	var throttledErr *ThrottledError
+	if errors.As(err, &throttledErr) && throttledErr.RetryAfter > delay {
+		delay = throttledErr.RetryAfter
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return true, delay
+}
+
+// newVmssFlexRetryPolicy returns the ExponentialBackoffRetryPolicy if VmssFlexARMRetryAttempts opts into the
+// retries, and NoRetryPolicy otherwise.
+func newVmssFlexRetryPolicy(attempts int) RetryPolicy {
+	if attempts <= 1 {
+		return NoRetryPolicy{}
+	}
+	return ExponentialBackoffRetryPolicy{
+		MaxAttempts:  attempts,
+		InitialDelay: consts.VmssFlexARMRetryInitialDelayInMilliseconds * time.Millisecond,
+		Factor:       2,
+		MaxDelay:     consts.VmssFlexARMRetryMaxDelayInSeconds * time.Second,
+	}
+}
+
+// SetRetryPolicy sets the RetryPolicy of the ARM calls of the vmss flex caches. The default policy configured by
+// VmssFlexARMRetryAttempts is restored if policy is nil.
+func (fs *FlexScaleSet) SetRetryPolicy(policy RetryPolicy) {
+	if policy == nil {
+		policy = newVmssFlexRetryPolicy(fs.Config.VmssFlexARMRetryAttempts)
+	}
+	fs.retryPolicy = policy
+}
+
+// retryARMCall makes the call, and retries it as the retry policy decides until it succeeds, its resource is not
+// found or ctx is done. The error of the last call is returned.
+func (fs *FlexScaleSet) retryARMCall(ctx context.Context, operation string, call func() *retry.Error) *retry.Error {
+	for attempt := 1; ; attempt++ {
+		rerr := call()
+		if rerr == nil || rerr.IsNotFound() || fs.retryPolicy == nil {
+			return rerr
+		}
+		shouldRetry, delay := fs.retryPolicy.ShouldRetry(newThrottledError(rerr, fs.clock.Now()), attempt)
+		if !shouldRetry {
+			return rerr
+		}
+		klog.V(2).InfoS("ARM call of VMSS Flex failed, retrying", "operation", operation, "attempt", attempt, "delay", delay, "err", rerr.Error())
+		select {
+		case <-ctx.Done():
+			return rerr
+		case <-fs.clock.After(delay):
+		}
+	}
+}
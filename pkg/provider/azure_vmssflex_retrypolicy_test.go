@@ -0,0 +1,148 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmclient/mockvmclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmssclient/mockvmssclient"
+	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
+)
+
+// retryOncePolicy retries the first failed call without delay, recording the errors it is asked about.
+type retryOncePolicy struct {
+	errs []error
+}
+
+func (p *retryOncePolicy) ShouldRetry(err error, attempt int) (bool, time.Duration) {
+	p.errs = append(p.errs, err)
+	return attempt == 1, 0
+}
+
+func TestExponentialBackoffRetryPolicy(t *testing.T) {
+	policy := ExponentialBackoffRetryPolicy{
+		MaxAttempts:  4,
+		InitialDelay: time.Second,
+		Factor:       2,
+		MaxDelay:     3 * time.Second,
+	}
+	errFailed := fmt.Errorf("failed")
+
+	testCases := []struct {
+		description         string
+		err                 error
+		attempt             int
+		expectedShouldRetry bool
+		expectedDelay       time.Duration
+	}{
+		{
+			description:         "ShouldRetry should wait the initial delay after the first attempt",
+			err:                 errFailed,
+			attempt:             1,
+			expectedShouldRetry: true,
+			expectedDelay:       time.Second,
+		},
+		{
+			description:         "ShouldRetry should multiply the delay by the factor",
+			err:                 errFailed,
+			attempt:             2,
+			expectedShouldRetry: true,
+			expectedDelay:       2 * time.Second,
+		},
+		{
+			description:         "ShouldRetry should bound the delay by the max delay",
+			err:                 errFailed,
+			attempt:             3,
+			expectedShouldRetry: true,
+			expectedDelay:       3 * time.Second,
+		},
+		{
+			description:         "ShouldRetry should wait the Retry-After if longer",
+			err:                 &ThrottledError{RetryAfter: 2500 * time.Millisecond, Err: errFailed},
+			attempt:             1,
+			expectedShouldRetry: true,
+			expectedDelay:       2500 * time.Millisecond,
+		},
+		{
+			description: "ShouldRetry should stop after the max attempts",
+			err:         errFailed,
+			attempt:     4,
+		},
+	}
+
+	for _, tc := range testCases {
+		shouldRetry, delay := policy.ShouldRetry(tc.err, tc.attempt)
+		assert.Equal(t, tc.expectedShouldRetry, shouldRetry, tc.description)
+		assert.Equal(t, tc.expectedDelay, delay, tc.description)
+	}
+
+	shouldRetry, _ := NoRetryPolicy{}.ShouldRetry(errFailed, 1)
+	assert.False(t, shouldRetry)
+	assert.Equal(t, NoRetryPolicy{}, newVmssFlexRetryPolicy(1))
+	assert.Equal(t, 3, newVmssFlexRetryPolicy(3).(ExponentialBackoffRetryPolicy).MaxAttempts)
+}
+
+func TestVmssFlexCacheRetryPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	assert.Equal(t, NoRetryPolicy{}, fs.retryPolicy)
+	policy := &retryOncePolicy{}
+	fs.SetRetryPolicy(policy)
+
+	throttled := &retry.Error{HTTPStatusCode: http.StatusTooManyRequests, RawError: fmt.Errorf("throttled")}
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	gomock.InOrder(
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, throttled),
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil),
+	)
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	gomock.InOrder(
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(nil, &retry.Error{HTTPStatusCode: http.StatusInternalServerError}),
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil),
+	)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).Times(1)
+
+	// the failed calls are retried once
+	vm, err := fs.getVmssFlexVM(testNodeName1, azcache.CacheReadTypeDefault)
+	assert.NoError(t, err)
+	assert.Equal(t, testVM1.ID, vm.ID)
+	assert.Len(t, policy.errs, 2)
+	var throttledErr *ThrottledError
+	assert.ErrorAs(t, policy.errs[0], &throttledErr)
+
+	// the calls whose resource is not found are not retried
+	policy.errs = nil
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, &retry.Error{HTTPStatusCode: http.StatusNotFound}).Times(1)
+	_, err = fs.vmssFlexCache.Get(getVmssFlexCachePartitionKeyByResourceGroup("rg"), azcache.CacheReadTypeForceRefresh)
+	assert.NoError(t, err)
+	assert.Empty(t, policy.errs)
+
+	// the default policy is restored
+	fs.SetRetryPolicy(nil)
+	assert.Equal(t, NoRetryPolicy{}, fs.retryPolicy)
+}
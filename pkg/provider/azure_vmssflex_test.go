@@ -33,6 +33,7 @@ import (
 	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/interfaceclient/mockinterfaceclient"
 	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmclient/mockvmclient"
 	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmssclient/mockvmssclient"
+	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
 	"sigs.k8s.io/cloud-provider-azure/pkg/consts"
 	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
 )
@@ -126,8 +127,8 @@ func TestGetNodeVMSetNameVmssFlex(t *testing.T) {
 	for _, tc := range testCases {
 		fs, err := NewTestFlexScaleSet(ctrl)
 		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
-		fs.vmssFlexVMNameToVmssID.Store(testNodeName1, testVmssFlexID1)
-		fs.vmssFlexVMNameToVmssID.Store(testNodeName2, testVmssFlexID2)
+		fs.vmssFlexVMNameToVmssID.Set(testNodeName1, testVmssFlexID1)
+		fs.vmssFlexVMNameToVmssID.Set(testNodeName2, testVmssFlexID2)
 
 		vmSetName, err := fs.GetNodeVMSetName(testNode1)
 		assert.Equal(t, tc.expectedVMSetName, vmSetName, tc.description)
@@ -157,8 +158,8 @@ func TestGetAgentPoolVMSetNamesVmssFlex(t *testing.T) {
 	for _, tc := range testCases {
 		fs, err := NewTestFlexScaleSet(ctrl)
 		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
-		fs.vmssFlexVMNameToVmssID.Store(testNodeName1, testVmssFlexID1)
-		fs.vmssFlexVMNameToVmssID.Store(testNodeName2, testVmssFlexID2)
+		fs.vmssFlexVMNameToVmssID.Set(testNodeName1, testVmssFlexID1)
+		fs.vmssFlexVMNameToVmssID.Set(testNodeName2, testVmssFlexID2)
 
 		agentPoolVMSetNames, err := fs.GetAgentPoolVMSetNames(tc.nodes)
 		assert.Equal(t, tc.expectedAgentPoolVMSetNames, agentPoolVMSetNames, tc.description)
@@ -220,8 +221,8 @@ func TestGetVMSetNamesVmssFlex(t *testing.T) {
 	for _, tc := range testCases {
 		fs, err := NewTestFlexScaleSet(ctrl)
 		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
-		fs.vmssFlexVMNameToVmssID.Store(testNodeName1, testVmssFlexID1)
-		fs.vmssFlexVMNameToVmssID.Store(testNodeName2, testVmssFlexID2)
+		fs.vmssFlexVMNameToVmssID.Set(testNodeName1, testVmssFlexID1)
+		fs.vmssFlexVMNameToVmssID.Set(testNodeName2, testVmssFlexID2)
 
 		if tc.useSingleSLB {
 			fs.LoadBalancerSku = consts.LoadBalancerSkuStandard
@@ -264,6 +265,33 @@ func TestGetNodeNameByProviderIDVmssFlex(t *testing.T) {
 			expectedNodeName:               "",
 			expectedErr:                    cloudprovider.InstanceNotFound,
 		},
+		{
+			description:                    "GetNodeNameByProviderID should return the correct nodeName by the legacy scale set style providerID",
+			providerID:                     "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1/virtualMachines/testvm1",
+			testVMListWithoutInstanceView:  testVMListWithoutInstanceView,
+			testVMListWithOnlyInstanceView: testVMListWithOnlyInstanceView,
+			vmListErr:                      nil,
+			expectedNodeName:               types.NodeName("vmssflex1000001"),
+			expectedErr:                    nil,
+		},
+		{
+			description:                    "GetNodeNameByProviderID should return error if the providerID is of a VMSS Uniform instance",
+			providerID:                     "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/0",
+			testVMListWithoutInstanceView:  testVMListWithoutInstanceView,
+			testVMListWithOnlyInstanceView: testVMListWithOnlyInstanceView,
+			vmListErr:                      nil,
+			expectedNodeName:               "",
+			expectedErr:                    fmt.Errorf("error splitting providerID: unrecognized providerID format %q", "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/0"),
+		},
+		{
+			description:                    "GetNodeNameByProviderID should return error if the providerID format is unrecognized",
+			providerID:                     "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb",
+			testVMListWithoutInstanceView:  testVMListWithoutInstanceView,
+			testVMListWithOnlyInstanceView: testVMListWithOnlyInstanceView,
+			vmListErr:                      nil,
+			expectedNodeName:               "",
+			expectedErr:                    fmt.Errorf("error splitting providerID: unrecognized providerID format %q", "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -284,6 +312,121 @@ func TestGetNodeNameByProviderIDVmssFlex(t *testing.T) {
 
 }
 
+func TestGetVmssFlexVMByProviderIDWithMixedCaseVMName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// ARM may list the VM names in mixed cases
+	vmList := generateTestVMListWithoutInstanceView()
+	vmList[1].Name = pointer.String("TestVM2")
+
+	testCases := []struct {
+		description        string
+		providerID         string
+		expectedVMName     string
+		expectedComputerID string
+		expectedErr        error
+	}{
+		{
+			description:        "GetVmssFlexVMByProviderID should match the mixed-case VM name in the providerID",
+			providerID:         "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/TestVM1",
+			expectedVMName:     "testvm1",
+			expectedComputerID: "vmssflex1000001",
+		},
+		{
+			description:        "GetVmssFlexVMByProviderID should match the lower-case VM name in the providerID with the mixed-case VM name listed",
+			providerID:         "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm2",
+			expectedVMName:     "TestVM2",
+			expectedComputerID: "vmssflex1000002",
+		},
+		{
+			description: "GetVmssFlexVMByProviderID should return InstanceNotFound if the VM does not exist",
+			providerID:  "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/NonExistingVM",
+			expectedErr: cloudprovider.InstanceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		fs, err := NewTestFlexScaleSet(ctrl)
+		assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+		mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+		mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+
+		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(vmList, nil).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+		vm, err := fs.GetVmssFlexVMByProviderID(tc.providerID)
+		assert.Equal(t, tc.expectedErr, err, tc.description)
+		if tc.expectedErr == nil {
+			assert.Equal(t, tc.expectedVMName, pointer.StringDeref(vm.Name, ""), tc.description)
+			assert.Equal(t, tc.expectedComputerID, pointer.StringDeref(vm.OsProfile.ComputerName, ""), tc.description)
+			assert.NotNil(t, vm.InstanceView, "the instance view listed by the lower-case VM name should be merged")
+		}
+	}
+}
+
+func TestGetVmssFlexByProviderID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		description      string
+		providerID       string
+		expectedVmssFlex *compute.VirtualMachineScaleSet
+		expectedErr      error
+	}{
+		{
+			description:      "GetVmssFlexByProviderID should return the vmss flex of the VM",
+			providerID:       "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm1",
+			expectedVmssFlex: &testVmssFlex1,
+		},
+		{
+			description:      "GetVmssFlexByProviderID should accept the legacy scale set style providerID",
+			providerID:       "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmssflex1/virtualMachines/testvm2",
+			expectedVmssFlex: &testVmssFlex1,
+		},
+		{
+			description: "GetVmssFlexByProviderID should return InstanceNotFound if the VM does not exist",
+			providerID:  "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/NonExistingVM",
+			expectedErr: cloudprovider.InstanceNotFound,
+		},
+		{
+			description: "GetVmssFlexByProviderID should return ErrorVmssFlexInvalidProviderID if the providerID is malformed",
+			providerID:  "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb",
+			expectedErr: ErrorVmssFlexInvalidProviderID,
+		},
+		{
+			description: "GetVmssFlexByProviderID should return ErrorVmssFlexInvalidProviderID for the uniform instances",
+			providerID:  "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/0",
+			expectedErr: ErrorVmssFlexInvalidProviderID,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			fs, err := NewTestFlexScaleSet(ctrl)
+			assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+
+			mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+			mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+			mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+			mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+			mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+			vmssFlex, err := fs.GetVmssFlexByProviderID(tc.providerID, azcache.CacheReadTypeDefault)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, vmssFlex)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedVmssFlex, vmssFlex)
+		})
+	}
+}
+
 func TestGetInstanceIDByNodeNameVmssFlex(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -877,7 +1020,7 @@ func TestGetNodeCIDRMasksByProviderIDVmssFlex(t *testing.T) {
 			vmListErr:                      nil,
 			expectedNodeMaskCIDRIPv4:       0,
 			expectedNodeMaskCIDRIPv6:       0,
-			expectedErr:                    fmt.Errorf("error splitting providerID"),
+			expectedErr:                    fmt.Errorf("error splitting providerID: unrecognized providerID format %q", "azure:///subscriptions//resourceGroups//providers/Microsoft.Compute/virtualMachines"),
 		},
 		{
 			description:                    "GetNodeCIDRMasksByProviderID should return the correct mask sizes even if some of the tags are not specified",
@@ -1122,6 +1265,36 @@ func TestEnsureHostInPoolVmssFlex(t *testing.T) {
 
 }
 
+func TestEnsureHostInPoolVmssFlexBeingDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs, err := NewTestFlexScaleSet(ctrl)
+	assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+	fs.Config.LoadBalancerSku = consts.LoadBalancerSkuStandard
+
+	deletingVmssFlex := genreteTestVmssFlex("vmssflex1", testVmssFlex1ID)
+	deletingVmssFlex.ProvisioningState = pointer.String(consts.ProvisioningStateDeleting)
+
+	mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+	mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return([]compute.VirtualMachineScaleSet{deletingVmssFlex}, nil).AnyTimes()
+
+	mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+	mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+	mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+
+	// the NIC of the node must not be read or updated as its scale set is being deleted
+	mockInterfacesClient := fs.InterfacesClient.(*mockinterfaceclient.MockInterface)
+	mockInterfacesClient.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	mockInterfacesClient.EXPECT().CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	rg, vmSetName, nodeName, _, err := fs.EnsureHostInPool(&v1.Service{}, "vmssflex1000001", "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb-internal/backendAddressPools/backendpool-1", "")
+	assert.NoError(t, err)
+	assert.Empty(t, rg)
+	assert.Empty(t, vmSetName)
+	assert.Empty(t, nodeName)
+}
+
 func TestEnsureVMSSFlexInPool(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1292,6 +1465,7 @@ func TestEnsureHostsInPoolVmssFlex(t *testing.T) {
 		nicGetErr                      *retry.Error
 		vmssPutErr                     *retry.Error
 		expectedErr                    error
+		expectedUpdatedVMs             int
 	}{
 		{
 			description: "EnsureHostsInPool should add a new backend pool to the vm and vmss",
@@ -1309,9 +1483,10 @@ func TestEnsureHostsInPoolVmssFlex(t *testing.T) {
 			testVMListWithoutInstanceView:  testVMListWithoutInstanceView,
 			testVMListWithOnlyInstanceView: testVMListWithOnlyInstanceView,
 			vmListErr:                      nil,
-			nic:                            testNic1,
+			nic:                            generateTestNic("testvm1-nic", false, network.ProvisioningStateSucceeded, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm1"),
 			nicGetErr:                      nil,
 			expectedErr:                    nil,
+			expectedUpdatedVMs:             1,
 		},
 		{
 			description: "EnsureHostsInPool should return error if basic load balancer is used",
@@ -1329,7 +1504,7 @@ func TestEnsureHostsInPoolVmssFlex(t *testing.T) {
 			testVMListWithoutInstanceView:  testVMListWithoutInstanceView,
 			testVMListWithOnlyInstanceView: testVMListWithOnlyInstanceView,
 			vmListErr:                      nil,
-			nic:                            testNic1,
+			nic:                            generateTestNic("testvm1-nic", false, network.ProvisioningStateSucceeded, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm1"),
 			nicGetErr:                      nil,
 			expectedErr:                    fmt.Errorf("ensure(/): backendPoolID(/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb-internal/backendAddressPools/backendpool-1) - failed to ensure host in pool: EnsureHostInPool: VMSS Flex does not support Basic Load Balancer"),
 		},
@@ -1349,7 +1524,7 @@ func TestEnsureHostsInPoolVmssFlex(t *testing.T) {
 			testVMListWithoutInstanceView:  testVMListWithoutInstanceView,
 			testVMListWithOnlyInstanceView: testVMListWithOnlyInstanceView,
 			vmListErr:                      nil,
-			nic:                            testNic1,
+			nic:                            generateTestNic("testvm1-nic", false, network.ProvisioningStateSucceeded, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/testvm1"),
 			nicGetErr:                      nil,
 			vmssPutErr:                     &retry.Error{RawError: fmt.Errorf("failed to update nic")},
 			expectedErr:                    fmt.Errorf("Retriable: false, RetryAfter: 0s, HTTPStatusCode: 0, RawError: failed to update nic"),
@@ -1376,10 +1551,12 @@ func TestEnsureHostsInPoolVmssFlex(t *testing.T) {
 		mockInterfacesClient.EXPECT().Get(gomock.Any(), gomock.Any(), "testvm1-nic", gomock.Any()).Return(tc.nic, tc.nicGetErr).AnyTimes()
 		mockInterfacesClient.EXPECT().CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
-		err = fs.EnsureHostsInPool(tc.service, tc.nodes, tc.backendPoolID, tc.vmSetNameOfLB)
+		updatedVMs, err := fs.EnsureHostsInPool(tc.service, tc.nodes, tc.backendPoolID, tc.vmSetNameOfLB)
 
 		if tc.expectedErr != nil {
 			assert.EqualError(t, err, tc.expectedErr.Error(), tc.description)
+		} else {
+			assert.Equal(t, tc.expectedUpdatedVMs, updatedVMs, tc.description)
 		}
 	}
 
@@ -1707,8 +1884,10 @@ func TestEnsureBackendPoolDeletedVmssFlex(t *testing.T) {
 		mockVMSSClient.EXPECT().CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(tc.vmssPutErr).AnyTimes()
 
 		mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
-		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(tc.testVMListWithoutInstanceView, tc.vmListErr).AnyTimes()
-		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(tc.testVMListWithOnlyInstanceView, tc.vmListErr).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex1ID).Return(tc.testVMListWithoutInstanceView, tc.vmListErr).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex1ID).Return(tc.testVMListWithOnlyInstanceView, tc.vmListErr).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{}, nil).AnyTimes()
+		mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), testVmssFlex2ID).Return([]compute.VirtualMachine{}, nil).AnyTimes()
 
 		mockInterfacesClient := fs.InterfacesClient.(*mockinterfaceclient.MockInterface)
 		mockInterfacesClient.EXPECT().Get(gomock.Any(), gomock.Any(), "testvm1-nic", gomock.Any()).Return(tc.nic, tc.nicGetErr).AnyTimes()
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/metrics"
+)
+
+const (
+	// vmssFlexDiscrepancyVMNotFound is the discrepancy of a cached vm which no longer exists.
+	vmssFlexDiscrepancyVMNotFound = "vm_not_found"
+	// vmssFlexDiscrepancyVmssFlexMismatch is the discrepancy of a cached vm which belongs to another vmss flex.
+	vmssFlexDiscrepancyVmssFlexMismatch = "vmss_flex_mismatch"
+	// vmssFlexDiscrepancyNodeNameMismatch is the discrepancy of a cached vm whose computer name maps to another node.
+	vmssFlexDiscrepancyNodeNameMismatch = "node_name_mismatch"
+)
+
+// maybeVerifyVmssFlexVM samples the lookups of the cached vmss flex vms at VmssFlexCacheVerificationSampleRate,
+// and verifies the vms of the sampled ones against ARM in the background. The lookup itself is never affected.
+func (fs *FlexScaleSet) maybeVerifyVmssFlexVM(nodeName string, cached compute.VirtualMachine) {
+	rate := fs.Config.VmssFlexCacheVerificationSampleRate
+	if rate <= 0 {
+		return
+	}
+	sample := fs.verificationSample
+	if sample == nil {
+		sample = rand.Float64 // #nosec G404
+	}
+	if sample() >= rate {
+		return
+	}
+
+	cachedVmssFlexID := ""
+	if cached.VirtualMachineProperties != nil && cached.VirtualMachineScaleSet != nil {
+		cachedVmssFlexID = pointer.StringDeref(cached.VirtualMachineScaleSet.ID, "")
+	}
+	vmID := pointer.StringDeref(cached.ID, "")
+	fs.vmssFlexVerifications.Add(1)
+	go func() {
+		defer fs.vmssFlexVerifications.Done()
+		fs.verifyVmssFlexVM(nodeName, vmID, cachedVmssFlexID)
+	}()
+}
+
+// verifyVmssFlexVM gets the vm live, and reports the discrepancies with the cached vm of the node. The vms which
+// could not be got for other reasons than not found are not verified.
+func (fs *FlexScaleSet) verifyVmssFlexVM(nodeName, vmID, cachedVmssFlexID string) {
+	resourceID, err := azure.ParseResourceID(vmID)
+	if err != nil {
+		klog.V(4).InfoS("Skip verifying the cached VMSS Flex VM with malformed ID", "node", nodeName, "vmID", vmID)
+		return
+	}
+	clients, err := fs.getVmssFlexSubscriptionClientsByResourceID(vmID)
+	if err != nil || checkVmssFlexClient(clients.vmClient, "VirtualMachinesClient", vmID) != nil {
+		klog.V(4).InfoS("Skip verifying the cached VMSS Flex VM without client", "node", nodeName, "vmID", vmID)
+		return
+	}
+
+	ctx, cancel := getContextWithCancel()
+	defer cancel()
+	release, err := fs.acquireARMRequestSlot(ctx)
+	if err != nil {
+		return
+	}
+	live, rerr := clients.vmClient.Get(ctx, resourceID.ResourceGroup, resourceID.ResourceName, "")
+	release()
+	if rerr != nil {
+		if rerr.IsNotFound() {
+			fs.reportVmssFlexDiscrepancy(nodeName, vmssFlexDiscrepancyVMNotFound, vmID, "")
+			return
+		}
+		klog.V(4).InfoS("Skip verifying the cached VMSS Flex VM since getting it failed", "node", nodeName, "vmID", vmID, "err", rerr.Error())
+		return
+	}
+
+	if live.VirtualMachineProperties == nil {
+		return
+	}
+	liveVmssFlexID := ""
+	if live.VirtualMachineScaleSet != nil {
+		liveVmssFlexID = pointer.StringDeref(live.VirtualMachineScaleSet.ID, "")
+	}
+	if !strings.EqualFold(strings.TrimPrefix(cachedVmssFlexID, "/"), strings.TrimPrefix(liveVmssFlexID, "/")) {
+		fs.reportVmssFlexDiscrepancy(nodeName, vmssFlexDiscrepancyVmssFlexMismatch, cachedVmssFlexID, liveVmssFlexID)
+	}
+	if hasUsableComputerName(&live) {
+		if liveNodeName := fs.getNodeNameByComputerName(*live.OsProfile.ComputerName); !strings.EqualFold(liveNodeName, nodeName) {
+			fs.reportVmssFlexDiscrepancy(nodeName, vmssFlexDiscrepancyNodeNameMismatch, nodeName, liveNodeName)
+		}
+	}
+}
+
+func (fs *FlexScaleSet) reportVmssFlexDiscrepancy(nodeName, kind, cached, live string) {
+	klog.InfoS("Found discrepancy between the cached and the live VMSS Flex VM", "node", nodeName, "kind", kind, "cached", cached, "live", live)
+	metrics.CountCacheDiscrepancy("vmss_flex_vm", kind)
+}
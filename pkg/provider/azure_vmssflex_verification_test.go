@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmclient/mockvmclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azureclients/vmssclient/mockvmssclient"
+	azcache "sigs.k8s.io/cloud-provider-azure/pkg/cache"
+	"sigs.k8s.io/cloud-provider-azure/pkg/retry"
+)
+
+func TestVmssFlexCacheVerification(t *testing.T) {
+	liveVMInOtherVmssFlex := generateVmssFlexTestVMWithoutInstanceView(testVM1Spec)
+	liveVMInOtherVmssFlex.VirtualMachineScaleSet.ID = pointer.String(testVmssFlex2ID)
+	liveVMWithOtherComputerName := generateVmssFlexTestVMWithoutInstanceView(testVM1Spec)
+	liveVMWithOtherComputerName.OsProfile.ComputerName = pointer.String("vmssflex1000009")
+
+	testCases := []struct {
+		description           string
+		sampleRate            float64
+		sample                float64
+		liveVM                compute.VirtualMachine
+		liveErr               *retry.Error
+		expectedGetCalls      int
+		expectedDiscrepancy   string
+		expectedDiscrepancies float64
+	}{
+		{
+			description: "the lookups should not be verified by default",
+		},
+		{
+			description: "the lookups not sampled should not be verified",
+			sampleRate:  0.5,
+			sample:      0.5,
+		},
+		{
+			description:         "the consistent vm should not be reported",
+			sampleRate:          1,
+			liveVM:              generateVmssFlexTestVMWithoutInstanceView(testVM1Spec),
+			expectedGetCalls:    1,
+			expectedDiscrepancy: vmssFlexDiscrepancyVmssFlexMismatch,
+		},
+		{
+			description:           "the vm in another vmss flex should be reported",
+			sampleRate:            1,
+			liveVM:                liveVMInOtherVmssFlex,
+			expectedGetCalls:      1,
+			expectedDiscrepancy:   vmssFlexDiscrepancyVmssFlexMismatch,
+			expectedDiscrepancies: 1,
+		},
+		{
+			description:           "the vm whose computer name maps to another node should be reported",
+			sampleRate:            1,
+			liveVM:                liveVMWithOtherComputerName,
+			expectedGetCalls:      1,
+			expectedDiscrepancy:   vmssFlexDiscrepancyNodeNameMismatch,
+			expectedDiscrepancies: 1,
+		},
+		{
+			description:           "the deleted vm should be reported",
+			sampleRate:            1,
+			liveErr:               &retry.Error{HTTPStatusCode: http.StatusNotFound},
+			expectedGetCalls:      1,
+			expectedDiscrepancy:   vmssFlexDiscrepancyVMNotFound,
+			expectedDiscrepancies: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fs, err := NewTestFlexScaleSet(ctrl)
+			assert.NoError(t, err, "unexpected error when creating test FlexScaleSet")
+			fs.Config.VmssFlexCacheVerificationSampleRate = tc.sampleRate
+			fs.verificationSample = func() float64 { return tc.sample }
+
+			mockVMSSClient := fs.cloud.VirtualMachineScaleSetsClient.(*mockvmssclient.MockInterface)
+			mockVMSSClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(testVmssFlexList, nil).AnyTimes()
+			mockVMClient := fs.VirtualMachinesClient.(*mockvmclient.MockInterface)
+			mockVMClient.EXPECT().ListVmssFlexVMsWithoutInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithoutInstanceView, nil).AnyTimes()
+			mockVMClient.EXPECT().ListVmssFlexVMsWithOnlyInstanceView(gomock.Any(), gomock.Any()).Return(testVMListWithOnlyInstanceView, nil).AnyTimes()
+			mockVMClient.EXPECT().Get(gomock.Any(), "rg", "testvm1", gomock.Any()).Return(tc.liveVM, tc.liveErr).Times(tc.expectedGetCalls)
+
+			before := getCacheCounterMetricValue(t, "cloudprovider_azure_cache_discrepancy_count", "vmss_flex_vm", "kind", tc.expectedDiscrepancy)
+			vm, err := fs.getVmssFlexVM(testNodeName1, azcache.CacheReadTypeDefault)
+			fs.vmssFlexVerifications.Wait()
+
+			// the lookup always returns the cached vm
+			assert.NoError(t, err)
+			assert.Equal(t, testVMWithoutInstanceView1.ID, vm.ID)
+			assert.Equal(t, testVmssFlex1ID, *vm.VirtualMachineScaleSet.ID)
+			if tc.expectedDiscrepancy != "" {
+				after := getCacheCounterMetricValue(t, "cloudprovider_azure_cache_discrepancy_count", "vmss_flex_vm", "kind", tc.expectedDiscrepancy)
+				assert.Equal(t, tc.expectedDiscrepancies, after-before)
+			}
+		})
+	}
+}
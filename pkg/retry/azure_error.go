@@ -62,6 +62,8 @@ type Error struct {
 	RetryAfter time.Time
 	// RetryAfter indicates the raw error from API.
 	RawError error
+	// CorrelationID indicates the correlation ID of the response, which is required by the support tickets.
+	CorrelationID string
 }
 
 // Error returns the error.
@@ -82,6 +84,18 @@ func (err *Error) Error() error {
 		err.Retriable, retryAfterSeconds, err.HTTPStatusCode, err.RawError)
 }
 
+// ErrorWithCorrelationID returns the error with the correlation ID of the response appended, if any.
+// The original error is wrapped, so it could still be unwrapped by the callers.
+func (err *Error) ErrorWithCorrelationID() error {
+	if err == nil {
+		return nil
+	}
+	if err.CorrelationID == "" {
+		return err.Error()
+	}
+	return fmt.Errorf("%w, CorrelationID: %s", err.Error(), err.CorrelationID)
+}
+
 // IsThrottled returns true the if the request is being throttled.
 func (err *Error) IsThrottled() bool {
 	if err == nil {
@@ -163,6 +177,7 @@ func GetError(resp *http.Response, err error) *Error {
 		RetryAfter:     retryAfter,
 		Retriable:      shouldRetryHTTPRequest(resp, err),
 		HTTPStatusCode: getHTTPStatusCode(resp),
+		CorrelationID:  getCorrelationID(resp),
 	}
 }
 
@@ -209,6 +224,15 @@ func getHTTPStatusCode(resp *http.Response) int {
 	return resp.StatusCode
 }
 
+// getCorrelationID gets the correlation ID from http response.
+func getCorrelationID(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+
+	return resp.Header.Get(consts.CorrelationRequestIDHeaderKey)
+}
+
 // shouldRetryHTTPRequest determines if the request is retriable.
 func shouldRetryHTTPRequest(resp *http.Response, err error) bool {
 	if resp != nil {
@@ -18,6 +18,7 @@ package retry
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -76,10 +77,11 @@ func TestGetError(t *testing.T) {
 	}
 
 	tests := []struct {
-		code       int
-		retryAfter int
-		err        error
-		expected   *Error
+		code          int
+		retryAfter    int
+		correlationID string
+		err           error
+		expected      *Error
 	}{
 		{
 			code:     http.StatusOK,
@@ -129,6 +131,16 @@ func TestGetError(t *testing.T) {
 				RawError:       fmt.Errorf("some error"),
 			},
 		},
+		{
+			code:          http.StatusTooManyRequests,
+			correlationID: "correlation",
+			expected: &Error{
+				Retriable:      false,
+				HTTPStatusCode: http.StatusTooManyRequests,
+				RawError:       fmt.Errorf("some error"),
+				CorrelationID:  "correlation",
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -140,11 +152,27 @@ func TestGetError(t *testing.T) {
 		if test.retryAfter != 0 {
 			resp.Header.Add("Retry-After", fmt.Sprintf("%d", test.retryAfter))
 		}
+		if test.correlationID != "" {
+			resp.Header.Add("x-ms-correlation-request-id", test.correlationID)
+		}
 		rerr := GetError(resp, test.err)
 		assert.Equal(t, test.expected, rerr)
 	}
 }
 
+func TestErrorWithCorrelationID(t *testing.T) {
+	var nilErr *Error
+	assert.Nil(t, nilErr.ErrorWithCorrelationID())
+
+	rerr := &Error{HTTPStatusCode: http.StatusTooManyRequests, RawError: fmt.Errorf("throttled")}
+	assert.Equal(t, rerr.Error(), rerr.ErrorWithCorrelationID())
+
+	rerr.CorrelationID = "correlation"
+	err := rerr.ErrorWithCorrelationID()
+	assert.EqualError(t, err, "Retriable: false, RetryAfter: 0s, HTTPStatusCode: 429, RawError: throttled, CorrelationID: correlation")
+	assert.Equal(t, "throttled", errors.Unwrap(errors.Unwrap(err)).Error())
+}
+
 func TestGetErrorNil(t *testing.T) {
 	rerr := GetError(nil, nil)
 	assert.Nil(t, rerr)